@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -22,6 +24,7 @@ import (
 	"github.com/freewebtopdf/asset-injector/internal/health"
 	"github.com/freewebtopdf/asset-injector/internal/matcher"
 	"github.com/freewebtopdf/asset-injector/internal/pack"
+	"github.com/freewebtopdf/asset-injector/internal/ruleset"
 	"github.com/freewebtopdf/asset-injector/internal/storage"
 
 	docs "github.com/freewebtopdf/asset-injector/docs"
@@ -57,6 +60,8 @@ import (
 
 func main() {
 	healthCheck := flag.Bool("health-check", false, "Perform health check and exit")
+	migrateToDB := flag.Bool("migrate-to-db", false, "Copy the rules of the YAML tree into an empty rule database and exit")
+	migrateToFiles := flag.Bool("migrate-to-files", false, "Write the rules of the rule database to empty rule directories and exit")
 	flag.Parse()
 
 	if *healthCheck {
@@ -89,21 +94,59 @@ func main() {
 	}
 	store := storage.NewStoreWithConfig(storeConfig)
 
+	if *migrateToDB || *migrateToFiles {
+		migrateRules(context.Background(), store, storeConfig, *migrateToDB)
+		return
+	}
+
+	var repository domain.RuleRepository = store
+	var database *storage.BoltStore // Set when the rules live in the database
+	if cfg.Storage.Backend == "bolt" {
+		database, err = storage.OpenBoltStore(cfg.Storage.DataDir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to open rule database")
+		}
+		repository = database
+	}
+
+	cacheConfig := cache.LRUConfig{
+		MaxSize:         cfg.Cache.MaxSize,
+		TTL:             cfg.Cache.TTL,
+		MaxBytes:        cfg.Cache.MaxBytes,
+		NegativeMaxSize: cfg.Cache.NegativeMaxSize,
+		NegativeTTL:     cfg.Cache.NegativeTTL,
+		Policy:          cfg.Cache.Policy,
+	}
+	var resultCache domain.CacheManager
+	if cfg.Cache.Shards > 1 {
+		resultCache = cache.NewShardedCache(cfg.Cache.Shards, cacheConfig)
+	} else {
+		resultCache = cache.NewLRUCacheWithConfig(cacheConfig)
+	}
+
+	patternMatcher := matcher.NewMatcherWithConfig(repository, resultCache, matcher.MatcherConfig{
+		Normalization: matcher.NormalizeConfig{
+			Enabled:        cfg.Normalization.Enabled,
+			TrackingParams: cfg.Normalization.TrackingParams,
+			TrailingSlash:  cfg.Normalization.TrailingSlash,
+		},
+	})
+
 	ctx := context.Background()
-	if err := store.Load(ctx); err != nil {
-		log.Fatal().Err(err).Msg("Failed to load rules")
+	rulesetPath := filepath.Join(cfg.Storage.DataDir, ruleset.FileName)
+	if database != nil {
+		loadDatabaseRules(ctx, database, patternMatcher)
+	} else {
+		loadRules(ctx, store, patternMatcher, rulesetPath)
 	}
 
 	if cfg.Community.AutoUpdate {
 		autoUpdatePacks(ctx, cfg)
 	}
 
-	lruCache := cache.NewLRUCache(cfg.Cache.MaxSize)
-
-	patternMatcher := matcher.NewMatcher(store, lruCache)
-
-	if err := patternMatcher.LoadRules(ctx); err != nil {
-		log.Fatal().Err(err).Msg("Failed to load rules into matcher")
+	hotKeysPath := filepath.Join(cfg.Storage.DataDir, cache.HotKeysFileName)
+	if cfg.Cache.WarmupKeys > 0 {
+		warmCache(ctx, patternMatcher, hotKeysPath, cfg.Cache.WarmupKeys)
 	}
 
 	// Start singles syncer if enabled
@@ -122,8 +165,9 @@ func main() {
 			}
 			if err := patternMatcher.LoadRules(context.Background()); err != nil {
 				log.Warn().Err(err).Msg("Failed to reload matcher after singles sync")
+				return
 			}
-			lruCache.Clear()
+			writeRulesetSnapshot(store, patternMatcher, rulesetPath, 0)
 		})
 		singlesSyncer.Start(ctx)
 		log.Info().Dur("interval", cfg.Community.SinglesSyncInterval).Msg("Singles syncer started")
@@ -131,21 +175,35 @@ func main() {
 
 	validator := domain.NewValidator()
 
-	healthChecker := health.NewSystemHealthChecker(store, patternMatcher, lruCache)
+	healthChecker := health.NewSystemHealthChecker(repository, patternMatcher, resultCache)
 
 	routerConfig := api.RouterConfig{
 		CORSOrigins:    cfg.Security.CORSOrigins,
 		BodyLimit:      cfg.Server.BodyLimit,
 		RateLimitRPS:   100,
 		RateLimitBurst: 200,
+		Batch: api.BatchConfig{
+			MaxURLs:     cfg.Batch.MaxURLs,
+			Timeout:     cfg.Batch.Timeout,
+			Concurrency: cfg.Batch.Concurrency,
+		},
 	}
 
-	app := api.SetupRouter(patternMatcher, store, lruCache, validator, healthChecker, routerConfig)
+	app := api.SetupRouter(patternMatcher, repository, resultCache, validator, healthChecker, routerConfig)
 
 	app.Server().ReadTimeout = cfg.Server.ReadTimeout
 	app.Server().WriteTimeout = cfg.Server.WriteTimeout
 
-	setupGracefulShutdown(app, singlesSyncer)
+	setupGracefulShutdown(app, singlesSyncer, func() {
+		if cfg.Cache.WarmupKeys > 0 {
+			saveHotKeys(resultCache, hotKeysPath, cfg.Cache.WarmupKeys)
+		}
+		if database != nil {
+			if err := database.Close(); err != nil {
+				log.Error().Err(err).Msg("Failed to close rule database")
+			}
+		}
+	})
 
 	serverAddr := fmt.Sprintf(":%d", cfg.Server.Port)
 	log.Info().
@@ -186,7 +244,18 @@ func logStartupConfig(cfg *config.Config) {
 		Int("server_body_limit", cfg.Server.BodyLimit).
 		Int("cache_max_size", cfg.Cache.MaxSize).
 		Dur("cache_ttl", cfg.Cache.TTL).
+		Int64("cache_max_bytes", cfg.Cache.MaxBytes).
+		Int("cache_shards", cfg.Cache.Shards).
+		Str("cache_policy", cfg.Cache.Policy).
+		Int("cache_warmup_keys", cfg.Cache.WarmupKeys).
+		Int("cache_negative_max_size", cfg.Cache.NegativeMaxSize).
+		Dur("cache_negative_ttl", cfg.Cache.NegativeTTL).
+		Bool("url_normalization", cfg.Normalization.Enabled).
+		Str("url_trailing_slash", cfg.Normalization.TrailingSlash).
+		Int("batch_max_urls", cfg.Batch.MaxURLs).
+		Dur("batch_timeout", cfg.Batch.Timeout).
 		Str("storage_data_dir", cfg.Storage.DataDir).
+		Str("storage_backend", cfg.Storage.Backend).
 		Strs("security_cors_origins", cfg.Security.CORSOrigins).
 		Bool("security_enable_https", cfg.Security.EnableHTTPS).
 		Str("logging_level", cfg.Logging.Level).
@@ -194,7 +263,166 @@ func logStartupConfig(cfg *config.Config) {
 		Msg("Configuration loaded successfully")
 }
 
-func setupGracefulShutdown(app *fiber.App, singlesSyncer *community.SinglesSyncer) {
+// loadRules loads the rules into the store and the matcher, from the ruleset snapshot
+// when no rule file changed since it was written and by scanning the rule files otherwise
+func loadRules(ctx context.Context, store *storage.Store, patternMatcher *matcher.Matcher, path string) {
+	start := time.Now()
+	report := ruleset.StartupReport{Source: ruleset.SourceFullScan}
+
+	snapshot, err := ruleset.Read(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		report.StaleReason = "no snapshot"
+	case err != nil:
+		log.Warn().Err(err).Str("path", path).Msg("Ignoring unreadable ruleset snapshot")
+		report.StaleReason = "unreadable snapshot"
+	default:
+		report.StaleReason, err = store.LoadSnapshot(ctx, snapshot)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load rules")
+		}
+		if report.StaleReason == "" {
+			report.Source = ruleset.SourceSnapshot
+			report.ScanDuration = snapshot.ScanDuration
+			report.SnapshotCreatedAt = snapshot.CreatedAt
+			patternMatcher.UseIndexHints(snapshot.IndexHints)
+			patternMatcher.UsePrefilterHints(snapshot.PrefilterHints)
+		}
+	}
+
+	if report.Source == ruleset.SourceFullScan {
+		if err := store.Load(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load rules")
+		}
+	}
+	if err := patternMatcher.LoadRules(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load rules into matcher")
+	}
+
+	report.Duration = time.Since(start)
+	if rules, err := store.GetAllRules(ctx); err == nil {
+		report.Rules = len(rules)
+	}
+	if report.Source == ruleset.SourceFullScan {
+		report.ScanDuration = report.Duration
+		writeRulesetSnapshot(store, patternMatcher, path, report.Duration)
+	}
+	store.SetStartupReport(report)
+
+	log.Info().
+		Str("source", report.Source).
+		Str("stale_reason", report.StaleReason).
+		Int("rules", report.Rules).
+		Dur("duration", report.Duration).
+		Dur("full_scan_duration", report.ScanDuration).
+		Msg("Rules loaded")
+}
+
+// loadDatabaseRules loads the rules of the database backend into the matcher
+func loadDatabaseRules(ctx context.Context, database *storage.BoltStore, patternMatcher *matcher.Matcher) {
+	start := time.Now()
+	if err := database.Load(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load rules")
+	}
+	if err := patternMatcher.LoadRules(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load rules into matcher")
+	}
+
+	rules, _ := database.GetAllRules(ctx)
+	if len(rules) == 0 {
+		log.Warn().Str("database", database.Path()).Msg("Rule database is empty; run with -migrate-to-db to import the rule files")
+	}
+	log.Info().
+		Str("source", "database").
+		Str("database", database.Path()).
+		Int("rules", len(rules)).
+		Dur("duration", time.Since(start)).
+		Msg("Rules loaded")
+}
+
+// migrateRules copies the rules between the YAML tree and the rule database. The
+// server must not be running, since it keeps the database locked.
+func migrateRules(ctx context.Context, store *storage.Store, storeConfig storage.StoreConfig, toDatabase bool) {
+	database, err := storage.OpenBoltStore(storeConfig.DataDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open rule database")
+	}
+	defer database.Close()
+
+	var migrated int
+	if toDatabase {
+		migrated, err = storage.MigrateToDatabase(ctx, store, database)
+	} else {
+		migrated, err = storage.MigrateToFiles(ctx, database, storeConfig)
+	}
+	if err != nil {
+		database.Close()
+		log.Fatal().Err(err).Bool("to_database", toDatabase).Msg("Rule migration failed")
+	}
+	log.Info().
+		Int("rules", migrated).
+		Str("database", database.Path()).
+		Bool("to_database", toDatabase).
+		Msg("Rules migrated")
+}
+
+// writeRulesetSnapshot saves the loaded rules for the next start. A scan duration of
+// zero keeps the one recorded in the previous snapshot.
+func writeRulesetSnapshot(store *storage.Store, patternMatcher *matcher.Matcher, path string, scanDuration time.Duration) {
+	snapshot := store.Snapshot()
+	if snapshot == nil {
+		return
+	}
+	snapshot.ScanDuration = scanDuration
+	if scanDuration == 0 {
+		if previous, err := ruleset.Read(path); err == nil {
+			snapshot.ScanDuration = previous.ScanDuration
+		}
+	}
+	snapshot.IndexHints = patternMatcher.IndexHints()
+	snapshot.PrefilterHints = patternMatcher.PrefilterHints()
+
+	if err := ruleset.Write(path, snapshot); err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("Failed to write ruleset snapshot")
+	}
+}
+
+// warmCache re-resolves the cache keys saved at the last shutdown in the background
+func warmCache(ctx context.Context, patternMatcher *matcher.Matcher, path string, limit int) {
+	keys, err := cache.LoadHotKeys(path)
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("Failed to load saved cache keys")
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	log.Info().Int("keys", len(keys)).Msg("Warming cache in background")
+	go func() {
+		patternMatcher.WarmCache(ctx, keys)
+		log.Info().Int("keys", len(keys)).Msg("Cache warm-up finished")
+	}()
+}
+
+// saveHotKeys saves the hottest cache keys so the next start can warm the cache
+func saveHotKeys(resultCache domain.CacheManager, path string, limit int) {
+	lister, ok := resultCache.(cache.HotKeyLister)
+	if !ok {
+		return
+	}
+	keys := lister.HotKeys(limit)
+	if err := cache.SaveHotKeys(path, keys); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to save cache keys")
+		return
+	}
+	log.Info().Int("keys", len(keys)).Str("path", path).Msg("Saved cache keys for warm-up")
+}
+
+func setupGracefulShutdown(app *fiber.App, singlesSyncer *community.SinglesSyncer, onStopped func()) {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
@@ -217,6 +445,11 @@ func setupGracefulShutdown(app *fiber.App, singlesSyncer *community.SinglesSynce
 			log.Error().Err(err).Msg("Error during HTTP server shutdown")
 		}
 
+		// Runs once no more requests are served
+		if onStopped != nil {
+			onStopped()
+		}
+
 		log.Info().Msg("Graceful shutdown completed")
 		os.Exit(0)
 	}()
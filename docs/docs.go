@@ -46,7 +46,7 @@ const docTemplate = `{
         },
         "/metrics": {
             "get": {
-                "description": "Returns system metrics including cache statistics and rule counts",
+                "description": "Returns system metrics including cache statistics, rule counts and the generations of the\nmatcher's and repository's rule sets, which increase with every rule change",
                 "produces": [
                     "application/json"
                 ],
@@ -82,33 +82,19 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/resolve": {
-            "post": {
-                "description": "Matches a URL against configured rules and returns the most specific CSS/JS assets",
-                "consumes": [
-                    "application/json"
-                ],
+        "/v1/packs": {
+            "get": {
+                "description": "Returns all installed rule packs with metadata",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Resolution"
-                ],
-                "summary": "Resolve URL pattern to CSS/JS assets",
-                "parameters": [
-                    {
-                        "description": "URL to resolve",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.ResolveRequest"
-                        }
-                    }
+                    "Packs"
                 ],
+                "summary": "List installed packs",
                 "responses": {
                     "200": {
-                        "description": "Successfully resolved URL",
+                        "description": "Successfully retrieved packs",
                         "schema": {
                             "allOf": [
                                 {
@@ -118,25 +104,13 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/api.ResolveResponse"
+                                            "$ref": "#/definitions/api.PackListResponse"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "400": {
-                        "description": "Invalid request payload",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    },
-                    "422": {
-                        "description": "Validation failed",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -146,19 +120,19 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/rules": {
+        "/v1/packs/available": {
             "get": {
-                "description": "Retrieves all configured URL matching rules",
+                "description": "Returns available packs from the community repository",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Rules"
+                    "Packs"
                 ],
-                "summary": "List all rules",
+                "summary": "List available community packs",
                 "responses": {
                     "200": {
-                        "description": "Successfully retrieved rules",
+                        "description": "Successfully retrieved available packs",
                         "schema": {
                             "allOf": [
                                 {
@@ -168,7 +142,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/api.RuleListResponse"
+                                            "$ref": "#/definitions/api.PackListResponse"
                                         }
                                     }
                                 }
@@ -180,11 +154,19 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Community repository unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
                     }
                 }
-            },
+            }
+        },
+        "/v1/packs/install": {
             "post": {
-                "description": "Creates a new URL matching rule or updates an existing one",
+                "description": "Installs a pack from the specified source",
                 "consumes": [
                     "application/json"
                 ],
@@ -192,23 +174,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Rules"
+                    "Packs"
                 ],
-                "summary": "Create or update a rule",
+                "summary": "Install a pack",
                 "parameters": [
                     {
-                        "description": "Rule to create or update",
-                        "name": "rule",
+                        "description": "Pack source to install",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/domain.Rule"
+                            "$ref": "#/definitions/api.InstallPackRequest"
                         }
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Successfully created rule",
+                        "description": "Successfully installed pack",
                         "schema": {
                             "allOf": [
                                 {
@@ -220,8 +202,11 @@ const docTemplate = `{
                                         "data": {
                                             "type": "object",
                                             "properties": {
-                                                "rule": {
-                                                    "$ref": "#/definitions/domain.Rule"
+                                                "message": {
+                                                    "type": "string"
+                                                },
+                                                "source": {
+                                                    "type": "string"
                                                 }
                                             }
                                         }
@@ -251,29 +236,97 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/rules/{id}": {
+        "/v1/packs/update": {
+            "post": {
+                "description": "Updates specified packs to their latest versions",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Packs"
+                ],
+                "summary": "Update packs",
+                "parameters": [
+                    {
+                        "description": "Packs to update",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.UpdatePacksRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully updated packs",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object",
+                                            "properties": {
+                                                "message": {
+                                                    "type": "string"
+                                                },
+                                                "updated": {
+                                                    "type": "array",
+                                                    "items": {
+                                                        "type": "string"
+                                                    }
+                                                }
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/packs/{name}": {
             "delete": {
-                "description": "Deletes a URL matching rule by its ID",
+                "description": "Uninstalls the specified pack",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Rules"
+                    "Packs"
                 ],
-                "summary": "Delete a rule",
+                "summary": "Uninstall a pack",
                 "parameters": [
                     {
                         "type": "string",
-                        "format": "uuid",
-                        "description": "Rule ID",
-                        "name": "id",
+                        "description": "Pack name",
+                        "name": "name",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully deleted rule",
+                        "description": "Successfully uninstalled pack",
                         "schema": {
                             "allOf": [
                                 {
@@ -288,7 +341,7 @@ const docTemplate = `{
                                                 "message": {
                                                     "type": "string"
                                                 },
-                                                "rule_id": {
+                                                "name": {
                                                     "type": "string"
                                                 }
                                             }
@@ -299,7 +352,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Rule not found",
+                        "description": "Pack not found",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -318,153 +371,1828 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.ErrorResponse": {
-            "description": "Standard error response format",
-            "type": "object",
-            "properties": {
-                "code": {
-                    "type": "string",
-                    "example": "VALIDATION_FAILED"
-                },
-                "details": {},
-                "message": {
-                    "type": "string",
-                    "example": "Invalid input provided"
-                },
-                "status": {
-                    "type": "string",
-                    "example": "error"
-                }
-            }
-        },
-        "api.HealthResponse": {
-            "description": "Health check response",
-            "type": "object",
-            "properties": {
-                "status": {
-                    "type": "string",
-                    "example": "ok"
-                },
-                "timestamp": {
-                    "type": "string",
-                    "example": "2023-01-01T12:00:00Z"
-                }
-            }
         },
-        "api.MetricsResponse": {
-            "description": "System metrics response",
-            "type": "object",
-            "properties": {
-                "cache": {
-                    "type": "object",
-                    "properties": {
-                        "hit_ratio": {
-                            "type": "number",
-                            "example": 0.83
-                        },
-                        "hits": {
-                            "type": "integer",
-                            "example": 1500
-                        },
-                        "max_size": {
-                            "type": "integer",
-                            "example": 10000
-                        },
-                        "misses": {
-                            "type": "integer",
-                            "example": 300
-                        },
-                        "size": {
-                            "type": "integer",
-                            "example": 800
+        "/v1/resolve": {
+            "post": {
+                "description": "Matches a URL against configured rules and returns the most specific CSS/JS assets.\nWith mode \"stacked\", assets of every matching rule are merged unless the top match is exclusive.\nWith explain=true, the cache is bypassed and every evaluated rule is returned with its score breakdown,\nincluding disabled and conflict-shadowed rules that match the URL.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Resolution"
+                ],
+                "summary": "Resolve URL pattern to CSS/JS assets",
+                "parameters": [
+                    {
+                        "description": "URL to resolve",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ResolveRequest"
                         }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the evaluation of every considered rule",
+                        "name": "explain",
+                        "in": "query"
                     }
-                },
-                "rules": {
-                    "type": "object",
-                    "properties": {
-                        "count": {
-                            "type": "integer",
-                            "example": 25
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Explanation of the resolution (explain=true)",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.ResolveExplainResponse"
+                                        }
+                                    }
+                                }
+                            ]
                         }
-                    }
-                },
-                "uptime": {
-                    "type": "object",
-                    "properties": {
-                        "timestamp": {
-                            "type": "string",
-                            "example": "2023-01-01T12:00:00Z"
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "api.ResolveRequest": {
+        "/v1/resolve/batch": {
+            "post": {
+                "description": "Resolves each URL like /v1/resolve and returns one result per URL in request order.\nInvalid or failed URLs get an error entry instead of failing the whole batch.\nAll items share one deadline; items still pending when it passes fail with TIMEOUT.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Resolution"
+                ],
+                "summary": "Resolve several URLs in one request",
+                "parameters": [
+                    {
+                        "description": "URLs to resolve",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.BatchResolveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Per-URL results",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.BatchResolveResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Empty or oversized batch",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/rules": {
+            "get": {
+                "description": "Retrieves all configured URL matching rules, optionally only those with every given attribute.\nThe database backend answers filtered requests from its secondary indexes.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "List all rules",
+                "parameters": [
+                    {
+                        "enum": [
+                            "local",
+                            "community",
+                            "override"
+                        ],
+                        "type": "string",
+                        "description": "Rule source",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Community pack name",
+                        "name": "pack",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rule tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rule type",
+                        "name": "type",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved rules",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RuleListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a new URL matching rule or updates an existing one",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Create or update a rule",
+                "parameters": [
+                    {
+                        "description": "Rule to create or update",
+                        "name": "rule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.Rule"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the rule history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Why the change is made, recorded in the rule history",
+                        "name": "X-Change-Reason",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Successfully created rule",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object",
+                                            "properties": {
+                                                "rule": {
+                                                    "$ref": "#/definitions/domain.Rule"
+                                                }
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/rules/bulk": {
+            "post": {
+                "description": "Applies creates, updates and deletes all together or not at all. Every operation is validated before anything is written,\nand the matcher is reloaded once afterwards. Updates replace the whole rule body; the rule keeps its source and file.\nEach rule may appear only once. The X-Actor and X-Change-Reason headers are recorded with every revision.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Apply rule changes in bulk",
+                "parameters": [
+                    {
+                        "description": "Operations to apply in order",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.BulkRulesRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the rule history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Why the change is made, recorded in the rule history",
+                        "name": "X-Change-Reason",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Every operation was applied",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.BulkRulesResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule of an update or delete not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Rule of a create already exists",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed; details list the failing operations",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error; no operation was applied",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/rules/export": {
+            "post": {
+                "description": "Generates downloadable rule pack files",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/x-yaml",
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Export rules as a pack",
+                "parameters": [
+                    {
+                        "description": "Export options",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ExportRulesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Pack file content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/rules/{id}": {
+            "put": {
+                "description": "Updates an existing URL matching rule",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Update a rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Rule fields to update",
+                        "name": "rule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.UpdateRuleRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the rule history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Why the change is made, recorded in the rule history",
+                        "name": "X-Change-Reason",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully updated rule",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object",
+                                            "properties": {
+                                                "rule": {
+                                                    "$ref": "#/definitions/domain.Rule"
+                                                }
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes a URL matching rule by its ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Delete a rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change, recorded in the rule history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Why the change is made, recorded in the rule history",
+                        "name": "X-Change-Reason",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully deleted rule",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object",
+                                            "properties": {
+                                                "message": {
+                                                    "type": "string"
+                                                },
+                                                "rule_id": {
+                                                    "type": "string"
+                                                }
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "Rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/rules/{id}/history": {
+            "get": {
+                "description": "Returns every recorded revision of a rule with the full rule body after the change, oldest first.\nDeleted rules keep their history.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Get rule history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rule revisions",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RuleHistoryResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "No history recorded for the rule",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/rules/{id}/history/{rev}/diff": {
+            "get": {
+                "description": "Lists the fields that differ between a revision and the one before it, or the revision given in \"against\".\nA deletion compares against no rule at all; updated_at is not compared.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Diff a rule revision",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision number",
+                        "name": "rev",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision to compare with instead of the previous one",
+                        "name": "against",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Changed fields",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RuleDiffResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid revision number",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule history or revision not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/rules/{id}/revert/{rev}": {
+            "post": {
+                "description": "Restores the rule body of a revision. An existing rule is updated and a deleted one is recreated as a local rule;\neither way the matcher is updated and a \"revert\" revision is recorded.\nThe X-Actor and X-Change-Reason headers are recorded with the revision.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Revert a rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision to restore",
+                        "name": "rev",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who makes the change",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Why the change is made",
+                        "name": "X-Change-Reason",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully reverted rule",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object",
+                                            "properties": {
+                                                "reverted_from": {
+                                                    "type": "integer"
+                                                },
+                                                "rule": {
+                                                    "$ref": "#/definitions/domain.Rule"
+                                                }
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid revision number",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule history or revision not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Revision cannot be restored",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/rules/{id}/source": {
+            "get": {
+                "description": "Returns the origin and attribution information for a rule",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rules"
+                ],
+                "summary": "Get rule source information",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved rule source",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/api.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RuleSourceResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "Rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.BatchItemError": {
+            "description": "Error for one URL of a batch",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "VALIDATION_FAILED"
+                },
+                "details": {},
+                "message": {
+                    "type": "string",
+                    "example": "Invalid URL format"
+                }
+            }
+        },
+        "api.BatchResolveItem": {
+            "description": "Resolution result or error for one URL of a batch",
+            "type": "object",
+            "properties": {
+                "cache_hit": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "css": {
+                    "type": "string",
+                    "example": ".banner { display: none; }"
+                },
+                "error": {
+                    "$ref": "#/definitions/api.BatchItemError"
+                },
+                "js": {
+                    "type": "string",
+                    "example": ""
+                },
+                "rule_id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/page"
+                },
+                "variant_id": {
+                    "description": "Variant of the matched rule, if it has variants",
+                    "type": "string",
+                    "example": "new-layout"
+                }
+            }
+        },
+        "api.BatchResolveRequest": {
+            "description": "Request payload for resolving several URLs at once",
+            "type": "object",
+            "required": [
+                "urls"
+            ],
+            "properties": {
+                "context": {
+                    "description": "Context describes the rendering environment shared by all URLs of the batch",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.RenderContext"
+                        }
+                    ]
+                },
+                "urls": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "https://example.com/page",
+                        "https://example.com/frame"
+                    ]
+                }
+            }
+        },
+        "api.BatchResolveResponse": {
+            "description": "Per-URL results in request order",
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "errors": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.BatchResolveItem"
+                    }
+                }
+            }
+        },
+        "api.BulkRulesRequest": {
+            "description": "Creates, updates and deletes applied together",
+            "type": "object",
+            "properties": {
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.RuleOperation"
+                    }
+                }
+            }
+        },
+        "api.BulkRulesResponse": {
+            "description": "IDs of the created, updated and deleted rules",
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "created": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "deleted": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updated": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.ErrorResponse": {
+            "description": "Standard error response format",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "VALIDATION_FAILED"
+                },
+                "details": {},
+                "message": {
+                    "type": "string",
+                    "example": "Invalid input provided"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "error"
+                }
+            }
+        },
+        "api.ExportRulesRequest": {
+            "description": "Request payload for exporting rules as a pack",
+            "type": "object",
+            "required": [
+                "author",
+                "description",
+                "name",
+                "version"
+            ],
+            "properties": {
+                "author": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "My custom rule pack"
+                },
+                "format": {
+                    "type": "string",
+                    "example": "yaml"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "my-custom-pack"
+                },
+                "rule_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "rule-1",
+                        "rule-2"
+                    ]
+                },
+                "version": {
+                    "type": "string",
+                    "example": "1.0.0"
+                }
+            }
+        },
+        "api.HealthResponse": {
+            "description": "Health check response",
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2023-01-01T12:00:00Z"
+                }
+            }
+        },
+        "api.InstallPackRequest": {
+            "description": "Request payload for pack installation",
+            "type": "object",
+            "required": [
+                "source"
+            ],
+            "properties": {
+                "source": {
+                    "type": "string",
+                    "example": "cookie-banners@1.0.0"
+                }
+            }
+        },
+        "api.MetricsResponse": {
+            "description": "System metrics response",
+            "type": "object",
+            "properties": {
+                "cache": {
+                    "type": "object",
+                    "properties": {
+                        "bytes": {
+                            "type": "integer",
+                            "example": 52428800
+                        },
+                        "expirations": {
+                            "type": "integer",
+                            "example": 40
+                        },
+                        "hit_ratio": {
+                            "type": "number",
+                            "example": 0.83
+                        },
+                        "hits": {
+                            "type": "integer",
+                            "example": 1500
+                        },
+                        "max_bytes": {
+                            "type": "integer",
+                            "example": 268435456
+                        },
+                        "max_size": {
+                            "type": "integer",
+                            "example": 10000
+                        },
+                        "misses": {
+                            "type": "integer",
+                            "example": 300
+                        },
+                        "negative_hits": {
+                            "type": "integer",
+                            "example": 120
+                        },
+                        "negative_max_size": {
+                            "type": "integer",
+                            "example": 10000
+                        },
+                        "negative_misses": {
+                            "type": "integer",
+                            "example": 180
+                        },
+                        "negative_size": {
+                            "type": "integer",
+                            "example": 95
+                        },
+                        "size": {
+                            "type": "integer",
+                            "example": 800
+                        }
+                    }
+                },
+                "rules": {
+                    "type": "object",
+                    "properties": {
+                        "count": {
+                            "type": "integer",
+                            "example": 25
+                        },
+                        "generation": {
+                            "type": "integer",
+                            "example": 42
+                        },
+                        "store_generation": {
+                            "type": "integer",
+                            "example": 40
+                        }
+                    }
+                },
+                "uptime": {
+                    "type": "object",
+                    "properties": {
+                        "timestamp": {
+                            "type": "string",
+                            "example": "2023-01-01T12:00:00Z"
+                        }
+                    }
+                }
+            }
+        },
+        "api.PackListResponse": {
+            "description": "Response containing list of installed packs",
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "packs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.PackInfo"
+                    }
+                }
+            }
+        },
+        "api.ResolveExplainResponse": {
+            "description": "Resolution result with the evaluation of every considered rule",
+            "type": "object",
+            "properties": {
+                "cache_hit": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "css": {
+                    "type": "string",
+                    "example": ".banner { display: none; }"
+                },
+                "evaluations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.RuleEvaluation"
+                    }
+                },
+                "js": {
+                    "type": "string",
+                    "example": "document.querySelector('.popup').remove();"
+                },
+                "normalized_url": {
+                    "type": "string",
+                    "example": "https://example.com/page"
+                },
+                "rule_count": {
+                    "type": "integer",
+                    "example": 25
+                },
+                "rule_id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "rule_ids": {
+                    "description": "Contributing rules in stacked mode, highest score first",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "score": {
+                    "type": "integer",
+                    "example": 1025
+                },
+                "skipped_by_index": {
+                    "description": "Rules ruled out by the index without evaluation",
+                    "type": "integer",
+                    "example": 20
+                },
+                "variant_id": {
+                    "description": "Variant of the matched rule that supplied the assets, \"control\" for its own assets",
+                    "type": "string",
+                    "example": "new-layout"
+                },
+                "variant_ids": {
+                    "description": "Variant per contributing rule in stacked mode",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.ResolveRequest": {
             "description": "Request payload for URL pattern resolution",
             "type": "object",
             "required": [
                 "url"
             ],
             "properties": {
+                "context": {
+                    "description": "Context describes the rendering environment evaluated against rule conditions",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.RenderContext"
+                        }
+                    ]
+                },
+                "mode": {
+                    "description": "\"stacked\" merges every matching rule",
+                    "type": "string",
+                    "enum": [
+                        "best",
+                        "stacked"
+                    ],
+                    "example": "best"
+                },
                 "url": {
                     "type": "string",
-                    "example": "https://example.com/page"
+                    "example": "https://example.com/page"
+                }
+            }
+        },
+        "api.ResolveResponse": {
+            "description": "Response payload containing matched CSS/JS assets",
+            "type": "object",
+            "properties": {
+                "cache_hit": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "css": {
+                    "type": "string",
+                    "example": ".banner { display: none; }"
+                },
+                "js": {
+                    "type": "string",
+                    "example": "document.querySelector('.popup').remove();"
+                },
+                "rule_id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "rule_ids": {
+                    "description": "Contributing rules in stacked mode, highest score first",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "variant_id": {
+                    "description": "Variant of the matched rule that supplied the assets, \"control\" for its own assets",
+                    "type": "string",
+                    "example": "new-layout"
+                },
+                "variant_ids": {
+                    "description": "Variant per contributing rule in stacked mode",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.RuleDiffResponse": {
+            "description": "Field changes between two revisions of a rule",
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.RuleFieldChange"
+                    }
+                },
+                "from_revision": {
+                    "description": "0 for the state before the rule was created",
+                    "type": "integer",
+                    "example": 2
+                },
+                "rule_id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "to_revision": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "api.RuleHistoryResponse": {
+            "description": "Revisions of a rule, oldest first",
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "revisions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.RuleRevision"
+                    }
+                },
+                "rule_id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                }
+            }
+        },
+        "api.RuleListItem": {
+            "description": "Rule with its current schedule status",
+            "type": "object",
+            "required": [
+                "id",
+                "pattern",
+                "type"
+            ],
+            "properties": {
+                "author": {
+                    "description": "Attribution fields for community sharing",
+                    "type": "string",
+                    "example": "contributor-name"
+                },
+                "conditions": {
+                    "description": "Render context requirements; the rule is skipped when they fail",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.RuleConditions"
+                        }
+                    ]
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2023-01-01T12:00:00Z"
+                },
+                "css": {
+                    "description": "100KB limit",
+                    "type": "string",
+                    "maxLength": 102400,
+                    "example": ".banner { display: none; }"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Hides cookie banner on example.com"
+                },
+                "exclude": {
+                    "description": "URLs matching any of these patterns are rejected even when the rule's pattern matches",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ExcludePattern"
+                    }
+                },
+                "exclusive": {
+                    "description": "Never stacked with other matching rules",
+                    "type": "boolean",
+                    "example": false
+                },
+                "file_path": {
+                    "description": "Path to the rule file on disk",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "js": {
+                    "description": "100KB limit",
+                    "type": "string",
+                    "maxLength": 102400,
+                    "example": "document.querySelector('.popup').remove();"
+                },
+                "match_raw": {
+                    "description": "Match the URL as requested instead of its normalized form",
+                    "type": "boolean",
+                    "example": false
+                },
+                "modified_by": {
+                    "type": "string",
+                    "example": "modifier-name"
+                },
+                "pattern": {
+                    "type": "string",
+                    "maxLength": 2048,
+                    "minLength": 1,
+                    "example": "https://example.com/*"
+                },
+                "priority": {
+                    "type": "integer",
+                    "maximum": 10000,
+                    "minimum": 0,
+                    "example": 1500
+                },
+                "schedule_status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "active",
+                        "expired"
+                    ],
+                    "example": "active"
+                },
+                "source": {
+                    "description": "Source tracking (internal, not serialized to YAML rule files)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.RuleSource"
+                        }
+                    ]
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "cookies",
+                        "privacy"
+                    ]
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "exact",
+                        "regex",
+                        "wildcard",
+                        "host",
+                        "path_prefix",
+                        "urlpattern"
+                    ],
+                    "example": "exact"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2023-01-01T12:00:00Z"
+                },
+                "valid_from": {
+                    "description": "Validity window; the rule is ignored before ValidFrom and from ValidUntil on",
+                    "type": "string",
+                    "example": "2026-11-27T00:00:00Z"
+                },
+                "valid_until": {
+                    "type": "string",
+                    "example": "2026-12-01T00:00:00Z"
+                },
+                "variant_salt": {
+                    "description": "Defaults to the rule ID",
+                    "type": "string",
+                    "example": "2026-q4"
+                },
+                "variants": {
+                    "description": "Alternative assets served to a share of URLs; the rest gets CSS and JS (variant \"control\")",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.RuleVariant"
+                    }
+                }
+            }
+        },
+        "api.RuleListResponse": {
+            "description": "Response containing list of rules",
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.RuleListItem"
+                    }
+                }
+            }
+        },
+        "api.RuleSourceResponse": {
+            "description": "Response containing rule origin and attribution",
+            "type": "object",
+            "properties": {
+                "author": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "modified_by": {
+                    "type": "string"
+                },
+                "rule_id": {
+                    "type": "string"
+                },
+                "source": {
+                    "$ref": "#/definitions/domain.RuleSource"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.SuccessResponse": {
+            "description": "Standard success response format",
+            "type": "object",
+            "properties": {
+                "data": {},
+                "status": {
+                    "type": "string",
+                    "example": "success"
+                }
+            }
+        },
+        "api.UpdatePacksRequest": {
+            "description": "Request payload for updating packs",
+            "type": "object",
+            "properties": {
+                "all": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "names": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "cookie-banners",
+                        "ad-blockers"
+                    ]
+                }
+            }
+        },
+        "api.UpdateRuleRequest": {
+            "description": "Request payload for updating a rule",
+            "type": "object",
+            "properties": {
+                "conditions": {
+                    "description": "Conditions replaces the rule's conditions; an empty object removes them",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.RuleConditions"
+                        }
+                    ]
+                },
+                "css": {
+                    "type": "string",
+                    "maxLength": 102400,
+                    "example": ".banner { display: none; }"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Updated description"
+                },
+                "exclude": {
+                    "description": "Exclude replaces the rule's exclude patterns; an empty list removes them",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ExcludePattern"
+                    }
+                },
+                "exclusive": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "js": {
+                    "type": "string",
+                    "maxLength": 102400,
+                    "example": "document.querySelector('.popup').remove();"
+                },
+                "match_raw": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "modified_by": {
+                    "type": "string",
+                    "example": "modifier-name"
+                },
+                "pattern": {
+                    "type": "string",
+                    "maxLength": 2048,
+                    "minLength": 1,
+                    "example": "https://example.com/*"
+                },
+                "priority": {
+                    "type": "integer",
+                    "maximum": 10000,
+                    "minimum": 0,
+                    "example": 1500
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "cookies",
+                        "privacy"
+                    ]
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "exact",
+                        "regex",
+                        "wildcard",
+                        "host",
+                        "path_prefix",
+                        "urlpattern"
+                    ],
+                    "example": "exact"
+                },
+                "valid_from": {
+                    "description": "ValidFrom and ValidUntil replace the validity window bounds; the zero time removes a bound",
+                    "type": "string",
+                    "example": "2026-11-27T00:00:00Z"
+                },
+                "valid_until": {
+                    "type": "string",
+                    "example": "2026-12-01T00:00:00Z"
+                },
+                "variant_salt": {
+                    "description": "VariantSalt replaces the salt of the variant assignment; changing it reshuffles URLs",
+                    "type": "string",
+                    "example": "2026-q4"
+                },
+                "variants": {
+                    "description": "Variants replaces the rule's variants; an empty list removes them",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.RuleVariant"
+                    }
                 }
             }
         },
-        "api.ResolveResponse": {
-            "description": "Response payload containing matched CSS/JS assets",
+        "domain.ExcludePattern": {
+            "description": "URL pattern that rejects URLs otherwise matched by the rule",
             "type": "object",
+            "required": [
+                "pattern",
+                "type"
+            ],
             "properties": {
-                "cache_hit": {
-                    "type": "boolean",
-                    "example": false
-                },
-                "css": {
-                    "type": "string",
-                    "example": ".banner { display: none; }"
-                },
-                "js": {
+                "pattern": {
                     "type": "string",
-                    "example": "document.querySelector('.popup').remove();"
+                    "maxLength": 2048,
+                    "minLength": 1,
+                    "example": "https://example.com/checkout/*"
                 },
-                "rule_id": {
+                "type": {
                     "type": "string",
-                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                    "enum": [
+                        "exact",
+                        "regex",
+                        "wildcard",
+                        "host",
+                        "path_prefix",
+                        "urlpattern"
+                    ],
+                    "example": "wildcard"
                 }
             }
         },
-        "api.RuleListResponse": {
-            "description": "Response containing list of rules",
+        "domain.PackInfo": {
             "type": "object",
             "properties": {
-                "count": {
-                    "type": "integer",
-                    "example": 5
+                "author": {
+                    "type": "string"
                 },
-                "rules": {
+                "description": {
+                    "type": "string"
+                },
+                "homepage": {
+                    "type": "string"
+                },
+                "installed_at": {
+                    "type": "string"
+                },
+                "license": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "rule_count": {
+                    "type": "integer"
+                },
+                "source_url": {
+                    "type": "string"
+                },
+                "tags": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/domain.Rule"
+                        "type": "string"
                     }
+                },
+                "version": {
+                    "type": "string"
                 }
             }
         },
-        "api.SuccessResponse": {
-            "description": "Standard success response format",
+        "domain.RenderContext": {
+            "description": "Rendering environment evaluated against rule conditions",
             "type": "object",
             "properties": {
-                "data": {},
-                "status": {
+                "accept_language": {
                     "type": "string",
-                    "example": "success"
+                    "maxLength": 256,
+                    "example": "de-DE,de;q=0.9,en;q=0.8"
+                },
+                "orientation": {
+                    "type": "string",
+                    "enum": [
+                        "portrait",
+                        "landscape"
+                    ],
+                    "example": "portrait"
+                },
+                "paper_format": {
+                    "type": "string",
+                    "maxLength": 32,
+                    "example": "A4"
+                },
+                "user_agent": {
+                    "type": "string",
+                    "maxLength": 1024,
+                    "example": "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"
+                },
+                "viewport_width": {
+                    "description": "CSS pixels",
+                    "type": "integer",
+                    "maximum": 100000,
+                    "minimum": 1,
+                    "example": 1280
                 }
             }
         },
+        "domain.RevisionAction": {
+            "type": "string",
+            "enum": [
+                "create",
+                "update",
+                "delete",
+                "revert"
+            ],
+            "x-enum-varnames": [
+                "RevisionCreated",
+                "RevisionUpdated",
+                "RevisionDeleted",
+                "RevisionReverted"
+            ]
+        },
         "domain.Rule": {
             "description": "URL pattern matching rule configuration",
             "type": "object",
@@ -474,6 +2202,19 @@ const docTemplate = `{
                 "type"
             ],
             "properties": {
+                "author": {
+                    "description": "Attribution fields for community sharing",
+                    "type": "string",
+                    "example": "contributor-name"
+                },
+                "conditions": {
+                    "description": "Render context requirements; the rule is skipped when they fail",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.RuleConditions"
+                        }
+                    ]
+                },
                 "created_at": {
                     "type": "string",
                     "example": "2023-01-01T12:00:00Z"
@@ -484,6 +2225,26 @@ const docTemplate = `{
                     "maxLength": 102400,
                     "example": ".banner { display: none; }"
                 },
+                "description": {
+                    "type": "string",
+                    "example": "Hides cookie banner on example.com"
+                },
+                "exclude": {
+                    "description": "URLs matching any of these patterns are rejected even when the rule's pattern matches",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ExcludePattern"
+                    }
+                },
+                "exclusive": {
+                    "description": "Never stacked with other matching rules",
+                    "type": "boolean",
+                    "example": false
+                },
+                "file_path": {
+                    "description": "Path to the rule file on disk",
+                    "type": "string"
+                },
                 "id": {
                     "type": "string",
                     "example": "123e4567-e89b-12d3-a456-426614174000"
@@ -494,6 +2255,15 @@ const docTemplate = `{
                     "maxLength": 102400,
                     "example": "document.querySelector('.popup').remove();"
                 },
+                "match_raw": {
+                    "description": "Match the URL as requested instead of its normalized form",
+                    "type": "boolean",
+                    "example": false
+                },
+                "modified_by": {
+                    "type": "string",
+                    "example": "modifier-name"
+                },
                 "pattern": {
                     "type": "string",
                     "maxLength": 2048,
@@ -506,20 +2276,330 @@ const docTemplate = `{
                     "minimum": 0,
                     "example": 1500
                 },
+                "source": {
+                    "description": "Source tracking (internal, not serialized to YAML rule files)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.RuleSource"
+                        }
+                    ]
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "cookies",
+                        "privacy"
+                    ]
+                },
                 "type": {
                     "type": "string",
                     "enum": [
                         "exact",
                         "regex",
-                        "wildcard"
+                        "wildcard",
+                        "host",
+                        "path_prefix",
+                        "urlpattern"
                     ],
                     "example": "exact"
                 },
                 "updated_at": {
                     "type": "string",
                     "example": "2023-01-01T12:00:00Z"
+                },
+                "valid_from": {
+                    "description": "Validity window; the rule is ignored before ValidFrom and from ValidUntil on",
+                    "type": "string",
+                    "example": "2026-11-27T00:00:00Z"
+                },
+                "valid_until": {
+                    "type": "string",
+                    "example": "2026-12-01T00:00:00Z"
+                },
+                "variant_salt": {
+                    "description": "Defaults to the rule ID",
+                    "type": "string",
+                    "example": "2026-q4"
+                },
+                "variants": {
+                    "description": "Alternative assets served to a share of URLs; the rest gets CSS and JS (variant \"control\")",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.RuleVariant"
+                    }
+                }
+            }
+        },
+        "domain.RuleConditions": {
+            "description": "Render context requirements of a rule",
+            "type": "object",
+            "properties": {
+                "languages": {
+                    "description": "Matched against the preferred Accept-Language entry; \"de\" also matches \"de-AT\"",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "de",
+                        "en-GB"
+                    ]
+                },
+                "max_viewport_width": {
+                    "type": "integer",
+                    "example": 1920
+                },
+                "min_viewport_width": {
+                    "type": "integer",
+                    "example": 768
+                },
+                "orientation": {
+                    "type": "string",
+                    "enum": [
+                        "portrait",
+                        "landscape"
+                    ],
+                    "example": "landscape"
+                },
+                "paper_formats": {
+                    "description": "Compared case-insensitively",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "A4",
+                        "Letter"
+                    ]
+                },
+                "user_agent": {
+                    "description": "Regex matched against the user agent",
+                    "type": "string",
+                    "example": "(?i)mobile"
+                }
+            }
+        },
+        "domain.RuleEvaluation": {
+            "type": "object",
+            "properties": {
+                "matched": {
+                    "type": "boolean"
+                },
+                "outcome": {
+                    "type": "string"
+                },
+                "pattern": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "rule_id": {
+                    "type": "string"
+                },
+                "score": {
+                    "description": "Only set for matching rules",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.ScoreBreakdown"
+                        }
+                    ]
+                },
+                "source": {
+                    "$ref": "#/definitions/domain.RuleSource"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.RuleFieldChange": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "css"
+                },
+                "from": {
+                    "description": "Absent when the field was added"
+                },
+                "to": {
+                    "description": "Absent when the field was removed"
+                }
+            }
+        },
+        "domain.RuleOperation": {
+            "description": "A create, update or delete in a bulk rules request",
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "Defaults to the ID of the rule",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "op": {
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.RuleOperationType"
+                        }
+                    ],
+                    "example": "create"
+                },
+                "rule": {
+                    "$ref": "#/definitions/domain.Rule"
+                }
+            }
+        },
+        "domain.RuleOperationType": {
+            "type": "string",
+            "enum": [
+                "create",
+                "update",
+                "delete"
+            ],
+            "x-enum-varnames": [
+                "RuleOpCreate",
+                "RuleOpUpdate",
+                "RuleOpDelete"
+            ]
+        },
+        "domain.RuleRevision": {
+            "description": "Recorded change of a rule with the full rule body after the change",
+            "type": "object",
+            "properties": {
+                "action": {
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete",
+                        "revert"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.RevisionAction"
+                        }
+                    ],
+                    "example": "update"
+                },
+                "actor": {
+                    "type": "string",
+                    "example": "jane"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Hide the new consent dialog"
+                },
+                "reverted_from": {
+                    "description": "Revision restored by a revert",
+                    "type": "integer",
+                    "example": 1
+                },
+                "revision": {
+                    "description": "Numbered per rule, starting at 1",
+                    "type": "integer",
+                    "example": 3
+                },
+                "rule": {
+                    "$ref": "#/definitions/domain.Rule"
+                },
+                "rule_id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2023-01-01T12:00:00Z"
+                }
+            }
+        },
+        "domain.RuleSource": {
+            "type": "object",
+            "properties": {
+                "pack_name": {
+                    "description": "Name of the source pack",
+                    "type": "string"
+                },
+                "pack_version": {
+                    "description": "Version of the source pack",
+                    "type": "string"
+                },
+                "source_url": {
+                    "description": "URL where the pack was downloaded from",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "local, community, override",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.SourceType"
+                        }
+                    ]
+                }
+            }
+        },
+        "domain.RuleVariant": {
+            "description": "Weighted alternative assets of a rule",
+            "type": "object",
+            "properties": {
+                "css": {
+                    "type": "string",
+                    "example": ".banner { visibility: hidden; }"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "new-layout"
+                },
+                "js": {
+                    "type": "string",
+                    "example": ""
+                },
+                "weight": {
+                    "description": "Percentage of matching URLs, 0-100",
+                    "type": "integer",
+                    "example": 10
+                }
+            }
+        },
+        "domain.ScoreBreakdown": {
+            "type": "object",
+            "properties": {
+                "base_score": {
+                    "description": "Determined by rule type",
+                    "type": "integer"
+                },
+                "length_bonus": {
+                    "description": "min(len(pattern), 499), capped lower for newer rule types",
+                    "type": "integer"
+                },
+                "priority_override": {
+                    "description": "Replaces the calculated score when set",
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
                 }
             }
+        },
+        "domain.SourceType": {
+            "type": "string",
+            "enum": [
+                "local",
+                "community",
+                "override"
+            ],
+            "x-enum-varnames": [
+                "SourceLocal",
+                "SourceCommunity",
+                "SourceOverride"
+            ]
         }
     },
     "securityDefinitions": {
@@ -548,7 +2628,7 @@ const docTemplate = `{
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
-	Host:             "localhost:8080",
+	Host:             "",
 	BasePath:         "/",
 	Schemes:          []string{"http", "https"},
 	Title:            "Asset Injector Microservice API",
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// BatchConfig limits the size and duration of batch resolve requests
+type BatchConfig struct {
+	MaxURLs     int           // Maximum number of URLs per request
+	Timeout     time.Duration // Deadline shared by all items of a request
+	Concurrency int           // Number of URLs resolved in parallel
+}
+
+// DefaultBatchConfig returns the batch limits used when none are configured
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		MaxURLs:     100,
+		Timeout:     3 * time.Second,
+		Concurrency: 8,
+	}
+}
+
+// SetBatchConfig sets the limits for batch resolve requests. Zero values keep the defaults.
+func (h *Handlers) SetBatchConfig(cfg BatchConfig) {
+	defaults := DefaultBatchConfig()
+	if cfg.MaxURLs <= 0 {
+		cfg.MaxURLs = defaults.MaxURLs
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaults.Timeout
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaults.Concurrency
+	}
+	h.batch = cfg
+}
+
+// BatchResolveRequest represents the request payload for the batch resolve endpoint
+// @Description Request payload for resolving several URLs at once
+type BatchResolveRequest struct {
+	URLs []string `json:"urls" validate:"required,min=1" example:"https://example.com/page,https://example.com/frame"`
+	// Context describes the rendering environment shared by all URLs of the batch
+	Context *domain.RenderContext `json:"context,omitempty"`
+}
+
+// BatchResolveItem is the result for a single URL of a batch
+// @Description Resolution result or error for one URL of a batch
+type BatchResolveItem struct {
+	URL      string          `json:"url" example:"https://example.com/page"`
+	RuleID   string          `json:"rule_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	CSS      string          `json:"css" example:".banner { display: none; }"`
+	JS       string          `json:"js" example:""`
+	CacheHit bool            `json:"cache_hit" example:"false"`
+	Error    *BatchItemError `json:"error,omitempty"`
+
+	VariantID string `json:"variant_id,omitempty" example:"new-layout"` // Variant of the matched rule, if it has variants
+}
+
+// BatchItemError describes why a single URL of a batch could not be resolved
+// @Description Error for one URL of a batch
+type BatchItemError struct {
+	Code    string `json:"code" example:"VALIDATION_FAILED"`
+	Message string `json:"message" example:"Invalid URL format"`
+	Details any    `json:"details,omitempty"`
+}
+
+// BatchResolveResponse represents the response payload for the batch resolve endpoint
+// @Description Per-URL results in request order
+type BatchResolveResponse struct {
+	Results []BatchResolveItem `json:"results"`
+	Count   int                `json:"count" example:"2"`
+	Errors  int                `json:"errors" example:"0"`
+}
+
+// BatchResolveHandler handles POST /v1/resolve/batch requests
+// @Summary      Resolve several URLs in one request
+// @Description  Resolves each URL like /v1/resolve and returns one result per URL in request order.
+// @Description  Invalid or failed URLs get an error entry instead of failing the whole batch.
+// @Description  All items share one deadline; items still pending when it passes fail with TIMEOUT.
+// @Tags         Resolution
+// @Accept       json
+// @Produce      json
+// @Param        request body BatchResolveRequest true "URLs to resolve"
+// @Success      200 {object} SuccessResponse{data=BatchResolveResponse} "Per-URL results"
+// @Failure      400 {object} ErrorResponse "Invalid request payload"
+// @Failure      422 {object} ErrorResponse "Empty or oversized batch"
+// @Router       /v1/resolve/batch [post]
+func (h *Handlers) BatchResolveHandler(c *fiber.Ctx) error {
+	ctx := c.Context()
+	requestID := ""
+	if rid := c.Locals("requestid"); rid != nil {
+		requestID = rid.(string)
+	}
+
+	var req BatchResolveRequest
+	if err := c.BodyParser(&req); err != nil {
+		appErr := domain.NewAppError(
+			domain.ErrInvalidInput,
+			"Invalid JSON payload",
+			400,
+			map[string]string{"error": err.Error()},
+		).WithContext(ctx, "batch_resolve_request_parsing")
+
+		return h.sendError(c, appErr)
+	}
+
+	if len(req.URLs) == 0 {
+		appErr := domain.NewAppError(
+			domain.ErrValidationFailed,
+			"At least one URL is required",
+			422,
+			map[string]any{"field": "urls"},
+		).WithContext(ctx, "batch_resolve_request_validation")
+		return h.sendError(c, appErr)
+	}
+
+	if len(req.URLs) > h.batch.MaxURLs {
+		appErr := domain.NewAppError(
+			domain.ErrValidationFailed,
+			"Too many URLs in batch",
+			422,
+			map[string]any{
+				"field":    "urls",
+				"count":    len(req.URLs),
+				"max_urls": h.batch.MaxURLs,
+			},
+		).WithContext(ctx, "batch_resolve_request_validation")
+		return h.sendError(c, appErr)
+	}
+
+	if err := req.Context.Validate(); err != nil {
+		appErr := err.(*domain.AppError).WithContext(ctx, "batch_resolve_request_validation")
+		return h.sendError(c, appErr)
+	}
+
+	batchCtx, cancel := context.WithTimeout(domain.WithRenderContext(ctx, req.Context), h.batch.Timeout)
+	defer cancel()
+
+	results := h.resolveBatch(batchCtx, req.URLs, requestID)
+
+	errorCount := 0
+	for i := range results {
+		if results[i].Error != nil {
+			errorCount++
+		}
+	}
+
+	return c.Status(200).JSON(SuccessResponse{
+		Status: "success",
+		Data: BatchResolveResponse{
+			Results: results,
+			Count:   len(results),
+			Errors:  errorCount,
+		},
+	})
+}
+
+// resolveBatch resolves the URLs on a bounded number of workers. Results keep the
+// order of the input, and each item carries its own error.
+func (h *Handlers) resolveBatch(ctx context.Context, urls []string, requestID string) []BatchResolveItem {
+	results := make([]BatchResolveItem, len(urls))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(h.batch.Concurrency, len(urls)) {
+		wg.Go(func() {
+			for i := range jobs {
+				results[i] = h.resolveBatchItem(ctx, urls[i], requestID)
+			}
+		})
+	}
+
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// resolveBatchItem resolves a single URL of a batch, converting failures into an item error
+func (h *Handlers) resolveBatchItem(ctx context.Context, url, requestID string) (item BatchResolveItem) {
+	url = strings.TrimSpace(url)
+	item.URL = url
+
+	// A panic in one item must not take down the worker or the process
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().
+				Interface("panic", r).
+				Str("url", url).
+				Str("request_id", requestID).
+				Msg("Panic while resolving batch item")
+			item = BatchResolveItem{URL: url, Error: &BatchItemError{
+				Code:    domain.ErrInternal,
+				Message: "Failed to resolve URL pattern",
+			}}
+		}
+	}()
+
+	if err := h.validator.ValidateURL(url); err != nil {
+		item.Error = batchItemError(err)
+		return item
+	}
+
+	if ctx.Err() != nil {
+		item.Error = &BatchItemError{Code: domain.ErrTimeout, Message: "Batch deadline exceeded"}
+		return item
+	}
+
+	result, err := h.matcher.Resolve(ctx, url)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("url", url).
+			Str("request_id", requestID).
+			Msg("Failed to resolve batch URL")
+		item.Error = batchItemError(err)
+		return item
+	}
+
+	if result != nil {
+		item.RuleID = result.RuleID
+		item.CSS = result.CSS
+		item.JS = result.JS
+		item.CacheHit = result.CacheHit
+		item.VariantID = result.VariantID
+	}
+	return item
+}
+
+// batchItemError converts an error into the per-item error representation.
+// Details of internal errors are not exposed, matching sendError for single resolves.
+func batchItemError(err error) *BatchItemError {
+	var appErr *domain.AppError
+	if !errors.As(err, &appErr) {
+		return &BatchItemError{Code: domain.ErrInternal, Message: "Failed to resolve URL pattern"}
+	}
+	switch appErr.Code {
+	case domain.ErrTimeout:
+		return &BatchItemError{Code: domain.ErrTimeout, Message: "Batch deadline exceeded"}
+	case domain.ErrInternal:
+		return &BatchItemError{Code: domain.ErrInternal, Message: "Failed to resolve URL pattern"}
+	default:
+		return &BatchItemError{Code: appErr.Code, Message: appErr.Message, Details: appErr.Details}
+	}
+}
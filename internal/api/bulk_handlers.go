@@ -0,0 +1,217 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// MaxBulkRuleOperations limits the operations of a bulk rules request
+const MaxBulkRuleOperations = 1000
+
+// BulkRulesRequest represents the request payload for the bulk rules endpoint
+// @Description Creates, updates and deletes applied together
+type BulkRulesRequest struct {
+	Operations []domain.RuleOperation `json:"operations"`
+}
+
+// BulkRulesResponse lists the rules a bulk request changed
+// @Description IDs of the created, updated and deleted rules
+type BulkRulesResponse struct {
+	Applied int      `json:"applied" example:"3"`
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Deleted []string `json:"deleted"`
+}
+
+// BulkOperationError describes an operation that failed validation
+// @Description Validation failure of one bulk operation
+type BulkOperationError struct {
+	Index   int    `json:"index" example:"0"`
+	Op      string `json:"op" example:"create"`
+	ID      string `json:"id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Message string `json:"message" example:"Validation failed"`
+	Details any    `json:"details,omitempty"`
+}
+
+// validateOperation prepares an operation's rule like the single-rule handlers do and
+// validates it
+func (h *Handlers) validateOperation(index int, op *domain.RuleOperation) *BulkOperationError {
+	op.Op = domain.RuleOperationType(strings.TrimSpace(string(op.Op)))
+	op.ID = strings.TrimSpace(op.ID)
+	failure := func(message string, details any) *BulkOperationError {
+		return &BulkOperationError{Index: index, Op: string(op.Op), ID: op.RuleID(), Message: message, Details: details}
+	}
+
+	switch op.Op {
+	case domain.RuleOpCreate, domain.RuleOpUpdate:
+		if op.Rule == nil {
+			return failure("Operation has no rule", map[string]string{"field": "rule", "reason": "required"})
+		}
+	case domain.RuleOpDelete:
+		if op.ID == "" {
+			return failure("Rule ID is required", map[string]string{"field": "id", "reason": "required"})
+		}
+		return nil
+	default:
+		return failure("Unknown operation", map[string]string{"field": "op", "reason": "must be create, update or delete"})
+	}
+
+	rule := op.Rule
+	sanitizeRule(rule)
+	rule.ID = strings.TrimSpace(rule.ID)
+	if op.ID != "" && rule.ID != "" && op.ID != rule.ID {
+		return failure("Operation ID does not match the rule ID", map[string]string{"field": "id", "reason": "mismatch"})
+	}
+	if rule.ID == "" {
+		rule.ID = op.ID
+	}
+	if rule.ID == "" {
+		if op.Op == domain.RuleOpUpdate {
+			return failure("Rule ID is required", map[string]string{"field": "id", "reason": "required"})
+		}
+		rule.ID = uuid.New().String()
+	}
+
+	// The store decides where rules live; an update keeps the rule's source and file
+	rule.FilePath = ""
+	rule.Source = domain.RuleSource{}
+	if op.Op == domain.RuleOpCreate {
+		rule.Source = domain.RuleSource{Type: domain.SourceLocal}
+	}
+
+	if err := h.validator.ValidateRule(rule); err != nil {
+		var appErr *domain.AppError
+		if errors.As(err, &appErr) {
+			return failure(appErr.Message, appErr.Details)
+		}
+		return failure(err.Error(), nil)
+	}
+	return nil
+}
+
+// BulkRulesHandler handles POST /v1/rules/bulk requests
+// @Summary      Apply rule changes in bulk
+// @Description  Applies creates, updates and deletes all together or not at all. Every operation is validated before anything is written,
+// @Description  and the matcher is reloaded once afterwards. Updates replace the whole rule body; the rule keeps its source and file.
+// @Description  Each rule may appear only once. The X-Actor and X-Change-Reason headers are recorded with every revision.
+// @Tags         Rules
+// @Accept       json
+// @Produce      json
+// @Param        request body BulkRulesRequest true "Operations to apply in order"
+// @Param        X-Actor header string false "Who makes the change, recorded in the rule history"
+// @Param        X-Change-Reason header string false "Why the change is made, recorded in the rule history"
+// @Success      200 {object} SuccessResponse{data=BulkRulesResponse} "Every operation was applied"
+// @Failure      400 {object} ErrorResponse "Invalid request payload"
+// @Failure      404 {object} ErrorResponse "Rule of an update or delete not found"
+// @Failure      409 {object} ErrorResponse "Rule of a create already exists"
+// @Failure      422 {object} ErrorResponse "Validation failed; details list the failing operations"
+// @Failure      500 {object} ErrorResponse "Internal server error; no operation was applied"
+// @Router       /v1/rules/bulk [post]
+func (h *Handlers) BulkRulesHandler(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	var req BulkRulesRequest
+	if err := c.BodyParser(&req); err != nil {
+		appErr := domain.NewAppError(
+			domain.ErrInvalidInput,
+			"Invalid JSON payload",
+			400,
+			map[string]string{"error": err.Error()},
+		).WithContext(ctx, "bulk_rules_parsing")
+		return h.sendError(c, appErr)
+	}
+
+	repository, ok := h.repository.(domain.BatchRuleRepository)
+	if !ok {
+		return h.sendError(c, domain.NewAppError(
+			domain.ErrNotFound,
+			"Bulk rule changes are not available",
+			404,
+			nil,
+		))
+	}
+
+	if len(req.Operations) == 0 {
+		return h.sendError(c, domain.NewAppError(
+			domain.ErrValidationFailed,
+			"At least one operation is required",
+			422,
+			map[string]string{"field": "operations", "reason": "required"},
+		).WithContext(ctx, "bulk_rules_validation"))
+	}
+	if len(req.Operations) > MaxBulkRuleOperations {
+		return h.sendError(c, domain.NewAppError(
+			domain.ErrValidationFailed,
+			"Too many operations in batch",
+			422,
+			map[string]any{
+				"field":          "operations",
+				"count":          len(req.Operations),
+				"max_operations": MaxBulkRuleOperations,
+			},
+		).WithContext(ctx, "bulk_rules_validation"))
+	}
+
+	var failures []BulkOperationError
+	for i := range req.Operations {
+		if failure := h.validateOperation(i, &req.Operations[i]); failure != nil {
+			failures = append(failures, *failure)
+		}
+	}
+	if len(failures) > 0 {
+		return h.sendError(c, domain.NewAppError(
+			domain.ErrValidationFailed,
+			"Bulk operations failed validation",
+			422,
+			map[string]any{"errors": failures},
+		).WithContext(ctx, "bulk_rules_validation"))
+	}
+
+	if err := repository.ApplyRules(domain.WithChangeInfo(ctx, changeInfo(c, "")), req.Operations); err != nil {
+		var appErr *domain.AppError
+		if errors.As(err, &appErr) && appErr.StatusCode < 500 {
+			return h.sendError(c, appErr)
+		}
+		log.Error().Err(err).Int("operations", len(req.Operations)).Msg("Failed to apply bulk rule changes")
+		return h.sendError(c, domain.NewAppError(
+			domain.ErrInternal,
+			"Failed to apply rule changes",
+			500,
+			nil,
+		))
+	}
+
+	// One reload drops only the cached results of the changed rules
+	if err := h.matcher.LoadRules(ctx); err != nil {
+		log.Error().Err(err).Int("operations", len(req.Operations)).Msg("Failed to reload matcher after bulk rule changes")
+		// Continue - rules are saved, matcher will be updated on next restart
+	}
+
+	response := BulkRulesResponse{
+		Applied: len(req.Operations),
+		Created: []string{},
+		Updated: []string{},
+		Deleted: []string{},
+	}
+	for _, op := range req.Operations {
+		switch op.Op {
+		case domain.RuleOpCreate:
+			response.Created = append(response.Created, op.RuleID())
+		case domain.RuleOpUpdate:
+			response.Updated = append(response.Updated, op.RuleID())
+		case domain.RuleOpDelete:
+			response.Deleted = append(response.Deleted, op.RuleID())
+		}
+	}
+
+	return c.Status(200).JSON(SuccessResponse{
+		Status: "success",
+		Data:   response,
+	})
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"strings"
 	"time"
 
@@ -24,6 +25,7 @@ type Handlers struct {
 	validator       domain.Validator
 	healthChecker   domain.HealthChecker
 	overrideCreator OverrideCreator
+	batch           BatchConfig
 }
 
 // NewHandlers creates a new instance of API handlers
@@ -34,6 +36,7 @@ func NewHandlers(matcher domain.PatternMatcher, repository domain.RuleRepository
 		cache:         cache,
 		validator:     validator,
 		healthChecker: healthChecker,
+		batch:         DefaultBatchConfig(),
 	}
 }
 
@@ -45,16 +48,41 @@ func (h *Handlers) SetOverrideCreator(oc OverrideCreator) {
 // ResolveRequest represents the request payload for the resolve endpoint
 // @Description Request payload for URL pattern resolution
 type ResolveRequest struct {
-	URL string `json:"url" validate:"required,url" example:"https://example.com/page"`
+	URL  string `json:"url" validate:"required,url" example:"https://example.com/page"`
+	Mode string `json:"mode,omitempty" validate:"omitempty,oneof=best stacked" example:"best" enums:"best,stacked"` // "stacked" merges every matching rule
+	// Context describes the rendering environment evaluated against rule conditions
+	Context *domain.RenderContext `json:"context,omitempty"`
 }
 
+// Resolution modes accepted by the resolve endpoint
+const (
+	ResolveModeBest    = "best"
+	ResolveModeStacked = "stacked"
+)
+
 // ResolveResponse represents the response payload for the resolve endpoint
 // @Description Response payload containing matched CSS/JS assets
 type ResolveResponse struct {
-	RuleID   string `json:"rule_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
-	CSS      string `json:"css" example:".banner { display: none; }"`
-	JS       string `json:"js" example:"document.querySelector('.popup').remove();"`
-	CacheHit bool   `json:"cache_hit" example:"false"`
+	RuleID   string   `json:"rule_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	RuleIDs  []string `json:"rule_ids,omitempty"` // Contributing rules in stacked mode, highest score first
+	CSS      string   `json:"css" example:".banner { display: none; }"`
+	JS       string   `json:"js" example:"document.querySelector('.popup').remove();"`
+	CacheHit bool     `json:"cache_hit" example:"false"`
+
+	// Variant of the matched rule that supplied the assets, "control" for its own assets
+	VariantID  string            `json:"variant_id,omitempty" example:"new-layout"`
+	VariantIDs map[string]string `json:"variant_ids,omitempty"` // Variant per contributing rule in stacked mode
+}
+
+// ResolveExplainResponse represents the response payload for the resolve endpoint in explain mode
+// @Description Resolution result with the evaluation of every considered rule
+type ResolveExplainResponse struct {
+	ResolveResponse
+	NormalizedURL  string                  `json:"normalized_url" example:"https://example.com/page"`
+	Score          int                     `json:"score" example:"1025"`
+	RuleCount      int                     `json:"rule_count" example:"25"`
+	SkippedByIndex int                     `json:"skipped_by_index" example:"20"` // Rules ruled out by the index without evaluation
+	Evaluations    []domain.RuleEvaluation `json:"evaluations"`
 }
 
 // ErrorResponse represents the standard error response format
@@ -76,8 +104,15 @@ type SuccessResponse struct {
 // RuleListResponse represents the response for listing rules
 // @Description Response containing list of rules
 type RuleListResponse struct {
-	Rules []domain.Rule `json:"rules"`
-	Count int           `json:"count" example:"5"`
+	Rules []RuleListItem `json:"rules"`
+	Count int            `json:"count" example:"5"`
+}
+
+// RuleListItem is a rule together with its schedule status at the time of the request
+// @Description Rule with its current schedule status
+type RuleListItem struct {
+	domain.Rule
+	ScheduleStatus string `json:"schedule_status" example:"active" enums:"pending,active,expired"`
 }
 
 // HealthResponse represents the health check response
@@ -96,9 +131,19 @@ type MetricsResponse struct {
 		Size     int     `json:"size" example:"800"`
 		MaxSize  int     `json:"max_size" example:"10000"`
 		HitRatio float64 `json:"hit_ratio" example:"0.83"`
+
+		Bytes           int64 `json:"bytes" example:"52428800"`
+		MaxBytes        int64 `json:"max_bytes" example:"268435456"`
+		Expirations     int64 `json:"expirations" example:"40"`
+		NegativeHits    int64 `json:"negative_hits" example:"120"`
+		NegativeMisses  int64 `json:"negative_misses" example:"180"`
+		NegativeSize    int   `json:"negative_size" example:"95"`
+		NegativeMaxSize int   `json:"negative_max_size" example:"10000"`
 	} `json:"cache"`
 	Rules struct {
-		Count int `json:"count" example:"25"`
+		Count           int    `json:"count" example:"25"`
+		Generation      uint64 `json:"generation" example:"42"`
+		StoreGeneration uint64 `json:"store_generation" example:"40"`
 	} `json:"rules"`
 	Uptime struct {
 		Timestamp string `json:"timestamp" example:"2023-01-01T12:00:00Z"`
@@ -107,12 +152,17 @@ type MetricsResponse struct {
 
 // ResolveHandler handles POST /v1/resolve requests
 // @Summary      Resolve URL pattern to CSS/JS assets
-// @Description  Matches a URL against configured rules and returns the most specific CSS/JS assets
+// @Description  Matches a URL against configured rules and returns the most specific CSS/JS assets.
+// @Description  With mode "stacked", assets of every matching rule are merged unless the top match is exclusive.
+// @Description  With explain=true, the cache is bypassed and every evaluated rule is returned with its score breakdown,
+// @Description  including disabled and conflict-shadowed rules that match the URL.
 // @Tags         Resolution
 // @Accept       json
 // @Produce      json
 // @Param        request body ResolveRequest true "URL to resolve"
+// @Param        explain query bool false "Return the evaluation of every considered rule"
 // @Success      200 {object} SuccessResponse{data=ResolveResponse} "Successfully resolved URL"
+// @Success      200 {object} SuccessResponse{data=ResolveExplainResponse} "Explanation of the resolution (explain=true)"
 // @Failure      400 {object} ErrorResponse "Invalid request payload"
 // @Failure      422 {object} ErrorResponse "Validation failed"
 // @Failure      500 {object} ErrorResponse "Internal server error"
@@ -143,8 +193,48 @@ func (h *Handlers) ResolveHandler(c *fiber.Ctx) error {
 		return h.sendError(c, appErr)
 	}
 
+	req.Mode = strings.TrimSpace(req.Mode)
+	if req.Mode != "" && req.Mode != ResolveModeBest && req.Mode != ResolveModeStacked {
+		appErr := domain.NewAppError(
+			domain.ErrValidationFailed,
+			"Invalid resolve mode",
+			422,
+			map[string]any{
+				"field":          "mode",
+				"value":          req.Mode,
+				"allowed_values": []string{ResolveModeBest, ResolveModeStacked},
+			},
+		).WithContext(ctx, "resolve_request_validation")
+		return h.sendError(c, appErr)
+	}
+
+	if err := req.Context.Validate(); err != nil {
+		appErr := err.(*domain.AppError).WithContext(ctx, "resolve_request_validation")
+		return h.sendError(c, appErr)
+	}
+
+	if c.QueryBool("explain") {
+		if req.Mode == ResolveModeStacked {
+			appErr := domain.NewAppError(
+				domain.ErrValidationFailed,
+				"Explain is only supported for the best resolve mode",
+				422,
+				map[string]any{"field": "mode", "value": req.Mode},
+			).WithContext(ctx, "resolve_request_validation")
+			return h.sendError(c, appErr)
+		}
+		return h.explainResolve(c, req.URL, req.Context, requestID)
+	}
+
 	// Resolve the URL pattern
-	result, err := h.matcher.Resolve(ctx, req.URL)
+	resolveCtx := domain.WithRenderContext(ctx, req.Context)
+	var result *domain.MatchResult
+	var err error
+	if req.Mode == ResolveModeStacked {
+		result, err = h.matcher.ResolveStacked(resolveCtx, req.URL)
+	} else {
+		result, err = h.matcher.Resolve(resolveCtx, req.URL)
+	}
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -174,22 +264,80 @@ func (h *Handlers) ResolveHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	data := map[string]any{
+		"rule_id":   result.RuleID,
+		"css":       result.CSS,
+		"js":        result.JS,
+		"cache_hit": result.CacheHit,
+	}
+	if result.VariantID != "" {
+		data["variant_id"] = result.VariantID
+	}
+	if req.Mode == ResolveModeStacked {
+		data["rule_ids"] = result.RuleIDs
+		if len(result.VariantIDs) > 0 {
+			data["variant_ids"] = result.VariantIDs
+		}
+	}
+
 	return c.Status(200).JSON(SuccessResponse{
 		Status: "success",
-		Data: map[string]any{
-			"rule_id":   result.RuleID,
-			"css":       result.CSS,
-			"js":        result.JS,
-			"cache_hit": result.CacheHit,
+		Data:   data,
+	})
+}
+
+// explainResolve resolves the URL without the cache and reports how each rule was evaluated
+func (h *Handlers) explainResolve(c *fiber.Ctx, url string, render *domain.RenderContext, requestID string) error {
+	ctx := c.Context()
+
+	explanation, err := h.matcher.Explain(domain.WithRenderContext(ctx, render), url)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("url", url).
+			Str("request_id", requestID).
+			Msg("Failed to explain URL resolution")
+
+		appErr := domain.NewAppError(
+			domain.ErrInternal,
+			"Failed to explain URL pattern resolution",
+			500,
+			nil,
+		).WithContext(ctx, "url_pattern_explanation")
+
+		return h.sendError(c, appErr)
+	}
+
+	return c.Status(200).JSON(SuccessResponse{
+		Status: "success",
+		Data: ResolveExplainResponse{
+			ResolveResponse: ResolveResponse{
+				RuleID:   explanation.Result.RuleID,
+				CSS:      explanation.Result.CSS,
+				JS:       explanation.Result.JS,
+				CacheHit: false,
+
+				VariantID: explanation.Result.VariantID,
+			},
+			NormalizedURL:  explanation.NormalizedURL,
+			Score:          explanation.Result.Score,
+			RuleCount:      explanation.RuleCount,
+			SkippedByIndex: explanation.SkippedByIndex,
+			Evaluations:    explanation.Evaluations,
 		},
 	})
 }
 
 // ListRulesHandler handles GET /v1/rules requests
 // @Summary      List all rules
-// @Description  Retrieves all configured URL matching rules
+// @Description  Retrieves all configured URL matching rules, optionally only those with every given attribute.
+// @Description  The database backend answers filtered requests from its secondary indexes.
 // @Tags         Rules
 // @Produce      json
+// @Param        source query string false "Rule source" Enums(local, community, override)
+// @Param        pack query string false "Community pack name"
+// @Param        tag query string false "Rule tag"
+// @Param        type query string false "Rule type"
 // @Success      200 {object} SuccessResponse{data=RuleListResponse} "Successfully retrieved rules"
 // @Failure      500 {object} ErrorResponse "Internal server error"
 // @Router       /v1/rules [get]
@@ -200,7 +348,14 @@ func (h *Handlers) ListRulesHandler(c *fiber.Ctx) error {
 		requestID = rid.(string)
 	}
 
-	rules, err := h.repository.GetAllRules(ctx)
+	filter := domain.RuleFilter{
+		Source: domain.SourceType(strings.TrimSpace(c.Query("source"))),
+		Pack:   strings.TrimSpace(c.Query("pack")),
+		Tag:    strings.TrimSpace(c.Query("tag")),
+		Type:   strings.TrimSpace(c.Query("type")),
+	}
+
+	rules, err := h.findRules(ctx, filter)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -217,15 +372,41 @@ func (h *Handlers) ListRulesHandler(c *fiber.Ctx) error {
 		return h.sendError(c, appErr)
 	}
 
+	now := time.Now()
+	items := make([]RuleListItem, len(rules))
+	for i := range rules {
+		items[i] = RuleListItem{Rule: rules[i], ScheduleStatus: rules[i].ScheduleStatus(now)}
+	}
+
 	return c.Status(200).JSON(SuccessResponse{
 		Status: "success",
 		Data: map[string]any{
-			"rules": rules,
-			"count": len(rules),
+			"rules": items,
+			"count": len(items),
 		},
 	})
 }
 
+// findRules returns the rules the filter selects, from the repository's indexes when
+// it has them
+func (h *Handlers) findRules(ctx context.Context, filter domain.RuleFilter) ([]domain.Rule, error) {
+	if finder, ok := h.repository.(domain.RuleFinder); ok {
+		return finder.FindRules(ctx, filter)
+	}
+
+	rules, err := h.repository.GetAllRules(ctx)
+	if err != nil || filter.IsEmpty() {
+		return rules, err
+	}
+	matched := make([]domain.Rule, 0, len(rules))
+	for i := range rules {
+		if filter.Matches(&rules[i]) {
+			matched = append(matched, rules[i])
+		}
+	}
+	return matched, nil
+}
+
 // CreateRuleHandler handles POST /v1/rules requests
 // @Summary      Create or update a rule
 // @Description  Creates a new URL matching rule or updates an existing one
@@ -233,6 +414,8 @@ func (h *Handlers) ListRulesHandler(c *fiber.Ctx) error {
 // @Accept       json
 // @Produce      json
 // @Param        rule body domain.Rule true "Rule to create or update"
+// @Param        X-Actor header string false "Who makes the change, recorded in the rule history"
+// @Param        X-Change-Reason header string false "Why the change is made, recorded in the rule history"
 // @Success      201 {object} SuccessResponse{data=object{rule=domain.Rule}} "Successfully created rule"
 // @Failure      400 {object} ErrorResponse "Invalid request payload"
 // @Failure      422 {object} ErrorResponse "Validation failed"
@@ -253,15 +436,7 @@ func (h *Handlers) CreateRuleHandler(c *fiber.Ctx) error {
 		return h.sendError(c, appErr)
 	}
 
-	// Sanitize input by trimming whitespace from all string fields
-	rule.Type = strings.TrimSpace(rule.Type)
-	rule.Pattern = strings.TrimSpace(rule.Pattern)
-	rule.CSS = strings.TrimSpace(rule.CSS)
-	rule.JS = strings.TrimSpace(rule.JS)
-
-	// Sanitize attribution fields
-	rule.Author = strings.TrimSpace(rule.Author)
-	rule.Description = strings.TrimSpace(rule.Description)
+	sanitizeRule(&rule)
 
 	// Auto-generate ID if not provided
 	if rule.ID == "" {
@@ -296,7 +471,7 @@ func (h *Handlers) CreateRuleHandler(c *fiber.Ctx) error {
 	}
 
 	// Create the rule in repository
-	if err := h.repository.CreateRule(ctx, &rule); err != nil {
+	if err := h.repository.CreateRule(domain.WithChangeInfo(ctx, changeInfo(c, rule.Author)), &rule); err != nil {
 		// Rollback: remove from matcher
 		if rollbackErr := h.matcher.RemoveRule(ctx, rule.ID); rollbackErr != nil {
 			log.Error().Err(rollbackErr).Str("rule_id", rule.ID).
@@ -337,6 +512,8 @@ func (h *Handlers) CreateRuleHandler(c *fiber.Ctx) error {
 // @Tags         Rules
 // @Produce      json
 // @Param        id path string true "Rule ID" format(uuid)
+// @Param        X-Actor header string false "Who makes the change, recorded in the rule history"
+// @Param        X-Change-Reason header string false "Why the change is made, recorded in the rule history"
 // @Success      200 {object} SuccessResponse{data=object{message=string,rule_id=string}} "Successfully deleted rule"
 // @Failure      404 {object} ErrorResponse "Rule not found"
 // @Failure      422 {object} ErrorResponse "Validation failed"
@@ -367,7 +544,7 @@ func (h *Handlers) DeleteRuleHandler(c *fiber.Ctx) error {
 	}
 
 	// Delete the rule
-	if err := h.repository.DeleteRule(ctx, ruleID); err != nil {
+	if err := h.repository.DeleteRule(domain.WithChangeInfo(ctx, changeInfo(c, "")), ruleID); err != nil {
 		log.Error().Err(err).Str("rule_id", ruleID).Msg("Failed to delete rule")
 		return h.sendError(c, domain.NewAppError(
 			domain.ErrInternal,
@@ -392,17 +569,63 @@ func (h *Handlers) DeleteRuleHandler(c *fiber.Ctx) error {
 	})
 }
 
+// sanitizeRule trims whitespace from the string fields of a rule submitted by a client
+func sanitizeRule(rule *domain.Rule) {
+	rule.Type = strings.TrimSpace(rule.Type)
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	rule.CSS = strings.TrimSpace(rule.CSS)
+	rule.JS = strings.TrimSpace(rule.JS)
+	trimExcludes(rule.Exclude)
+	trimVariants(rule.Variants)
+	rule.VariantSalt = strings.TrimSpace(rule.VariantSalt)
+
+	// Sanitize attribution fields
+	rule.Author = strings.TrimSpace(rule.Author)
+	rule.Description = strings.TrimSpace(rule.Description)
+}
+
+// trimExcludes trims whitespace from the type and pattern of exclude entries
+func trimExcludes(excludes []domain.ExcludePattern) {
+	for i := range excludes {
+		excludes[i].Type = strings.TrimSpace(excludes[i].Type)
+		excludes[i].Pattern = strings.TrimSpace(excludes[i].Pattern)
+	}
+}
+
+// trimVariants trims whitespace from the ID and assets of rule variants
+func trimVariants(variants []domain.RuleVariant) {
+	for i := range variants {
+		variants[i].ID = strings.TrimSpace(variants[i].ID)
+		variants[i].CSS = strings.TrimSpace(variants[i].CSS)
+		variants[i].JS = strings.TrimSpace(variants[i].JS)
+	}
+}
+
 // UpdateRuleRequest represents the request payload for updating a rule
 // @Description Request payload for updating a rule
 type UpdateRuleRequest struct {
-	Type        string   `json:"type,omitempty" validate:"omitempty,oneof=exact regex wildcard" example:"exact"`
+	Type        string   `json:"type,omitempty" validate:"omitempty,oneof=exact regex wildcard host path_prefix urlpattern" example:"exact" enums:"exact,regex,wildcard,host,path_prefix,urlpattern"`
 	Pattern     string   `json:"pattern,omitempty" validate:"omitempty,min=1,max=2048" example:"https://example.com/*"`
 	CSS         string   `json:"css,omitempty" validate:"omitempty,max=102400" example:".banner { display: none; }"`
 	JS          string   `json:"js,omitempty" validate:"omitempty,max=102400" example:"document.querySelector('.popup').remove();"`
 	Priority    *int     `json:"priority,omitempty" validate:"omitempty,min=0,max=10000" example:"1500"`
+	Exclusive   *bool    `json:"exclusive,omitempty" example:"false"`
+	MatchRaw    *bool    `json:"match_raw,omitempty" example:"false"`
 	ModifiedBy  string   `json:"modified_by,omitempty" example:"modifier-name"`
 	Description string   `json:"description,omitempty" example:"Updated description"`
 	Tags        []string `json:"tags,omitempty" example:"cookies,privacy"`
+
+	// Conditions replaces the rule's conditions; an empty object removes them
+	Conditions *domain.RuleConditions `json:"conditions,omitempty"`
+	// Exclude replaces the rule's exclude patterns; an empty list removes them
+	Exclude []domain.ExcludePattern `json:"exclude,omitempty"`
+	// ValidFrom and ValidUntil replace the validity window bounds; the zero time removes a bound
+	ValidFrom  *time.Time `json:"valid_from,omitempty" example:"2026-11-27T00:00:00Z"`
+	ValidUntil *time.Time `json:"valid_until,omitempty" example:"2026-12-01T00:00:00Z"`
+	// Variants replaces the rule's variants; an empty list removes them
+	Variants []domain.RuleVariant `json:"variants,omitempty"`
+	// VariantSalt replaces the salt of the variant assignment; changing it reshuffles URLs
+	VariantSalt *string `json:"variant_salt,omitempty" example:"2026-q4"`
 }
 
 // UpdateRuleHandler handles PUT /v1/rules/:id requests
@@ -413,6 +636,8 @@ type UpdateRuleRequest struct {
 // @Produce      json
 // @Param        id path string true "Rule ID" format(uuid)
 // @Param        rule body UpdateRuleRequest true "Rule fields to update"
+// @Param        X-Actor header string false "Who makes the change, recorded in the rule history"
+// @Param        X-Change-Reason header string false "Why the change is made, recorded in the rule history"
 // @Success      200 {object} SuccessResponse{data=object{rule=domain.Rule}} "Successfully updated rule"
 // @Failure      400 {object} ErrorResponse "Invalid request payload"
 // @Failure      404 {object} ErrorResponse "Rule not found"
@@ -480,6 +705,47 @@ func (h *Handlers) UpdateRuleHandler(c *fiber.Ctx) error {
 	if req.Priority != nil {
 		existingRule.Priority = req.Priority
 	}
+	if req.Exclusive != nil {
+		existingRule.Exclusive = *req.Exclusive
+	}
+	if req.MatchRaw != nil {
+		existingRule.MatchRaw = *req.MatchRaw
+	}
+	if req.Conditions != nil {
+		existingRule.Conditions = req.Conditions
+		if req.Conditions.IsZero() {
+			existingRule.Conditions = nil
+		}
+	}
+	if req.ValidFrom != nil {
+		existingRule.ValidFrom = req.ValidFrom
+		if req.ValidFrom.IsZero() {
+			existingRule.ValidFrom = nil
+		}
+	}
+	if req.ValidUntil != nil {
+		existingRule.ValidUntil = req.ValidUntil
+		if req.ValidUntil.IsZero() {
+			existingRule.ValidUntil = nil
+		}
+	}
+	if req.Exclude != nil {
+		trimExcludes(req.Exclude)
+		existingRule.Exclude = req.Exclude
+		if len(req.Exclude) == 0 {
+			existingRule.Exclude = nil
+		}
+	}
+	if req.Variants != nil {
+		trimVariants(req.Variants)
+		existingRule.Variants = req.Variants
+		if len(req.Variants) == 0 {
+			existingRule.Variants = nil
+		}
+	}
+	if req.VariantSalt != nil {
+		existingRule.VariantSalt = strings.TrimSpace(*req.VariantSalt)
+	}
 	if req.Description != "" {
 		existingRule.Description = strings.TrimSpace(req.Description)
 	}
@@ -514,7 +780,7 @@ func (h *Handlers) UpdateRuleHandler(c *fiber.Ctx) error {
 	}
 
 	// Update the rule in repository
-	if err := h.repository.UpdateRule(ctx, existingRule); err != nil {
+	if err := h.repository.UpdateRule(domain.WithChangeInfo(ctx, changeInfo(c, modifiedBy)), existingRule); err != nil {
 		log.Error().Err(err).Interface("rule", existingRule).Msg("Failed to update rule")
 
 		// Check if it's a validation error (e.g., invalid regex)
@@ -578,7 +844,8 @@ func (h *Handlers) HealthHandler(c *fiber.Ctx) error {
 
 // MetricsHandler handles GET /metrics requests
 // @Summary      System metrics
-// @Description  Returns system metrics including cache statistics and rule counts
+// @Description  Returns system metrics including cache statistics, rule counts and the generations of the
+// @Description  matcher's and repository's rule sets, which increase with every rule change
 // @Tags         System
 // @Produce      json
 // @Success      200 {object} SuccessResponse{data=MetricsResponse} "Successfully retrieved metrics"
@@ -596,6 +863,13 @@ func (h *Handlers) MetricsHandler(c *fiber.Ctx) error {
 	if err == nil {
 		ruleCount = len(rules)
 	}
+	ruleMetrics := map[string]any{"count": ruleCount}
+	if versioner, ok := h.matcher.(domain.RuleSetVersioner); ok {
+		ruleMetrics["generation"] = versioner.Generation()
+	}
+	if versioner, ok := h.repository.(domain.RuleSetVersioner); ok {
+		ruleMetrics["store_generation"] = versioner.Generation()
+	}
 
 	return c.Status(200).JSON(SuccessResponse{
 		Status: "success",
@@ -606,10 +880,16 @@ func (h *Handlers) MetricsHandler(c *fiber.Ctx) error {
 				"size":      cacheStats.Size,
 				"max_size":  cacheStats.MaxSize,
 				"hit_ratio": cacheStats.HitRatio,
+
+				"bytes":             cacheStats.Bytes,
+				"max_bytes":         cacheStats.MaxBytes,
+				"expirations":       cacheStats.Expirations,
+				"negative_hits":     cacheStats.NegativeHits,
+				"negative_misses":   cacheStats.NegativeMisses,
+				"negative_size":     cacheStats.NegativeSize,
+				"negative_max_size": cacheStats.NegativeMaxSize,
 			},
-			"rules": map[string]any{
-				"count": ruleCount,
-			},
+			"rules": ruleMetrics,
 			"uptime": map[string]any{
 				"timestamp": time.Now().UTC().Format(time.RFC3339),
 			},
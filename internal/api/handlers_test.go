@@ -19,6 +19,7 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/storage"
 )
 
 // MockPatternMatcher is a mock implementation of PatternMatcher
@@ -34,6 +35,22 @@ func (m *MockPatternMatcher) Resolve(ctx context.Context, url string) (*domain.M
 	return args.Get(0).(*domain.MatchResult), args.Error(1)
 }
 
+func (m *MockPatternMatcher) ResolveStacked(ctx context.Context, url string) (*domain.MatchResult, error) {
+	args := m.Called(ctx, url)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MatchResult), args.Error(1)
+}
+
+func (m *MockPatternMatcher) Explain(ctx context.Context, url string) (*domain.ResolveExplanation, error) {
+	args := m.Called(ctx, url)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ResolveExplanation), args.Error(1)
+}
+
 func (m *MockPatternMatcher) AddRule(ctx context.Context, rule *domain.Rule) error {
 	args := m.Called(ctx, rule)
 	return args.Error(0)
@@ -133,6 +150,11 @@ func (m *MockCacheManager) Invalidate(key string) {
 	m.Called(key)
 }
 
+func (m *MockCacheManager) InvalidateWhere(match func(key string, result *domain.MatchResult) bool) int {
+	args := m.Called(match)
+	return args.Int(0)
+}
+
 func (m *MockCacheManager) Clear() {
 	m.Called()
 }
@@ -2078,3 +2100,695 @@ func genErrorType() gopter.Gen {
 		"normal", // No error
 	)
 }
+
+// Unit test for stacked resolve mode
+func TestResolveHandler_StackedMode(t *testing.T) {
+	mockMatcher := new(MockPatternMatcher)
+	mockValidator := new(MockValidator)
+
+	mockValidator.On("ValidateURL", "https://example.com/table").Return(nil)
+	mockMatcher.On("ResolveStacked", mock.Anything, "https://example.com/table").Return(&domain.MatchResult{
+		RuleID:  "page-rule",
+		RuleIDs: []string{"page-rule", "site-rule"},
+		CSS:     "/* rule: site-rule */\n.cookie { display: none; }\n/* rule: page-rule */\ntable { width: 100%; }\n",
+
+		VariantIDs: map[string]string{"site-rule": "compact"},
+	}, nil)
+
+	handlers := NewHandlers(mockMatcher, new(MockRuleRepository), new(MockCacheManager), mockValidator, new(MockHealthChecker))
+	app := fiber.New()
+	app.Post("/v1/resolve", handlers.ResolveHandler)
+
+	jsonBody, _ := json.Marshal(ResolveRequest{URL: "https://example.com/table", Mode: ResolveModeStacked})
+	req := httptest.NewRequest("POST", "/v1/resolve", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var response SuccessResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	data := response.Data.(map[string]interface{})
+	assert.Equal(t, "page-rule", data["rule_id"])
+	assert.Equal(t, []interface{}{"page-rule", "site-rule"}, data["rule_ids"])
+	assert.Contains(t, data["css"], "/* rule: site-rule */")
+	assert.Equal(t, map[string]interface{}{"site-rule": "compact"}, data["variant_ids"])
+	assert.NotContains(t, data, "variant_id")
+
+	mockMatcher.AssertNotCalled(t, "Resolve", mock.Anything, mock.Anything)
+	mockMatcher.AssertExpectations(t)
+}
+
+// Unit test for reporting the variant chosen for a URL
+func TestResolveHandler_Variant(t *testing.T) {
+	mockMatcher := new(MockPatternMatcher)
+	mockValidator := new(MockValidator)
+
+	mockValidator.On("ValidateURL", "https://example.com/page").Return(nil)
+	mockMatcher.On("Resolve", mock.Anything, "https://example.com/page").Return(&domain.MatchResult{
+		RuleID:    "rollout",
+		CSS:       ".new-layout {}",
+		VariantID: "new-layout",
+	}, nil)
+
+	handlers := NewHandlers(mockMatcher, new(MockRuleRepository), new(MockCacheManager), mockValidator, new(MockHealthChecker))
+	app := fiber.New()
+	app.Post("/v1/resolve", handlers.ResolveHandler)
+
+	req := httptest.NewRequest("POST", "/v1/resolve", strings.NewReader(`{"url":"https://example.com/page"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var response SuccessResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	data := response.Data.(map[string]interface{})
+	assert.Equal(t, "rollout", data["rule_id"])
+	assert.Equal(t, "new-layout", data["variant_id"])
+	assert.NotContains(t, data, "variant_ids")
+
+	mockMatcher.AssertExpectations(t)
+}
+
+// Unit test for unknown resolve modes
+func TestResolveHandler_InvalidMode(t *testing.T) {
+	mockMatcher := new(MockPatternMatcher)
+	mockValidator := new(MockValidator)
+	mockValidator.On("ValidateURL", "https://example.com").Return(nil)
+
+	handlers := NewHandlers(mockMatcher, new(MockRuleRepository), new(MockCacheManager), mockValidator, new(MockHealthChecker))
+	app := fiber.New()
+	app.Post("/v1/resolve", handlers.ResolveHandler)
+
+	req := httptest.NewRequest("POST", "/v1/resolve", strings.NewReader(`{"url":"https://example.com","mode":"merge"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 422, resp.StatusCode)
+
+	mockMatcher.AssertNotCalled(t, "Resolve", mock.Anything, mock.Anything)
+	mockMatcher.AssertNotCalled(t, "ResolveStacked", mock.Anything, mock.Anything)
+}
+
+// Unit test for passing the render context to the matcher
+func TestResolveHandler_RenderContext(t *testing.T) {
+	mockMatcher := new(MockPatternMatcher)
+	mockValidator := new(MockValidator)
+
+	mockValidator.On("ValidateURL", "https://example.com/page").Return(nil)
+	hasContext := mock.MatchedBy(func(ctx context.Context) bool {
+		render := domain.RenderContextFrom(ctx)
+		return render != nil && render.PaperFormat == "Letter" && render.ViewportWidth == 375
+	})
+	mockMatcher.On("Resolve", hasContext, "https://example.com/page").Return(&domain.MatchResult{RuleID: "letter-rule"}, nil)
+
+	handlers := NewHandlers(mockMatcher, new(MockRuleRepository), new(MockCacheManager), mockValidator, new(MockHealthChecker))
+	app := fiber.New()
+	app.Post("/v1/resolve", handlers.ResolveHandler)
+
+	body := `{"url":"https://example.com/page","context":{"viewport_width":375,"paper_format":"Letter","orientation":"portrait"}}`
+	req := httptest.NewRequest("POST", "/v1/resolve", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	mockMatcher.AssertExpectations(t)
+}
+
+// Unit test for render context validation
+func TestResolveHandler_InvalidRenderContext(t *testing.T) {
+	mockMatcher := new(MockPatternMatcher)
+	mockValidator := new(MockValidator)
+	mockValidator.On("ValidateURL", "https://example.com/page").Return(nil)
+
+	handlers := NewHandlers(mockMatcher, new(MockRuleRepository), new(MockCacheManager), mockValidator, new(MockHealthChecker))
+	app := fiber.New()
+	app.Post("/v1/resolve", handlers.ResolveHandler)
+
+	body := `{"url":"https://example.com/page","context":{"orientation":"sideways"}}`
+	req := httptest.NewRequest("POST", "/v1/resolve", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 422, resp.StatusCode)
+
+	mockMatcher.AssertNotCalled(t, "Resolve", mock.Anything, mock.Anything)
+}
+
+// Unit test for the schedule status in rule listings
+func TestListRulesHandler_ScheduleStatus(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	rules := []domain.Rule{
+		{ID: "always", Type: "exact", Pattern: "https://example.com/a"},
+		{ID: "pending", Type: "exact", Pattern: "https://example.com/b", ValidFrom: &future},
+		{ID: "active", Type: "exact", Pattern: "https://example.com/c", ValidFrom: &past, ValidUntil: &future},
+		{ID: "expired", Type: "exact", Pattern: "https://example.com/d", ValidUntil: &past},
+	}
+	mockRepo := new(MockRuleRepository)
+	mockRepo.On("GetAllRules", mock.Anything).Return(rules, nil)
+
+	handlers := NewHandlers(new(MockPatternMatcher), mockRepo, new(MockCacheManager), new(MockValidator), new(MockHealthChecker))
+	app := fiber.New()
+	app.Get("/v1/rules", handlers.ListRulesHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/v1/rules", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var response struct {
+		Data struct {
+			Rules []struct {
+				ID             string     `json:"id"`
+				ValidFrom      *time.Time `json:"valid_from"`
+				ScheduleStatus string     `json:"schedule_status"`
+			} `json:"rules"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	if !assert.Len(t, response.Data.Rules, 4) {
+		return
+	}
+
+	statuses := make(map[string]string)
+	for _, rule := range response.Data.Rules {
+		statuses[rule.ID] = rule.ScheduleStatus
+	}
+	assert.Equal(t, map[string]string{"always": "active", "pending": "pending", "active": "active", "expired": "expired"}, statuses)
+	assert.NotNil(t, response.Data.Rules[1].ValidFrom, "rule fields are still included")
+}
+
+func TestListRulesHandler_Filters(t *testing.T) {
+	ctx := context.Background()
+	rules := []domain.Rule{
+		{ID: "a", Type: "exact", Pattern: "https://example.com/a", Tags: []string{"ads"}, Source: domain.RuleSource{Type: domain.SourceCommunity, PackName: "privacy"}},
+		{ID: "b", Type: "wildcard", Pattern: "*example.com/b*", Tags: []string{"ads"}, Source: domain.RuleSource{Type: domain.SourceLocal}},
+		{ID: "c", Type: "wildcard", Pattern: "*example.com/c*", Source: domain.RuleSource{Type: domain.SourceLocal}},
+	}
+
+	mockRepo := new(MockRuleRepository)
+	mockRepo.On("GetAllRules", mock.Anything).Return(rules, nil)
+
+	// The database backend answers from its indexes
+	db, err := storage.OpenBoltStore(t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	for i := range rules {
+		rule := rules[i]
+		assert.NoError(t, db.CreateRule(ctx, &rule))
+	}
+
+	for name, repo := range map[string]domain.RuleRepository{"files": mockRepo, "bolt": db} {
+		handlers := NewHandlers(new(MockPatternMatcher), repo, new(MockCacheManager), new(MockValidator), new(MockHealthChecker))
+		app := fiber.New()
+		app.Get("/v1/rules", handlers.ListRulesHandler)
+
+		for query, want := range map[string][]string{
+			"":                            {"a", "b", "c"},
+			"?tag=ads":                    {"a", "b"},
+			"?type=wildcard&source=local": {"b", "c"},
+			"?pack=privacy&tag=ads":       {"a"},
+			"?tag=print":                  {},
+		} {
+			resp, err := app.Test(httptest.NewRequest("GET", "/v1/rules"+query, nil))
+			assert.NoError(t, err)
+			assert.Equal(t, 200, resp.StatusCode)
+
+			var response struct {
+				Data struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+					Count int `json:"count"`
+				} `json:"data"`
+			}
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			ids := []string{}
+			for _, rule := range response.Data.Rules {
+				ids = append(ids, rule.ID)
+			}
+			assert.Equal(t, want, ids, "%s %s", name, query)
+			assert.Equal(t, len(want), response.Data.Count)
+		}
+	}
+}
+
+// versionedMatcher reports a fixed rule set generation
+type versionedMatcher struct {
+	*MockPatternMatcher
+	generation uint64
+}
+
+func (m versionedMatcher) Generation() uint64 { return m.generation }
+
+func TestMetricsHandler_RuleSetGenerations(t *testing.T) {
+	mockRepo := new(MockRuleRepository)
+	mockRepo.On("GetAllRules", mock.Anything).Return([]domain.Rule{{ID: "a"}}, nil)
+	mockCache := new(MockCacheManager)
+	mockCache.On("Stats").Return(domain.CacheStats{})
+
+	matcher := versionedMatcher{MockPatternMatcher: new(MockPatternMatcher), generation: 7}
+	handlers := NewHandlers(matcher, mockRepo, mockCache, new(MockValidator), new(MockHealthChecker))
+	app := fiber.New()
+	app.Get("/metrics", handlers.MetricsHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var response struct {
+		Data MetricsResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.Equal(t, 1, response.Data.Rules.Count)
+	assert.Equal(t, uint64(7), response.Data.Rules.Generation)
+	// The mock repository does not publish versioned snapshots
+	assert.Equal(t, uint64(0), response.Data.Rules.StoreGeneration)
+}
+
+// Unit test for explain mode
+func TestResolveHandler_Explain(t *testing.T) {
+	mockMatcher := new(MockPatternMatcher)
+	mockValidator := new(MockValidator)
+	priority := 2000
+
+	mockValidator.On("ValidateURL", "https://example.com/page").Return(nil)
+	mockMatcher.On("Explain", mock.Anything, "https://example.com/page").Return(&domain.ResolveExplanation{
+		URL:            "https://example.com/page",
+		Result:         &domain.MatchResult{RuleID: "exact-rule", CSS: "a {}", Score: 1024},
+		RuleCount:      5,
+		SkippedByIndex: 2,
+		Evaluations: []domain.RuleEvaluation{
+			{RuleID: "exact-rule", Type: "exact", Matched: true, Outcome: domain.EvaluationWinner,
+				Score: &domain.ScoreBreakdown{BaseScore: 1000, LengthBonus: 24, Total: 1024}},
+			{RuleID: "disabled-rule", Type: "wildcard", Matched: true, Outcome: domain.EvaluationExcluded, Reason: "disabled",
+				Score: &domain.ScoreBreakdown{BaseScore: 100, LengthBonus: 21, PriorityOverride: &priority, Total: 2000}},
+			{RuleID: "other-rule", Type: "regex", Outcome: domain.EvaluationNoMatch, Reason: "pattern does not match URL"},
+		},
+	}, nil)
+
+	handlers := NewHandlers(mockMatcher, new(MockRuleRepository), new(MockCacheManager), mockValidator, new(MockHealthChecker))
+	app := fiber.New()
+	app.Post("/v1/resolve", handlers.ResolveHandler)
+
+	req := httptest.NewRequest("POST", "/v1/resolve?explain=true", strings.NewReader(`{"url":"https://example.com/page"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var response SuccessResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	data := response.Data.(map[string]interface{})
+	assert.Equal(t, "exact-rule", data["rule_id"])
+	assert.Equal(t, false, data["cache_hit"])
+	assert.Equal(t, float64(1024), data["score"])
+	assert.Equal(t, float64(5), data["rule_count"])
+	assert.Equal(t, float64(2), data["skipped_by_index"])
+
+	evaluations := data["evaluations"].([]interface{})
+	assert.Len(t, evaluations, 3)
+	excluded := evaluations[1].(map[string]interface{})
+	assert.Equal(t, domain.EvaluationExcluded, excluded["outcome"])
+	assert.Equal(t, "disabled", excluded["reason"])
+	assert.Equal(t, float64(2000), excluded["score"].(map[string]interface{})["priority_override"])
+	assert.NotContains(t, evaluations[2].(map[string]interface{}), "score")
+
+	mockMatcher.AssertNotCalled(t, "Resolve", mock.Anything, mock.Anything)
+	mockMatcher.AssertExpectations(t)
+}
+
+// Unit test for explain mode combined with stacked resolution
+func TestResolveHandler_ExplainStackedRejected(t *testing.T) {
+	mockMatcher := new(MockPatternMatcher)
+	mockValidator := new(MockValidator)
+	mockValidator.On("ValidateURL", "https://example.com").Return(nil)
+
+	handlers := NewHandlers(mockMatcher, new(MockRuleRepository), new(MockCacheManager), mockValidator, new(MockHealthChecker))
+	app := fiber.New()
+	app.Post("/v1/resolve", handlers.ResolveHandler)
+
+	req := httptest.NewRequest("POST", "/v1/resolve?explain=true", strings.NewReader(`{"url":"https://example.com","mode":"stacked"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 422, resp.StatusCode)
+
+	mockMatcher.AssertNotCalled(t, "Explain", mock.Anything, mock.Anything)
+	mockMatcher.AssertNotCalled(t, "ResolveStacked", mock.Anything, mock.Anything)
+}
+
+// newBatchTestApp wires the batch resolve handler with the given limits
+func newBatchTestApp(matcher *MockPatternMatcher, validator *MockValidator, cfg BatchConfig) *fiber.App {
+	handlers := NewHandlers(matcher, new(MockRuleRepository), new(MockCacheManager), validator, new(MockHealthChecker))
+	handlers.SetBatchConfig(cfg)
+	app := fiber.New()
+	app.Post("/v1/resolve/batch", handlers.BatchResolveHandler)
+	return app
+}
+
+// postBatch sends a batch resolve request and decodes the response data
+func postBatch(t *testing.T, app *fiber.App, urls []string) (int, BatchResolveResponse) {
+	t.Helper()
+	jsonBody, _ := json.Marshal(BatchResolveRequest{URLs: urls})
+	req := httptest.NewRequest("POST", "/v1/resolve/batch", bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+
+	var response struct {
+		Data BatchResolveResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	return resp.StatusCode, response.Data
+}
+
+// Unit test for batch resolution with per-item errors
+func TestBatchResolveHandler_PerItemErrors(t *testing.T) {
+	mockMatcher := new(MockPatternMatcher)
+	mockValidator := new(MockValidator)
+
+	mockValidator.On("ValidateURL", "https://example.com/a").Return(nil)
+	mockValidator.On("ValidateURL", "not-a-url").Return(domain.NewAppError(domain.ErrValidationFailed, "Invalid URL format", 422, nil))
+	mockValidator.On("ValidateURL", "https://example.com/broken").Return(nil)
+	mockValidator.On("ValidateURL", "https://example.com/none").Return(nil)
+
+	mockMatcher.On("Resolve", mock.Anything, "https://example.com/a").Return(&domain.MatchResult{RuleID: "rule-a", CSS: "a {}", CacheHit: true}, nil)
+	mockMatcher.On("Resolve", mock.Anything, "https://example.com/broken").Return(nil, fmt.Errorf("storage exploded"))
+	mockMatcher.On("Resolve", mock.Anything, "https://example.com/none").Return(&domain.MatchResult{}, nil)
+
+	app := newBatchTestApp(mockMatcher, mockValidator, BatchConfig{Concurrency: 2})
+	status, data := postBatch(t, app, []string{"https://example.com/a", "not-a-url", "https://example.com/broken", "https://example.com/none"})
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 4, data.Count)
+	assert.Equal(t, 2, data.Errors)
+	if assert.Len(t, data.Results, 4) {
+		assert.Equal(t, "https://example.com/a", data.Results[0].URL)
+		assert.Equal(t, "rule-a", data.Results[0].RuleID)
+		assert.True(t, data.Results[0].CacheHit)
+		assert.Nil(t, data.Results[0].Error)
+
+		assert.Equal(t, "not-a-url", data.Results[1].URL)
+		assert.Equal(t, domain.ErrValidationFailed, data.Results[1].Error.Code)
+
+		assert.Equal(t, domain.ErrInternal, data.Results[2].Error.Code)
+		assert.NotContains(t, data.Results[2].Error.Message, "storage exploded")
+
+		assert.Nil(t, data.Results[3].Error)
+		assert.Empty(t, data.Results[3].RuleID)
+	}
+
+	mockMatcher.AssertNotCalled(t, "Resolve", mock.Anything, "not-a-url")
+}
+
+// Unit test for batch size limits
+func TestBatchResolveHandler_SizeLimits(t *testing.T) {
+	mockMatcher := new(MockPatternMatcher)
+	app := newBatchTestApp(mockMatcher, new(MockValidator), BatchConfig{MaxURLs: 2})
+
+	status, _ := postBatch(t, app, nil)
+	assert.Equal(t, 422, status)
+
+	status, _ = postBatch(t, app, []string{"https://a.com", "https://b.com", "https://c.com"})
+	assert.Equal(t, 422, status)
+
+	mockMatcher.AssertNotCalled(t, "Resolve", mock.Anything, mock.Anything)
+}
+
+// Unit test for the shared batch deadline
+func TestBatchResolveHandler_SharedDeadline(t *testing.T) {
+	mockMatcher := new(MockPatternMatcher)
+	mockValidator := new(MockValidator)
+	mockValidator.On("ValidateURL", mock.Anything).Return(nil)
+	mockMatcher.On("Resolve", mock.Anything, "https://example.com/slow").
+		WaitUntil(time.After(100*time.Millisecond)).
+		Return(&domain.MatchResult{RuleID: "slow"}, nil)
+
+	app := newBatchTestApp(mockMatcher, mockValidator, BatchConfig{Timeout: 20 * time.Millisecond, Concurrency: 1})
+	status, data := postBatch(t, app, []string{"https://example.com/slow", "https://example.com/late"})
+
+	assert.Equal(t, 200, status)
+	if assert.Len(t, data.Results, 2) {
+		assert.Equal(t, "slow", data.Results[0].RuleID)
+		assert.Equal(t, domain.ErrTimeout, data.Results[1].Error.Code)
+	}
+	mockMatcher.AssertNotCalled(t, "Resolve", mock.Anything, "https://example.com/late")
+}
+
+// Feature: github.com/freewebtopdf/asset-injector, Property 42: Batch results preserve request order
+func TestProperty_BatchResultsPreserveOrder(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("For any list of URLs, batch results should match the request order one to one", prop.ForAll(
+		func(paths []int) bool {
+			mockMatcher := new(MockPatternMatcher)
+			mockValidator := new(MockValidator)
+			mockValidator.On("ValidateURL", mock.Anything).Return(nil)
+
+			urls := make([]string, len(paths))
+			for i, path := range paths {
+				urls[i] = fmt.Sprintf("https://example.com/%d", path)
+				mockMatcher.On("Resolve", mock.Anything, urls[i]).Return(&domain.MatchResult{RuleID: fmt.Sprintf("rule-%d", path)}, nil)
+			}
+
+			app := newBatchTestApp(mockMatcher, mockValidator, BatchConfig{Concurrency: 4})
+			jsonBody, _ := json.Marshal(BatchResolveRequest{URLs: urls})
+			req := httptest.NewRequest("POST", "/v1/resolve/batch", bytes.NewReader(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req, -1)
+			if err != nil || resp.StatusCode != 200 {
+				return false
+			}
+
+			var response struct {
+				Data BatchResolveResponse `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				return false
+			}
+			if len(response.Data.Results) != len(urls) {
+				return false
+			}
+			for i, item := range response.Data.Results {
+				if item.URL != urls[i] || item.RuleID != fmt.Sprintf("rule-%d", paths[i]) || item.Error != nil {
+					return false
+				}
+			}
+			return true
+		},
+		gen.SliceOfN(20, gen.IntRange(0, 50)).SuchThat(func(paths []int) bool { return len(paths) > 0 }),
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
+
+func TestRuleHistoryHandlers(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewStore(t.TempDir())
+	assert.NoError(t, store.Load(ctx))
+
+	mockMatcher := new(MockPatternMatcher)
+	mockMatcher.On("AddRule", mock.Anything, mock.Anything).Return(nil)
+	mockMatcher.On("UpdateRule", mock.Anything, mock.Anything).Return(nil)
+	mockMatcher.On("RemoveRule", mock.Anything, mock.Anything).Return(nil)
+
+	handlers := NewHandlers(mockMatcher, store, new(MockCacheManager), domain.NewValidator(), new(MockHealthChecker))
+	app := fiber.New()
+	app.Post("/v1/rules", handlers.CreateRuleHandler)
+	app.Put("/v1/rules/:id", handlers.UpdateRuleHandler)
+	app.Delete("/v1/rules/:id", handlers.DeleteRuleHandler)
+	app.Get("/v1/rules/:id/history", handlers.GetRuleHistoryHandler)
+	app.Get("/v1/rules/:id/history/:rev/diff", handlers.GetRuleRevisionDiffHandler)
+	app.Post("/v1/rules/:id/revert/:rev", handlers.RevertRuleHandler)
+
+	send := func(method, path, body string, headers map[string]string) (int, map[string]any) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var response map[string]any
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		return resp.StatusCode, response
+	}
+
+	id := "123e4567-e89b-42d3-a456-426614174000"
+	status, _ := send("POST", "/v1/rules", `{"id":"`+id+`","type":"exact","pattern":"https://example.com/","css":".a {}","author":"jane"}`, nil)
+	assert.Equal(t, 201, status)
+	status, _ = send("PUT", "/v1/rules/"+id, `{"css":".b {}"}`, map[string]string{HeaderActor: "joe", HeaderChangeReason: "new banner"})
+	assert.Equal(t, 200, status)
+	status, _ = send("DELETE", "/v1/rules/"+id, "", map[string]string{HeaderActor: "joe"})
+	assert.Equal(t, 200, status)
+
+	status, response := send("GET", "/v1/rules/"+id+"/history", "", nil)
+	assert.Equal(t, 200, status)
+	data := response["data"].(map[string]any)
+	assert.Equal(t, float64(3), data["count"])
+	revisions := data["revisions"].([]any)
+	created := revisions[0].(map[string]any)
+	assert.Equal(t, "create", created["action"])
+	assert.Equal(t, "jane", created["actor"])
+	updated := revisions[1].(map[string]any)
+	assert.Equal(t, "joe", updated["actor"])
+	assert.Equal(t, "new banner", updated["reason"])
+
+	// An update is compared with the revision before it, a deletion with no rule
+	status, response = send("GET", "/v1/rules/"+id+"/history/2/diff", "", nil)
+	assert.Equal(t, 200, status)
+	data = response["data"].(map[string]any)
+	assert.Equal(t, float64(1), data["from_revision"])
+	assert.Equal(t, []any{map[string]any{"field": "css", "from": ".a {}", "to": ".b {}"}}, data["changes"])
+
+	status, response = send("GET", "/v1/rules/"+id+"/history/3/diff", "", nil)
+	assert.Equal(t, 200, status)
+	for _, change := range response["data"].(map[string]any)["changes"].([]any) {
+		assert.NotContains(t, change, "to")
+	}
+
+	status, response = send("GET", "/v1/rules/"+id+"/history/1/diff?against=2", "", nil)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, []any{map[string]any{"field": "css", "from": ".b {}", "to": ".a {}"}}, response["data"].(map[string]any)["changes"])
+
+	status, _ = send("GET", "/v1/rules/"+id+"/history/9/diff", "", nil)
+	assert.Equal(t, 404, status)
+	status, _ = send("GET", "/v1/rules/"+id+"/history/first/diff", "", nil)
+	assert.Equal(t, 400, status)
+
+	// Reverting a deleted rule recreates it through the matcher
+	status, _ = send("POST", "/v1/rules/"+id+"/revert/3", "", nil)
+	assert.Equal(t, 422, status)
+	status, response = send("POST", "/v1/rules/"+id+"/revert/1", "", map[string]string{HeaderActor: "ops"})
+	assert.Equal(t, 200, status)
+	assert.Equal(t, float64(1), response["data"].(map[string]any)["reverted_from"])
+
+	rule, err := store.GetRuleByID(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, ".a {}", rule.CSS)
+	mockMatcher.AssertNumberOfCalls(t, "AddRule", 2)
+
+	// Reverting an existing rule updates it through the matcher
+	status, _ = send("POST", "/v1/rules/"+id+"/revert/2", "", nil)
+	assert.Equal(t, 200, status)
+	rule, err = store.GetRuleByID(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, ".b {}", rule.CSS)
+	mockMatcher.AssertNumberOfCalls(t, "UpdateRule", 2)
+
+	history, err := store.GetRuleHistory(ctx, id)
+	assert.NoError(t, err)
+	assert.Len(t, history, 5)
+	assert.Equal(t, domain.RevisionReverted, history[3].Action)
+	assert.Equal(t, "ops", history[3].Actor)
+	assert.Equal(t, 1, history[3].RevertedFrom)
+	assert.Equal(t, "Revert to revision 2", history[4].Reason)
+
+	// Repositories without a history report none
+	plain := NewHandlers(mockMatcher, new(MockRuleRepository), new(MockCacheManager), domain.NewValidator(), new(MockHealthChecker))
+	plainApp := fiber.New()
+	plainApp.Get("/v1/rules/:id/history", plain.GetRuleHistoryHandler)
+	resp, err := plainApp.Test(httptest.NewRequest("GET", "/v1/rules/"+id+"/history", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestBulkRulesHandler(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewStore(t.TempDir())
+	assert.NoError(t, store.Load(ctx))
+
+	updatedID := "123e4567-e89b-42d3-a456-426614174001"
+	deletedID := "123e4567-e89b-42d3-a456-426614174002"
+	for _, id := range []string{updatedID, deletedID} {
+		assert.NoError(t, store.CreateRule(ctx, &domain.Rule{ID: id, Type: "exact", Pattern: "https://example.com/" + id, CSS: ".a {}"}))
+	}
+
+	mockMatcher := new(MockPatternMatcher)
+	mockMatcher.On("LoadRules", mock.Anything).Return(nil)
+
+	handlers := NewHandlers(mockMatcher, store, new(MockCacheManager), domain.NewValidator(), new(MockHealthChecker))
+	app := fiber.New()
+	app.Post("/v1/rules/bulk", handlers.BulkRulesHandler)
+
+	send := func(body string) (int, map[string]any) {
+		req := httptest.NewRequest("POST", "/v1/rules/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(HeaderActor, "importer")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var response map[string]any
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		return resp.StatusCode, response
+	}
+
+	// Every invalid operation is reported and nothing is written
+	status, response := send(`{"operations":[
+		{"op":"create","rule":{"type":"exact","pattern":"https://example.com/new","css":".n {}"}},
+		{"op":"create","rule":{"type":"regex","pattern":"(unclosed"}},
+		{"op":"upsert","id":"` + updatedID + `"},
+		{"op":"delete"}
+	]}`)
+	assert.Equal(t, 422, status)
+	failures := response["details"].(map[string]any)["errors"].([]any)
+	indexes := make([]float64, len(failures))
+	for i, failure := range failures {
+		indexes[i] = failure.(map[string]any)["index"].(float64)
+	}
+	assert.Equal(t, []float64{1, 2, 3}, indexes)
+
+	status, _ = send(`{"operations":[]}`)
+	assert.Equal(t, 422, status)
+
+	// A missing rule rejects the whole batch
+	status, response = send(`{"operations":[
+		{"op":"create","rule":{"type":"exact","pattern":"https://example.com/new","css":".n {}"}},
+		{"op":"delete","id":"123e4567-e89b-42d3-a456-426614174009"}
+	]}`)
+	assert.Equal(t, 404, status)
+	assert.Equal(t, float64(1), response["details"].(map[string]any)["index"])
+	rules, err := store.GetAllRules(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	mockMatcher.AssertNotCalled(t, "LoadRules", mock.Anything)
+
+	status, response = send(`{"operations":[
+		{"op":"create","rule":{"type":"exact","pattern":"https://example.com/new","css":" .n {} "}},
+		{"op":"update","id":"` + updatedID + `","rule":{"type":"exact","pattern":"https://example.com/updated","css":".b {}","source":{"type":"community"}}},
+		{"op":"delete","id":"` + deletedID + `"}
+	]}`)
+	assert.Equal(t, 200, status)
+	data := response["data"].(map[string]any)
+	assert.Equal(t, float64(3), data["applied"])
+	assert.Equal(t, []any{updatedID}, data["updated"])
+	assert.Equal(t, []any{deletedID}, data["deleted"])
+	created := data["created"].([]any)
+	if assert.Len(t, created, 1) {
+		rule, err := store.GetRuleByID(ctx, created[0].(string))
+		assert.NoError(t, err)
+		assert.Equal(t, ".n {}", rule.CSS)
+	}
+	mockMatcher.AssertNumberOfCalls(t, "LoadRules", 1)
+
+	rule, err := store.GetRuleByID(ctx, updatedID)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/updated", rule.Pattern)
+	assert.Equal(t, domain.SourceLocal, rule.Source.Type, "clients cannot change the source")
+	_, err = store.GetRuleByID(ctx, deletedID)
+	assert.Error(t, err)
+
+	revisions, err := store.GetRuleHistory(ctx, deletedID)
+	assert.NoError(t, err)
+	assert.Equal(t, "importer", revisions[len(revisions)-1].Actor)
+
+	// Repositories without batch support do not offer the endpoint
+	plain := NewHandlers(mockMatcher, new(MockRuleRepository), new(MockCacheManager), domain.NewValidator(), new(MockHealthChecker))
+	app = fiber.New()
+	app.Post("/v1/rules/bulk", plain.BulkRulesHandler)
+	status, _ = send(`{"operations":[{"op":"delete","id":"` + updatedID + `"}]}`)
+	assert.Equal(t, 404, status)
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// Headers describing who made a rule change and why, recorded in the rule history
+const (
+	HeaderActor        = "X-Actor"
+	HeaderChangeReason = "X-Change-Reason"
+)
+
+// RuleHistoryResponse lists the recorded revisions of a rule
+// @Description Revisions of a rule, oldest first
+type RuleHistoryResponse struct {
+	RuleID    string                `json:"rule_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Revisions []domain.RuleRevision `json:"revisions"`
+	Count     int                   `json:"count" example:"3"`
+}
+
+// RuleDiffResponse lists the fields a revision changed
+// @Description Field changes between two revisions of a rule
+type RuleDiffResponse struct {
+	RuleID       string                   `json:"rule_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	FromRevision int                      `json:"from_revision" example:"2"` // 0 for the state before the rule was created
+	ToRevision   int                      `json:"to_revision" example:"3"`
+	Changes      []domain.RuleFieldChange `json:"changes"`
+}
+
+// changeInfo reads the actor and reason of a rule change from the request headers.
+// Without an actor header the fallback, such as the rule's modified_by, is used.
+func changeInfo(c *fiber.Ctx, fallbackActor string) domain.ChangeInfo {
+	info := domain.ChangeInfo{
+		Actor:  strings.TrimSpace(c.Get(HeaderActor)),
+		Reason: strings.TrimSpace(c.Get(HeaderChangeReason)),
+	}
+	if info.Actor == "" {
+		info.Actor = strings.TrimSpace(fallbackActor)
+	}
+	return info
+}
+
+// ruleHistory returns the repository's rule history, or an error if it records none
+func (h *Handlers) ruleHistory() (domain.RuleHistory, *domain.AppError) {
+	history, ok := h.repository.(domain.RuleHistory)
+	if !ok {
+		return nil, domain.NewAppError(
+			domain.ErrNotFound,
+			"Rule history is not available",
+			404,
+			nil,
+		)
+	}
+	return history, nil
+}
+
+// historyParams reads the rule ID and, if requested, the revision number from the path
+func historyParams(c *fiber.Ctx, withRevision bool) (string, int, *domain.AppError) {
+	ruleID := strings.TrimSpace(c.Params("id"))
+	if ruleID == "" {
+		return "", 0, domain.NewAppError(
+			domain.ErrValidationFailed,
+			"Rule ID is required",
+			422,
+			map[string]string{"field": "id", "reason": "required"},
+		)
+	}
+	if !withRevision {
+		return ruleID, 0, nil
+	}
+
+	revision, err := strconv.Atoi(c.Params("rev"))
+	if err != nil || revision < 1 {
+		return "", 0, domain.NewAppError(
+			domain.ErrInvalidInput,
+			"Revision must be a positive integer",
+			400,
+			map[string]string{"field": "rev", "value": c.Params("rev")},
+		)
+	}
+	return ruleID, revision, nil
+}
+
+// historyError converts a rule history error into an AppError
+func historyError(ctx context.Context, err error, operation string) *domain.AppError {
+	var appErr *domain.AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return domain.NewAppErrorWithCause(
+		domain.ErrInternal,
+		"Failed to read rule history",
+		500,
+		err,
+		nil,
+	).WithContext(ctx, operation)
+}
+
+// GetRuleHistoryHandler handles GET /v1/rules/:id/history requests
+// @Summary      Get rule history
+// @Description  Returns every recorded revision of a rule with the full rule body after the change, oldest first.
+// @Description  Deleted rules keep their history.
+// @Tags         Rules
+// @Produce      json
+// @Param        id path string true "Rule ID"
+// @Success      200 {object} SuccessResponse{data=RuleHistoryResponse} "Rule revisions"
+// @Failure      404 {object} ErrorResponse "No history recorded for the rule"
+// @Failure      422 {object} ErrorResponse "Validation failed"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Router       /v1/rules/{id}/history [get]
+func (h *Handlers) GetRuleHistoryHandler(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	ruleID, _, appErr := historyParams(c, false)
+	if appErr != nil {
+		return h.sendError(c, appErr)
+	}
+	history, appErr := h.ruleHistory()
+	if appErr != nil {
+		return h.sendError(c, appErr)
+	}
+
+	revisions, err := history.GetRuleHistory(ctx, ruleID)
+	if err != nil {
+		return h.sendError(c, historyError(ctx, err, "get_rule_history"))
+	}
+
+	return c.Status(200).JSON(SuccessResponse{
+		Status: "success",
+		Data: RuleHistoryResponse{
+			RuleID:    ruleID,
+			Revisions: revisions,
+			Count:     len(revisions),
+		},
+	})
+}
+
+// GetRuleRevisionDiffHandler handles GET /v1/rules/:id/history/:rev/diff requests
+// @Summary      Diff a rule revision
+// @Description  Lists the fields that differ between a revision and the one before it, or the revision given in "against".
+// @Description  A deletion compares against no rule at all; updated_at is not compared.
+// @Tags         Rules
+// @Produce      json
+// @Param        id path string true "Rule ID"
+// @Param        rev path int true "Revision number"
+// @Param        against query int false "Revision to compare with instead of the previous one"
+// @Success      200 {object} SuccessResponse{data=RuleDiffResponse} "Changed fields"
+// @Failure      400 {object} ErrorResponse "Invalid revision number"
+// @Failure      404 {object} ErrorResponse "Rule history or revision not found"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Router       /v1/rules/{id}/history/{rev}/diff [get]
+func (h *Handlers) GetRuleRevisionDiffHandler(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	ruleID, revision, appErr := historyParams(c, true)
+	if appErr != nil {
+		return h.sendError(c, appErr)
+	}
+	history, appErr := h.ruleHistory()
+	if appErr != nil {
+		return h.sendError(c, appErr)
+	}
+
+	revisions, err := history.GetRuleHistory(ctx, ruleID)
+	if err != nil {
+		return h.sendError(c, historyError(ctx, err, "diff_rule_revision"))
+	}
+
+	against := -1 // The revision before the requested one
+	if value := c.Query("against"); value != "" {
+		against, err = strconv.Atoi(value)
+		if err != nil || against < 1 {
+			return h.sendError(c, domain.NewAppError(
+				domain.ErrInvalidInput,
+				"Revision must be a positive integer",
+				400,
+				map[string]string{"field": "against", "value": value},
+			))
+		}
+	}
+
+	var to, from *domain.RuleRevision
+	for i := range revisions {
+		switch revisions[i].Revision {
+		case revision:
+			to = &revisions[i]
+			if against == -1 && i > 0 {
+				from = &revisions[i-1]
+			}
+		case against:
+			from = &revisions[i]
+		}
+	}
+	if to == nil || (against > 0 && from == nil) {
+		missing := revision
+		if to != nil {
+			missing = against
+		}
+		return h.sendError(c, domain.NewAppError(
+			domain.ErrNotFound,
+			"Revision not found",
+			404,
+			map[string]any{"rule_id": ruleID, "revision": missing},
+		))
+	}
+
+	response := RuleDiffResponse{
+		RuleID:     ruleID,
+		ToRevision: to.Revision,
+		Changes:    domain.DiffRules(ruleAfter(from), ruleAfter(to)),
+	}
+	if from != nil {
+		response.FromRevision = from.Revision
+	}
+
+	return c.Status(200).JSON(SuccessResponse{
+		Status: "success",
+		Data:   response,
+	})
+}
+
+// ruleAfter returns the rule as it was after a revision, or nil if it did not exist
+func ruleAfter(revision *domain.RuleRevision) *domain.Rule {
+	if revision == nil || revision.Action == domain.RevisionDeleted {
+		return nil
+	}
+	return &revision.Rule
+}
+
+// RevertRuleHandler handles POST /v1/rules/:id/revert/:rev requests
+// @Summary      Revert a rule
+// @Description  Restores the rule body of a revision. An existing rule is updated and a deleted one is recreated as a local rule;
+// @Description  either way the matcher is updated and a "revert" revision is recorded.
+// @Description  The X-Actor and X-Change-Reason headers are recorded with the revision.
+// @Tags         Rules
+// @Produce      json
+// @Param        id path string true "Rule ID"
+// @Param        rev path int true "Revision to restore"
+// @Param        X-Actor header string false "Who makes the change"
+// @Param        X-Change-Reason header string false "Why the change is made"
+// @Success      200 {object} SuccessResponse{data=object{rule=domain.Rule,reverted_from=int}} "Successfully reverted rule"
+// @Failure      400 {object} ErrorResponse "Invalid revision number"
+// @Failure      404 {object} ErrorResponse "Rule history or revision not found"
+// @Failure      422 {object} ErrorResponse "Revision cannot be restored"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Router       /v1/rules/{id}/revert/{rev} [post]
+func (h *Handlers) RevertRuleHandler(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	ruleID, revision, appErr := historyParams(c, true)
+	if appErr != nil {
+		return h.sendError(c, appErr)
+	}
+	history, appErr := h.ruleHistory()
+	if appErr != nil {
+		return h.sendError(c, appErr)
+	}
+
+	target, err := history.GetRuleRevision(ctx, ruleID, revision)
+	if err != nil {
+		return h.sendError(c, historyError(ctx, err, "revert_rule"))
+	}
+	if target.Action == domain.RevisionDeleted {
+		return h.sendError(c, domain.NewAppError(
+			domain.ErrValidationFailed,
+			"Cannot revert to a deletion",
+			422,
+			map[string]any{"rule_id": ruleID, "revision": revision, "reason": "use DELETE /v1/rules/{id} instead"},
+		))
+	}
+
+	rule := target.Rule
+	rule.UpdatedAt = time.Now()
+	// The store keeps the current file and source of an existing rule
+	rule.FilePath = ""
+	rule.Source = domain.RuleSource{}
+
+	if err := h.validator.ValidateRule(&rule); err != nil {
+		appErr := err.(*domain.AppError).WithContext(ctx, "revert_rule_validation")
+		return h.sendError(c, appErr)
+	}
+
+	info := changeInfo(c, "")
+	info.RevertedFrom = revision
+	if info.Reason == "" {
+		info.Reason = fmt.Sprintf("Revert to revision %d", revision)
+	}
+	writeCtx := domain.WithChangeInfo(ctx, info)
+
+	if _, err := h.repository.GetRuleByID(ctx, ruleID); err == nil {
+		if err := h.repository.UpdateRule(writeCtx, &rule); err != nil {
+			log.Error().Err(err).Str("rule_id", ruleID).Int("revision", revision).Msg("Failed to revert rule")
+			return h.sendError(c, domain.NewAppError(
+				domain.ErrInternal,
+				"Failed to revert rule",
+				500,
+				nil,
+			))
+		}
+
+		if err := h.matcher.UpdateRule(ctx, &rule); err != nil {
+			log.Error().Err(err).Str("rule_id", ruleID).Msg("Failed to update rule in matcher")
+			// Continue - rule is saved, matcher will be updated on next restart
+		}
+	} else {
+		// A deleted rule is recreated in the local rules directory
+		rule.Source = domain.RuleSource{Type: domain.SourceLocal}
+
+		if err := h.matcher.AddRule(ctx, &rule); err != nil {
+			log.Error().Err(err).Str("rule_id", ruleID).Msg("Failed to add rule to matcher")
+			return h.sendError(c, domain.NewAppError(
+				domain.ErrValidationFailed,
+				"Invalid rule pattern",
+				422,
+				map[string]string{"field": "pattern", "reason": err.Error()},
+			))
+		}
+
+		if err := h.repository.CreateRule(writeCtx, &rule); err != nil {
+			if rollbackErr := h.matcher.RemoveRule(ctx, rule.ID); rollbackErr != nil {
+				log.Error().Err(rollbackErr).Str("rule_id", rule.ID).
+					Msg("Failed to rollback matcher after repository failure - state may be inconsistent")
+			}
+
+			log.Error().Err(err).Str("rule_id", ruleID).Int("revision", revision).Msg("Failed to recreate rule")
+			return h.sendError(c, domain.NewAppError(
+				domain.ErrInternal,
+				"Failed to revert rule",
+				500,
+				nil,
+			))
+		}
+	}
+
+	return c.Status(200).JSON(SuccessResponse{
+		Status: "success",
+		Data: map[string]any{
+			"rule":          rule,
+			"reverted_from": revision,
+		},
+	})
+}
@@ -22,6 +22,7 @@ type RouterConfig struct {
 	BodyLimit      int
 	RateLimitRPS   int
 	RateLimitBurst int
+	Batch          BatchConfig
 }
 
 // RouterDependencies contains all dependencies needed by the router
@@ -63,6 +64,7 @@ func SetupRouterWithDeps(deps RouterDependencies, config RouterConfig) *RouterRe
 
 	// Create handlers
 	handlers := NewHandlers(deps.Matcher, deps.Repository, deps.Cache, deps.Validator, deps.HealthChecker)
+	handlers.SetBatchConfig(config.Batch)
 	packHandlers := NewPackHandlers(deps.PackManager, deps.Repository, deps.RuleExporter)
 
 	// Middleware pipeline (order is critical)
@@ -112,7 +114,7 @@ func SetupRouterWithDeps(deps RouterDependencies, config RouterConfig) *RouterRe
 		app.Use(cors.New(cors.Config{
 			AllowOrigins:     strings.Join(config.CORSOrigins, ","),
 			AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-			AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Request-ID",
+			AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Request-ID,X-Actor,X-Change-Reason",
 			AllowCredentials: false,
 			MaxAge:           86400, // 24 hours
 		}))
@@ -123,15 +125,20 @@ func SetupRouterWithDeps(deps RouterDependencies, config RouterConfig) *RouterRe
 	// API routes
 	v1 := app.Group("/v1")
 
-	// Resolve endpoint
+	// Resolve endpoints
 	v1.Post("/resolve", handlers.ResolveHandler)
+	v1.Post("/resolve/batch", handlers.BatchResolveHandler)
 
 	// Rules endpoints
 	v1.Get("/rules", handlers.ListRulesHandler)
 	v1.Post("/rules", handlers.CreateRuleHandler)
+	v1.Post("/rules/bulk", handlers.BulkRulesHandler)
 	v1.Put("/rules/:id", handlers.UpdateRuleHandler)
 	v1.Delete("/rules/:id", handlers.DeleteRuleHandler)
 	v1.Get("/rules/:id/source", packHandlers.GetRuleSourceHandler)
+	v1.Get("/rules/:id/history", handlers.GetRuleHistoryHandler)
+	v1.Get("/rules/:id/history/:rev/diff", handlers.GetRuleRevisionDiffHandler)
+	v1.Post("/rules/:id/revert/:rev", handlers.RevertRuleHandler)
 
 	// Pack management endpoints
 	v1.Get("/packs", packHandlers.ListInstalledPacksHandler)
@@ -0,0 +1,138 @@
+package cache
+
+// ARC queue IDs; B1 and B2 hold ghost entries that only remember evicted keys
+const (
+	queueT1 uint8 = iota + 1
+	queueT2
+	queueB1
+	queueB2
+)
+
+// arcPolicy implements the Adaptive Replacement Cache. Entries seen once live in T1
+// and entries hit again in T2. Keys evicted from either are remembered in the ghost
+// lists B1 and B2; a miss on a ghost key shifts the target size p of T1 towards
+// recency or frequency, whichever would have kept the entry.
+type arcPolicy struct {
+	capacity int
+	p        int // Target size of T1
+
+	t1, t2 nodeQueue
+	b1, b2 nodeQueue
+	ghosts map[string]*node
+}
+
+// newARCPolicy creates a policy for a cache of the given capacity
+func newARCPolicy(capacity int) *arcPolicy {
+	a := &arcPolicy{capacity: max(capacity, 1)}
+	a.reset()
+	return a
+}
+
+// queue returns the queue a node is linked into
+func (a *arcPolicy) queue(n *node) *nodeQueue {
+	switch n.queue {
+	case queueT1:
+		return &a.t1
+	case queueT2:
+		return &a.t2
+	case queueB1:
+		return &a.b1
+	default:
+		return &a.b2
+	}
+}
+
+func (a *arcPolicy) hit(n *node) {
+	a.queue(n).remove(n)
+	a.t2.pushFront(n, queueT2)
+}
+
+func (a *arcPolicy) insert(n *node) []*node {
+	var evicted []*node
+	full := a.t1.len+a.t2.len >= a.capacity
+
+	if ghost := a.ghosts[n.key]; ghost != nil {
+		// The key was evicted too early: grow the list that would have kept it
+		fromB2 := ghost.queue == queueB2
+		if fromB2 {
+			a.p = max(0, a.p-max(1, a.b1.len/a.b2.len))
+		} else {
+			a.p = min(a.capacity, a.p+max(1, a.b2.len/a.b1.len))
+		}
+		a.dropGhost(ghost)
+		if full {
+			evicted = a.replace(fromB2)
+		}
+		a.t2.pushFront(n, queueT2)
+		return evicted
+	}
+
+	if a.t1.len+a.b1.len >= a.capacity {
+		if a.t1.len < a.capacity {
+			a.dropGhost(a.b1.back())
+			if full {
+				evicted = a.replace(false)
+			}
+		} else {
+			// T1 alone fills the cache; its oldest entry leaves without a ghost
+			evicted = []*node{a.t1.popBack()}
+		}
+	} else if total := a.t1.len + a.t2.len + a.b1.len + a.b2.len; total >= a.capacity {
+		if total >= 2*a.capacity && a.b2.len > 0 {
+			a.dropGhost(a.b2.back())
+		}
+		if full {
+			evicted = a.replace(false)
+		}
+	}
+	a.t1.pushFront(n, queueT1)
+	return evicted
+}
+
+// replace evicts the oldest entry of T1 or T2, depending on the target size, and
+// remembers its key in the matching ghost list
+func (a *arcPolicy) replace(fromB2 bool) []*node {
+	if a.t1.len > 0 && (a.t1.len > a.p || (fromB2 && a.t1.len == a.p) || a.t2.len == 0) {
+		n := a.t1.popBack()
+		a.addGhost(n.key, &a.b1, queueB1)
+		return []*node{n}
+	}
+	if n := a.t2.popBack(); n != nil {
+		a.addGhost(n.key, &a.b2, queueB2)
+		return []*node{n}
+	}
+	return nil
+}
+
+// addGhost remembers an evicted key
+func (a *arcPolicy) addGhost(key string, q *nodeQueue, id uint8) {
+	ghost := &node{key: key}
+	q.pushFront(ghost, id)
+	a.ghosts[key] = ghost
+}
+
+// dropGhost forgets an evicted key
+func (a *arcPolicy) dropGhost(ghost *node) {
+	a.queue(ghost).remove(ghost)
+	delete(a.ghosts, ghost.key)
+}
+
+func (a *arcPolicy) remove(n *node) {
+	a.queue(n).remove(n)
+}
+
+func (a *arcPolicy) victim() *node {
+	if evicted := a.replace(false); len(evicted) > 0 {
+		return evicted[0]
+	}
+	return nil
+}
+
+func (a *arcPolicy) reset() {
+	a.p = 0
+	a.t1.init()
+	a.t2.init()
+	a.b1.init()
+	a.b2.init()
+	a.ghosts = make(map[string]*node)
+}
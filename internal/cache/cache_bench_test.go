@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// benchmarkKeys returns cache keys shaped like normalized URLs
+func benchmarkKeys(count int) []string {
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("https://site%d.example.com/page/%d", i%500, i)
+	}
+	return keys
+}
+
+// benchmarkCaches returns the implementations compared under parallel load
+func benchmarkCaches(maxSize int) map[string]domain.CacheManager {
+	return map[string]domain.CacheManager{
+		"lru":        NewLRUCache(maxSize),
+		"sharded-16": NewShardedCache(16, LRUConfig{MaxSize: maxSize}),
+		"sharded-64": NewShardedCache(64, LRUConfig{MaxSize: maxSize}),
+	}
+}
+
+// runParallel drives a cache from all goroutines; every writeEvery-th operation is a Set
+func runParallel(b *testing.B, cache domain.CacheManager, keys []string, writeEvery int) {
+	result := &domain.MatchResult{RuleID: "rule", CSS: "body { margin: 0; }"}
+	for _, key := range keys {
+		cache.Set(key, result)
+	}
+
+	var next atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// Each goroutine starts at a different offset so they do not walk keys in lockstep
+		i := int(next.Add(7919))
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if writeEvery > 0 && i%writeEvery == 0 {
+				cache.Set(key, result)
+			} else {
+				cache.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheParallelGet(b *testing.B) {
+	keys := benchmarkKeys(10_000)
+	for name, cache := range benchmarkCaches(len(keys)) {
+		b.Run(name, func(b *testing.B) {
+			runParallel(b, cache, keys, 0)
+		})
+	}
+}
+
+func BenchmarkCacheParallelMixed(b *testing.B) {
+	keys := benchmarkKeys(20_000)
+	for name, cache := range benchmarkCaches(10_000) {
+		b.Run(name, func(b *testing.B) {
+			runParallel(b, cache, keys, 10)
+		})
+	}
+}
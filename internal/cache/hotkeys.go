@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HotKeysFileName is the file in DATA_DIR the hottest cache keys are saved to on shutdown
+const HotKeysFileName = "cache_hot_keys.json"
+
+// HotKeysFile is the on-disk format of saved cache keys, hottest first
+type HotKeysFile struct {
+	Version string    `json:"version"`
+	SavedAt time.Time `json:"saved_at"`
+	Keys    []string  `json:"keys"`
+}
+
+// HotKeyLister is implemented by caches that can list their hottest keys
+type HotKeyLister interface {
+	HotKeys(limit int) []string
+}
+
+// SaveHotKeys writes keys to path atomically
+func SaveHotKeys(path string, keys []string) error {
+	data, err := json.MarshalIndent(HotKeysFile{
+		Version: "1.0",
+		SavedAt: time.Now(),
+		Keys:    keys,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal hot keys: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create hot keys directory: %w", err)
+	}
+
+	// Write atomically using temp file
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write hot keys file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename hot keys file: %w", err)
+	}
+	return nil
+}
+
+// LoadHotKeys reads keys saved by SaveHotKeys. A missing file yields no keys.
+func LoadHotKeys(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hot keys file: %w", err)
+	}
+
+	var file HotKeysFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse hot keys file: %w", err)
+	}
+	return file.Keys, nil
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUCache_HotKeys(t *testing.T) {
+	now := time.Now()
+	cache := NewLRUCacheWithConfig(LRUConfig{MaxSize: 10, TTL: time.Minute, NegativeMaxSize: 10, NegativeTTL: time.Minute})
+	fakeClock(cache, &now)
+
+	cache.Set("old", &domain.MatchResult{RuleID: "rule"})
+	now = now.Add(30 * time.Second)
+	cache.Set("a", &domain.MatchResult{RuleID: "rule"})
+	cache.Set("b", &domain.MatchResult{RuleID: "rule"})
+	cache.Set("none", &domain.MatchResult{})
+	cache.Get("a")
+
+	// Most recently used first; no-match results are left out
+	assert.Equal(t, []string{"a", "b", "old"}, cache.HotKeys(10))
+	assert.Equal(t, []string{"a"}, cache.HotKeys(1))
+
+	// Expired entries are left out
+	now = now.Add(45 * time.Second)
+	assert.Equal(t, []string{"a", "b"}, cache.HotKeys(10))
+}
+
+func TestShardedCache_HotKeys(t *testing.T) {
+	cache := NewShardedCache(4, LRUConfig{MaxSize: 100})
+	for i := range 20 {
+		cache.Set(fmt.Sprintf("key%d", i), &domain.MatchResult{RuleID: "rule"})
+	}
+
+	keys := cache.HotKeys(8)
+	assert.Len(t, keys, 8)
+	// The most recently set key is the hottest of its shard and comes first among them
+	assert.Contains(t, keys, "key19")
+	assert.Len(t, cache.HotKeys(100), 20)
+}
+
+func TestSaveAndLoadHotKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", HotKeysFileName)
+
+	keys, err := LoadHotKeys(path)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.NoError(t, SaveHotKeys(path, []string{"https://example.com/", "stacked:https://example.com/a"}))
+	keys, err = LoadHotKeys(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/", "stacked:https://example.com/a"}, keys)
+
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0644))
+	_, err = LoadHotKeys(path)
+	assert.Error(t, err)
+}
@@ -11,10 +11,33 @@ import (
 
 // node represents a node in the doubly-linked list
 type node struct {
-	key   string
-	value *domain.MatchResult
-	prev  *node
-	next  *node
+	key     string
+	value   *domain.MatchResult
+	expires time.Time // Zero when the entry never expires
+	bytes   int64     // Estimated memory held by the entry
+	prev    *node
+	next    *node
+
+	// Links and queue ID within the eviction policy's queues
+	qprev *node
+	qnext *node
+	queue uint8
+}
+
+// LRUConfig holds optional LRU cache behaviour
+type LRUConfig struct {
+	MaxSize int
+	TTL     time.Duration // Lifetime of an entry; zero keeps entries until evicted
+
+	// Memory budget for entries of the main store; zero only bounds the entry count
+	MaxBytes int64
+
+	// Eviction policy, one of PolicyLRU, PolicyTinyLFU or PolicyARC; empty means LRU
+	Policy string
+
+	// Negative cache for results without a matching rule; disabled when NegativeMaxSize is zero
+	NegativeMaxSize int
+	NegativeTTL     time.Duration
 }
 
 // LRUCache implements the CacheManager interface using LRU eviction policy
@@ -22,10 +45,18 @@ type LRUCache struct {
 	maxSize int
 	size    int
 
-	// Doubly-linked list for LRU ordering
+	// Estimated memory held by entries and its limit; zero maxBytes means no limit
+	maxBytes int64
+	bytes    int64
+
+	// Doubly-linked list for LRU ordering; decides eviction unless a policy is set
 	head *node
 	tail *node
 
+	// Frequency-aware eviction policy; nil for plain LRU
+	policy     evictionPolicy
+	policyName string
+
 	// HashMap for O(1) lookups
 	cache map[string]*node
 
@@ -33,19 +64,50 @@ type LRUCache struct {
 	mutex sync.RWMutex
 
 	// Atomic counters for metrics
-	hits   int64
-	misses int64
+	hits          int64
+	misses        int64
+	invalidations int64
+	expirations   int64
+
+	// Entry lifetime and clock; zero ttl keeps entries until evicted
+	ttl time.Duration
+	now func() time.Time
+
+	// Separate store for no-match results, guarded by mutex; nil when disabled
+	negative       *LRUCache
+	negativeHits   int64
+	negativeMisses int64
 
 	// Health monitoring
 	lastHealthCheck time.Time
 	healthMutex     sync.RWMutex
 }
 
-// NewLRUCache creates a new LRU cache with the specified maximum size
+// NewLRUCache creates a new LRU cache with the specified maximum size whose entries
+// never expire and which does not cache no-match results
 func NewLRUCache(maxSize int) *LRUCache {
+	return NewLRUCacheWithConfig(LRUConfig{MaxSize: maxSize})
+}
+
+// NewLRUCacheWithConfig creates a new LRU cache with entry expiry and an optional
+// negative cache
+func NewLRUCacheWithConfig(config LRUConfig) *LRUCache {
+	c := newLRU(config.MaxSize, config.MaxBytes, config.TTL, config.Policy)
+	if config.NegativeMaxSize > 0 {
+		// No-match entries hold no assets, so their count bounds their memory
+		c.negative = newLRU(config.NegativeMaxSize, 0, config.NegativeTTL, config.Policy)
+	}
+	return c
+}
+
+// newLRU creates a single LRU store
+func newLRU(maxSize int, maxBytes int64, ttl time.Duration, policy string) *LRUCache {
 	if maxSize <= 0 {
 		maxSize = 10000 // Default size from requirements
 	}
+	if policy == "" {
+		policy = PolicyLRU
+	}
 
 	// Create dummy head and tail nodes for easier list manipulation
 	head := &node{}
@@ -56,81 +118,176 @@ func NewLRUCache(maxSize int) *LRUCache {
 	return &LRUCache{
 		maxSize:         maxSize,
 		size:            0,
+		maxBytes:        max(maxBytes, 0),
+		policy:          newPolicy(policy, maxSize),
+		policyName:      policy,
 		head:            head,
 		tail:            tail,
 		cache:           make(map[string]*node),
+		ttl:             ttl,
+		now:             time.Now,
 		lastHealthCheck: time.Now(),
 	}
 }
 
-// Get retrieves a value from the cache and marks it as recently used
+// Get retrieves a value from the cache and marks it as recently used. Keys missing
+// from the main store are looked up in the negative cache.
 func (c *LRUCache) Get(key string) (*domain.MatchResult, bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	foundNode, exists := c.cache[key]
-	if !exists {
-		atomic.AddInt64(&c.misses, 1)
-		return nil, false
+	foundNode := c.lookup(key)
+	if foundNode != nil {
+		atomic.AddInt64(&c.hits, 1)
+	} else {
+		if c.negative == nil {
+			atomic.AddInt64(&c.misses, 1)
+			return nil, false
+		}
+		if foundNode = c.negative.lookup(key); foundNode == nil {
+			atomic.AddInt64(&c.misses, 1)
+			atomic.AddInt64(&c.negativeMisses, 1)
+			return nil, false
+		}
+		// Negative hits are neither hits nor misses of the main store
+		atomic.AddInt64(&c.negativeHits, 1)
 	}
 
-	// Move to front (most recently used)
-	c.moveToFront(foundNode)
-	atomic.AddInt64(&c.hits, 1)
-
 	// Create a copy to avoid race conditions on shared cached objects
-	result := &domain.MatchResult{
-		RuleID:    foundNode.value.RuleID,
-		CSS:       foundNode.value.CSS,
-		JS:        foundNode.value.JS,
-		Score:     foundNode.value.Score,
-		CacheHit:  true,
-		Timestamp: time.Now(),
-	}
+	result := foundNode.value.Clone()
+	result.CacheHit = true
+	result.Timestamp = time.Now()
 	return result, true
 }
 
-// Set adds or updates a value in the cache
+// Set adds or updates a value in the cache. Results without a matching rule go to the
+// negative cache and are dropped when it is disabled.
 func (c *LRUCache) Set(key string, result *domain.MatchResult) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if node, exists := c.cache[key]; exists {
-		// Update existing node with a copy
-		node.value = &domain.MatchResult{
-			RuleID:    result.RuleID,
-			CSS:       result.CSS,
-			JS:        result.JS,
-			Score:     result.Score,
-			CacheHit:  result.CacheHit,
-			Timestamp: result.Timestamp,
+	if result.RuleID == "" && len(result.RuleIDs) == 0 {
+		c.remove(key)
+		if c.negative != nil {
+			c.negative.store(key, result)
 		}
-		c.moveToFront(node)
 		return
 	}
+	if c.negative != nil {
+		c.negative.remove(key)
+	}
+	c.store(key, result)
+}
 
-	// Create new node with a copy of the result
-	newNode := &node{
-		key: key,
-		value: &domain.MatchResult{
-			RuleID:    result.RuleID,
-			CSS:       result.CSS,
-			JS:        result.JS,
-			Score:     result.Score,
-			CacheHit:  result.CacheHit,
-			Timestamp: result.Timestamp,
-		},
+// lookup returns the live entry for a key and marks it as recently used. Expired
+// entries are removed. Must be called with the owning cache's mutex held.
+func (c *LRUCache) lookup(key string) *node {
+	foundNode, exists := c.cache[key]
+	if !exists {
+		return nil
+	}
+	if !foundNode.expires.IsZero() && !c.now().Before(foundNode.expires) {
+		c.remove(key)
+		atomic.AddInt64(&c.expirations, 1)
+		return nil
+	}
+
+	// Move to front (most recently used)
+	c.moveToFront(foundNode)
+	if c.policy != nil {
+		c.policy.hit(foundNode)
+	}
+	return foundNode
+}
+
+// store adds or updates an entry, restarting its lifetime, and evicts the least
+// recently used entries until both the entry and byte limits hold. An entry larger
+// than the whole byte budget is not stored. Must be called with the owning cache's
+// mutex held.
+func (c *LRUCache) store(key string, result *domain.MatchResult) {
+	bytes := entrySize(key, result)
+	if c.maxBytes > 0 && bytes > c.maxBytes {
+		c.remove(key)
+		return
+	}
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.now().Add(c.ttl)
 	}
 
-	// Add to front of list
-	c.addToFront(newNode)
-	c.cache[key] = newNode
-	c.size++
+	if existing, exists := c.cache[key]; exists {
+		// Update existing node with a copy
+		c.bytes += bytes - existing.bytes
+		existing.value = result.Clone()
+		existing.expires = expires
+		existing.bytes = bytes
+		c.moveToFront(existing)
+		if c.policy != nil {
+			c.policy.hit(existing)
+		}
+	} else {
+		// Create new node with a copy of the result
+		newNode := &node{
+			key:     key,
+			value:   result.Clone(),
+			expires: expires,
+			bytes:   bytes,
+		}
+
+		// Add to front of list
+		c.addToFront(newNode)
+		c.cache[key] = newNode
+		c.size++
+		c.bytes += bytes
+		if c.policy != nil {
+			for _, evicted := range c.policy.insert(newNode) {
+				c.drop(evicted)
+			}
+		}
+	}
 
 	// Check if we need to evict
-	if c.size > c.maxSize {
-		c.evictLRU()
+	for c.size > c.maxSize || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		if !c.evict() {
+			break
+		}
+	}
+}
+
+// HotKeys returns up to limit unexpired keys of the main store, most recently used
+// first. No-match results are left out.
+func (c *LRUCache) HotKeys(limit int) []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	keys := make([]string, 0, min(limit, c.size))
+	now := c.now()
+	for n := c.head.next; n != c.tail && len(keys) < limit; n = n.next {
+		if n.expires.IsZero() || now.Before(n.expires) {
+			keys = append(keys, n.key)
+		}
 	}
+	return keys
+}
+
+// remove deletes a key from the store. Must be called with the owning cache's mutex held.
+func (c *LRUCache) remove(key string) {
+	if node, exists := c.cache[key]; exists {
+		if c.policy != nil {
+			c.policy.remove(node)
+		}
+		c.drop(node)
+	}
+}
+
+// drop deletes a node the policy no longer tracks. Must be called with the owning
+// cache's mutex held.
+func (c *LRUCache) drop(node *node) {
+	c.removeNode(node)
+	delete(c.cache, node.key)
+	c.size--
+	c.bytes -= node.bytes
 }
 
 // Invalidate removes a specific key from the cache
@@ -138,18 +295,60 @@ func (c *LRUCache) Invalidate(key string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if node, exists := c.cache[key]; exists {
-		c.removeNode(node)
-		delete(c.cache, key)
-		c.size--
+	c.remove(key)
+	if c.negative != nil {
+		c.negative.remove(key)
 	}
 }
 
+// InvalidateWhere removes every entry match returns true for, negative entries
+// included. Hit and miss counters are kept so rule changes do not reset the cache metrics.
+func (c *LRUCache) InvalidateWhere(match func(key string, result *domain.MatchResult) bool) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	removed := c.removeWhere(match)
+	if c.negative != nil {
+		removed += c.negative.removeWhere(match)
+	}
+	atomic.AddInt64(&c.invalidations, int64(removed))
+	return removed
+}
+
+// removeWhere deletes the entries match returns true for. Must be called with the
+// owning cache's mutex held.
+func (c *LRUCache) removeWhere(match func(key string, result *domain.MatchResult) bool) int {
+	removed := 0
+	for key, node := range c.cache {
+		if match(key, node.value) {
+			c.remove(key)
+			removed++
+		}
+	}
+	return removed
+}
+
 // Clear removes all entries from the cache
 func (c *LRUCache) Clear() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	c.reset()
+	if c.negative != nil {
+		c.negative.reset()
+	}
+
+	// Reset counters
+	atomic.StoreInt64(&c.hits, 0)
+	atomic.StoreInt64(&c.misses, 0)
+	atomic.StoreInt64(&c.invalidations, 0)
+	atomic.StoreInt64(&c.negativeHits, 0)
+	atomic.StoreInt64(&c.negativeMisses, 0)
+}
+
+// reset empties the store and its expiry counter. Must be called with the owning
+// cache's mutex held.
+func (c *LRUCache) reset() {
 	// Reset the doubly-linked list
 	c.head.next = c.tail
 	c.tail.prev = c.head
@@ -157,10 +356,11 @@ func (c *LRUCache) Clear() {
 	// Clear the hashmap
 	c.cache = make(map[string]*node)
 	c.size = 0
-
-	// Reset counters
-	atomic.StoreInt64(&c.hits, 0)
-	atomic.StoreInt64(&c.misses, 0)
+	c.bytes = 0
+	if c.policy != nil {
+		c.policy.reset()
+	}
+	atomic.StoreInt64(&c.expirations, 0)
 }
 
 // Stats returns current cache statistics
@@ -177,13 +377,29 @@ func (c *LRUCache) Stats() domain.CacheStats {
 		hitRatio = float64(hits) / float64(total)
 	}
 
-	return domain.CacheStats{
+	stats := domain.CacheStats{
 		Hits:     hits,
 		Misses:   misses,
 		Size:     c.size,
 		MaxSize:  c.maxSize,
 		HitRatio: hitRatio,
+		Policy:   c.policyName,
+
+		Bytes:    c.bytes,
+		MaxBytes: c.maxBytes,
+
+		Invalidations: atomic.LoadInt64(&c.invalidations),
+		Expirations:   atomic.LoadInt64(&c.expirations),
+	}
+	if c.negative != nil {
+		stats.NegativeHits = atomic.LoadInt64(&c.negativeHits)
+		stats.NegativeMisses = atomic.LoadInt64(&c.negativeMisses)
+		stats.NegativeSize = c.negative.size
+		stats.NegativeMaxSize = c.negative.maxSize
+		stats.NegativeBytes = c.negative.bytes
+		stats.Expirations += atomic.LoadInt64(&c.negative.expirations)
 	}
+	return stats
 }
 
 // HealthCheck performs a health check on the cache
@@ -194,8 +410,11 @@ func (c *LRUCache) HealthCheck(ctx context.Context) domain.HealthStatus {
 	now := time.Now()
 	c.lastHealthCheck = now
 
-	stats := c.Stats()
+	return statsHealth(c.Stats(), now)
+}
 
+// statsHealth derives the cache health status from its statistics
+func statsHealth(stats domain.CacheStats, now time.Time) domain.HealthStatus {
 	status := "healthy"
 	message := "Cache is operating normally"
 	details := map[string]any{
@@ -204,6 +423,19 @@ func (c *LRUCache) HealthCheck(ctx context.Context) domain.HealthStatus {
 		"hit_ratio": stats.HitRatio,
 		"hits":      stats.Hits,
 		"misses":    stats.Misses,
+		"policy":    stats.Policy,
+
+		"bytes":     stats.Bytes,
+		"max_bytes": stats.MaxBytes,
+
+		"invalidations": stats.Invalidations,
+		"expirations":   stats.Expirations,
+
+		"negative_hits":     stats.NegativeHits,
+		"negative_misses":   stats.NegativeMisses,
+		"negative_size":     stats.NegativeSize,
+		"negative_max_size": stats.NegativeMaxSize,
+		"negative_bytes":    stats.NegativeBytes,
 	}
 
 	// Check for potential issues
@@ -213,6 +445,14 @@ func (c *LRUCache) HealthCheck(ctx context.Context) domain.HealthStatus {
 		details["warning"] = "Cache utilization above 90%"
 	}
 
+	if stats.MaxBytes > 0 && stats.Bytes >= int64(float64(stats.MaxBytes)*0.9) {
+		if status == "healthy" {
+			status = "degraded"
+			message = "Cache is near its memory budget"
+		}
+		details["bytes_warning"] = "Cache memory use above 90%"
+	}
+
 	if stats.HitRatio < 0.5 && stats.Hits+stats.Misses > 100 {
 		if status == "healthy" {
 			status = "degraded"
@@ -249,14 +489,36 @@ func (c *LRUCache) removeNode(node *node) {
 	node.next.prev = node.prev
 }
 
-// evictLRU removes the least recently used item from the cache
-func (c *LRUCache) evictLRU() {
-	if c.tail.prev == c.head {
-		return // Empty cache
+// evict removes the entry the policy picks, or the least recently used one. It
+// reports false when the cache is empty.
+func (c *LRUCache) evict() bool {
+	if c.policy == nil {
+		if c.tail.prev == c.head {
+			return false // Empty cache
+		}
+		c.drop(c.tail.prev)
+		return true
 	}
 
-	lru := c.tail.prev
-	c.removeNode(lru)
-	delete(c.cache, lru.key)
-	c.size--
+	victim := c.policy.victim()
+	if victim == nil {
+		return false
+	}
+	c.drop(victim)
+	return true
+}
+
+// entryOverhead approximates the memory of a node, its map slot and the result struct
+const entryOverhead = 256
+
+// entrySize estimates the memory an entry holds: the key and every string of the result
+func entrySize(key string, result *domain.MatchResult) int64 {
+	size := entryOverhead + len(key) + len(result.RuleID) + len(result.CSS) + len(result.JS) + len(result.VariantID)
+	for _, id := range result.RuleIDs {
+		size += len(id) + 16
+	}
+	for ruleID, variantID := range result.VariantIDs {
+		size += len(ruleID) + len(variantID) + 32
+	}
+	return int64(size)
 }
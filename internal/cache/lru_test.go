@@ -1,7 +1,9 @@
 package cache
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -56,6 +58,32 @@ func TestLRUCache_SetAndGet(t *testing.T) {
 	assert.True(t, value.CacheHit) // Cache hit should be true when retrieved from cache
 }
 
+func TestLRUCache_GetKeepsVariants(t *testing.T) {
+	cache := NewLRUCache(2)
+
+	cache.Set("key1", &domain.MatchResult{RuleID: "rule1", VariantID: "compact"})
+	cache.Set("stacked:key1", &domain.MatchResult{
+		RuleID:     "rule1",
+		RuleIDs:    []string{"rule1", "rule2"},
+		VariantID:  "compact",
+		VariantIDs: map[string]string{"rule1": "compact", "rule2": domain.ControlVariantID},
+	})
+
+	value, found := cache.Get("key1")
+	require.True(t, found)
+	assert.Equal(t, "compact", value.VariantID)
+
+	value, found = cache.Get("stacked:key1")
+	require.True(t, found)
+	assert.Equal(t, "compact", value.VariantID)
+	assert.Equal(t, map[string]string{"rule1": "compact", "rule2": domain.ControlVariantID}, value.VariantIDs)
+
+	// Callers cannot modify the cached entry
+	value.VariantIDs["rule2"] = "changed"
+	value, _ = cache.Get("stacked:key1")
+	assert.Equal(t, domain.ControlVariantID, value.VariantIDs["rule2"])
+}
+
 func TestLRUCache_Eviction(t *testing.T) {
 	cache := NewLRUCache(2)
 
@@ -153,6 +181,215 @@ func TestLRUCache_Invalidate(t *testing.T) {
 	assert.Equal(t, 0, stats.Size)
 }
 
+func TestLRUCache_InvalidateWhere(t *testing.T) {
+	cache := NewLRUCache(10)
+	cache.Set("a", &domain.MatchResult{RuleID: "rule1"})
+	cache.Set("b", &domain.MatchResult{RuleID: "rule2"})
+	cache.Set("c", &domain.MatchResult{RuleID: "rule1"})
+	cache.Get("a")
+	cache.Get("missing")
+
+	removed := cache.InvalidateWhere(func(key string, result *domain.MatchResult) bool {
+		return result.RuleID == "rule1"
+	})
+	assert.Equal(t, 2, removed)
+
+	_, found := cache.Get("a")
+	assert.False(t, found)
+	_, found = cache.Get("b")
+	assert.True(t, found)
+
+	// Counters survive targeted invalidation
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Size)
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, int64(2), stats.Misses)
+	assert.Equal(t, int64(2), stats.Invalidations)
+
+	// The list stays consistent for eviction
+	cache.Set("d", &domain.MatchResult{RuleID: "rule3"})
+	assert.Equal(t, 2, cache.Stats().Size)
+}
+
+// fakeClock replaces the clock of the cache and its negative store
+func fakeClock(cache *LRUCache, now *time.Time) {
+	cache.now = func() time.Time { return *now }
+	if cache.negative != nil {
+		cache.negative.now = cache.now
+	}
+}
+
+func TestLRUCache_TTL(t *testing.T) {
+	now := time.Now()
+	cache := NewLRUCacheWithConfig(LRUConfig{MaxSize: 10, TTL: time.Minute})
+	fakeClock(cache, &now)
+
+	cache.Set("a", &domain.MatchResult{RuleID: "rule1"})
+	now = now.Add(30 * time.Second)
+	cache.Set("b", &domain.MatchResult{RuleID: "rule2"})
+
+	// Reads do not extend the lifetime of an entry
+	_, found := cache.Get("a")
+	assert.True(t, found)
+
+	now = now.Add(30 * time.Second)
+	_, found = cache.Get("a")
+	assert.False(t, found)
+	_, found = cache.Get("b")
+	assert.True(t, found)
+
+	// Setting a key again restarts its lifetime
+	cache.Set("b", &domain.MatchResult{RuleID: "rule2"})
+	now = now.Add(45 * time.Second)
+	_, found = cache.Get("b")
+	assert.True(t, found)
+
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Size)
+	assert.Equal(t, int64(3), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, int64(1), stats.Expirations)
+}
+
+func TestLRUCache_NegativeCache(t *testing.T) {
+	now := time.Now()
+	cache := NewLRUCacheWithConfig(LRUConfig{MaxSize: 10, TTL: time.Hour, NegativeMaxSize: 2, NegativeTTL: time.Minute})
+	fakeClock(cache, &now)
+
+	// No-match results live in the negative store and do not take main cache slots
+	cache.Set("none1", &domain.MatchResult{})
+	cache.Set("match", &domain.MatchResult{RuleID: "rule1"})
+	result, found := cache.Get("none1")
+	require.True(t, found)
+	assert.Empty(t, result.RuleID)
+	assert.True(t, result.CacheHit)
+
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Size)
+	assert.Equal(t, 1, stats.NegativeSize)
+	assert.Equal(t, 2, stats.NegativeMaxSize)
+
+	// The negative store is bounded on its own
+	cache.Set("none2", &domain.MatchResult{})
+	cache.Set("none3", &domain.MatchResult{})
+	_, found = cache.Get("none1")
+	assert.False(t, found)
+	assert.Equal(t, 2, cache.Stats().NegativeSize)
+
+	// A key moves between the stores when its result changes
+	cache.Set("none2", &domain.MatchResult{RuleID: "rule2"})
+	cache.Set("match", &domain.MatchResult{})
+	stats = cache.Stats()
+	assert.Equal(t, 1, stats.Size)
+	assert.Equal(t, 2, stats.NegativeSize)
+
+	// Negative entries expire after their own TTL
+	now = now.Add(time.Minute)
+	_, found = cache.Get("none3")
+	assert.False(t, found)
+	_, found = cache.Get("none2")
+	assert.True(t, found)
+
+	stats = cache.Stats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(2), stats.Misses)
+	assert.Equal(t, int64(1), stats.NegativeHits)
+	assert.Equal(t, int64(2), stats.NegativeMisses)
+	assert.Equal(t, int64(1), stats.Expirations)
+
+	// Invalidation and Clear cover both stores
+	cache.Set("none4", &domain.MatchResult{})
+	assert.Equal(t, 1, cache.InvalidateWhere(func(key string, _ *domain.MatchResult) bool { return key == "none4" }))
+	cache.Invalidate("match")
+	assert.Equal(t, 0, cache.Stats().NegativeSize)
+
+	cache.Set("none5", &domain.MatchResult{})
+	cache.Clear()
+	stats = cache.Stats()
+	assert.Equal(t, 0, stats.Size)
+	assert.Equal(t, 0, stats.NegativeSize)
+	assert.Equal(t, int64(0), stats.NegativeHits)
+}
+
+func TestLRUCache_NegativeHitStats(t *testing.T) {
+	cache := NewLRUCacheWithConfig(LRUConfig{MaxSize: 10, NegativeMaxSize: 10})
+	cache.Set("match", &domain.MatchResult{RuleID: "rule1"})
+	cache.Set("none", &domain.MatchResult{})
+
+	_, found := cache.Get("match")
+	require.True(t, found)
+	_, found = cache.Get("none")
+	require.True(t, found)
+
+	// The negative hit is only counted by the negative counters
+	stats := cache.Stats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(0), stats.Misses)
+	assert.Equal(t, 1.0, stats.HitRatio)
+	assert.Equal(t, int64(1), stats.NegativeHits)
+	assert.Equal(t, int64(0), stats.NegativeMisses)
+}
+
+func TestLRUCache_NegativeCacheDisabled(t *testing.T) {
+	cache := NewLRUCache(10)
+	cache.Set("none", &domain.MatchResult{})
+
+	_, found := cache.Get("none")
+	assert.False(t, found)
+	stats := cache.Stats()
+	assert.Equal(t, 0, stats.Size)
+	assert.Equal(t, int64(0), stats.NegativeMisses)
+}
+
+func TestLRUCache_MaxBytes(t *testing.T) {
+	css := strings.Repeat("a", 1000)
+	entry := entrySize("k1", &domain.MatchResult{RuleID: "rule1", CSS: css})
+	cache := NewLRUCacheWithConfig(LRUConfig{MaxSize: 100, MaxBytes: 3 * entry})
+
+	for _, key := range []string{"k1", "k2", "k3"} {
+		cache.Set(key, &domain.MatchResult{RuleID: "rule1", CSS: css})
+	}
+	stats := cache.Stats()
+	assert.Equal(t, 3, stats.Size)
+	assert.Equal(t, 3*entry, stats.Bytes)
+	assert.Equal(t, 3*entry, stats.MaxBytes)
+
+	// A fourth entry evicts the least recently used one to stay within the budget
+	cache.Get("k1")
+	cache.Set("k4", &domain.MatchResult{RuleID: "rule1", CSS: css})
+	_, found := cache.Get("k2")
+	assert.False(t, found)
+	assert.LessOrEqual(t, cache.Stats().Bytes, 3*entry)
+
+	// Growing an entry evicts others; shrinking it releases its bytes
+	cache.Set("k1", &domain.MatchResult{RuleID: "rule1", CSS: css + css})
+	stats = cache.Stats()
+	assert.Equal(t, 2, stats.Size)
+	assert.LessOrEqual(t, stats.Bytes, 3*entry)
+	cache.Set("k1", &domain.MatchResult{RuleID: "rule1"})
+	assert.Less(t, cache.Stats().Bytes, 2*entry)
+
+	// An entry larger than the whole budget is not stored
+	cache.Set("huge", &domain.MatchResult{RuleID: "rule1", CSS: strings.Repeat(css, 4)})
+	_, found = cache.Get("huge")
+	assert.False(t, found)
+
+	cache.Invalidate("k1")
+	cache.Clear()
+	assert.Equal(t, int64(0), cache.Stats().Bytes)
+}
+
+func TestLRUCache_HealthCheckBytePressure(t *testing.T) {
+	cache := NewLRUCacheWithConfig(LRUConfig{MaxSize: 100, MaxBytes: 4096})
+	cache.Set("k1", &domain.MatchResult{RuleID: "rule1", CSS: strings.Repeat("a", 3700)})
+
+	health := cache.HealthCheck(context.Background())
+	assert.Equal(t, "degraded", health.Status)
+	assert.Equal(t, "Cache is near its memory budget", health.Message)
+	assert.Contains(t, health.Details, "bytes_warning")
+	assert.NotContains(t, health.Details, "warning")
+}
+
 func TestLRUCache_Clear(t *testing.T) {
 	cache := NewLRUCache(2)
 
@@ -0,0 +1,89 @@
+package cache
+
+// Eviction policies selectable with LRUConfig.Policy
+const (
+	PolicyLRU     = "lru"     // Evict the least recently used entry
+	PolicyTinyLFU = "tinylfu" // W-TinyLFU: admit entries into the main space by estimated frequency
+	PolicyARC     = "arc"     // Adaptive Replacement Cache: balance recency and frequency
+)
+
+// evictionPolicy decides which entries a store evicts when it is over its limits. The
+// store keeps its own recency list, which decides eviction when no policy is set.
+// Nodes a policy returns for eviction are already unlinked from its queues. Methods
+// are called with the store's mutex held.
+type evictionPolicy interface {
+	// hit records an access to a stored entry
+	hit(n *node)
+	// insert records a new entry and returns the entries to evict to stay within
+	// capacity, which may include the new entry itself
+	insert(n *node) []*node
+	// remove forgets an entry removed by invalidation or expiry
+	remove(n *node)
+	// victim returns the entry to evict next, or nil when no entry is tracked
+	victim() *node
+	// reset forgets all entries
+	reset()
+}
+
+// newPolicy returns the policy for a store of the given capacity; nil means plain LRU
+func newPolicy(name string, capacity int) evictionPolicy {
+	switch name {
+	case PolicyTinyLFU:
+		return newTinyLFUPolicy(capacity)
+	case PolicyARC:
+		return newARCPolicy(capacity)
+	default:
+		return nil
+	}
+}
+
+// nodeQueue is a doubly-linked list of nodes using their policy links. The front holds
+// the most recently inserted or accessed node.
+type nodeQueue struct {
+	root node
+	len  int
+}
+
+// init empties the queue
+func (q *nodeQueue) init() {
+	q.root.qnext = &q.root
+	q.root.qprev = &q.root
+	q.len = 0
+}
+
+// pushFront adds a node to the front of the queue and tags it with the queue's ID
+func (q *nodeQueue) pushFront(n *node, id uint8) {
+	n.queue = id
+	n.qprev = &q.root
+	n.qnext = q.root.qnext
+	q.root.qnext.qprev = n
+	q.root.qnext = n
+	q.len++
+}
+
+// remove unlinks a node from the queue
+func (q *nodeQueue) remove(n *node) {
+	n.qprev.qnext = n.qnext
+	n.qnext.qprev = n.qprev
+	n.qprev = nil
+	n.qnext = nil
+	n.queue = 0
+	q.len--
+}
+
+// back returns the least recently used node, or nil when the queue is empty
+func (q *nodeQueue) back() *node {
+	if q.len == 0 {
+		return nil
+	}
+	return q.root.qprev
+}
+
+// popBack unlinks and returns the least recently used node, or nil when the queue is empty
+func (q *nodeQueue) popBack() *node {
+	n := q.back()
+	if n != nil {
+		q.remove(n)
+	}
+	return n
+}
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:generate go run ./testdata/gentrace -o testdata/trace.txt.gz
+
+// loadTrace reads the synthetic URL trace in testdata, written by testdata/gentrace:
+// requests to a few hot documentation sites with a long tail of one-off article URLs,
+// interrupted by crawler bursts of unique product URLs
+func loadTrace(t *testing.T) []string {
+	t.Helper()
+	file, err := os.Open("testdata/trace.txt.gz")
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+
+	var urls []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		urls = append(urls, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	return urls
+}
+
+// replayHitRatio resolves every URL through the cache, storing it on a miss
+func replayHitRatio(cache domain.CacheManager, urls []string) float64 {
+	result := &domain.MatchResult{RuleID: "rule"}
+	for _, url := range urls {
+		if _, found := cache.Get(url); !found {
+			cache.Set(url, result)
+		}
+	}
+	return cache.Stats().HitRatio
+}
+
+func TestPolicies_TraceHitRatio(t *testing.T) {
+	urls := loadTrace(t)
+	require.NotEmpty(t, urls)
+
+	ratios := make(map[string]float64)
+	for _, policy := range []string{PolicyLRU, PolicyTinyLFU, PolicyARC} {
+		cache := NewLRUCacheWithConfig(LRUConfig{MaxSize: 300, Policy: policy})
+		ratios[policy] = replayHitRatio(cache, urls)
+		assert.LessOrEqual(t, cache.Stats().Size, 300)
+		t.Logf("%s hit ratio: %.3f", policy, ratios[policy])
+	}
+
+	// Crawler bursts flush the hot set from LRU but not from the frequency-aware policies
+	assert.Greater(t, ratios[PolicyTinyLFU], ratios[PolicyLRU]+0.03)
+	assert.Greater(t, ratios[PolicyARC], ratios[PolicyLRU]+0.03)
+
+	sharded := NewShardedCache(4, LRUConfig{MaxSize: 300, Policy: PolicyTinyLFU})
+	assert.Greater(t, replayHitRatio(sharded, urls), ratios[PolicyLRU])
+	assert.Equal(t, PolicyTinyLFU, sharded.Stats().Policy)
+}
+
+func TestPolicies_ScanResistance(t *testing.T) {
+	for _, policy := range []string{PolicyTinyLFU, PolicyARC} {
+		t.Run(policy, func(t *testing.T) {
+			cache := NewLRUCacheWithConfig(LRUConfig{MaxSize: 100, Policy: policy})
+			hot := make([]string, 50)
+			for i := range hot {
+				hot[i] = fmt.Sprintf("hot%d", i)
+			}
+			for range 3 {
+				replayHitRatio(cache, hot)
+			}
+
+			// A scan of one-off keys larger than the cache keeps most of the hot set
+			scan := make([]string, 500)
+			for i := range scan {
+				scan[i] = fmt.Sprintf("scan%d", i)
+			}
+			replayHitRatio(cache, scan)
+
+			kept := 0
+			for _, key := range hot {
+				if _, found := cache.Get(key); found {
+					kept++
+				}
+			}
+			assert.GreaterOrEqual(t, kept, 40)
+			assert.LessOrEqual(t, cache.Stats().Size, 100)
+		})
+	}
+}
+
+func TestPolicies_KeepLimitsAndAccounting(t *testing.T) {
+	for _, policy := range []string{PolicyLRU, PolicyTinyLFU, PolicyARC} {
+		t.Run(policy, func(t *testing.T) {
+			entry := entrySize("key00", &domain.MatchResult{RuleID: "rule"})
+			cache := NewLRUCacheWithConfig(LRUConfig{MaxSize: 20, MaxBytes: 10 * entry, Policy: policy})
+			for i := range 200 {
+				key := fmt.Sprintf("key%02d", i%40)
+				cache.Set(key, &domain.MatchResult{RuleID: "rule"})
+				cache.Get(fmt.Sprintf("key%02d", (i*7)%40))
+				if i%25 == 0 {
+					cache.Invalidate(key)
+				}
+			}
+
+			stats := cache.Stats()
+			assert.Equal(t, policy, stats.Policy)
+			assert.LessOrEqual(t, stats.Size, 10)
+			assert.Equal(t, len(cache.cache), stats.Size)
+			assert.Equal(t, int64(stats.Size)*entry, stats.Bytes)
+
+			cache.InvalidateWhere(func(string, *domain.MatchResult) bool { return true })
+			assert.Equal(t, int64(0), cache.Stats().Bytes)
+			cache.Set("again", &domain.MatchResult{RuleID: "rule"})
+			_, found := cache.Get("again")
+			assert.True(t, found)
+
+			cache.Clear()
+			assert.Equal(t, 0, cache.Stats().Size)
+		})
+	}
+}
+
+func TestCountMinSketch(t *testing.T) {
+	sketch := newCountMinSketch(100)
+	for range 5 {
+		sketch.increment("hot")
+	}
+	sketch.increment("cold")
+	assert.GreaterOrEqual(t, sketch.estimate("hot"), uint8(5))
+	assert.Less(t, sketch.estimate("cold"), sketch.estimate("hot"))
+
+	// Counters saturate at 15 and are halved once the sample size is reached
+	for range 20 {
+		sketch.increment("hot")
+	}
+	assert.Equal(t, uint8(15), sketch.estimate("hot"))
+	for i := range sketch.sampleSize {
+		sketch.increment(fmt.Sprintf("other%d", i))
+	}
+	assert.Less(t, sketch.estimate("hot"), uint8(15))
+
+	sketch.reset()
+	assert.Equal(t, uint8(0), sketch.estimate("hot"))
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"hash/maphash"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// ShardedCache implements the CacheManager interface by spreading keys over
+// independent LRU caches, so concurrent lookups of different keys rarely wait on the
+// same lock. Recency, limits and expiry are tracked per shard, which makes eviction
+// approximately rather than strictly least recently used.
+type ShardedCache struct {
+	seed   maphash.Seed
+	shards []*LRUCache
+}
+
+// NewShardedCache creates a cache of the given number of shards. The limits of the
+// configuration apply to the cache as a whole and are divided evenly among the shards.
+func NewShardedCache(shards int, config LRUConfig) *ShardedCache {
+	if shards < 1 {
+		shards = 1
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = 10000 // Default size from requirements
+	}
+
+	shardConfig := config
+	shardConfig.MaxSize = int(divideLimit(int64(config.MaxSize), shards))
+	shardConfig.MaxBytes = divideLimit(config.MaxBytes, shards)
+	shardConfig.NegativeMaxSize = int(divideLimit(int64(config.NegativeMaxSize), shards))
+
+	c := &ShardedCache{
+		seed:   maphash.MakeSeed(),
+		shards: make([]*LRUCache, shards),
+	}
+	for i := range c.shards {
+		c.shards[i] = NewLRUCacheWithConfig(shardConfig)
+	}
+	return c
+}
+
+// divideLimit splits a limit among shards, rounding up; zero stays zero
+func divideLimit(limit int64, shards int) int64 {
+	if limit <= 0 {
+		return 0
+	}
+	return (limit + int64(shards) - 1) / int64(shards)
+}
+
+// shard returns the shard a key belongs to
+func (c *ShardedCache) shard(key string) *LRUCache {
+	return c.shards[maphash.String(c.seed, key)%uint64(len(c.shards))]
+}
+
+// Get retrieves a value from the key's shard and marks it as recently used
+func (c *ShardedCache) Get(key string) (*domain.MatchResult, bool) {
+	return c.shard(key).Get(key)
+}
+
+// Set adds or updates a value in the key's shard
+func (c *ShardedCache) Set(key string, result *domain.MatchResult) {
+	c.shard(key).Set(key, result)
+}
+
+// Invalidate removes a specific key from the cache
+func (c *ShardedCache) Invalidate(key string) {
+	c.shard(key).Invalidate(key)
+}
+
+// InvalidateWhere removes every entry match returns true for, one shard at a time
+func (c *ShardedCache) InvalidateWhere(match func(key string, result *domain.MatchResult) bool) int {
+	removed := 0
+	for _, shard := range c.shards {
+		removed += shard.InvalidateWhere(match)
+	}
+	return removed
+}
+
+// Clear removes all entries from every shard
+func (c *ShardedCache) Clear() {
+	for _, shard := range c.shards {
+		shard.Clear()
+	}
+}
+
+// HotKeys returns up to limit keys, taking the most recently used keys of each shard in turn
+func (c *ShardedCache) HotKeys(limit int) []string {
+	perShard := make([][]string, len(c.shards))
+	for i, shard := range c.shards {
+		perShard[i] = shard.HotKeys(limit)
+	}
+
+	keys := make([]string, 0, limit)
+	for rank := 0; len(keys) < limit; rank++ {
+		added := false
+		for _, shardKeys := range perShard {
+			if rank < len(shardKeys) && len(keys) < limit {
+				keys = append(keys, shardKeys[rank])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return keys
+}
+
+// Stats returns the statistics of all shards combined
+func (c *ShardedCache) Stats() domain.CacheStats {
+	var total domain.CacheStats
+	for _, shard := range c.shards {
+		stats := shard.Stats()
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.Size += stats.Size
+		total.MaxSize += stats.MaxSize
+		total.Bytes += stats.Bytes
+		total.MaxBytes += stats.MaxBytes
+		total.Invalidations += stats.Invalidations
+		total.Expirations += stats.Expirations
+		total.NegativeHits += stats.NegativeHits
+		total.NegativeMisses += stats.NegativeMisses
+		total.NegativeSize += stats.NegativeSize
+		total.NegativeMaxSize += stats.NegativeMaxSize
+		total.NegativeBytes += stats.NegativeBytes
+		total.Policy = stats.Policy
+	}
+	if requests := total.Hits + total.Misses; requests > 0 {
+		total.HitRatio = float64(total.Hits) / float64(requests)
+	}
+	return total
+}
+
+// HealthCheck performs a health check on the combined statistics
+func (c *ShardedCache) HealthCheck(ctx context.Context) domain.HealthStatus {
+	health := statsHealth(c.Stats(), time.Now())
+	health.Details["shards"] = len(c.shards)
+	return health
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewShardedCache(t *testing.T) {
+	cache := NewShardedCache(8, LRUConfig{MaxSize: 100, MaxBytes: 1000, NegativeMaxSize: 10})
+	require.Len(t, cache.shards, 8)
+
+	// Limits are divided among the shards, rounding up
+	for _, shard := range cache.shards {
+		assert.Equal(t, 13, shard.maxSize)
+		assert.Equal(t, int64(125), shard.maxBytes)
+		assert.Equal(t, 2, shard.negative.maxSize)
+	}
+	stats := cache.Stats()
+	assert.Equal(t, 104, stats.MaxSize)
+	assert.Equal(t, int64(1000), stats.MaxBytes)
+	assert.Equal(t, 16, stats.NegativeMaxSize)
+
+	assert.Len(t, NewShardedCache(0, LRUConfig{}).shards, 1)
+}
+
+func TestShardedCache_Operations(t *testing.T) {
+	cache := NewShardedCache(4, LRUConfig{MaxSize: 1000, NegativeMaxSize: 100})
+	for i := range 20 {
+		cache.Set(fmt.Sprintf("key%d", i), &domain.MatchResult{RuleID: fmt.Sprintf("rule%d", i%2)})
+	}
+	cache.Set("none", &domain.MatchResult{})
+
+	result, found := cache.Get("key3")
+	require.True(t, found)
+	assert.Equal(t, "rule1", result.RuleID)
+	assert.True(t, result.CacheHit)
+	_, found = cache.Get("none")
+	assert.True(t, found)
+	_, found = cache.Get("missing")
+	assert.False(t, found)
+
+	cache.Invalidate("key3")
+	_, found = cache.Get("key3")
+	assert.False(t, found)
+
+	removed := cache.InvalidateWhere(func(_ string, result *domain.MatchResult) bool {
+		return result.RuleID == "rule0"
+	})
+	assert.Equal(t, 10, removed)
+
+	// Statistics are combined over all shards
+	stats := cache.Stats()
+	assert.Equal(t, 9, stats.Size)
+	assert.Equal(t, 1, stats.NegativeSize)
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(2), stats.Misses)
+	assert.Equal(t, int64(1), stats.NegativeHits)
+	assert.Equal(t, int64(10), stats.Invalidations)
+	assert.InDelta(t, 1.0/3, stats.HitRatio, 0.001)
+
+	health := cache.HealthCheck(context.Background())
+	assert.Equal(t, "healthy", health.Status)
+	assert.Equal(t, 4, health.Details["shards"])
+	assert.Equal(t, 9, health.Details["size"])
+
+	cache.Clear()
+	stats = cache.Stats()
+	assert.Equal(t, 0, stats.Size)
+	assert.Equal(t, int64(0), stats.Hits)
+}
+
+func TestShardedCache_ConcurrentAccess(t *testing.T) {
+	cache := NewShardedCache(8, LRUConfig{MaxSize: 200})
+
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				key := fmt.Sprintf("key%d", (worker*500+i)%300)
+				cache.Set(key, &domain.MatchResult{RuleID: "rule"})
+				cache.Get(key)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := cache.Stats()
+	assert.LessOrEqual(t, stats.Size, stats.MaxSize)
+	assert.Equal(t, int64(4000), stats.Hits+stats.Misses)
+}
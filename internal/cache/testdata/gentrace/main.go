@@ -0,0 +1,103 @@
+// Command gentrace writes the synthetic URL trace replayed by TestPolicies_TraceHitRatio.
+// The trace is deterministic for a seed, so it can be checked and regenerated:
+//
+//	go generate ./internal/cache
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sort"
+)
+
+var (
+	sites = []string{"docs.example.com", "developer.example.org", "help.example.net", "wiki.example.io", "learn.example.dev"}
+	pages = []string{"intro", "setup", "api", "faq", "reference", "tutorial"}
+)
+
+const (
+	pagesPerSite     = 80   // Hot documentation pages per site
+	zipfExponent     = 0.9  // Popularity of the hot page with rank r is 1/(r+1)^s
+	blocks           = 12   // Each block is regular traffic followed by a crawler burst
+	blockRequests    = 2500 // Regular requests per block
+	oneOffShare      = 0.15 // Share of regular requests for one-off news articles
+	newsSites        = 400
+	burstRequests    = 800  // Unique product URLs per crawler burst
+	productsPerBurst = 1000 // Product ID range of each burst
+)
+
+func main() {
+	output := flag.String("o", "trace.txt.gz", "output file")
+	seed := flag.Uint64("seed", 20261016, "random seed")
+	flag.Parse()
+
+	if err := write(*output, generate(*seed)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the requested URLs in order
+func generate(seed uint64) []string {
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	var hot []string
+	for _, site := range sites {
+		for i := range pagesPerSite {
+			hot = append(hot, fmt.Sprintf("https://%s/guide/%s-%d", site, pages[rng.IntN(len(pages))], i))
+		}
+	}
+	cumulative := make([]float64, len(hot))
+	total := 0.0
+	for rank := range hot {
+		total += 1 / math.Pow(float64(rank+1), zipfExponent)
+		cumulative[rank] = total
+	}
+
+	var urls []string
+	articles := 0
+	for block := range blocks {
+		for range blockRequests {
+			if rng.Float64() < oneOffShare {
+				articles++
+				urls = append(urls, fmt.Sprintf("https://news%d.example.com/article/%d", rng.IntN(newsSites)+1, articles))
+				continue
+			}
+			rank := sort.SearchFloat64s(cumulative, rng.Float64()*total)
+			urls = append(urls, hot[min(rank, len(hot)-1)])
+		}
+		for i := range burstRequests {
+			urls = append(urls, fmt.Sprintf("https://shop%d.example.com/product/%d?ref=crawl", block, block*productsPerBurst+i))
+		}
+	}
+	return urls
+}
+
+// write stores the URLs one per line, gzip compressed
+func write(path string, urls []string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	compressed := gzip.NewWriter(file)
+	buffered := bufio.NewWriter(compressed)
+	for _, url := range urls {
+		if _, err := fmt.Fprintln(buffered, url); err != nil {
+			return err
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
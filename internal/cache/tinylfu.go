@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+// W-TinyLFU queue IDs
+const (
+	queueWindow uint8 = iota + 1
+	queueProbation
+	queueProtected
+)
+
+// tinyLFUPolicy implements W-TinyLFU. New entries enter a small LRU window; an entry
+// leaving the window only replaces the main space's eviction candidate if it has been
+// requested more often, as estimated by a count-min sketch. The main space is a
+// segmented LRU whose protected segment holds entries hit again after admission, so a
+// burst of one-off keys cannot flush frequently requested ones.
+type tinyLFUPolicy struct {
+	sketch    *countMinSketch
+	window    nodeQueue
+	probation nodeQueue
+	protected nodeQueue
+
+	windowCap    int
+	mainCap      int
+	protectedCap int
+}
+
+// newTinyLFUPolicy creates a policy with a 1% window and a protected segment of 80% of
+// the main space
+func newTinyLFUPolicy(capacity int) *tinyLFUPolicy {
+	windowCap := max(1, capacity/100)
+	mainCap := max(1, capacity-windowCap)
+	p := &tinyLFUPolicy{
+		sketch:       newCountMinSketch(capacity),
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: max(1, mainCap*8/10),
+	}
+	p.reset()
+	return p
+}
+
+// queue returns the queue a node is linked into
+func (p *tinyLFUPolicy) queue(n *node) *nodeQueue {
+	switch n.queue {
+	case queueWindow:
+		return &p.window
+	case queueProbation:
+		return &p.probation
+	default:
+		return &p.protected
+	}
+}
+
+func (p *tinyLFUPolicy) hit(n *node) {
+	p.sketch.increment(n.key)
+	switch n.queue {
+	case queueWindow:
+		p.window.remove(n)
+		p.window.pushFront(n, queueWindow)
+	case queueProbation:
+		// A second access promotes the entry, demoting the oldest protected one if full
+		p.probation.remove(n)
+		p.protected.pushFront(n, queueProtected)
+		if p.protected.len > p.protectedCap {
+			p.probation.pushFront(p.protected.popBack(), queueProbation)
+		}
+	case queueProtected:
+		p.protected.remove(n)
+		p.protected.pushFront(n, queueProtected)
+	}
+}
+
+func (p *tinyLFUPolicy) insert(n *node) []*node {
+	p.sketch.increment(n.key)
+	p.window.pushFront(n, queueWindow)
+	if p.window.len <= p.windowCap {
+		return nil
+	}
+
+	// The entry leaving the window competes with the main space's eviction candidate
+	candidate := p.window.popBack()
+	if p.probation.len+p.protected.len < p.mainCap {
+		p.probation.pushFront(candidate, queueProbation)
+		return nil
+	}
+	victim := p.probation.back()
+	if victim == nil {
+		victim = p.protected.back()
+	}
+	if p.sketch.estimate(candidate.key) <= p.sketch.estimate(victim.key) {
+		return []*node{candidate}
+	}
+	p.queue(victim).remove(victim)
+	p.probation.pushFront(candidate, queueProbation)
+	return []*node{victim}
+}
+
+func (p *tinyLFUPolicy) remove(n *node) {
+	p.queue(n).remove(n)
+}
+
+func (p *tinyLFUPolicy) victim() *node {
+	for _, q := range []*nodeQueue{&p.probation, &p.window, &p.protected} {
+		if n := q.popBack(); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func (p *tinyLFUPolicy) reset() {
+	p.window.init()
+	p.probation.init()
+	p.protected.init()
+	p.sketch.reset()
+}
+
+// sketchDepth is the number of independent counter rows of a count-min sketch
+const sketchDepth = 4
+
+// countMinSketch estimates how often keys were seen with 4-bit saturating counters.
+// Counters are halved once the number of increments reaches ten times the capacity,
+// so the estimates favour recent popularity.
+type countMinSketch struct {
+	seed       maphash.Seed
+	counters   []uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+// newCountMinSketch creates a sketch sized for a cache of the given capacity
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := uint64(1) << bits.Len(uint(max(capacity, 16)-1))
+	return &countMinSketch{
+		seed:       maphash.MakeSeed(),
+		counters:   make([]uint8, sketchDepth*width),
+		mask:       width - 1,
+		sampleSize: 10 * max(capacity, 1),
+	}
+}
+
+// index returns the position of a key's counter in a row
+func (s *countMinSketch) index(hash uint64, row int) int {
+	step := hash>>32 | 1
+	return row*int(s.mask+1) + int((hash+uint64(row)*step)&s.mask)
+}
+
+// increment counts one occurrence of a key
+func (s *countMinSketch) increment(key string) {
+	hash := maphash.String(s.seed, key)
+	for row := range sketchDepth {
+		if i := s.index(hash, row); s.counters[i] < 15 {
+			s.counters[i]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sampleSize {
+		for i := range s.counters {
+			s.counters[i] >>= 1
+		}
+		s.additions /= 2
+	}
+}
+
+// estimate returns the estimated number of occurrences of a key
+func (s *countMinSketch) estimate(key string) uint8 {
+	hash := maphash.String(s.seed, key)
+	count := uint8(15)
+	for row := range sketchDepth {
+		count = min(count, s.counters[s.index(hash, row)])
+	}
+	return count
+}
+
+// reset zeroes every counter
+func (s *countMinSketch) reset() {
+	clear(s.counters)
+	s.additions = 0
+}
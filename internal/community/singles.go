@@ -343,7 +343,9 @@ func validateRuleContent(body []byte) error {
 	if rule.Type == "" {
 		return fmt.Errorf("rule missing required field: type")
 	}
-	if rule.Type != "exact" && rule.Type != "wildcard" && rule.Type != "regex" {
+	switch rule.Type {
+	case "exact", "wildcard", "regex", "host", "path_prefix", "urlpattern":
+	default:
 		return fmt.Errorf("invalid rule type: %s", rule.Type)
 	}
 	if rule.CSS == "" && rule.JS == "" {
@@ -23,10 +23,41 @@ type Config struct {
 	Cache struct {
 		MaxSize int           `env:"CACHE_MAX_SIZE" envDefault:"10000" validate:"min=100"`
 		TTL     time.Duration `env:"CACHE_TTL" envDefault:"1h"`
+
+		// Independent LRU shards keyed by URL hash; 1 uses a single LRU list
+		Shards int `env:"CACHE_SHARDS" envDefault:"1" validate:"min=1,max=256"`
+
+		// Eviction policy of every shard and of the negative cache
+		Policy string `env:"CACHE_POLICY" envDefault:"lru" validate:"oneof=lru tinylfu arc"`
+
+		// Hottest keys saved to DATA_DIR on shutdown and re-resolved on startup; 0 disables
+		WarmupKeys int `env:"CACHE_WARMUP_KEYS" envDefault:"1000" validate:"min=0"`
+
+		// Memory budget for cached assets in bytes; 0 only bounds the entry count
+		MaxBytes int64 `env:"CACHE_MAX_BYTES" envDefault:"268435456" validate:"min=0"`
+
+		// Negative cache for URLs no rule matches; a size of 0 disables it
+		NegativeMaxSize int           `env:"CACHE_NEGATIVE_MAX_SIZE" envDefault:"10000" validate:"min=0"`
+		NegativeTTL     time.Duration `env:"CACHE_NEGATIVE_TTL" envDefault:"5m"`
+	}
+
+	Normalization struct {
+		Enabled        bool     `env:"URL_NORMALIZE" envDefault:"true"`
+		TrackingParams []string `env:"URL_TRACKING_PARAMS" envSeparator:"," envDefault:"utm_*,gclid,dclid,fbclid,msclkid,mc_cid,mc_eid,_ga"`
+		TrailingSlash  string   `env:"URL_TRAILING_SLASH" envDefault:"keep" validate:"oneof=keep strip add"`
+	}
+
+	Batch struct {
+		MaxURLs     int           `env:"BATCH_MAX_URLS" envDefault:"100" validate:"min=1,max=1000"`
+		Timeout     time.Duration `env:"BATCH_TIMEOUT" envDefault:"3s"`
+		Concurrency int           `env:"BATCH_CONCURRENCY" envDefault:"8" validate:"min=1,max=256"`
 	}
 
 	Storage struct {
 		DataDir string `env:"DATA_DIR" envDefault:"./data"`
+
+		// Rule repository: YAML rule files, or an embedded database in DATA_DIR
+		Backend string `env:"STORAGE_BACKEND" envDefault:"files" validate:"oneof=files bolt"`
 	}
 
 	Security struct {
@@ -130,11 +161,22 @@ func validateCustomRules(cfg *Config) error {
 	if cfg.Cache.TTL < time.Second {
 		return fmt.Errorf("cache TTL must be at least 1 second")
 	}
+	if cfg.Cache.NegativeMaxSize > 0 && cfg.Cache.NegativeTTL < time.Second {
+		return fmt.Errorf("negative cache TTL must be at least 1 second")
+	}
+	if cfg.Batch.Timeout < time.Millisecond {
+		return fmt.Errorf("batch timeout must be at least 1ms")
+	}
 
 	if err := validateCommunityConfig(&cfg.Community); err != nil {
 		return err
 	}
 
+	// Packs and singles are rule files, which the database backend does not read
+	if cfg.Storage.Backend == "bolt" && (cfg.Community.AutoUpdate || cfg.Community.SinglesSyncEnabled) {
+		return fmt.Errorf("AUTO_UPDATE_PACKS and SINGLES_SYNC_ENABLED require STORAGE_BACKEND=files")
+	}
+
 	return nil
 }
 
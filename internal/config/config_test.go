@@ -23,7 +23,20 @@ func TestLoad_DefaultValues(t *testing.T) {
 	assert.Equal(t, 1048576, cfg.Server.BodyLimit)
 	assert.Equal(t, 10000, cfg.Cache.MaxSize)
 	assert.Equal(t, time.Hour, cfg.Cache.TTL)
+	assert.Equal(t, int64(256<<20), cfg.Cache.MaxBytes)
+	assert.Equal(t, 1, cfg.Cache.Shards)
+	assert.Equal(t, "lru", cfg.Cache.Policy)
+	assert.Equal(t, 1000, cfg.Cache.WarmupKeys)
+	assert.Equal(t, 10000, cfg.Cache.NegativeMaxSize)
+	assert.Equal(t, 5*time.Minute, cfg.Cache.NegativeTTL)
+	assert.True(t, cfg.Normalization.Enabled)
+	assert.Contains(t, cfg.Normalization.TrackingParams, "utm_*")
+	assert.Equal(t, "keep", cfg.Normalization.TrailingSlash)
+	assert.Equal(t, 100, cfg.Batch.MaxURLs)
+	assert.Equal(t, 3*time.Second, cfg.Batch.Timeout)
+	assert.Equal(t, 8, cfg.Batch.Concurrency)
 	assert.Equal(t, "./data", cfg.Storage.DataDir)
+	assert.Equal(t, "files", cfg.Storage.Backend)
 	assert.Empty(t, cfg.Security.CORSOrigins)
 	assert.False(t, cfg.Security.EnableHTTPS)
 	assert.Equal(t, "info", cfg.Logging.Level)
@@ -107,6 +120,58 @@ func TestValidate_InvalidCacheSize(t *testing.T) {
 	assert.Contains(t, err.Error(), "MaxSize must be at least 100")
 }
 
+func TestValidate_NegativeCacheTTL(t *testing.T) {
+	cfg := createValidConfig(t.TempDir())
+	cfg.Cache.NegativeMaxSize = 1000
+	cfg.Cache.NegativeTTL = 0
+
+	err := Validate(cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "negative cache TTL must be at least 1 second")
+
+	// A disabled negative cache needs no TTL
+	cfg.Cache.NegativeMaxSize = 0
+	assert.NoError(t, Validate(cfg))
+}
+
+func TestValidate_InvalidCachePolicy(t *testing.T) {
+	cfg := createValidConfig(t.TempDir())
+	cfg.Cache.Policy = "lfu"
+
+	err := Validate(cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Policy must be one of: lru tinylfu arc")
+}
+
+func TestValidate_InvalidStorageBackend(t *testing.T) {
+	cfg := createValidConfig(t.TempDir())
+	cfg.Storage.Backend = "sqlite"
+
+	err := Validate(cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Backend must be one of: files bolt")
+
+	cfg.Storage.Backend = "bolt"
+	assert.NoError(t, Validate(cfg))
+}
+
+func TestValidate_BoltBackendRejectsRuleFileSync(t *testing.T) {
+	cfg := createValidConfig(t.TempDir())
+	cfg.Storage.Backend = "bolt"
+	cfg.Community.AutoUpdate = true
+
+	err := Validate(cfg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "require STORAGE_BACKEND=files")
+
+	cfg.Community.AutoUpdate = false
+	cfg.Community.SinglesSyncEnabled = true
+	assert.Error(t, Validate(cfg))
+
+	cfg.Storage.Backend = "files"
+	assert.NoError(t, Validate(cfg))
+}
+
 func TestValidate_InvalidLogLevel(t *testing.T) {
 	cfg := &Config{}
 	cfg.Server.Port = 8080
@@ -227,8 +292,11 @@ func TestEnsureDirectories(t *testing.T) {
 func clearEnvVars() {
 	envVars := []string{
 		"PORT", "READ_TIMEOUT", "WRITE_TIMEOUT", "BODY_LIMIT",
-		"CACHE_MAX_SIZE", "CACHE_TTL",
-		"DATA_DIR",
+		"CACHE_MAX_SIZE", "CACHE_TTL", "CACHE_MAX_BYTES", "CACHE_SHARDS", "CACHE_POLICY", "CACHE_WARMUP_KEYS",
+		"CACHE_NEGATIVE_MAX_SIZE", "CACHE_NEGATIVE_TTL",
+		"URL_NORMALIZE", "URL_TRACKING_PARAMS", "URL_TRAILING_SLASH",
+		"BATCH_MAX_URLS", "BATCH_TIMEOUT", "BATCH_CONCURRENCY",
+		"DATA_DIR", "STORAGE_BACKEND",
 		"CORS_ORIGINS", "ENABLE_HTTPS",
 		"LOG_LEVEL", "LOG_FORMAT",
 		"RULES_DIR", "LOCAL_RULES_DIR", "COMMUNITY_RULES_DIR", "OVERRIDE_RULES_DIR",
@@ -248,9 +316,17 @@ func createValidConfig(tempDir string) *Config {
 	cfg.Server.WriteTimeout = time.Second
 	cfg.Cache.MaxSize = 1000
 	cfg.Cache.TTL = time.Hour
+	cfg.Cache.Shards = 1
+	cfg.Cache.Policy = "lru"
+	cfg.Normalization.Enabled = true
+	cfg.Normalization.TrailingSlash = "keep"
+	cfg.Batch.MaxURLs = 100
+	cfg.Batch.Timeout = 3 * time.Second
+	cfg.Batch.Concurrency = 8
 	cfg.Logging.Level = "info"
 	cfg.Logging.Format = "json"
 	cfg.Storage.DataDir = tempDir + "/data"
+	cfg.Storage.Backend = "files"
 	cfg.Security.CORSOrigins = []string{"*"}
 	cfg.Security.EnableHTTPS = false
 	cfg.Community.RulesDir = tempDir + "/rules"
@@ -37,6 +37,11 @@ func NewDisabledRulesManager(rulesDir string) *DisabledRulesManager {
 	}
 }
 
+// FilePath returns the path of the .disabled.json file
+func (m *DisabledRulesManager) FilePath() string {
+	return m.filePath
+}
+
 // Load reads the disabled rules from the .disabled.json file
 func (m *DisabledRulesManager) Load() error {
 	m.mu.Lock()
@@ -198,6 +203,17 @@ func (m *DisabledRulesManager) Clear() error {
 	return m.saveUnsafe()
 }
 
+// MergeEntries adds the entries to the disabled rules, keeping their metadata
+func (m *DisabledRulesManager) MergeEntries(entries []DisabledRuleEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entry := range entries {
+		m.disabled[entry.RuleID] = entry
+	}
+	return m.saveUnsafe()
+}
+
 // SetDisabledRules replaces all disabled rules with the given list
 func (m *DisabledRulesManager) SetDisabledRules(ruleIDs []string, reason string) error {
 	m.mu.Lock()
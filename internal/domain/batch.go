@@ -0,0 +1,27 @@
+package domain
+
+// RuleOperationType is the kind of change a bulk rule operation makes
+type RuleOperationType string
+
+const (
+	RuleOpCreate RuleOperationType = "create"
+	RuleOpUpdate RuleOperationType = "update"
+	RuleOpDelete RuleOperationType = "delete"
+)
+
+// RuleOperation is one change of a batch applied by BatchRuleRepository.ApplyRules.
+// Creates and updates carry the full rule; a delete only needs the ID.
+// @Description A create, update or delete in a bulk rules request
+type RuleOperation struct {
+	Op   RuleOperationType `json:"op" enums:"create,update,delete" example:"create"`
+	ID   string            `json:"id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"` // Defaults to the ID of the rule
+	Rule *Rule             `json:"rule,omitempty"`
+}
+
+// RuleID returns the ID of the rule the operation changes
+func (o RuleOperation) RuleID() string {
+	if o.ID == "" && o.Rule != nil {
+		return o.Rule.ID
+	}
+	return o.ID
+}
@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+)
+
+// RevisionAction describes the change a rule revision records
+type RevisionAction string
+
+const (
+	// RevisionCreated records a newly created rule
+	RevisionCreated RevisionAction = "create"
+	// RevisionUpdated records an update of an existing rule
+	RevisionUpdated RevisionAction = "update"
+	// RevisionDeleted records a deletion; the revision holds the deleted rule
+	RevisionDeleted RevisionAction = "delete"
+	// RevisionReverted records a rule restored to an earlier revision
+	RevisionReverted RevisionAction = "revert"
+)
+
+// RuleRevision is one entry of a rule's change history
+// @Description Recorded change of a rule with the full rule body after the change
+type RuleRevision struct {
+	Revision     int            `json:"revision" example:"3"` // Numbered per rule, starting at 1
+	RuleID       string         `json:"rule_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Action       RevisionAction `json:"action" example:"update" enums:"create,update,delete,revert"`
+	Actor        string         `json:"actor,omitempty" example:"jane"`
+	Reason       string         `json:"reason,omitempty" example:"Hide the new consent dialog"`
+	Timestamp    time.Time      `json:"timestamp" example:"2023-01-01T12:00:00Z"`
+	RevertedFrom int            `json:"reverted_from,omitempty" example:"1"` // Revision restored by a revert
+	Rule         Rule           `json:"rule"`
+}
+
+// RuleFieldChange is a field that differs between two versions of a rule
+type RuleFieldChange struct {
+	Field string `json:"field" example:"css"`
+	From  any    `json:"from,omitempty"` // Absent when the field was added
+	To    any    `json:"to,omitempty"`   // Absent when the field was removed
+}
+
+// DiffRules lists the fields that differ between two versions of a rule by their JSON
+// names, sorted by name. A nil rule has no fields. updated_at is not compared since it
+// changes with every revision.
+func DiffRules(from, to *Rule) []RuleFieldChange {
+	before, after := ruleFields(from), ruleFields(to)
+	names := maps.Clone(before)
+	maps.Copy(names, after)
+	fields := slices.Sorted(maps.Keys(names))
+
+	changes := make([]RuleFieldChange, 0)
+	for _, field := range fields {
+		if field == "updated_at" || bytes.Equal(before[field], after[field]) {
+			continue
+		}
+		changes = append(changes, RuleFieldChange{Field: field, From: fieldValue(before[field]), To: fieldValue(after[field])})
+	}
+	return changes
+}
+
+// ruleFields returns the JSON encoding of each field of the rule
+func ruleFields(rule *Rule) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	if rule == nil {
+		return fields
+	}
+	data, err := json.Marshal(rule)
+	if err == nil {
+		_ = json.Unmarshal(data, &fields)
+	}
+	return fields
+}
+
+// fieldValue decodes a JSON field for a diff, or returns nil when it is absent
+func fieldValue(raw json.RawMessage) any {
+	if raw == nil {
+		return nil
+	}
+	var value any
+	_ = json.Unmarshal(raw, &value)
+	return value
+}
+
+// ChangeInfo describes who made a rule change and why, for the rule history
+type ChangeInfo struct {
+	Actor  string
+	Reason string
+	// Revision a revert restores; zero for other changes
+	RevertedFrom int
+}
+
+type changeInfoKey struct{}
+
+// WithChangeInfo returns a context carrying the change info for repository writes
+func WithChangeInfo(ctx context.Context, info ChangeInfo) context.Context {
+	return context.WithValue(ctx, changeInfoKey{}, info)
+}
+
+// ChangeInfoFromContext returns the change info of the context, if any
+func ChangeInfoFromContext(ctx context.Context) ChangeInfo {
+	info, _ := ctx.Value(changeInfoKey{}).(ChangeInfo)
+	return info
+}
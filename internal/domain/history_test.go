@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRules(t *testing.T) {
+	priority := 1500
+	before := &Rule{ID: "a", Type: "exact", Pattern: "https://example.com", CSS: "a", UpdatedAt: time.Unix(1, 0)}
+	after := &Rule{ID: "a", Type: "exact", Pattern: "https://example.com", CSS: "b", Priority: &priority, UpdatedAt: time.Unix(2, 0)}
+
+	assert.Equal(t, []RuleFieldChange{
+		{Field: "css", From: "a", To: "b"},
+		{Field: "priority", To: float64(1500)},
+	}, DiffRules(before, after))
+	assert.Equal(t, []RuleFieldChange{{Field: "priority", From: float64(1500)}}, DiffRules(after, &Rule{ID: "a", Type: "exact", Pattern: "https://example.com", CSS: "b"}))
+	assert.Empty(t, DiffRules(before, before))
+
+	// A missing rule has no fields
+	created := DiffRules(nil, before)
+	fields := make([]string, len(created))
+	for i, change := range created {
+		fields[i] = change.Field
+		assert.Nil(t, change.From)
+	}
+	assert.Equal(t, []string{"created_at", "css", "id", "js", "pattern", "source", "type"}, fields)
+}
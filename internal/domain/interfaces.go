@@ -15,17 +15,47 @@ type RuleRepository interface {
 	GetStats(ctx context.Context) map[string]any
 }
 
-// BatchRuleRepository extends RuleRepository with batch operations for better performance
+// BatchRuleRepository extends RuleRepository with batch operations for better performance.
+// A batch takes effect completely or not at all, and is published as one change.
 type BatchRuleRepository interface {
 	RuleRepository
 	CreateRules(ctx context.Context, rules []*Rule) error
 	UpdateRules(ctx context.Context, rules []*Rule) error
 	DeleteRules(ctx context.Context, ids []string) error
+	// ApplyRules applies mixed operations in order; each rule may appear only once
+	ApplyRules(ctx context.Context, operations []RuleOperation) error
+}
+
+// ExcludedRuleSource is implemented by repositories that can report loaded rules
+// which are not active, such as disabled or conflict-shadowed rules
+type ExcludedRuleSource interface {
+	GetExcludedRules(ctx context.Context) []ExcludedRule
+}
+
+// RuleSetVersioner is implemented by matchers and repositories that publish their rules
+// as versioned snapshots; the generation increases with every change
+type RuleSetVersioner interface {
+	Generation() uint64
+}
+
+// RuleHistory is implemented by repositories that record a revision for every rule
+// change. Revisions are returned oldest first.
+type RuleHistory interface {
+	GetRuleHistory(ctx context.Context, id string) ([]RuleRevision, error)
+	GetRuleRevision(ctx context.Context, id string, revision int) (*RuleRevision, error)
+}
+
+// RuleFinder is implemented by repositories with secondary indexes that can select
+// rules without scanning all of them. Rules are returned ordered by ID.
+type RuleFinder interface {
+	FindRules(ctx context.Context, filter RuleFilter) ([]Rule, error)
 }
 
 // PatternMatcher defines the contract for URL matching operations
 type PatternMatcher interface {
 	Resolve(ctx context.Context, url string) (*MatchResult, error)
+	ResolveStacked(ctx context.Context, url string) (*MatchResult, error)
+	Explain(ctx context.Context, url string) (*ResolveExplanation, error)
 	AddRule(ctx context.Context, rule *Rule) error
 	UpdateRule(ctx context.Context, rule *Rule) error
 	RemoveRule(ctx context.Context, id string) error
@@ -42,6 +72,9 @@ type CacheManager interface {
 	Get(key string) (*MatchResult, bool)
 	Set(key string, result *MatchResult)
 	Invalidate(key string)
+	// InvalidateWhere removes the entries match returns true for and returns their count.
+	// Unlike Clear it keeps the hit/miss counters. match must not modify the result.
+	InvalidateWhere(match func(key string, result *MatchResult) bool) int
 	Clear()
 	Stats() CacheStats
 
@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+)
+
+// Page orientations accepted in render contexts and rule conditions
+const (
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+)
+
+// RenderContext describes the environment a URL is rendered in. All fields are optional.
+// @Description Rendering environment evaluated against rule conditions
+type RenderContext struct {
+	ViewportWidth  int    `json:"viewport_width,omitempty" validate:"omitempty,min=1,max=100000" example:"1280"` // CSS pixels
+	UserAgent      string `json:"user_agent,omitempty" validate:"max=1024" example:"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"`
+	AcceptLanguage string `json:"accept_language,omitempty" validate:"max=256" example:"de-DE,de;q=0.9,en;q=0.8"`
+	PaperFormat    string `json:"paper_format,omitempty" validate:"max=32" example:"A4"`
+	Orientation    string `json:"orientation,omitempty" validate:"omitempty,oneof=portrait landscape" example:"portrait" enums:"portrait,landscape"`
+}
+
+// RuleConditions restrict a rule to matching render contexts. Every condition that is
+// set must hold; a condition on a field the render context leaves empty fails.
+// @Description Render context requirements of a rule
+type RuleConditions struct {
+	MinViewportWidth *int     `json:"min_viewport_width,omitempty" yaml:"min_viewport_width,omitempty" example:"768"`
+	MaxViewportWidth *int     `json:"max_viewport_width,omitempty" yaml:"max_viewport_width,omitempty" example:"1920"`
+	UserAgent        string   `json:"user_agent,omitempty" yaml:"user_agent,omitempty" example:"(?i)mobile"`      // Regex matched against the user agent
+	Languages        []string `json:"languages,omitempty" yaml:"languages,omitempty" example:"de,en-GB"`          // Matched against the preferred Accept-Language entry; "de" also matches "de-AT"
+	PaperFormats     []string `json:"paper_formats,omitempty" yaml:"paper_formats,omitempty" example:"A4,Letter"` // Compared case-insensitively
+	Orientation      string   `json:"orientation,omitempty" yaml:"orientation,omitempty" example:"landscape" enums:"portrait,landscape"`
+}
+
+// IsZero reports whether no condition is set
+func (c *RuleConditions) IsZero() bool {
+	return c == nil || (c.MinViewportWidth == nil && c.MaxViewportWidth == nil && c.UserAgent == "" &&
+		len(c.Languages) == 0 && len(c.PaperFormats) == 0 && c.Orientation == "")
+}
+
+// Validate checks the render context fields of a resolve request
+func (r *RenderContext) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.ViewportWidth < 0 || r.ViewportWidth > 100000 {
+		return NewAppError(ErrValidationFailed, "Viewport width must be between 1 and 100000", 422, map[string]any{"field": "context.viewport_width", "value": r.ViewportWidth})
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+		max   int
+	}{
+		{"context.user_agent", r.UserAgent, 1024},
+		{"context.accept_language", r.AcceptLanguage, 256},
+		{"context.paper_format", r.PaperFormat, 32},
+	} {
+		if len(field.value) > field.max {
+			return NewAppError(ErrValidationFailed, fmt.Sprintf("Field too long (max %d characters)", field.max), 422, map[string]any{"field": field.name, "length": len(field.value)})
+		}
+	}
+	if r.Orientation != "" && r.Orientation != OrientationPortrait && r.Orientation != OrientationLandscape {
+		return NewAppError(ErrValidationFailed, "Invalid orientation", 422, map[string]any{
+			"field":          "context.orientation",
+			"value":          r.Orientation,
+			"allowed_values": []string{OrientationPortrait, OrientationLandscape},
+		})
+	}
+	return nil
+}
+
+// renderContextKey is the context.Context key for the render context of a request
+type renderContextKey struct{}
+
+// WithRenderContext returns a context carrying the render context used by the matcher
+func WithRenderContext(ctx context.Context, render *RenderContext) context.Context {
+	if render == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, renderContextKey{}, render)
+}
+
+// RenderContextFrom returns the render context stored in ctx, or nil if there is none
+func RenderContextFrom(ctx context.Context) *RenderContext {
+	render, _ := ctx.Value(renderContextKey{}).(*RenderContext)
+	return render
+}
@@ -1,8 +1,12 @@
 package domain
 
 import (
+	"maps"
 	"regexp"
+	"slices"
 	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/urlpattern"
 )
 
 // SourceType represents the origin type of a rule
@@ -29,14 +33,30 @@ type RuleSource struct {
 // @Description URL pattern matching rule configuration
 type Rule struct {
 	ID        string    `json:"id" yaml:"id" validate:"required,uuid4" example:"123e4567-e89b-12d3-a456-426614174000"`
-	Type      string    `json:"type" yaml:"type" validate:"required,oneof=exact regex wildcard" example:"exact" enums:"exact,regex,wildcard"`
+	Type      string    `json:"type" yaml:"type" validate:"required,oneof=exact regex wildcard host path_prefix urlpattern" example:"exact" enums:"exact,regex,wildcard,host,path_prefix,urlpattern"`
 	Pattern   string    `json:"pattern" yaml:"pattern" validate:"required,min=1,max=2048" example:"https://example.com/*"`
 	CSS       string    `json:"css" yaml:"css" validate:"max=102400" example:".banner { display: none; }"`               // 100KB limit
 	JS        string    `json:"js" yaml:"js" validate:"max=102400" example:"document.querySelector('.popup').remove();"` // 100KB limit
 	Priority  *int      `json:"priority,omitempty" yaml:"priority,omitempty" validate:"omitempty,min=0,max=10000" example:"1500"`
+	Exclusive bool      `json:"exclusive,omitempty" yaml:"exclusive,omitempty" example:"false"` // Never stacked with other matching rules
+	MatchRaw  bool      `json:"match_raw,omitempty" yaml:"match_raw,omitempty" example:"false"` // Match the URL as requested instead of its normalized form
 	CreatedAt time.Time `json:"created_at" yaml:"created_at,omitempty" example:"2023-01-01T12:00:00Z"`
 	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at,omitempty" example:"2023-01-01T12:00:00Z"`
 
+	// Render context requirements; the rule is skipped when they fail
+	Conditions *RuleConditions `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+
+	// URLs matching any of these patterns are rejected even when the rule's pattern matches
+	Exclude []ExcludePattern `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+
+	// Validity window; the rule is ignored before ValidFrom and from ValidUntil on
+	ValidFrom  *time.Time `json:"valid_from,omitempty" yaml:"valid_from,omitempty" example:"2026-11-27T00:00:00Z"`
+	ValidUntil *time.Time `json:"valid_until,omitempty" yaml:"valid_until,omitempty" example:"2026-12-01T00:00:00Z"`
+
+	// Alternative assets served to a share of URLs; the rest gets CSS and JS (variant "control")
+	Variants    []RuleVariant `json:"variants,omitempty" yaml:"variants,omitempty"`
+	VariantSalt string        `json:"variant_salt,omitempty" yaml:"variant_salt,omitempty" example:"2026-q4"` // Defaults to the rule ID
+
 	// Attribution fields for community sharing
 	Author      string   `json:"author,omitempty" yaml:"author,omitempty" example:"contributor-name"`
 	ModifiedBy  string   `json:"modified_by,omitempty" yaml:"modified_by,omitempty" example:"modifier-name"`
@@ -48,7 +68,9 @@ type Rule struct {
 	FilePath string     `json:"file_path,omitempty" yaml:"-"` // Path to the rule file on disk
 
 	// Internal fields for performance
-	compiledRegex *regexp.Regexp `json:"-" yaml:"-"` // Pre-compiled for regex rules
+	compiledRegex      *regexp.Regexp      `json:"-" yaml:"-"` // Pre-compiled for regex rules
+	compiledURLPattern *urlpattern.Pattern `json:"-" yaml:"-"` // Pre-compiled for urlpattern rules
+	compiledUserAgent  *regexp.Regexp      `json:"-" yaml:"-"` // Pre-compiled user agent condition
 }
 
 // GetCompiledRegex returns the compiled regex for the rule
@@ -61,14 +83,188 @@ func (r *Rule) SetCompiledRegex(regex *regexp.Regexp) {
 	r.compiledRegex = regex
 }
 
+// GetCompiledURLPattern returns the compiled URL pattern for the rule
+func (r *Rule) GetCompiledURLPattern() *urlpattern.Pattern {
+	return r.compiledURLPattern
+}
+
+// SetCompiledURLPattern sets the compiled URL pattern for the rule
+func (r *Rule) SetCompiledURLPattern(pattern *urlpattern.Pattern) {
+	r.compiledURLPattern = pattern
+}
+
+// GetCompiledUserAgent returns the compiled user agent condition of the rule
+func (r *Rule) GetCompiledUserAgent() *regexp.Regexp {
+	return r.compiledUserAgent
+}
+
+// SetCompiledUserAgent sets the compiled user agent condition of the rule
+func (r *Rule) SetCompiledUserAgent(regex *regexp.Regexp) {
+	r.compiledUserAgent = regex
+}
+
+// Schedule statuses of a rule relative to its validity window
+const (
+	SchedulePending = "pending" // ValidFrom is still in the future
+	ScheduleActive  = "active"  // Inside the window, or no window set
+	ScheduleExpired = "expired" // ValidUntil has passed
+)
+
+// ScheduleStatus reports where now falls relative to the rule's validity window
+func (r *Rule) ScheduleStatus(now time.Time) string {
+	if r.ValidFrom != nil && now.Before(*r.ValidFrom) {
+		return SchedulePending
+	}
+	if r.ValidUntil != nil && !now.Before(*r.ValidUntil) {
+		return ScheduleExpired
+	}
+	return ScheduleActive
+}
+
+// RuleFilter selects rules by their indexed attributes; empty fields match every rule
+type RuleFilter struct {
+	Source SourceType
+	Pack   string
+	Tag    string
+	Type   string
+}
+
+// IsEmpty reports whether the filter matches every rule
+func (f RuleFilter) IsEmpty() bool {
+	return f == RuleFilter{}
+}
+
+// Matches reports whether the rule has every attribute the filter sets
+func (f RuleFilter) Matches(rule *Rule) bool {
+	if f.Source != "" && rule.Source.Type != f.Source {
+		return false
+	}
+	if f.Pack != "" && rule.Source.PackName != f.Pack {
+		return false
+	}
+	if f.Type != "" && rule.Type != f.Type {
+		return false
+	}
+	return f.Tag == "" || slices.Contains(rule.Tags, f.Tag)
+}
+
+// ControlVariantID identifies the rule's own assets when the rule has variants
+const ControlVariantID = "control"
+
+// RuleVariant is an alternative CSS/JS body served to a percentage of matching URLs
+// @Description Weighted alternative assets of a rule
+type RuleVariant struct {
+	ID     string `json:"id" yaml:"id" example:"new-layout"`
+	Weight int    `json:"weight" yaml:"weight" example:"10"` // Percentage of matching URLs, 0-100
+	CSS    string `json:"css,omitempty" yaml:"css,omitempty" example:".banner { visibility: hidden; }"`
+	JS     string `json:"js,omitempty" yaml:"js,omitempty" example:""`
+}
+
+// ExcludePattern is a typed URL pattern that prevents its rule from matching
+// @Description URL pattern that rejects URLs otherwise matched by the rule
+type ExcludePattern struct {
+	Type    string `json:"type" yaml:"type" validate:"required,oneof=exact regex wildcard host path_prefix urlpattern" example:"wildcard" enums:"exact,regex,wildcard,host,path_prefix,urlpattern"`
+	Pattern string `json:"pattern" yaml:"pattern" validate:"required,min=1,max=2048" example:"https://example.com/checkout/*"`
+
+	// Internal fields for performance
+	compiledRegex      *regexp.Regexp      `json:"-" yaml:"-"` // Pre-compiled for regex excludes
+	compiledURLPattern *urlpattern.Pattern `json:"-" yaml:"-"` // Pre-compiled for urlpattern excludes
+}
+
+// GetCompiledRegex returns the compiled regex for the exclude pattern
+func (e *ExcludePattern) GetCompiledRegex() *regexp.Regexp {
+	return e.compiledRegex
+}
+
+// SetCompiledRegex sets the compiled regex for the exclude pattern
+func (e *ExcludePattern) SetCompiledRegex(regex *regexp.Regexp) {
+	e.compiledRegex = regex
+}
+
+// GetCompiledURLPattern returns the compiled URL pattern for the exclude pattern
+func (e *ExcludePattern) GetCompiledURLPattern() *urlpattern.Pattern {
+	return e.compiledURLPattern
+}
+
+// SetCompiledURLPattern sets the compiled URL pattern for the exclude pattern
+func (e *ExcludePattern) SetCompiledURLPattern(pattern *urlpattern.Pattern) {
+	e.compiledURLPattern = pattern
+}
+
 // MatchResult represents the result of a URL pattern match
 type MatchResult struct {
 	RuleID    string    `json:"rule_id"`
+	RuleIDs   []string  `json:"rule_ids,omitempty"` // Contributing rules of a stacked resolution, highest score first
 	CSS       string    `json:"css"`
 	JS        string    `json:"js"`
 	Score     int       `json:"score,omitempty"`
 	CacheHit  bool      `json:"cache_hit"`
 	Timestamp time.Time `json:"timestamp"`
+
+	// Variant of the matched rule that supplied the assets; empty when the rule has no variants
+	VariantID  string            `json:"variant_id,omitempty"`
+	VariantIDs map[string]string `json:"variant_ids,omitempty"` // Variant per contributing rule of a stacked resolution
+
+	// Set on cached results whose CSS and JS are left empty and read from the matcher's
+	// rule snapshot by RuleID and VariantID; Generation is the rule set they were computed in
+	AssetsByRef bool   `json:"-"`
+	Generation  uint64 `json:"-"`
+}
+
+// Clone returns a copy of the result that shares no slices or maps with it
+func (r *MatchResult) Clone() *MatchResult {
+	clone := *r
+	clone.RuleIDs = slices.Clone(r.RuleIDs)
+	clone.VariantIDs = maps.Clone(r.VariantIDs)
+	return &clone
+}
+
+// Evaluation outcomes reported by resolve explanations
+const (
+	EvaluationWinner   = "winner"   // Matched and selected
+	EvaluationLost     = "lost"     // Matched but outscored by another rule
+	EvaluationNoMatch  = "no_match" // Pattern did not match the URL
+	EvaluationExcluded = "excluded" // Not active in the matcher (disabled or shadowed)
+
+	EvaluationConditionsFailed = "conditions_failed" // Pattern matched but the render context fails the rule's conditions
+	EvaluationRejected         = "rejected"          // Pattern matched but so did one of the rule's exclude patterns
+	EvaluationOutsideWindow    = "outside_window"    // Pattern matched but the rule is pending or expired
+)
+
+// ScoreBreakdown shows how a rule's specificity score was computed
+type ScoreBreakdown struct {
+	BaseScore        int  `json:"base_score"`                  // Determined by rule type
+	LengthBonus      int  `json:"length_bonus"`                // min(len(pattern), 499), capped lower for newer rule types
+	PriorityOverride *int `json:"priority_override,omitempty"` // Replaces the calculated score when set
+	Total            int  `json:"total"`
+}
+
+// RuleEvaluation describes how a single rule was evaluated against a URL
+type RuleEvaluation struct {
+	RuleID  string          `json:"rule_id"`
+	Type    string          `json:"type"`
+	Pattern string          `json:"pattern"`
+	Source  RuleSource      `json:"source"`
+	Matched bool            `json:"matched"`
+	Outcome string          `json:"outcome"`
+	Reason  string          `json:"reason,omitempty"`
+	Score   *ScoreBreakdown `json:"score,omitempty"` // Only set for matching rules
+}
+
+// ResolveExplanation lists every rule considered while resolving a URL
+type ResolveExplanation struct {
+	URL            string           `json:"url"`
+	NormalizedURL  string           `json:"normalized_url"` // URL matched by rules without MatchRaw
+	Result         *MatchResult     `json:"result"`
+	Evaluations    []RuleEvaluation `json:"evaluations"`
+	RuleCount      int              `json:"rule_count"`       // Active rules in the matcher
+	SkippedByIndex int              `json:"skipped_by_index"` // Active rules ruled out by the index without evaluation
+}
+
+// ExcludedRule is a loaded rule that is not active, together with the reason
+type ExcludedRule struct {
+	Rule   Rule   `json:"rule"`
+	Reason string `json:"reason"`
 }
 
 // CacheStats represents cache performance metrics
@@ -78,6 +274,24 @@ type CacheStats struct {
 	Size     int     `json:"size"`
 	MaxSize  int     `json:"max_size"`
 	HitRatio float64 `json:"hit_ratio"`
+	Policy   string  `json:"policy,omitempty"` // Eviction policy
+
+	// Estimated memory held by entries and the budget it is kept under; zero means no budget
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"max_bytes"`
+
+	// Entries removed by targeted invalidation and by expiry since the last Clear
+	Invalidations int64 `json:"invalidations"`
+	Expirations   int64 `json:"expirations"`
+
+	// Negative cache of results without a matching rule; lookups that missed the main
+	// cache count as a negative hit or miss while it is enabled. Negative hits are not
+	// counted in Hits, Misses or HitRatio.
+	NegativeHits    int64 `json:"negative_hits"`
+	NegativeMisses  int64 `json:"negative_misses"`
+	NegativeSize    int   `json:"negative_size"`
+	NegativeMaxSize int   `json:"negative_max_size"`
+	NegativeBytes   int64 `json:"negative_bytes"`
 }
 
 // HealthStatus represents the health status of a component
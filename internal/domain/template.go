@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"regexp"
+	"slices"
+)
+
+// templateRefPattern matches a capture group reference such as {{.tenant}} in rule CSS/JS
+var templateRefPattern = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// TemplateRefs returns the capture group names referenced by content, in order of first use
+func TemplateRefs(content string) []string {
+	var names []string
+	for _, match := range templateRefPattern.FindAllStringSubmatch(content, -1) {
+		if !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// ExpandTemplate replaces every capture group reference in content by value(name).
+// Text that is not a well-formed reference is left unchanged.
+func ExpandTemplate(content string, value func(name string) string) string {
+	return templateRefPattern.ReplaceAllStringFunc(content, func(ref string) string {
+		return value(templateRefPattern.FindStringSubmatch(ref)[1])
+	})
+}
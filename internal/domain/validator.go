@@ -7,8 +7,20 @@ import (
 	"slices"
 	"strings"
 	"unicode/utf8"
+
+	"github.com/freewebtopdf/asset-injector/internal/urlpattern"
+	"golang.org/x/net/idna"
 )
 
+// maxExcludePatterns limits the exclude list of a single rule
+const maxExcludePatterns = 50
+
+// maxVariants limits the variants of a single rule
+const maxVariants = 10
+
+// variantIDPattern restricts variant IDs to short identifiers usable as metric labels
+var variantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
 // InputValidator implements comprehensive input validation
 type InputValidator struct {
 	maxContentSize    int
@@ -61,6 +73,11 @@ func (v *InputValidator) ValidateRule(rule *Rule) error {
 		return err
 	}
 
+	// Validate exclude patterns
+	if err := v.validateExcludes(rule.Exclude); err != nil {
+		return err
+	}
+
 	// Validate CSS content
 	if err := v.ValidateContent(rule.CSS, v.maxContentSize); err != nil {
 		return NewAppErrorWithCause(ErrValidationFailed, "Invalid CSS content", 422, err, map[string]any{"field": "css"})
@@ -83,6 +100,197 @@ func (v *InputValidator) ValidateRule(rule *Rule) error {
 		}
 	}
 
+	// Validate conditions if set
+	if err := v.validateConditions(rule.Conditions); err != nil {
+		return err
+	}
+
+	// Validate variants if set
+	if err := v.validateVariants(rule); err != nil {
+		return err
+	}
+
+	// Validate capture group references in CSS/JS
+	if err := v.validateTemplates(rule); err != nil {
+		return err
+	}
+
+	// Validate the validity window
+	if rule.ValidFrom != nil && rule.ValidUntil != nil && !rule.ValidFrom.Before(*rule.ValidUntil) {
+		return NewAppError(ErrValidationFailed, "valid_until must be after valid_from", 422, map[string]any{
+			"field":       "valid_until",
+			"valid_from":  rule.ValidFrom,
+			"valid_until": rule.ValidUntil,
+		})
+	}
+
+	return nil
+}
+
+// validateExcludes validates the type and pattern of every exclude entry
+func (v *InputValidator) validateExcludes(excludes []ExcludePattern) error {
+	if len(excludes) > maxExcludePatterns {
+		return NewAppError(ErrValidationFailed, fmt.Sprintf("Too many exclude patterns (max %d)", maxExcludePatterns), 422, map[string]any{
+			"field": "exclude",
+			"count": len(excludes),
+		})
+	}
+
+	for i, exclude := range excludes {
+		err := v.validateRuleType(exclude.Type)
+		if err == nil {
+			err = v.validatePattern(exclude.Type, exclude.Pattern)
+		}
+		if err != nil {
+			return NewAppErrorWithCause(ErrValidationFailed, "Invalid exclude pattern", 422, err, map[string]any{
+				"field":   fmt.Sprintf("exclude[%d]", i),
+				"type":    exclude.Type,
+				"pattern": exclude.Pattern,
+			})
+		}
+	}
+	return nil
+}
+
+// validateVariants validates the variant IDs, weights and assets of a rule
+func (v *InputValidator) validateVariants(rule *Rule) error {
+	if len(rule.VariantSalt) > 128 {
+		return NewAppError(ErrValidationFailed, "Variant salt too long (max 128 characters)", 422, map[string]any{"field": "variant_salt", "length": len(rule.VariantSalt)})
+	}
+	if len(rule.Variants) > maxVariants {
+		return NewAppError(ErrValidationFailed, fmt.Sprintf("Too many variants (max %d)", maxVariants), 422, map[string]any{"field": "variants", "count": len(rule.Variants)})
+	}
+
+	seen := make(map[string]bool, len(rule.Variants))
+	totalWeight := 0
+	for i, variant := range rule.Variants {
+		field := fmt.Sprintf("variants[%d]", i)
+		if !variantIDPattern.MatchString(variant.ID) || variant.ID == ControlVariantID {
+			return NewAppError(ErrValidationFailed, "Variant ID must be 1-64 letters, digits, '-' or '_' and not \"control\"", 422, map[string]any{"field": field + ".id", "value": variant.ID})
+		}
+		if seen[variant.ID] {
+			return NewAppError(ErrValidationFailed, "Duplicate variant ID", 422, map[string]any{"field": field + ".id", "value": variant.ID})
+		}
+		seen[variant.ID] = true
+
+		if variant.Weight < 0 || variant.Weight > 100 {
+			return NewAppError(ErrValidationFailed, "Variant weight must be between 0 and 100", 422, map[string]any{"field": field + ".weight", "value": variant.Weight})
+		}
+		totalWeight += variant.Weight
+
+		if err := v.ValidateContent(variant.CSS, v.maxContentSize); err != nil {
+			return NewAppErrorWithCause(ErrValidationFailed, "Invalid variant CSS content", 422, err, map[string]any{"field": field + ".css"})
+		}
+		if err := v.ValidateContent(variant.JS, v.maxContentSize); err != nil {
+			return NewAppErrorWithCause(ErrValidationFailed, "Invalid variant JS content", 422, err, map[string]any{"field": field + ".js"})
+		}
+	}
+	if totalWeight > 100 {
+		return NewAppError(ErrValidationFailed, "Variant weights must not add up to more than 100", 422, map[string]any{"field": "variants", "total_weight": totalWeight})
+	}
+
+	return nil
+}
+
+// validateTemplates checks that every capture group referenced by the rule's CSS/JS,
+// including its variants, is a named group of its regex or URL pattern
+func (v *InputValidator) validateTemplates(rule *Rule) error {
+	type templateBody struct{ field, content string }
+	bodies := []templateBody{{"css", rule.CSS}, {"js", rule.JS}}
+	for i, variant := range rule.Variants {
+		bodies = append(bodies,
+			templateBody{fmt.Sprintf("variants[%d].css", i), variant.CSS},
+			templateBody{fmt.Sprintf("variants[%d].js", i), variant.JS})
+	}
+
+	var groups []string
+	resolved := false
+	for _, body := range bodies {
+		refs := TemplateRefs(body.content)
+		if len(refs) == 0 {
+			continue
+		}
+		if !resolved {
+			resolved = true
+			switch rule.Type {
+			case "regex":
+				if re, err := regexp.Compile(rule.Pattern); err == nil {
+					groups = slices.DeleteFunc(re.SubexpNames(), func(name string) bool { return name == "" })
+				}
+			case "urlpattern":
+				if pattern, err := urlpattern.Compile(rule.Pattern); err == nil {
+					groups = pattern.GroupNames()
+				}
+			default:
+				return NewAppError(ErrValidationFailed, "Capture group references require a regex or urlpattern rule", 422, map[string]any{
+					"field": body.field,
+					"type":  rule.Type,
+				})
+			}
+		}
+		for _, ref := range refs {
+			if !slices.Contains(groups, ref) {
+				return NewAppError(ErrValidationFailed, "Template references a capture group the pattern does not define", 422, map[string]any{
+					"field":  body.field,
+					"group":  ref,
+					"groups": groups,
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateConditions validates the render context conditions of a rule
+func (v *InputValidator) validateConditions(conditions *RuleConditions) error {
+	if conditions == nil {
+		return nil
+	}
+
+	for _, width := range []struct {
+		field string
+		value *int
+	}{
+		{"conditions.min_viewport_width", conditions.MinViewportWidth},
+		{"conditions.max_viewport_width", conditions.MaxViewportWidth},
+	} {
+		if width.value != nil && (*width.value < 1 || *width.value > 100000) {
+			return NewAppError(ErrValidationFailed, "Viewport width must be between 1 and 100000", 422, map[string]any{"field": width.field, "value": *width.value})
+		}
+	}
+	if conditions.MinViewportWidth != nil && conditions.MaxViewportWidth != nil && *conditions.MinViewportWidth > *conditions.MaxViewportWidth {
+		return NewAppError(ErrValidationFailed, "Minimum viewport width exceeds maximum", 422, map[string]any{
+			"field": "conditions.min_viewport_width",
+			"min":   *conditions.MinViewportWidth,
+			"max":   *conditions.MaxViewportWidth,
+		})
+	}
+
+	if conditions.UserAgent != "" {
+		if _, err := regexp.Compile(conditions.UserAgent); err != nil {
+			return NewAppErrorWithCause(ErrValidationFailed, "Invalid user agent regex", 422, err, map[string]any{
+				"field":   "conditions.user_agent",
+				"pattern": conditions.UserAgent,
+			})
+		}
+	}
+
+	if slices.Contains(conditions.Languages, "") {
+		return NewAppError(ErrValidationFailed, "Languages must not be empty", 422, map[string]any{"field": "conditions.languages"})
+	}
+	if slices.Contains(conditions.PaperFormats, "") {
+		return NewAppError(ErrValidationFailed, "Paper formats must not be empty", 422, map[string]any{"field": "conditions.paper_formats"})
+	}
+
+	if conditions.Orientation != "" && conditions.Orientation != OrientationPortrait && conditions.Orientation != OrientationLandscape {
+		return NewAppError(ErrValidationFailed, "Invalid orientation", 422, map[string]any{
+			"field":          "conditions.orientation",
+			"value":          conditions.Orientation,
+			"allowed_values": []string{OrientationPortrait, OrientationLandscape},
+		})
+	}
+
 	return nil
 }
 
@@ -158,7 +366,7 @@ func (v *InputValidator) ValidateContent(content string, maxSize int) error {
 
 // validateRuleType validates the rule type
 func (v *InputValidator) validateRuleType(ruleType string) error {
-	allowedTypes := []string{"exact", "regex", "wildcard"}
+	allowedTypes := []string{"exact", "regex", "wildcard", "host", "path_prefix", "urlpattern"}
 	if slices.Contains(allowedTypes, ruleType) {
 		return nil
 	}
@@ -217,6 +425,41 @@ func (v *InputValidator) validatePattern(ruleType, pattern string) error {
 				"pattern": pattern,
 			})
 		}
+	case "host":
+		// A bare hostname; subdomains are matched implicitly
+		if strings.ContainsAny(pattern, "/:*?#@ ") {
+			return NewAppError(ErrValidationFailed, "Host pattern must be a bare hostname without scheme, port, path or wildcards", 422, map[string]any{
+				"field":   "pattern",
+				"pattern": pattern,
+			})
+		}
+		if _, err := idna.Lookup.ToASCII(pattern); err != nil {
+			return NewAppErrorWithCause(ErrValidationFailed, "Invalid host pattern", 422, err, map[string]any{
+				"field":   "pattern",
+				"pattern": pattern,
+			})
+		}
+	case "path_prefix":
+		// An absolute URL whose path is matched segment by segment
+		if err := v.ValidateURL(pattern); err != nil {
+			return NewAppErrorWithCause(ErrValidationFailed, "Invalid path prefix pattern URL", 422, err, map[string]any{
+				"field":   "pattern",
+				"pattern": pattern,
+			})
+		}
+		if strings.ContainsAny(pattern, "?#*") {
+			return NewAppError(ErrValidationFailed, "Path prefix pattern must not contain a query, fragment or wildcards", 422, map[string]any{
+				"field":   "pattern",
+				"pattern": pattern,
+			})
+		}
+	case "urlpattern":
+		if _, err := urlpattern.Compile(pattern); err != nil {
+			return NewAppErrorWithCause(ErrValidationFailed, "Invalid URL pattern", 422, err, map[string]any{
+				"field":   "pattern",
+				"pattern": pattern,
+			})
+		}
 	}
 
 	return nil
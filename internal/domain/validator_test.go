@@ -0,0 +1,218 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInputValidator_ValidatePattern(t *testing.T) {
+	tests := []struct {
+		name     string
+		ruleType string
+		pattern  string
+		valid    bool
+	}{
+		{"host", "host", "example.com", true},
+		{"idn host", "host", "bücher.example", true},
+		{"host with scheme", "host", "https://example.com", false},
+		{"host with path", "host", "example.com/docs", false},
+		{"host with port", "host", "example.com:8080", false},
+		{"host with wildcard", "host", "*.example.com", false},
+		{"path prefix", "path_prefix", "https://example.com/docs", true},
+		{"path prefix without path", "path_prefix", "https://example.com", true},
+		{"path prefix without scheme", "path_prefix", "example.com/docs", false},
+		{"path prefix with query", "path_prefix", "https://example.com/docs?page=1", false},
+		{"path prefix with wildcard", "path_prefix", "https://example.com/docs/*", false},
+		{"urlpattern", "urlpattern", "https://*.example.com/books/:id(\\d+)", true},
+		{"urlpattern without protocol", "urlpattern", "/books/:id", false},
+		{"urlpattern duplicate group", "urlpattern", "https://example.com/:id/:id", false},
+	}
+
+	v := NewInputValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{ID: "id", Type: tt.ruleType, Pattern: tt.pattern, CSS: "body {}"}
+			err := v.ValidateRule(rule)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestInputValidator_ValidateConditions(t *testing.T) {
+	width := func(v int) *int { return &v }
+
+	tests := []struct {
+		name       string
+		conditions *RuleConditions
+		valid      bool
+	}{
+		{"none", nil, true},
+		{"viewport range", &RuleConditions{MinViewportWidth: width(768), MaxViewportWidth: width(1920)}, true},
+		{"inverted viewport range", &RuleConditions{MinViewportWidth: width(1920), MaxViewportWidth: width(768)}, false},
+		{"zero viewport", &RuleConditions{MaxViewportWidth: width(0)}, false},
+		{"user agent regex", &RuleConditions{UserAgent: "(?i)mobile|android"}, true},
+		{"invalid user agent regex", &RuleConditions{UserAgent: "(mobile"}, false},
+		{"languages", &RuleConditions{Languages: []string{"de", "en-GB"}}, true},
+		{"empty language", &RuleConditions{Languages: []string{""}}, false},
+		{"empty paper format", &RuleConditions{PaperFormats: []string{"A4", ""}}, false},
+		{"orientation", &RuleConditions{Orientation: OrientationLandscape}, true},
+		{"invalid orientation", &RuleConditions{Orientation: "sideways"}, false},
+	}
+
+	v := NewInputValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{ID: "id", Type: "host", Pattern: "example.com", CSS: "body {}", Conditions: tt.conditions}
+			err := v.ValidateRule(rule)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestInputValidator_ValidateExcludes(t *testing.T) {
+	tests := []struct {
+		name    string
+		exclude []ExcludePattern
+		valid   bool
+	}{
+		{"none", nil, true},
+		{"typed patterns", []ExcludePattern{{Type: "wildcard", Pattern: "https://example.com/checkout/*"}, {Type: "path_prefix", Pattern: "https://example.com/account"}}, true},
+		{"unknown type", []ExcludePattern{{Type: "glob", Pattern: "https://example.com/*"}}, false},
+		{"empty pattern", []ExcludePattern{{Type: "exact", Pattern: ""}}, false},
+		{"invalid regex", []ExcludePattern{{Type: "regex", Pattern: "(cart"}}, false},
+		{"host with path", []ExcludePattern{{Type: "host", Pattern: "example.com/cart"}}, false},
+		{"too many", make([]ExcludePattern, maxExcludePatterns+1), false},
+	}
+
+	v := NewInputValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{ID: "id", Type: "host", Pattern: "example.com", CSS: "body {}", Exclude: tt.exclude}
+			err := v.ValidateRule(rule)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestInputValidator_ValidateVariants(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []RuleVariant
+		salt     string
+		valid    bool
+	}{
+		{"none", nil, "", true},
+		{"weighted", []RuleVariant{{ID: "compact", Weight: 10, CSS: "a {}"}, {ID: "no_js", Weight: 90}}, "q4", true},
+		{"zero weight", []RuleVariant{{ID: "paused", Weight: 0, CSS: "a {}"}}, "", true},
+		{"empty ID", []RuleVariant{{ID: "", Weight: 10}}, "", false},
+		{"invalid ID", []RuleVariant{{ID: "new layout", Weight: 10}}, "", false},
+		{"reserved ID", []RuleVariant{{ID: ControlVariantID, Weight: 10}}, "", false},
+		{"duplicate ID", []RuleVariant{{ID: "a", Weight: 10}, {ID: "a", Weight: 10}}, "", false},
+		{"negative weight", []RuleVariant{{ID: "a", Weight: -1}}, "", false},
+		{"weights over 100", []RuleVariant{{ID: "a", Weight: 60}, {ID: "b", Weight: 41}}, "", false},
+		{"invalid content", []RuleVariant{{ID: "a", Weight: 10, JS: "a\xffb"}}, "", false},
+		{"too many", make([]RuleVariant, maxVariants+1), "", false},
+		{"salt too long", nil, strings.Repeat("s", 129), false},
+	}
+
+	v := NewInputValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{ID: "id", Type: "host", Pattern: "example.com", CSS: "body {}", Variants: tt.variants, VariantSalt: tt.salt}
+			err := v.ValidateRule(rule)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestTemplateRefs(t *testing.T) {
+	content := ".tenant-{{.tenant}} { background: url(/logos/{{ .tenant }}.png); } /* {{.id}} {{tenant}} {{.1}} */"
+	assert.Equal(t, []string{"tenant", "id"}, TemplateRefs(content))
+	assert.Empty(t, TemplateRefs("a { b: c; }"))
+
+	expanded := ExpandTemplate(content, func(name string) string { return "<" + name + ">" })
+	assert.Equal(t, ".tenant-<tenant> { background: url(/logos/<tenant>.png); } /* <id> {{tenant}} {{.1}} */", expanded)
+}
+
+func TestInputValidator_ValidateTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		ruleType string
+		pattern  string
+		css      string
+		variants []RuleVariant
+		valid    bool
+	}{
+		{"regex group", "regex", `^https://(?P<tenant>[a-z]+)\.example\.com/`, ".t-{{.tenant}} {}", nil, true},
+		{"regex short group syntax", "regex", `/article/(?<id>\d+)`, "#a-{{.id}} {}", nil, true},
+		{"urlpattern group", "urlpattern", `https://example.com/books/:id(\d+)`, "#book-{{.id}} {}", nil, true},
+		{"no references", "host", "example.com", "a {}", nil, true},
+		{"unknown regex group", "regex", `/article/(?P<id>\d+)`, "#a-{{.slug}} {}", nil, false},
+		{"unnamed regex group", "regex", `/article/(\d+)`, "#a-{{.id}} {}", nil, false},
+		{"unknown urlpattern group", "urlpattern", `https://example.com/books/:id`, "{{.title}}", nil, false},
+		{"unsupported type", "wildcard", "https://example.com/*", "{{.tenant}}", nil, false},
+		{"variant reference", "regex", `/article/(?P<id>\d+)`, "a {}", []RuleVariant{{ID: "v", Weight: 10, JS: "load('{{.id}}')"}}, true},
+		{"unknown variant reference", "regex", `/article/(?P<id>\d+)`, "a {}", []RuleVariant{{ID: "v", Weight: 10, JS: "load('{{.slug}}')"}}, false},
+	}
+
+	v := NewInputValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{ID: "id", Type: tt.ruleType, Pattern: tt.pattern, CSS: tt.css, Variants: tt.variants}
+			err := v.ValidateRule(rule)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestRule_ScheduleStatus(t *testing.T) {
+	from := time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC)
+	until := from.Add(96 * time.Hour)
+	rule := &Rule{ValidFrom: &from, ValidUntil: &until}
+
+	assert.Equal(t, SchedulePending, rule.ScheduleStatus(from.Add(-time.Second)))
+	assert.Equal(t, ScheduleActive, rule.ScheduleStatus(from))
+	assert.Equal(t, ScheduleActive, rule.ScheduleStatus(until.Add(-time.Second)))
+	assert.Equal(t, ScheduleExpired, rule.ScheduleStatus(until))
+	assert.Equal(t, ScheduleActive, (&Rule{}).ScheduleStatus(from))
+
+	v := NewInputValidator()
+	valid := &Rule{ID: "id", Type: "host", Pattern: "example.com", CSS: "body {}", ValidFrom: &from, ValidUntil: &until}
+	assert.NoError(t, v.ValidateRule(valid))
+	inverted := &Rule{ID: "id", Type: "host", Pattern: "example.com", CSS: "body {}", ValidFrom: &until, ValidUntil: &from}
+	assert.Error(t, v.ValidateRule(inverted))
+	empty := &Rule{ID: "id", Type: "host", Pattern: "example.com", CSS: "body {}", ValidFrom: &from, ValidUntil: &from}
+	assert.Error(t, v.ValidateRule(empty))
+}
+
+func TestRenderContext_Validate(t *testing.T) {
+	var render *RenderContext
+	assert.NoError(t, render.Validate())
+	assert.NoError(t, (&RenderContext{ViewportWidth: 1280, PaperFormat: "A4", Orientation: OrientationPortrait}).Validate())
+	assert.Error(t, (&RenderContext{ViewportWidth: -1}).Validate())
+	assert.Error(t, (&RenderContext{Orientation: "sideways"}).Validate())
+	assert.Error(t, (&RenderContext{PaperFormat: strings.Repeat("A", 33)}).Validate())
+}
@@ -162,6 +162,14 @@ func (h *SystemHealthChecker) collectSystemMetrics(ctx context.Context) map[stri
 		"size":      cacheStats.Size,
 		"max_size":  cacheStats.MaxSize,
 		"hit_ratio": cacheStats.HitRatio,
+
+		"bytes":             cacheStats.Bytes,
+		"max_bytes":         cacheStats.MaxBytes,
+		"expirations":       cacheStats.Expirations,
+		"negative_hits":     cacheStats.NegativeHits,
+		"negative_misses":   cacheStats.NegativeMisses,
+		"negative_size":     cacheStats.NegativeSize,
+		"negative_max_size": cacheStats.NegativeMaxSize,
 	}
 
 	// Add system-level metrics
@@ -95,6 +95,15 @@ func (l *FileRuleLoader) LoadAll(ctx context.Context) ([]domain.Rule, []LoadErro
 	return rules, loadErrors, nil
 }
 
+// Restore replaces the loaded rules and errors with those of an earlier load, such as
+// one restored from a ruleset snapshot, without reading any file
+func (l *FileRuleLoader) Restore(rules []domain.Rule, loadErrors []LoadError) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules = rules
+	l.loadErrors = loadErrors
+}
+
 // Reload triggers a full reload of all rules from disk
 func (l *FileRuleLoader) Reload(ctx context.Context) error {
 	_, _, err := l.LoadAll(ctx)
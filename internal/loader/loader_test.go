@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -268,6 +269,57 @@ func TestWriter_WriteMultipleRules(t *testing.T) {
 	assert.Equal(t, "multi-3", loadedRules[2].ID)
 }
 
+func TestWriter_RoundTripRuleTypes(t *testing.T) {
+	tempDir := t.TempDir()
+	maxWidth := 767
+	validFrom := time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC)
+	validUntil := validFrom.Add(96 * time.Hour)
+
+	rules := []domain.Rule{
+		{ID: "host-rule", Type: "host", Pattern: "example.com", CSS: "a"},
+		{ID: "prefix-rule", Type: "path_prefix", Pattern: "https://example.com/docs", CSS: "b"},
+		{ID: "pattern-rule", Type: "urlpattern", Pattern: `https://*.example.com/books/:id(\d+){/*}?#:section`, CSS: "c"},
+		{ID: "conditional-rule", Type: "wildcard", Pattern: "https://example.com/*", CSS: "d", Conditions: &domain.RuleConditions{
+			MaxViewportWidth: &maxWidth,
+			UserAgent:        "(?i)mobile",
+			Languages:        []string{"de"},
+			PaperFormats:     []string{"A4", "Letter"},
+			Orientation:      domain.OrientationLandscape,
+		}},
+		{ID: "excluding-rule", Type: "host", Pattern: "example.com", CSS: "e", Exclude: []domain.ExcludePattern{
+			{Type: "wildcard", Pattern: "https://example.com/checkout/*"},
+			{Type: "regex", Pattern: `^https://example\.com/cart(/|$)`},
+		}},
+		{ID: "scheduled-rule", Type: "exact", Pattern: "https://example.com/sale", CSS: "f", ValidFrom: &validFrom, ValidUntil: &validUntil},
+		{ID: "variant-rule", Type: "host", Pattern: "shop.example.com", CSS: "g", VariantSalt: "q4", Variants: []domain.RuleVariant{
+			{ID: "compact", Weight: 10, CSS: "h"},
+			{ID: "no-js", Weight: 5, JS: "i"},
+		}},
+	}
+
+	writer := NewWriter(tempDir)
+	require.NoError(t, writer.WriteRules(rules, "types.rule.yaml"))
+
+	parser := NewParser()
+	loadedRules, loadErr := parser.ParseFile(ScannedFile{
+		Path:       filepath.Join(tempDir, "types.rule.yaml"),
+		SourceType: domain.SourceLocal,
+	})
+	require.Nil(t, loadErr)
+	require.Len(t, loadedRules, len(rules))
+
+	for i := range rules {
+		assert.Equal(t, rules[i].Type, loadedRules[i].Type)
+		assert.Equal(t, rules[i].Pattern, loadedRules[i].Pattern)
+		assert.Equal(t, rules[i].Conditions, loadedRules[i].Conditions)
+		assert.Equal(t, rules[i].Exclude, loadedRules[i].Exclude)
+		assert.Equal(t, rules[i].ValidFrom, loadedRules[i].ValidFrom)
+		assert.Equal(t, rules[i].ValidUntil, loadedRules[i].ValidUntil)
+		assert.Equal(t, rules[i].Variants, loadedRules[i].Variants)
+		assert.Equal(t, rules[i].VariantSalt, loadedRules[i].VariantSalt)
+	}
+}
+
 func TestFileRuleLoader_LoadAll(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -100,12 +100,22 @@ type ruleYAML struct {
 	CSS         string    `yaml:"css,omitempty"`
 	JS          string    `yaml:"js,omitempty"`
 	Priority    *int      `yaml:"priority,omitempty"`
+	Exclusive   bool      `yaml:"exclusive,omitempty"`
+	MatchRaw    bool      `yaml:"match_raw,omitempty"`
 	Author      string    `yaml:"author,omitempty"`
 	ModifiedBy  string    `yaml:"modified_by,omitempty"`
 	Description string    `yaml:"description,omitempty"`
 	Tags        []string  `yaml:"tags,omitempty"`
 	CreatedAt   time.Time `yaml:"created_at,omitempty"`
 	UpdatedAt   time.Time `yaml:"updated_at,omitempty"`
+
+	Conditions *domain.RuleConditions  `yaml:"conditions,omitempty"`
+	Exclude    []domain.ExcludePattern `yaml:"exclude,omitempty"`
+	ValidFrom  *time.Time              `yaml:"valid_from,omitempty"`
+	ValidUntil *time.Time              `yaml:"valid_until,omitempty"`
+
+	Variants    []domain.RuleVariant `yaml:"variants,omitempty"`
+	VariantSalt string               `yaml:"variant_salt,omitempty"`
 }
 
 // prepareRuleForWrite converts a domain.Rule to the YAML-serializable format
@@ -117,6 +127,14 @@ func prepareRuleForWrite(rule *domain.Rule) ruleYAML {
 		CSS:         rule.CSS,
 		JS:          rule.JS,
 		Priority:    rule.Priority,
+		Exclusive:   rule.Exclusive,
+		MatchRaw:    rule.MatchRaw,
+		Conditions:  rule.Conditions,
+		Exclude:     rule.Exclude,
+		ValidFrom:   rule.ValidFrom,
+		ValidUntil:  rule.ValidUntil,
+		Variants:    rule.Variants,
+		VariantSalt: rule.VariantSalt,
 		Author:      rule.Author,
 		ModifiedBy:  rule.ModifiedBy,
 		Description: rule.Description,
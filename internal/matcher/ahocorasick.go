@@ -0,0 +1,97 @@
+package matcher
+
+// ahoCorasick finds every occurrence of a fixed set of strings in a single pass over
+// the input. The automaton is compiled into a dense transition table over byte classes,
+// where bytes that occur in no pattern share class 0, so each input byte costs one
+// table lookup.
+type ahoCorasick struct {
+	classes [256]int32 // Byte -> alphabet class
+	width   int        // Number of classes
+	delta   []int32    // state*width + class -> next state
+	out     []int32    // Pattern ending at each state, or -1
+	dict    []int32    // Nearest state on the failure chain that ends a pattern, or -1
+}
+
+// newAhoCorasick builds an automaton for the patterns; the ID reported for a match is
+// the pattern's index. Patterns must be non-empty and unique.
+func newAhoCorasick(patterns []string) *ahoCorasick {
+	a := &ahoCorasick{width: 1}
+	for _, pattern := range patterns {
+		for i := 0; i < len(pattern); i++ {
+			if a.classes[pattern[i]] == 0 {
+				a.classes[pattern[i]] = int32(a.width)
+				a.width++
+			}
+		}
+	}
+
+	// Build the trie; 0 marks a missing edge because the root is never a child
+	a.delta = make([]int32, a.width)
+	a.out = []int32{-1}
+	for id, pattern := range patterns {
+		state := int32(0)
+		for i := 0; i < len(pattern); i++ {
+			edge := int(state)*a.width + int(a.classes[pattern[i]])
+			if a.delta[edge] == 0 {
+				a.delta[edge] = int32(len(a.out))
+				a.delta = append(a.delta, make([]int32, a.width)...)
+				a.out = append(a.out, -1)
+			}
+			state = a.delta[edge]
+		}
+		a.out[state] = int32(id)
+	}
+
+	// Turn the trie into a DFA in breadth-first order: missing edges follow the
+	// failure link, which always points to a shallower, already completed state
+	fail := make([]int32, len(a.out))
+	a.dict = make([]int32, len(a.out))
+	a.dict[0] = -1
+	queue := make([]int32, 0, len(a.out))
+	for class := 1; class < a.width; class++ {
+		if child := a.delta[class]; child != 0 {
+			a.dict[child] = -1
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		row := int(state) * a.width
+		failRow := int(fail[state]) * a.width
+		for class := 1; class < a.width; class++ {
+			child := a.delta[row+class]
+			if child == 0 {
+				a.delta[row+class] = a.delta[failRow+class]
+				continue
+			}
+			fail[child] = a.delta[failRow+class]
+			if a.out[fail[child]] >= 0 {
+				a.dict[child] = fail[child]
+			} else {
+				a.dict[child] = a.dict[fail[child]]
+			}
+			queue = append(queue, child)
+		}
+	}
+	return a
+}
+
+// scan calls found with the ID of every pattern occurring in text. A pattern is
+// reported once per occurrence.
+func (a *ahoCorasick) scan(text string, found func(id int)) {
+	state := int32(0)
+	for i := 0; i < len(text); i++ {
+		state = a.delta[int(state)*a.width+int(a.classes[text[i]])]
+		for match := state; match > 0; match = a.dict[match] {
+			if id := a.out[match]; id >= 0 {
+				found(int(id))
+			}
+		}
+	}
+}
+
+// states returns the number of automaton states for diagnostics
+func (a *ahoCorasick) states() int {
+	return len(a.out)
+}
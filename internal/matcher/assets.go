@@ -0,0 +1,153 @@
+package matcher
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// assetSnapshot is an immutable view of the assets every rule serves. Cached results
+// reference it by rule and variant ID instead of holding copies of the CSS and JS.
+type assetSnapshot struct {
+	generation uint64
+	rules      map[string]*snapshotEntry
+}
+
+// snapshotEntry holds the assets of one rule and the generation they last changed in
+type snapshotEntry struct {
+	css      string
+	js       string
+	variants []domain.RuleVariant
+	since    uint64
+}
+
+// buildAssetSnapshot snapshots the assets of the rules for a generation. Entries of
+// rules whose assets did not change are shared with the previous snapshot and keep
+// their generation, so cached references to them stay valid.
+func buildAssetSnapshot(rules []domain.Rule, previous *assetSnapshot, generation uint64) *assetSnapshot {
+	snapshot := &assetSnapshot{
+		generation: generation,
+		rules:      make(map[string]*snapshotEntry, len(rules)),
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if previous != nil {
+			if existing := previous.rules[rule.ID]; existing != nil && existing.sameAs(rule) {
+				snapshot.rules[rule.ID] = existing
+				continue
+			}
+		}
+		snapshot.rules[rule.ID] = &snapshotEntry{
+			css:      rule.CSS,
+			js:       rule.JS,
+			variants: slices.Clone(rule.Variants),
+			since:    generation,
+		}
+	}
+	return snapshot
+}
+
+// sameAs reports whether the entry holds the rule's current assets
+func (a *snapshotEntry) sameAs(rule *domain.Rule) bool {
+	return a.css == rule.CSS && a.js == rule.JS && slices.Equal(a.variants, rule.Variants)
+}
+
+// assets returns the CSS and JS of a cached result. Results holding a reference are
+// read from the snapshot; fresh is false when the referenced assets changed after the
+// result was cached, or the result is newer than the snapshot.
+func (s *assetSnapshot) assets(cached *domain.MatchResult) (css, js string, fresh bool) {
+	if !cached.AssetsByRef {
+		return cached.CSS, cached.JS, true
+	}
+	if s == nil || cached.Generation > s.generation {
+		return "", "", false
+	}
+	return s.ruleAssets(cached.RuleID, cached.VariantID, cached.Generation)
+}
+
+// stackedAssets returns the merged CSS and JS of a cached stacked result. Results
+// holding a reference are merged again from the snapshot; fresh is false when the
+// assets of any of the stacked rules changed after the result was cached.
+func (s *assetSnapshot) stackedAssets(cached *domain.MatchResult) (css, js string, fresh bool) {
+	if !cached.AssetsByRef {
+		return cached.CSS, cached.JS, true
+	}
+	if s == nil || cached.Generation > s.generation {
+		return "", "", false
+	}
+	var stack assetStack
+	for i := len(cached.RuleIDs) - 1; i >= 0; i-- {
+		id := cached.RuleIDs[i]
+		ruleCSS, ruleJS, fresh := s.ruleAssets(id, cached.VariantIDs[id], cached.Generation)
+		if !fresh {
+			return "", "", false
+		}
+		stack.add(id, ruleCSS, ruleJS)
+	}
+	return stack.css.String(), stack.js.String(), true
+}
+
+// ruleAssets returns the assets of a rule's variant unless they changed after the generation
+func (s *assetSnapshot) ruleAssets(ruleID, variantID string, generation uint64) (css, js string, fresh bool) {
+	entry := s.rules[ruleID]
+	if entry == nil || entry.since > generation {
+		return "", "", false
+	}
+	if variantID == "" || variantID == domain.ControlVariantID {
+		return entry.css, entry.js, true
+	}
+	for i := range entry.variants {
+		if entry.variants[i].ID == variantID {
+			return entry.variants[i].CSS, entry.variants[i].JS, true
+		}
+	}
+	return "", "", false
+}
+
+// assetStack concatenates the assets of stacked rules, each preceded by a comment
+// naming the rule
+type assetStack struct {
+	css, js strings.Builder
+}
+
+// add appends the assets of a rule
+func (st *assetStack) add(ruleID, css, js string) {
+	boundary := "/* rule: " + strings.ReplaceAll(ruleID, "*/", "* /") + " */\n"
+	if css != "" {
+		st.css.WriteString(boundary)
+		st.css.WriteString(css)
+		st.css.WriteString("\n")
+	}
+	if js != "" {
+		st.js.WriteString(boundary)
+		st.js.WriteString(js)
+		st.js.WriteString("\n")
+	}
+}
+
+// assetReference returns the form of a result stored in the cache. Unless one of the
+// rules it was built from renders capture groups, which makes its output depend on the
+// URL, the copy references the rules' assets in the snapshot of the generation instead
+// of holding them.
+func assetReference(result *domain.MatchResult, generation uint64, rules ...*domain.Rule) *domain.MatchResult {
+	if slices.ContainsFunc(rules, rendersTemplates) {
+		return result
+	}
+	reference := *result
+	reference.CSS = ""
+	reference.JS = ""
+	reference.AssetsByRef = true
+	reference.Generation = generation
+	return &reference
+}
+
+// rendersTemplates reports whether any of the rule's assets contain template references
+func rendersTemplates(rule *domain.Rule) bool {
+	if strings.Contains(rule.CSS, "{{") || strings.Contains(rule.JS, "{{") {
+		return true
+	}
+	return slices.ContainsFunc(rule.Variants, func(variant domain.RuleVariant) bool {
+		return strings.Contains(variant.CSS, "{{") || strings.Contains(variant.JS, "{{")
+	})
+}
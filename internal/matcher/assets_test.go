@@ -0,0 +1,124 @@
+package matcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/cache"
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcher_CachedResultsReferenceAssets(t *testing.T) {
+	ctx := context.Background()
+	const (
+		shop = "https://shop.example.com/cart"
+		blog = "https://blog.example.com/post"
+	)
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "shop", Type: "host", Pattern: "shop.example.com", CSS: ".a { display: none; }", JS: "a();"},
+		{ID: "blog", Type: "regex", Pattern: `^https://(?P<site>blog)\.example\.com/`, CSS: ".{{.site}} { color: red; }"},
+	}}
+	lru := cache.NewLRUCache(100)
+	matcher := NewMatcher(repo, lru)
+	require.NoError(t, matcher.LoadRules(ctx))
+	warmCache(t, matcher, shop, blog)
+
+	// Plain assets are stored as a reference; rendered ones are URL specific and copied
+	cached, found := lru.Get(shop)
+	require.True(t, found)
+	assert.True(t, cached.AssetsByRef)
+	assert.Empty(t, cached.CSS)
+	assert.Empty(t, cached.JS)
+
+	cached, found = lru.Get(blog)
+	require.True(t, found)
+	assert.False(t, cached.AssetsByRef)
+	assert.Equal(t, ".blog { color: red; }", cached.CSS)
+
+	result, err := matcher.Resolve(ctx, shop)
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, ".a { display: none; }", result.CSS)
+	assert.Equal(t, "a();", result.JS)
+
+	// Unchanged rules keep their references valid across reloads
+	repo.rules = []domain.Rule{repo.rules[0], {ID: "blog", Type: "regex", Pattern: repo.rules[1].Pattern, CSS: "changed"}}
+	require.NoError(t, matcher.LoadRules(ctx))
+	result, err = matcher.Resolve(ctx, shop)
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+}
+
+func TestMatcher_StaleAssetReferenceIsResolvedAgain(t *testing.T) {
+	ctx := context.Background()
+	const shop = "https://shop.example.com/cart"
+	c := newMockCache()
+	matcher := NewMatcher(&mockRepository{}, c)
+	rule := domain.Rule{ID: "shop", Type: "host", Pattern: "shop.example.com", CSS: "old",
+		Variants: []domain.RuleVariant{{ID: "v1", Weight: 100, CSS: "variant"}}}
+	require.NoError(t, matcher.AddRule(ctx, &rule))
+	warmCache(t, matcher, shop)
+	stale := c.data[shop]
+	require.True(t, stale.AssetsByRef)
+
+	// A reference cached before the rule changed is never served, even if it survived invalidation
+	rule.Variants[0].CSS = "new"
+	require.NoError(t, matcher.UpdateRule(ctx, &rule))
+	c.Set(shop, stale)
+
+	result, err := matcher.Resolve(ctx, shop)
+	require.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.Equal(t, "new", result.CSS)
+	assert.Equal(t, "v1", result.VariantID)
+
+	result, err = matcher.Resolve(ctx, shop)
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, "new", result.CSS)
+}
+
+func TestMatcher_StaleStackedReferenceIsResolvedAgain(t *testing.T) {
+	ctx := context.Background()
+	const shop = "https://shop.example.com/cart"
+	c := newMockCache()
+	matcher := NewMatcher(&mockRepository{}, c)
+	host := domain.Rule{ID: "host", Type: "host", Pattern: "shop.example.com", CSS: "old"}
+	wildcard := domain.Rule{ID: "wildcard", Type: "wildcard", Pattern: "*example.com*", JS: "a();",
+		Variants: []domain.RuleVariant{{ID: "v1", Weight: 100, JS: "b();"}}}
+	require.NoError(t, matcher.AddRule(ctx, &host))
+	require.NoError(t, matcher.AddRule(ctx, &wildcard))
+
+	first, err := matcher.ResolveStacked(ctx, shop)
+	require.NoError(t, err)
+	stale := c.data[stackedCacheKey(shop)]
+	require.True(t, stale.AssetsByRef)
+	assert.Empty(t, stale.CSS)
+	assert.Empty(t, stale.JS)
+
+	// A hit merges the referenced assets exactly like the resolution did
+	result, err := matcher.ResolveStacked(ctx, shop)
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, first.CSS, result.CSS)
+	assert.Equal(t, "/* rule: wildcard */\nb();\n", result.JS)
+
+	// A reference cached before one of the stacked rules changed is never served
+	host.CSS = "new"
+	require.NoError(t, matcher.UpdateRule(ctx, &host))
+	c.Set(stackedCacheKey(shop), stale)
+
+	result, err = matcher.ResolveStacked(ctx, shop)
+	require.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.Equal(t, "/* rule: host */\nnew\n", result.CSS)
+
+	result, err = matcher.ResolveStacked(ctx, shop)
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, "/* rule: host */\nnew\n", result.CSS)
+	assert.Equal(t, map[string]string{"wildcard": "v1"}, result.VariantIDs)
+}
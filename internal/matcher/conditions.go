@@ -0,0 +1,287 @@
+package matcher
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// conditionField flags a render context field referenced by rule conditions
+type conditionField uint8
+
+const (
+	conditionViewport conditionField = 1 << iota
+	conditionUserAgent
+	conditionLanguage
+	conditionPaperFormat
+	conditionOrientation
+)
+
+// conditionFieldNames are reported in matcher statistics
+var conditionFieldNames = []struct {
+	field conditionField
+	name  string
+}{
+	{conditionViewport, "viewport_width"},
+	{conditionUserAgent, "user_agent"},
+	{conditionLanguage, "accept_language"},
+	{conditionPaperFormat, "paper_format"},
+	{conditionOrientation, "orientation"},
+}
+
+// conditionState summarizes the conditions of all rules. Only the render context
+// fields some rule references become part of cache keys.
+type conditionState struct {
+	fields conditionField
+	rules  int // Rules with at least one condition
+	// viewportBounds are the sorted widths at which some viewport condition changes
+	// its outcome, so widths between two bounds share a cache entry
+	viewportBounds []int
+}
+
+// contextKeyMarker separates the URL from the render context part of a cache key
+const contextKeyMarker = "\x00ctx"
+
+// buildConditionState collects the referenced fields and viewport bounds of the rules
+func buildConditionState(rules []domain.Rule) conditionState {
+	var state conditionState
+	for i := range rules {
+		conditions := rules[i].Conditions
+		if conditions.IsZero() {
+			continue
+		}
+		state.rules++
+
+		if conditions.MinViewportWidth != nil {
+			state.fields |= conditionViewport
+			state.viewportBounds = append(state.viewportBounds, *conditions.MinViewportWidth)
+		}
+		if conditions.MaxViewportWidth != nil {
+			state.fields |= conditionViewport
+			state.viewportBounds = append(state.viewportBounds, *conditions.MaxViewportWidth+1)
+		}
+		if conditions.UserAgent != "" {
+			state.fields |= conditionUserAgent
+		}
+		if len(conditions.Languages) > 0 {
+			state.fields |= conditionLanguage
+		}
+		if len(conditions.PaperFormats) > 0 {
+			state.fields |= conditionPaperFormat
+		}
+		if conditions.Orientation != "" {
+			state.fields |= conditionOrientation
+		}
+	}
+
+	slices.Sort(state.viewportBounds)
+	state.viewportBounds = slices.Compact(state.viewportBounds)
+	return state
+}
+
+// cacheKeySuffix encodes the referenced render context fields for use in a cache key.
+// It is empty while no rule has conditions.
+func (s conditionState) cacheKeySuffix(render *domain.RenderContext) string {
+	if s.fields == 0 {
+		return ""
+	}
+	if render == nil {
+		render = &domain.RenderContext{}
+	}
+
+	var b strings.Builder
+	b.WriteString(contextKeyMarker)
+	if s.fields&conditionViewport != 0 {
+		b.WriteString("|vw=")
+		if render.ViewportWidth > 0 {
+			bucket, found := slices.BinarySearch(s.viewportBounds, render.ViewportWidth)
+			if found {
+				bucket++
+			}
+			b.WriteString(strconv.Itoa(bucket))
+		}
+	}
+	if s.fields&conditionUserAgent != 0 {
+		b.WriteString("|ua=")
+		b.WriteString(render.UserAgent)
+	}
+	if s.fields&conditionLanguage != 0 {
+		b.WriteString("|lang=")
+		b.WriteString(strings.ToLower(preferredLanguage(render.AcceptLanguage)))
+	}
+	if s.fields&conditionPaperFormat != 0 {
+		b.WriteString("|paper=")
+		b.WriteString(strings.ToLower(render.PaperFormat))
+	}
+	if s.fields&conditionOrientation != 0 {
+		b.WriteString("|orientation=")
+		b.WriteString(strings.ToLower(render.Orientation))
+	}
+	return b.String()
+}
+
+// contextKeyFields are the cache key suffix fields in the order cacheKeySuffix writes them
+var contextKeyFields = []string{"|vw=", "|ua=", "|lang=", "|paper=", "|orientation="}
+
+// renderContextFromSuffix rebuilds a render context that produces the given cache key
+// suffix. A viewport bucket becomes the smallest width in it. It reports false when the
+// suffix does not fit the current viewport bounds.
+func (s conditionState) renderContextFromSuffix(suffix string) (*domain.RenderContext, bool) {
+	rest := strings.TrimPrefix(suffix, contextKeyMarker)
+
+	// Locate the fields in order; each value runs until the next field
+	starts := make([]int, len(contextKeyFields))
+	from := 0
+	for i, field := range contextKeyFields {
+		starts[i] = -1
+		if pos := strings.Index(rest[from:], field); pos >= 0 {
+			starts[i] = from + pos
+			from = starts[i] + len(field)
+		}
+	}
+	value := func(i int) string {
+		if starts[i] < 0 {
+			return ""
+		}
+		end := len(rest)
+		for _, next := range starts[i+1:] {
+			if next >= 0 {
+				end = next
+				break
+			}
+		}
+		return rest[starts[i]+len(contextKeyFields[i]) : end]
+	}
+
+	render := &domain.RenderContext{
+		UserAgent:      value(1),
+		AcceptLanguage: value(2),
+		PaperFormat:    value(3),
+		Orientation:    value(4),
+	}
+	if bucket := value(0); bucket != "" {
+		index, err := strconv.Atoi(bucket)
+		if err != nil || index < 0 || index > len(s.viewportBounds) {
+			return nil, false
+		}
+		render.ViewportWidth = 1
+		if index > 0 {
+			render.ViewportWidth = s.viewportBounds[index-1]
+		}
+	}
+	return render, true
+}
+
+// fieldNames returns the names of the referenced render context fields
+func (s conditionState) fieldNames() []string {
+	names := []string{}
+	for _, entry := range conditionFieldNames {
+		if s.fields&entry.field != 0 {
+			names = append(names, entry.name)
+		}
+	}
+	return names
+}
+
+// evaluateConditions checks the rule's conditions against the render context and
+// explains the first one that fails
+func evaluateConditions(rule *domain.Rule, render *domain.RenderContext) (bool, string) {
+	conditions := rule.Conditions
+	if conditions.IsZero() {
+		return true, ""
+	}
+	if render == nil {
+		render = &domain.RenderContext{}
+	}
+
+	if conditions.MinViewportWidth != nil || conditions.MaxViewportWidth != nil {
+		width := render.ViewportWidth
+		switch {
+		case width <= 0:
+			return false, "viewport width not provided"
+		case conditions.MinViewportWidth != nil && width < *conditions.MinViewportWidth:
+			return false, fmt.Sprintf("viewport width %d below minimum %d", width, *conditions.MinViewportWidth)
+		case conditions.MaxViewportWidth != nil && width > *conditions.MaxViewportWidth:
+			return false, fmt.Sprintf("viewport width %d above maximum %d", width, *conditions.MaxViewportWidth)
+		}
+	}
+
+	if conditions.UserAgent != "" {
+		switch {
+		case render.UserAgent == "":
+			return false, "user agent not provided"
+		case rule.GetCompiledUserAgent() == nil:
+			return false, "user agent condition failed to compile"
+		case !rule.GetCompiledUserAgent().MatchString(render.UserAgent):
+			return false, "user agent does not match"
+		}
+	}
+
+	if len(conditions.Languages) > 0 {
+		language := preferredLanguage(render.AcceptLanguage)
+		if language == "" {
+			return false, "accept language not provided"
+		}
+		if !slices.ContainsFunc(conditions.Languages, func(tag string) bool { return languageMatches(tag, language) }) {
+			return false, fmt.Sprintf("language %s not in %v", language, conditions.Languages)
+		}
+	}
+
+	if len(conditions.PaperFormats) > 0 {
+		if render.PaperFormat == "" {
+			return false, "paper format not provided"
+		}
+		if !slices.ContainsFunc(conditions.PaperFormats, func(format string) bool { return strings.EqualFold(format, render.PaperFormat) }) {
+			return false, fmt.Sprintf("paper format %s not in %v", render.PaperFormat, conditions.PaperFormats)
+		}
+	}
+
+	if conditions.Orientation != "" {
+		if render.Orientation == "" {
+			return false, "orientation not provided"
+		}
+		if !strings.EqualFold(conditions.Orientation, render.Orientation) {
+			return false, fmt.Sprintf("orientation %s does not match %s", render.Orientation, conditions.Orientation)
+		}
+	}
+
+	return true, ""
+}
+
+// preferredLanguage returns the language range with the highest quality value from an
+// Accept-Language header, or "" if there is none. Earlier entries win ties.
+func preferredLanguage(header string) string {
+	best, bestQuality := "", 0.0
+	for entry := range strings.SplitSeq(header, ",") {
+		tag, params, _ := strings.Cut(entry, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality > bestQuality {
+			best, bestQuality = tag, quality
+		}
+	}
+	return best
+}
+
+// languageMatches reports whether the language tag of a condition covers the language,
+// so "de" matches "de" and "de-AT" but not "den"
+func languageMatches(tag, language string) bool {
+	if len(language) < len(tag) || !strings.EqualFold(language[:len(tag)], tag) {
+		return false
+	}
+	return len(language) == len(tag) || language[len(tag)] == '-'
+}
@@ -0,0 +1,235 @@
+package matcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestEvaluateConditions(t *testing.T) {
+	rule := domain.Rule{
+		ID: "conditional",
+		Conditions: &domain.RuleConditions{
+			MinViewportWidth: intPtr(768),
+			MaxViewportWidth: intPtr(1920),
+			UserAgent:        "(?i)chrome",
+			Languages:        []string{"de", "en-GB"},
+			PaperFormats:     []string{"A4"},
+			Orientation:      domain.OrientationPortrait,
+		},
+	}
+	require.NoError(t, compilePatterns(&rule))
+
+	valid := domain.RenderContext{
+		ViewportWidth:  1024,
+		UserAgent:      "Mozilla/5.0 Chrome/120",
+		AcceptLanguage: "de-AT,en;q=0.5",
+		PaperFormat:    "a4",
+		Orientation:    "portrait",
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*domain.RenderContext)
+		reason string
+	}{
+		{"all conditions hold", func(*domain.RenderContext) {}, ""},
+		{"viewport missing", func(r *domain.RenderContext) { r.ViewportWidth = 0 }, "viewport width not provided"},
+		{"viewport too small", func(r *domain.RenderContext) { r.ViewportWidth = 375 }, "viewport width 375 below minimum 768"},
+		{"viewport too large", func(r *domain.RenderContext) { r.ViewportWidth = 2560 }, "viewport width 2560 above maximum 1920"},
+		{"user agent missing", func(r *domain.RenderContext) { r.UserAgent = "" }, "user agent not provided"},
+		{"user agent mismatch", func(r *domain.RenderContext) { r.UserAgent = "Firefox" }, "user agent does not match"},
+		{"language missing", func(r *domain.RenderContext) { r.AcceptLanguage = "" }, "accept language not provided"},
+		{"language mismatch", func(r *domain.RenderContext) { r.AcceptLanguage = "en-US,de;q=0.9" }, "language en-US not in [de en-GB]"},
+		{"language by quality", func(r *domain.RenderContext) { r.AcceptLanguage = "fr;q=0.3,en-gb;q=0.8" }, ""},
+		{"paper mismatch", func(r *domain.RenderContext) { r.PaperFormat = "Letter" }, "paper format Letter not in [A4]"},
+		{"orientation mismatch", func(r *domain.RenderContext) { r.Orientation = "landscape" }, "orientation landscape does not match portrait"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			render := valid
+			tt.modify(&render)
+			met, reason := evaluateConditions(&rule, &render)
+			assert.Equal(t, tt.reason == "", met)
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+
+	met, _ := evaluateConditions(&domain.Rule{}, nil)
+	assert.True(t, met, "rules without conditions always apply")
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"de":                        "de",
+		"de-DE,de;q=0.9,en;q=0.8":   "de-DE",
+		"en;q=0.5, fr;q=0.7":        "fr",
+		"*, es;q=0.2":               "es",
+		"it;q=0, pt;q=bad, nl;q=.1": "nl",
+	}
+	for header, expected := range tests {
+		assert.Equal(t, expected, preferredLanguage(header), header)
+	}
+}
+
+func TestConditionState_CacheKeySuffix(t *testing.T) {
+	assert.Empty(t, conditionState{}.cacheKeySuffix(&domain.RenderContext{PaperFormat: "A4"}))
+
+	state := buildConditionState([]domain.Rule{
+		{Conditions: &domain.RuleConditions{MinViewportWidth: intPtr(768)}},
+		{Conditions: &domain.RuleConditions{MaxViewportWidth: intPtr(1023), PaperFormats: []string{"Letter"}}},
+		{Conditions: &domain.RuleConditions{}},
+		{},
+	})
+	assert.Equal(t, 2, state.rules)
+	assert.Equal(t, []string{"viewport_width", "paper_format"}, state.fieldNames())
+	assert.Equal(t, []int{768, 1024}, state.viewportBounds)
+
+	key := func(width int, paper, userAgent string) string {
+		return state.cacheKeySuffix(&domain.RenderContext{ViewportWidth: width, PaperFormat: paper, UserAgent: userAgent})
+	}
+
+	// Unreferenced fields do not split cache entries
+	assert.Equal(t, key(800, "A4", "a"), key(800, "A4", "b"))
+	assert.Equal(t, key(800, "A4", ""), key(800, "a4", ""))
+	// Widths between the same bounds share a key
+	assert.Equal(t, key(800, "A4", ""), key(1023, "A4", ""))
+	assert.NotEqual(t, key(767, "A4", ""), key(768, "A4", ""))
+	assert.NotEqual(t, key(1023, "A4", ""), key(1024, "A4", ""))
+	assert.NotEqual(t, key(800, "A4", ""), key(800, "Letter", ""))
+	assert.NotEqual(t, key(0, "A4", ""), key(1, "A4", ""))
+}
+
+func TestMatcher_ResolveWithConditions(t *testing.T) {
+	ctx := context.Background()
+	cache := newMockCache()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "letter", Type: "wildcard", Pattern: "https://example.com/*", CSS: "@page { size: letter; }", Priority: intPtr(2000),
+			Conditions: &domain.RuleConditions{PaperFormats: []string{"Letter"}}},
+		{ID: "mobile", Type: "wildcard", Pattern: "https://example.com/*", CSS: ".nav { display: none; }", Priority: intPtr(1500),
+			Conditions: &domain.RuleConditions{MaxViewportWidth: intPtr(767)}},
+		{ID: "site", Type: "wildcard", Pattern: "https://example.com/*", CSS: ".banner { display: none; }"},
+	}}
+	matcher := NewMatcher(repo, cache)
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	resolve := func(render *domain.RenderContext) *domain.MatchResult {
+		result, err := matcher.Resolve(domain.WithRenderContext(ctx, render), "https://example.com/page")
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.Equal(t, "site", resolve(nil).RuleID)
+	assert.Equal(t, "letter", resolve(&domain.RenderContext{PaperFormat: "letter", ViewportWidth: 375}).RuleID)
+	assert.Equal(t, "mobile", resolve(&domain.RenderContext{PaperFormat: "A4", ViewportWidth: 375}).RuleID)
+	assert.Equal(t, "site", resolve(&domain.RenderContext{PaperFormat: "A4", ViewportWidth: 1280}).RuleID)
+
+	// Contexts that only differ in unreferenced fields share the cache entry
+	result := resolve(&domain.RenderContext{PaperFormat: "A4", ViewportWidth: 1440, UserAgent: "bot"})
+	assert.Equal(t, "site", result.RuleID)
+	assert.True(t, result.CacheHit)
+
+	stats := matcher.GetStats(ctx)
+	assert.Equal(t, 2, stats["conditional_rules"])
+	assert.Equal(t, []string{"viewport_width", "paper_format"}, stats["context_key_fields"])
+
+	// Explain reports rules whose pattern matched but whose conditions failed
+	explanation, err := matcher.Explain(domain.WithRenderContext(ctx, &domain.RenderContext{PaperFormat: "A4", ViewportWidth: 1280}), "https://example.com/page")
+	require.NoError(t, err)
+	require.Len(t, explanation.Evaluations, 3)
+	assert.Equal(t, "site", explanation.Evaluations[0].RuleID)
+	failed := explanation.Evaluations[1]
+	assert.Equal(t, domain.EvaluationConditionsFailed, failed.Outcome)
+	assert.True(t, failed.Matched)
+	assert.Contains(t, failed.Reason, "conditions not met: ")
+}
+
+// Feature: github.com/freewebtopdf/asset-injector, Property 44: Render contexts with equal cache keys resolve identically
+func TestProperty_ContextCacheKeyConsistency(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	genConditions := gopter.CombineGens(
+		gen.IntRange(0, 3),
+		gen.IntRange(300, 1500),
+		gen.OneConstOf("", "A4", "Letter"),
+		gen.OneConstOf("", "de", "en-GB"),
+	).Map(func(values []any) *domain.RuleConditions {
+		conditions := &domain.RuleConditions{}
+		width := values[1].(int)
+		switch values[0].(int) {
+		case 1:
+			conditions.MinViewportWidth = &width
+		case 2:
+			conditions.MaxViewportWidth = &width
+		case 3:
+			minWidth := width / 2
+			conditions.MinViewportWidth, conditions.MaxViewportWidth = &minWidth, &width
+		}
+		if paper := values[2].(string); paper != "" {
+			conditions.PaperFormats = []string{paper}
+		}
+		if language := values[3].(string); language != "" {
+			conditions.Languages = []string{language}
+		}
+		return conditions
+	})
+
+	genRender := gopter.CombineGens(
+		gen.IntRange(0, 2000),
+		gen.OneConstOf("", "A4", "a4", "Letter", "Legal"),
+		gen.OneConstOf("", "de-DE", "en-GB,de;q=0.5", "fr", "DE"),
+		gen.OneConstOf("", "Chrome", "Firefox"),
+	).Map(func(values []any) *domain.RenderContext {
+		return &domain.RenderContext{
+			ViewportWidth:  values[0].(int),
+			PaperFormat:    values[1].(string),
+			AcceptLanguage: values[2].(string),
+			UserAgent:      values[3].(string),
+		}
+	})
+
+	properties.Property("For any rule set and two render contexts with the same cache key, resolution should select the same rule", prop.ForAll(
+		func(conditions []*domain.RuleConditions, a, b *domain.RenderContext) bool {
+			rules := make([]domain.Rule, len(conditions))
+			for i := range conditions {
+				rules[i] = domain.Rule{ID: string(rune('a' + i)), Type: "wildcard", Pattern: "https://example.com/*", CSS: "css", Priority: intPtr(i), Conditions: conditions[i]}
+			}
+			matcher := NewMatcher(&mockRepository{rules: rules}, newMockCache())
+			if err := matcher.LoadRules(context.Background()); err != nil {
+				return false
+			}
+
+			if matcher.snapshot.Load().conditions.cacheKeySuffix(a) != matcher.snapshot.Load().conditions.cacheKeySuffix(b) {
+				return true
+			}
+			// Explain bypasses the cache, so both contexts are evaluated independently
+			first, err := matcher.Explain(domain.WithRenderContext(context.Background(), a), "https://example.com/")
+			if err != nil {
+				return false
+			}
+			second, err := matcher.Explain(domain.WithRenderContext(context.Background(), b), "https://example.com/")
+			if err != nil {
+				return false
+			}
+			return first.Result.RuleID == second.Result.RuleID
+		},
+		gen.SliceOfN(5, genConditions),
+		genRender,
+		genRender,
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
@@ -0,0 +1,106 @@
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/urlpattern"
+)
+
+// compileExcludes pre-compiles the regex and URL pattern excludes of a rule. The
+// exclude slice is copied first because rules share it with the repository.
+func compileExcludes(rule *domain.Rule) error {
+	if len(rule.Exclude) == 0 {
+		return nil
+	}
+	rule.Exclude = slices.Clone(rule.Exclude)
+
+	for i := range rule.Exclude {
+		exclude := &rule.Exclude[i]
+		field := fmt.Sprintf("exclude[%d].pattern", i)
+
+		switch exclude.Type {
+		case "regex":
+			compiled, err := regexp.Compile(exclude.Pattern)
+			if err != nil {
+				return domain.NewAppError(
+					domain.ErrValidationFailed,
+					"Invalid exclude regex pattern",
+					422,
+					map[string]any{
+						"field":  field,
+						"value":  exclude.Pattern,
+						"reason": err.Error(),
+					},
+				)
+			}
+			exclude.SetCompiledRegex(compiled)
+		case "urlpattern":
+			compiled, err := urlpattern.Compile(exclude.Pattern)
+			if err != nil {
+				return domain.NewAppError(
+					domain.ErrValidationFailed,
+					"Invalid exclude URL pattern",
+					422,
+					map[string]any{
+						"field":  field,
+						"value":  exclude.Pattern,
+						"reason": err.Error(),
+					},
+				)
+			}
+			exclude.SetCompiledURLPattern(compiled)
+		}
+	}
+	return nil
+}
+
+// excludedBy returns the first exclude pattern of the rule matching the URL, or nil.
+// Excludes that failed to compile reject every URL so a broken exclude never widens
+// the set of pages a rule applies to.
+func excludedBy(rule *domain.Rule, url string) *domain.ExcludePattern {
+	for i := range rule.Exclude {
+		if excludeMatches(&rule.Exclude[i], url) {
+			return &rule.Exclude[i]
+		}
+	}
+	return nil
+}
+
+// excludeMatches reports whether a single exclude pattern matches the URL
+func excludeMatches(exclude *domain.ExcludePattern, url string) bool {
+	switch exclude.Type {
+	case "exact":
+		return exclude.Pattern == url
+	case "regex":
+		compiled := exclude.GetCompiledRegex()
+		return compiled == nil || compiled.MatchString(url)
+	case "wildcard":
+		return wildcardMatch(exclude.Pattern, url)
+	case "urlpattern":
+		compiled := exclude.GetCompiledURLPattern()
+		return compiled == nil || compiled.Match(url)
+	case "path_prefix":
+		return matchPathPrefix(exclude.Pattern, url)
+	case "host":
+		return matchHost(exclude.Pattern, url)
+	default:
+		return true
+	}
+}
+
+// rejectedReason explains why a rule was rejected by one of its exclude patterns
+func rejectedReason(exclude *domain.ExcludePattern) string {
+	if invalidExclude(*exclude) {
+		return fmt.Sprintf("exclude %s pattern %q failed to compile", exclude.Type, exclude.Pattern)
+	}
+	return fmt.Sprintf("rejected by exclude %s pattern %q", exclude.Type, exclude.Pattern)
+}
+
+// invalidExclude reports whether an exclude pattern is missing its compiled form
+func invalidExclude(exclude domain.ExcludePattern) bool {
+	return (exclude.Type == "regex" && exclude.GetCompiledRegex() == nil) ||
+		(exclude.Type == "urlpattern" && exclude.GetCompiledURLPattern() == nil)
+}
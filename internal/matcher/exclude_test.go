@@ -0,0 +1,90 @@
+package matcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExcludeMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		exclude domain.ExcludePattern
+		url     string
+		matches bool
+	}{
+		{"exact", domain.ExcludePattern{Type: "exact", Pattern: "https://example.com/cart"}, "https://example.com/cart", true},
+		{"exact other", domain.ExcludePattern{Type: "exact", Pattern: "https://example.com/cart"}, "https://example.com/carts", false},
+		{"wildcard", domain.ExcludePattern{Type: "wildcard", Pattern: "https://example.com/checkout/*"}, "https://example.com/checkout/pay", true},
+		{"regex", domain.ExcludePattern{Type: "regex", Pattern: `/admin(/|$)`}, "https://example.com/admin", true},
+		{"regex other", domain.ExcludePattern{Type: "regex", Pattern: `/admin(/|$)`}, "https://example.com/administration", false},
+		{"path prefix", domain.ExcludePattern{Type: "path_prefix", Pattern: "https://example.com/account"}, "https://example.com/account/orders", true},
+		{"host", domain.ExcludePattern{Type: "host", Pattern: "shop.example.com"}, "https://eu.shop.example.com/", true},
+		{"host other", domain.ExcludePattern{Type: "host", Pattern: "shop.example.com"}, "https://example.com/shop", false},
+		{"urlpattern", domain.ExcludePattern{Type: "urlpattern", Pattern: `https://example.com/orders/:id(\d+)`}, "https://example.com/orders/42", true},
+		{"uncompiled regex", domain.ExcludePattern{Type: "regex", Pattern: "(admin"}, "https://example.com/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &domain.Rule{Type: "host", Pattern: "example.com", Exclude: []domain.ExcludePattern{tt.exclude}}
+			_ = compileExcludes(rule)
+			assert.Equal(t, tt.matches, excludeMatches(&rule.Exclude[0], tt.url))
+		})
+	}
+}
+
+func TestCompileExcludes(t *testing.T) {
+	shared := []domain.ExcludePattern{{Type: "regex", Pattern: "/admin"}}
+	rule := &domain.Rule{Type: "host", Pattern: "example.com", Exclude: shared}
+
+	require.NoError(t, compileExcludes(rule))
+	assert.NotNil(t, rule.Exclude[0].GetCompiledRegex())
+	assert.Nil(t, shared[0].GetCompiledRegex(), "the caller's exclude slice must not be modified")
+
+	rule.Exclude = []domain.ExcludePattern{{Type: "urlpattern", Pattern: "/relative"}}
+	err := compileExcludes(rule)
+	require.Error(t, err)
+	var appErr *domain.AppError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "exclude[0].pattern", appErr.Details.(map[string]any)["field"])
+}
+
+func TestMatcher_ResolveWithExcludes(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "site", Type: "host", Pattern: "Example.com", CSS: ".banner { display: none; }", Exclude: []domain.ExcludePattern{
+			{Type: "wildcard", Pattern: "https://example.com/checkout/*"},
+			{Type: "exact", Pattern: "HTTPS://EXAMPLE.COM/cart"},
+		}},
+		{ID: "docs", Type: "path_prefix", Pattern: "https://example.com/docs", CSS: ".toc { display: none; }"},
+	}}
+	matcher := NewMatcherWithConfig(repo, newMockCache(), MatcherConfig{Normalization: NormalizeConfig{Enabled: true}})
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	resolve := func(url string) string {
+		result, err := matcher.Resolve(ctx, url)
+		require.NoError(t, err)
+		return result.RuleID
+	}
+
+	assert.Equal(t, "site", resolve("https://example.com/"))
+	assert.Equal(t, "site", resolve("https://shop.example.com/checkout/pay"))
+	assert.Empty(t, resolve("https://example.com/checkout/pay"))
+	assert.Empty(t, resolve("https://example.com/cart"), "exact excludes are normalized like the URL")
+	assert.Equal(t, "docs", resolve("https://example.com/docs/intro"))
+
+	explanation, err := matcher.Explain(ctx, "https://example.com/checkout/pay")
+	require.NoError(t, err)
+	require.NotEmpty(t, explanation.Evaluations)
+	rejected := explanation.Evaluations[0]
+	assert.Equal(t, "site", rejected.RuleID)
+	assert.Equal(t, domain.EvaluationRejected, rejected.Outcome)
+	assert.True(t, rejected.Matched)
+	assert.Equal(t, `rejected by exclude wildcard pattern "https://example.com/checkout/*"`, rejected.Reason)
+	assert.Empty(t, explanation.Result.RuleID)
+}
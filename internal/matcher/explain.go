@@ -0,0 +1,222 @@
+package matcher
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// Explain evaluates the URL against the rule set and reports the outcome for every
+// rule that was considered. The cache is neither read nor written, so the result
+// always reflects the current rules. When the repository implements
+// domain.ExcludedRuleSource, inactive rules whose pattern matches are listed too.
+func (m *Matcher) Explain(ctx context.Context, url string) (*domain.ResolveExplanation, error) {
+	select {
+	case <-ctx.Done():
+		return nil, domain.NewAppErrorWithCause(
+			domain.ErrTimeout,
+			"Explain operation cancelled",
+			408,
+			ctx.Err(),
+			map[string]any{"url": url},
+		).WithContext(ctx, "explain")
+	default:
+	}
+
+	// The regex prefilter is bypassed so non-matching regex rules are reported too
+	target := m.newResolveTarget(ctx, url)
+	ruleCount := len(target.snapshot.rules)
+	candidates := m.candidateRules(target, false)
+
+	evaluations := make([]domain.RuleEvaluation, 0, len(candidates))
+	winner := -1
+	var bestScore int
+
+	for i, rule := range candidates {
+		select {
+		case <-ctx.Done():
+			return nil, domain.NewAppErrorWithCause(
+				domain.ErrTimeout,
+				"Explain operation cancelled during matching",
+				408,
+				ctx.Err(),
+				map[string]any{"url": url, "processed_rules": i},
+			).WithContext(ctx, "explain")
+		default:
+		}
+
+		evaluation := newRuleEvaluation(rule)
+		matches, breakdown := m.scoreRule(rule, target.urlFor(rule))
+		var rejectedBy *domain.ExcludePattern
+		if matches {
+			rejectedBy = excludedBy(rule, target.urlFor(rule))
+		}
+		met, conditionReason := evaluateConditions(rule, target.render)
+		switch {
+		case rejectedBy != nil:
+			evaluation.Matched = true
+			evaluation.Outcome = domain.EvaluationRejected
+			evaluation.Reason = rejectedReason(rejectedBy)
+			evaluation.Score = &breakdown
+		case matches && rule.ScheduleStatus(target.now) != domain.ScheduleActive:
+			evaluation.Matched = true
+			evaluation.Outcome = domain.EvaluationOutsideWindow
+			evaluation.Reason = scheduleReason(rule, target.now)
+			evaluation.Score = &breakdown
+		case matches && !met:
+			evaluation.Matched = true
+			evaluation.Outcome = domain.EvaluationConditionsFailed
+			evaluation.Reason = "conditions not met: " + conditionReason
+			evaluation.Score = &breakdown
+		case matches:
+			evaluation.Matched = true
+			evaluation.Outcome = domain.EvaluationLost
+			evaluation.Score = &breakdown
+			// Same selection as Resolve: higher score wins, earlier rule wins ties
+			if winner < 0 || breakdown.Total > bestScore {
+				winner = i
+				bestScore = breakdown.Total
+			}
+		default:
+			evaluation.Outcome = domain.EvaluationNoMatch
+			evaluation.Reason = noMatchReason(rule)
+		}
+		evaluations = append(evaluations, evaluation)
+	}
+
+	result := &domain.MatchResult{Timestamp: time.Now()}
+	if winner >= 0 {
+		best := candidates[winner]
+		result.RuleID = best.ID
+		result.VariantID, result.CSS, result.JS = ruleAssets(best, target)
+		result.Score = bestScore
+
+		for i := range evaluations {
+			switch {
+			case i == winner:
+				evaluations[i].Outcome = domain.EvaluationWinner
+			case evaluations[i].Outcome != domain.EvaluationLost:
+			case evaluations[i].Score.Total == bestScore:
+				evaluations[i].Reason = fmt.Sprintf("tied with rule %s, which was added earlier", best.ID)
+			default:
+				evaluations[i].Reason = fmt.Sprintf("outscored by rule %s (score %d)", best.ID, bestScore)
+			}
+		}
+	}
+
+	evaluations = append(evaluations, m.explainExcluded(ctx, target)...)
+
+	// Winner first, then losing matches by score, matches failing their conditions,
+	// matches outside their validity window, matches rejected by an exclude pattern,
+	// excluded matches, and finally non-matches
+	slices.SortStableFunc(evaluations, func(a, b domain.RuleEvaluation) int {
+		if c := cmp.Compare(outcomeRank(a.Outcome), outcomeRank(b.Outcome)); c != 0 {
+			return c
+		}
+		return cmp.Compare(evaluationScore(b), evaluationScore(a))
+	})
+
+	return &domain.ResolveExplanation{
+		URL:            url,
+		NormalizedURL:  target.normalized,
+		Result:         result,
+		Evaluations:    evaluations,
+		RuleCount:      ruleCount,
+		SkippedByIndex: ruleCount - len(candidates),
+	}, nil
+}
+
+// explainExcluded evaluates inactive rules reported by the repository and returns
+// those whose pattern matches the URL
+func (m *Matcher) explainExcluded(ctx context.Context, target resolveTarget) []domain.RuleEvaluation {
+	source, ok := m.repository.(domain.ExcludedRuleSource)
+	if !ok {
+		return nil
+	}
+
+	var evaluations []domain.RuleEvaluation
+	for _, excluded := range source.GetExcludedRules(ctx) {
+		rule := excluded.Rule
+		if err := compilePatterns(&rule); err != nil {
+			continue
+		}
+
+		rule = m.prepareRule(rule)
+		matches, breakdown := m.scoreRule(&rule, target.urlFor(&rule))
+		if !matches || excludedBy(&rule, target.urlFor(&rule)) != nil {
+			continue
+		}
+
+		evaluation := newRuleEvaluation(&rule)
+		evaluation.Matched = true
+		evaluation.Outcome = domain.EvaluationExcluded
+		evaluation.Reason = excluded.Reason
+		evaluation.Score = &breakdown
+		evaluations = append(evaluations, evaluation)
+	}
+	return evaluations
+}
+
+// newRuleEvaluation fills in the rule identification fields of an evaluation
+func newRuleEvaluation(rule *domain.Rule) domain.RuleEvaluation {
+	return domain.RuleEvaluation{
+		RuleID:  rule.ID,
+		Type:    rule.Type,
+		Pattern: rule.Pattern,
+		Source:  rule.Source,
+	}
+}
+
+// noMatchReason explains why a rule did not match
+func noMatchReason(rule *domain.Rule) string {
+	switch rule.Type {
+	case "exact", "wildcard", "path_prefix":
+		return "pattern does not match URL"
+	case "host":
+		return "URL host is neither the pattern host nor one of its subdomains"
+	case "regex":
+		if rule.GetCompiledRegex() == nil {
+			return "regex pattern failed to compile"
+		}
+		return "pattern does not match URL"
+	case "urlpattern":
+		if rule.GetCompiledURLPattern() == nil {
+			return "URL pattern failed to compile"
+		}
+		return "pattern does not match URL"
+	default:
+		return fmt.Sprintf("unsupported rule type %q", rule.Type)
+	}
+}
+
+// outcomeRank orders evaluation outcomes for display
+func outcomeRank(outcome string) int {
+	switch outcome {
+	case domain.EvaluationWinner:
+		return 0
+	case domain.EvaluationLost:
+		return 1
+	case domain.EvaluationConditionsFailed:
+		return 2
+	case domain.EvaluationOutsideWindow:
+		return 3
+	case domain.EvaluationRejected:
+		return 4
+	case domain.EvaluationExcluded:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// evaluationScore returns the total score of an evaluation, or 0 when it did not match
+func evaluationScore(evaluation domain.RuleEvaluation) int {
+	if evaluation.Score == nil {
+		return 0
+	}
+	return evaluation.Score.Total
+}
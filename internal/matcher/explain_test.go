@@ -0,0 +1,109 @@
+package matcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// excludingRepository reports a fixed set of excluded rules alongside the active ones
+type excludingRepository struct {
+	mockRepository
+	excluded []domain.ExcludedRule
+}
+
+func (r *excludingRepository) GetExcludedRules(ctx context.Context) []domain.ExcludedRule {
+	return r.excluded
+}
+
+func TestMatcher_Explain(t *testing.T) {
+	ctx := context.Background()
+	priority := 50
+	repo := &excludingRepository{
+		mockRepository: mockRepository{rules: []domain.Rule{
+			{ID: "site", Type: "wildcard", Pattern: "https://example.com/*", CSS: "site"},
+			{ID: "page", Type: "exact", Pattern: "https://example.com/page", CSS: "page"},
+			{ID: "low", Type: "regex", Pattern: `^https://example\.com/`, Priority: &priority},
+			{ID: "docs", Type: "regex", Pattern: `^https://example\.com/docs/`},
+			{ID: "elsewhere", Type: "exact", Pattern: "https://other.org/"},
+		}},
+		excluded: []domain.ExcludedRule{
+			{Rule: domain.Rule{ID: "muted", Type: "wildcard", Pattern: "*example.com*"}, Reason: "disabled"},
+			{Rule: domain.Rule{ID: "unrelated", Type: "exact", Pattern: "https://other.org/"}, Reason: "disabled"},
+		},
+	}
+	cache := newMockCache()
+	matcher := NewMatcher(repo, cache)
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	explanation, err := matcher.Explain(ctx, "https://example.com/page")
+	require.NoError(t, err)
+
+	assert.Equal(t, "page", explanation.Result.RuleID)
+	assert.Equal(t, "page", explanation.Result.CSS)
+	assert.Equal(t, 1000+len("https://example.com/page"), explanation.Result.Score)
+	assert.Equal(t, 5, explanation.RuleCount)
+	assert.Equal(t, 1, explanation.SkippedByIndex)
+
+	ids := make([]string, len(explanation.Evaluations))
+	for i, evaluation := range explanation.Evaluations {
+		ids[i] = evaluation.RuleID
+	}
+	assert.Equal(t, []string{"page", "site", "low", "muted", "docs"}, ids)
+
+	winner := explanation.Evaluations[0]
+	assert.Equal(t, domain.EvaluationWinner, winner.Outcome)
+	assert.Equal(t, domain.ScoreBreakdown{BaseScore: 1000, LengthBonus: 24, Total: 1024}, *winner.Score)
+
+	lost := explanation.Evaluations[2]
+	assert.Equal(t, domain.EvaluationLost, lost.Outcome)
+	assert.Equal(t, 500, lost.Score.BaseScore)
+	assert.Equal(t, 50, *lost.Score.PriorityOverride)
+	assert.Equal(t, 50, lost.Score.Total)
+	assert.Contains(t, lost.Reason, "outscored by rule page")
+
+	excluded := explanation.Evaluations[3]
+	assert.Equal(t, domain.EvaluationExcluded, excluded.Outcome)
+	assert.True(t, excluded.Matched)
+	assert.Equal(t, "disabled", excluded.Reason)
+
+	noMatch := explanation.Evaluations[4]
+	assert.Equal(t, domain.EvaluationNoMatch, noMatch.Outcome)
+	assert.False(t, noMatch.Matched)
+	assert.Nil(t, noMatch.Score)
+
+	// Explain must neither read nor populate the cache
+	_, cached := cache.Get("https://example.com/page")
+	assert.False(t, cached)
+}
+
+func TestMatcher_Explain_AgreesWithResolve(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "first", Type: "wildcard", Pattern: "https://example.com/*"},
+		{ID: "second", Type: "wildcard", Pattern: "https://example.org/*"},
+		{ID: "tie", Type: "wildcard", Pattern: "https://example.com/?"},
+	}}
+	matcher := NewMatcher(repo, newMockCache())
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	for _, url := range []string{"https://example.com/a", "https://example.org/b", "https://unknown.net/"} {
+		explanation, err := matcher.Explain(ctx, url)
+		require.NoError(t, err)
+		result, err := matcher.Resolve(ctx, url)
+		require.NoError(t, err)
+
+		assert.Equal(t, result.RuleID, explanation.Result.RuleID, url)
+		assert.Equal(t, result.Score, explanation.Result.Score, url)
+	}
+
+	explanation, err := matcher.Explain(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	require.Len(t, explanation.Evaluations, 2)
+	assert.Equal(t, "tie", explanation.Evaluations[1].RuleID)
+	assert.Contains(t, explanation.Evaluations[1].Reason, "tied with rule first")
+}
@@ -0,0 +1,111 @@
+package matcher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// flightKey identifies resolutions that produce the same result. The generation keeps
+// callers arriving after a rule change from joining a resolution of the old rules.
+type flightKey struct {
+	cacheKey   string
+	generation uint64
+}
+
+// flight is a resolution shared by concurrent callers
+type flight struct {
+	done    chan struct{}
+	result  *domain.MatchResult
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// flightGroup coalesces concurrent cache misses for the same key into one resolution
+type flightGroup struct {
+	mu        sync.Mutex
+	flights   map[flightKey]*flight
+	leaders   int64 // Resolutions started
+	coalesced int64 // Callers that waited for a resolution started by another caller
+	abandoned int64 // Resolutions cancelled because every caller gave up
+}
+
+// do returns the result of resolve for the key, running it once for all concurrent
+// callers. resolve runs detached from the cancellation of any single caller: a caller
+// whose context ends stops waiting and gets its context error, and the resolution is
+// only cancelled once every caller has given up. Every caller gets its own copy of the
+// result. The bool reports whether the result was computed for another caller.
+func (g *flightGroup) do(ctx context.Context, key flightKey, resolve func(ctx context.Context) (*domain.MatchResult, error)) (*domain.MatchResult, bool, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[flightKey]*flight)
+	}
+	f, shared := g.flights[key]
+	if shared {
+		f.waiters++
+		g.coalesced++
+	} else {
+		resolveCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.flights[key] = f
+		g.leaders++
+		go g.run(resolveCtx, key, f, resolve)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if f.err != nil {
+			return nil, shared, f.err
+		}
+		return f.result.Clone(), shared, nil
+	case <-ctx.Done():
+		g.leave(key, f)
+		return nil, shared, ctx.Err()
+	}
+}
+
+// run resolves the key and releases the waiting callers
+func (g *flightGroup) run(ctx context.Context, key flightKey, f *flight, resolve func(ctx context.Context) (*domain.MatchResult, error)) {
+	defer close(f.done)
+	defer f.cancel()
+	f.result, f.err = resolve(ctx)
+
+	g.mu.Lock()
+	g.forget(key, f)
+	g.mu.Unlock()
+}
+
+// leave removes a caller that gave up and cancels the resolution once nobody waits for it
+func (g *flightGroup) leave(key flightKey, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f.waiters--
+	if f.waiters == 0 && g.forget(key, f) {
+		g.abandoned++
+		f.cancel()
+	}
+}
+
+// forget removes the flight unless a newer one already took its key
+func (g *flightGroup) forget(key flightKey, f *flight) bool {
+	if g.flights[key] != f {
+		return false
+	}
+	delete(g.flights, key)
+	return true
+}
+
+// stats returns the coalescing counters for matcher statistics
+func (g *flightGroup) stats() map[string]any {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return map[string]any{
+		"in_flight": len(g.flights),
+		"started":   g.leaders,
+		"coalesced": g.coalesced,
+		"abandoned": g.abandoned,
+	}
+}
@@ -0,0 +1,209 @@
+package matcher
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingCache holds every Set until release is closed, keeping resolutions in flight
+type blockingCache struct {
+	*mockCache
+	storing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newBlockingCache() *blockingCache {
+	return &blockingCache{mockCache: newMockCache(), storing: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (c *blockingCache) Set(key string, result *domain.MatchResult) {
+	c.once.Do(func() { close(c.storing) })
+	<-c.release
+	c.mockCache.Set(key, result)
+}
+
+func TestMatcher_CoalescesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "docs", Type: "wildcard", Pattern: "*docs.example.com*", CSS: "a"},
+	}}
+	c := newBlockingCache()
+	matcher := NewMatcher(repo, c)
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	const callers = 8
+	results := make(chan *domain.MatchResult, callers)
+	errs := make(chan error, callers)
+	resolve := func() {
+		result, err := matcher.Resolve(ctx, "https://docs.example.com/guide")
+		results <- result
+		errs <- err
+	}
+
+	go resolve()
+	<-c.storing
+	for range callers - 1 {
+		go resolve()
+	}
+	require.Eventually(t, func() bool {
+		return matcher.flights.stats()["coalesced"] == int64(callers-1)
+	}, time.Second, time.Millisecond)
+	close(c.release)
+
+	seen := make(map[*domain.MatchResult]bool)
+	for range callers {
+		require.NoError(t, <-errs)
+		result := <-results
+		assert.Equal(t, "docs", result.RuleID)
+		assert.Equal(t, "a", result.CSS)
+		assert.False(t, seen[result], "callers must not share a result")
+		seen[result] = true
+	}
+
+	stats := matcher.flights.stats()
+	assert.Equal(t, int64(1), stats["started"])
+	assert.Equal(t, 0, stats["in_flight"])
+	assert.Equal(t, stats, matcher.GetStats(ctx)["resolve_coalescing"])
+
+	// The shared result was cached once
+	cached, found := c.Get(matcher.newResolveTarget(ctx, "https://docs.example.com/guide").cacheKey)
+	require.True(t, found)
+	assert.Equal(t, "docs", cached.RuleID)
+}
+
+func TestMatcher_CoalescedResolveCancellation(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "docs", Type: "wildcard", Pattern: "*docs.example.com*", CSS: "a"},
+	}}
+	c := newBlockingCache()
+	matcher := NewMatcher(repo, c)
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	// The caller that started the resolution gives up; the one waiting for it still gets the result
+	leaderCtx, cancelLeader := context.WithCancel(ctx)
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := matcher.Resolve(leaderCtx, "https://docs.example.com/guide")
+		leaderErr <- err
+	}()
+	<-c.storing
+
+	waiterResult := make(chan *domain.MatchResult, 1)
+	go func() {
+		result, err := matcher.Resolve(ctx, "https://docs.example.com/guide")
+		assert.NoError(t, err)
+		waiterResult <- result
+	}()
+	require.Eventually(t, func() bool {
+		return matcher.flights.stats()["coalesced"] == int64(1)
+	}, time.Second, time.Millisecond)
+
+	cancelLeader()
+	err := <-leaderErr
+	var appErr *domain.AppError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 408, appErr.StatusCode)
+
+	close(c.release)
+	assert.Equal(t, "docs", (<-waiterResult).RuleID)
+	assert.Equal(t, int64(0), matcher.flights.stats()["abandoned"])
+}
+
+func TestFlightGroup_AbandonedResolution(t *testing.T) {
+	var group flightGroup
+	key := flightKey{cacheKey: "https://example.com/"}
+	started := make(chan struct{})
+	resolveErr := make(chan error, 1)
+	resolve := func(ctx context.Context) (*domain.MatchResult, error) {
+		close(started)
+		<-ctx.Done()
+		resolveErr <- ctx.Err()
+		return nil, ctx.Err()
+	}
+
+	callerCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := group.do(callerCtx, key, resolve)
+		done <- err
+	}()
+	<-started
+	cancel()
+
+	// Once its only caller is gone the resolution is cancelled and forgotten
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.ErrorIs(t, <-resolveErr, context.Canceled)
+	stats := group.stats()
+	assert.Equal(t, int64(1), stats["abandoned"])
+	assert.Equal(t, 0, stats["in_flight"])
+
+	// A later caller starts a new resolution
+	result, shared, err := group.do(context.Background(), key, func(context.Context) (*domain.MatchResult, error) {
+		return &domain.MatchResult{RuleID: "rule"}, nil
+	})
+	require.NoError(t, err)
+	assert.False(t, shared)
+	assert.Equal(t, "rule", result.RuleID)
+	assert.Equal(t, int64(2), group.stats()["started"])
+}
+
+func TestMatcher_CoalescedResultsAreNotShared(t *testing.T) {
+	ctx := context.Background()
+	const url = "https://docs.example.com/guide"
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "docs", Type: "host", Pattern: "docs.example.com", CSS: "a",
+			Variants: []domain.RuleVariant{{ID: "v1", Weight: 100, CSS: "b"}}},
+		{ID: "site", Type: "wildcard", Pattern: "*example.com*", JS: "c();"},
+	}}
+	c := newBlockingCache()
+	matcher := NewMatcher(repo, c)
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	const callers = 4
+	results := make(chan *domain.MatchResult, callers)
+	resolve := func() {
+		result, err := matcher.ResolveStacked(ctx, url)
+		assert.NoError(t, err)
+		results <- result
+	}
+	go resolve()
+	<-c.storing
+	for range callers - 1 {
+		go resolve()
+	}
+	require.Eventually(t, func() bool {
+		return matcher.flights.stats()["coalesced"] == int64(callers-1)
+	}, time.Second, time.Millisecond)
+	close(c.release)
+
+	// Every caller modifies its own result while the others read theirs
+	var wg sync.WaitGroup
+	for i := range callers {
+		result := <-results
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, []string{"site", "docs"}, result.RuleIDs)
+			assert.Equal(t, "v1", result.VariantIDs["docs"])
+			if i == 0 {
+				result.RuleIDs[1] = "changed"
+				result.VariantIDs["docs"] = "changed"
+			}
+		}()
+	}
+	wg.Wait()
+
+	cached, found := c.Get(stackedCacheKey(url))
+	require.True(t, found)
+	assert.Equal(t, []string{"site", "docs"}, cached.RuleIDs)
+	assert.Equal(t, map[string]string{"docs": "v1"}, cached.VariantIDs)
+}
@@ -0,0 +1,367 @@
+package matcher
+
+import (
+	"regexp/syntax"
+	"slices"
+	"strings"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// indexKind identifies which bucket of the rule index a rule is stored in
+type indexKind int
+
+const (
+	// indexFallback holds rules that have to be evaluated for every URL
+	indexFallback indexKind = iota
+	// indexExact holds exact rules keyed by their full pattern
+	indexExact
+	// indexHost holds rules that only match a single literal host
+	indexHost
+	// indexHostSuffix holds rules that match any host ending in a literal suffix
+	indexHostSuffix
+	// indexDomain holds host rules matching a hostname and all of its subdomains
+	indexDomain
+	// indexHostname holds rules matching a single literal hostname on any port
+	indexHostname
+)
+
+// indexKey describes where a rule lives in the index
+type indexKey struct {
+	kind  indexKind
+	value string
+}
+
+// patternKey identifies rules whose patterns are indexed the same way
+type patternKey struct {
+	ruleType string
+	pattern  string
+}
+
+// ruleIndex narrows down the rules that need to be evaluated for a URL.
+// Rule positions refer to the matcher's rules slice, so candidates can be
+// evaluated in insertion order to keep tie-breaking stable.
+type ruleIndex struct {
+	exact     map[string][]int
+	hosts     *hostNode
+	hostnames map[string][]int
+	domains   *hostNode
+	// hostnameRules counts rules in the hostname and domain buckets, which need
+	// the URL's hostname extracted before lookup
+	hostnameRules int
+	fallback      []int
+	// Bucket of every indexed pattern, reused when the next index is built
+	keys map[patternKey]indexKey
+}
+
+// hostNode is a trie node keyed by host labels in reverse order (com → example → www)
+type hostNode struct {
+	children map[string]*hostNode
+	exact    []int // Rules whose host is exactly the label path to this node
+	suffix   []int // Rules matching any host that ends with "." + the label path
+	domain   []int // Rules matching the label path itself and any host ending with "." + it
+}
+
+// newRuleIndex creates an empty rule index
+func newRuleIndex() *ruleIndex {
+	return &ruleIndex{
+		exact:     make(map[string][]int),
+		hosts:     &hostNode{},
+		hostnames: make(map[string][]int),
+		domains:   &hostNode{},
+		keys:      make(map[patternKey]indexKey),
+	}
+}
+
+// buildRuleIndex creates an index over all rules in the slice. Patterns with a known
+// bucket are not analysed again.
+func buildRuleIndex(rules []domain.Rule, known map[patternKey]indexKey) *ruleIndex {
+	idx := newRuleIndex()
+	for i := range rules {
+		pk := patternKey{ruleType: rules[i].Type, pattern: rules[i].Pattern}
+		key, found := idx.keys[pk]
+		if !found {
+			if key, found = known[pk]; !found {
+				key = indexKeyFor(&rules[i])
+			}
+			idx.keys[pk] = key
+		}
+		idx.add(key, i)
+	}
+	return idx
+}
+
+// add inserts the rule position into the bucket of its key
+func (idx *ruleIndex) add(key indexKey, pos int) {
+	switch key.kind {
+	case indexExact:
+		idx.exact[key.value] = append(idx.exact[key.value], pos)
+	case indexHost:
+		node := idx.hosts.insert(key.value)
+		node.exact = append(node.exact, pos)
+	case indexHostSuffix:
+		node := idx.hosts.insert(key.value)
+		node.suffix = append(node.suffix, pos)
+	case indexDomain:
+		node := idx.domains.insert(key.value)
+		node.domain = append(node.domain, pos)
+		idx.hostnameRules++
+	case indexHostname:
+		idx.hostnames[key.value] = append(idx.hostnames[key.value], pos)
+		idx.hostnameRules++
+	default:
+		idx.fallback = append(idx.fallback, pos)
+	}
+}
+
+// candidates returns the positions of all rules that could match the URL,
+// sorted in ascending order without duplicates
+func (idx *ruleIndex) candidates(url string) []int {
+	exact := idx.exact[url]
+	positions := make([]int, 0, len(exact)+len(idx.fallback)+4)
+	positions = append(positions, exact...)
+	positions = append(positions, idx.fallback...)
+
+	if schemeEnd := strings.Index(url, "://"); schemeEnd >= 0 {
+		rest := url[schemeEnd+3:]
+		authorityEnd := strings.IndexAny(rest, "/?#")
+		if authorityEnd < 0 {
+			authorityEnd = len(rest)
+		}
+		positions = idx.hosts.collect(rest[:authorityEnd], true, positions)
+		// Host wildcards can span path separators, so every longer prefix ending
+		// right before a "/" is a potential host for suffix rules
+		for i := authorityEnd + 1; i < len(rest); i++ {
+			if rest[i] == '/' {
+				positions = idx.hosts.collect(rest[:i], false, positions)
+			}
+		}
+	}
+
+	if idx.hostnameRules > 0 {
+		if hostname := urlHostname(url); hostname != "" {
+			positions = append(positions, idx.hostnames[hostname]...)
+			positions = idx.domains.collectDomain(hostname, positions)
+		}
+	}
+
+	slices.Sort(positions)
+	return slices.Compact(positions)
+}
+
+// size returns the number of rules per bucket for diagnostics
+func (idx *ruleIndex) size() map[string]int {
+	hostRules, suffixRules := idx.hosts.count()
+	exactRules := 0
+	for _, positions := range idx.exact {
+		exactRules += len(positions)
+	}
+	hostnameRules := 0
+	for _, positions := range idx.hostnames {
+		hostnameRules += len(positions)
+	}
+	return map[string]int{
+		"exact":       exactRules,
+		"host":        hostRules,
+		"host_suffix": suffixRules,
+		"domain":      idx.hostnameRules - hostnameRules,
+		"hostname":    hostnameRules,
+		"fallback":    len(idx.fallback),
+	}
+}
+
+// insert walks the trie for the host, creating nodes as needed
+func (n *hostNode) insert(host string) *hostNode {
+	node := n
+	for end := len(host); ; {
+		dot := strings.LastIndexByte(host[:end], '.')
+		label := host[dot+1 : end]
+		if node.children == nil {
+			node.children = make(map[string]*hostNode)
+		}
+		child, exists := node.children[label]
+		if !exists {
+			child = &hostNode{}
+			node.children[label] = child
+		}
+		node = child
+		if dot < 0 {
+			return node
+		}
+		end = dot
+	}
+}
+
+// collect appends the rules whose host entry matches the given host.
+// Suffix rules apply while labels remain in front of the matched path;
+// exact host rules only apply when the whole host is consumed.
+func (n *hostNode) collect(host string, includeExact bool, positions []int) []int {
+	node := n
+	for end := len(host); ; {
+		dot := strings.LastIndexByte(host[:end], '.')
+		node = node.children[host[dot+1:end]]
+		if node == nil {
+			return positions
+		}
+		if dot < 0 {
+			if includeExact {
+				positions = append(positions, node.exact...)
+			}
+			return positions
+		}
+		positions = append(positions, node.suffix...)
+		end = dot
+	}
+}
+
+// collectDomain appends the domain rules for the host and every parent domain of it
+func (n *hostNode) collectDomain(host string, positions []int) []int {
+	node := n
+	for end := len(host); ; {
+		dot := strings.LastIndexByte(host[:end], '.')
+		node = node.children[host[dot+1:end]]
+		if node == nil {
+			return positions
+		}
+		positions = append(positions, node.domain...)
+		if dot < 0 {
+			return positions
+		}
+		end = dot
+	}
+}
+
+// count returns the number of exact host and host suffix rules below this node
+func (n *hostNode) count() (int, int) {
+	hostRules, suffixRules := len(n.exact), len(n.suffix)
+	for _, child := range n.children {
+		h, s := child.count()
+		hostRules += h
+		suffixRules += s
+	}
+	return hostRules, suffixRules
+}
+
+// indexKeyFor determines the index bucket for a rule
+func indexKeyFor(rule *domain.Rule) indexKey {
+	switch rule.Type {
+	case "exact":
+		return indexKey{kind: indexExact, value: rule.Pattern}
+	case "wildcard":
+		return wildcardIndexKey(rule.Pattern)
+	case "regex":
+		return regexIndexKey(rule.Pattern)
+	case "path_prefix":
+		return pathPrefixIndexKey(rule.Pattern)
+	case "host":
+		return indexKey{kind: indexDomain, value: rule.Pattern}
+	case "urlpattern":
+		if compiled := rule.GetCompiledURLPattern(); compiled != nil {
+			if hostname, ok := compiled.Hostname(); ok {
+				return indexKey{kind: indexHostname, value: hostname}
+			}
+		}
+		return indexKey{kind: indexFallback}
+	default:
+		return indexKey{kind: indexFallback}
+	}
+}
+
+// wildcardIndexKey extracts a literal host or host suffix from a wildcard pattern.
+// Only patterns of the form scheme://host/... or scheme://*.suffix/... are indexed.
+func wildcardIndexKey(pattern string) indexKey {
+	schemeEnd := strings.Index(pattern, "://")
+	if schemeEnd <= 0 || strings.ContainsAny(pattern[:schemeEnd], "*?") {
+		return indexKey{kind: indexFallback}
+	}
+
+	rest := pattern[schemeEnd+3:]
+	slash := strings.IndexByte(rest, '/')
+	if slash <= 0 {
+		return indexKey{kind: indexFallback}
+	}
+	host := rest[:slash]
+
+	if suffix, found := strings.CutPrefix(host, "*."); found {
+		if suffix != "" && !strings.ContainsAny(suffix, "*?") {
+			return indexKey{kind: indexHostSuffix, value: suffix}
+		}
+		return indexKey{kind: indexFallback}
+	}
+
+	if strings.ContainsAny(host, "*?") {
+		return indexKey{kind: indexFallback}
+	}
+	return indexKey{kind: indexHost, value: host}
+}
+
+// regexIndexKey extracts a literal host from a regex anchored with ^scheme://host/
+func regexIndexKey(pattern string) indexKey {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return indexKey{kind: indexFallback}
+	}
+	re = re.Simplify()
+
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 || re.Sub[0].Op != syntax.OpBeginText {
+		return indexKey{kind: indexFallback}
+	}
+	literal := re.Sub[1]
+	if literal.Op != syntax.OpLiteral || literal.Flags&syntax.FoldCase != 0 {
+		return indexKey{kind: indexFallback}
+	}
+
+	prefix := string(literal.Rune)
+	schemeEnd := strings.Index(prefix, "://")
+	if schemeEnd <= 0 {
+		return indexKey{kind: indexFallback}
+	}
+	rest := prefix[schemeEnd+3:]
+	slash := strings.IndexByte(rest, '/')
+	if slash <= 0 {
+		return indexKey{kind: indexFallback}
+	}
+	return indexKey{kind: indexHost, value: rest[:slash]}
+}
+
+// pathPrefixIndexKey extracts the literal authority of a path prefix pattern
+func pathPrefixIndexKey(pattern string) indexKey {
+	schemeEnd := strings.Index(pattern, "://")
+	if schemeEnd <= 0 {
+		return indexKey{kind: indexFallback}
+	}
+	authority := pattern[schemeEnd+3:]
+	if slash := strings.IndexByte(authority, '/'); slash >= 0 {
+		authority = authority[:slash]
+	}
+	if authority == "" {
+		return indexKey{kind: indexFallback}
+	}
+	return indexKey{kind: indexHost, value: authority}
+}
+
+// urlHostname returns the lowercase hostname of an absolute URL without port or
+// credentials, keeping the brackets of IPv6 literals. It returns "" if there is none.
+func urlHostname(url string) string {
+	schemeEnd := strings.Index(url, "://")
+	if schemeEnd < 0 {
+		return ""
+	}
+	authority := url[schemeEnd+3:]
+	if end := strings.IndexAny(authority, "/?#"); end >= 0 {
+		authority = authority[:end]
+	}
+	if at := strings.LastIndexByte(authority, '@'); at >= 0 {
+		authority = authority[at+1:]
+	}
+
+	host := authority
+	if strings.HasPrefix(authority, "[") {
+		if end := strings.IndexByte(authority, ']'); end >= 0 {
+			host = authority[:end+1]
+		}
+	} else if colon := strings.LastIndexByte(authority, ':'); colon >= 0 {
+		host = authority[:colon]
+	}
+	return strings.ToLower(host)
+}
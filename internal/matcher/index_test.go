@@ -0,0 +1,254 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// linearResolve evaluates every rule in order, mirroring the pre-index matcher
+func linearResolve(m *Matcher, rules []domain.Rule, url string) (string, int) {
+	bestID, bestScore, found := "", 0, false
+	for i := range rules {
+		if matches, score := m.matchRule(&rules[i], url); matches {
+			if !found || score > bestScore {
+				bestID, bestScore, found = rules[i].ID, score, true
+			}
+		}
+	}
+	return bestID, bestScore
+}
+
+func TestIndexKeyFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		ruleType string
+		pattern  string
+		expected indexKey
+	}{
+		{"exact", "exact", "https://example.com/a", indexKey{indexExact, "https://example.com/a"}},
+		{"wildcard host", "wildcard", "https://example.com/*", indexKey{indexHost, "example.com"}},
+		{"wildcard host with port", "wildcard", "http://example.com:8080/docs/*", indexKey{indexHost, "example.com:8080"}},
+		{"wildcard host suffix", "wildcard", "https://*.example.com/*", indexKey{indexHostSuffix, "example.com"}},
+		{"wildcard partial host", "wildcard", "https://*example.com/*", indexKey{kind: indexFallback}},
+		{"wildcard inner label", "wildcard", "https://www.*.com/*", indexKey{kind: indexFallback}},
+		{"wildcard without path", "wildcard", "https://example.com*", indexKey{kind: indexFallback}},
+		{"wildcard scheme", "wildcard", "*://example.com/*", indexKey{kind: indexFallback}},
+		{"wildcard without scheme", "wildcard", "*.com", indexKey{kind: indexFallback}},
+		{"anchored regex", "regex", `^https://example\.com/docs/\d+$`, indexKey{indexHost, "example.com"}},
+		{"unanchored regex", "regex", `https://example\.com/`, indexKey{kind: indexFallback}},
+		{"regex with scheme alternation", "regex", `^https?://example\.com/`, indexKey{kind: indexFallback}},
+		{"case insensitive regex", "regex", `(?i)^https://example\.com/`, indexKey{kind: indexFallback}},
+		{"regex without host terminator", "regex", `^https://example\.com`, indexKey{kind: indexFallback}},
+		{"path prefix", "path_prefix", "https://example.com/docs", indexKey{indexHost, "example.com"}},
+		{"path prefix without path", "path_prefix", "http://example.com:8080", indexKey{indexHost, "example.com:8080"}},
+		{"host", "host", "example.com", indexKey{indexDomain, "example.com"}},
+		{"urlpattern literal hostname", "urlpattern", "https://Example.com:8080/books/:id", indexKey{indexHostname, "example.com"}},
+		{"urlpattern hostname group", "urlpattern", "https://*.example.com/*", indexKey{kind: indexFallback}},
+		{"unknown type", "other", "https://example.com/", indexKey{kind: indexFallback}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &domain.Rule{Type: tt.ruleType, Pattern: tt.pattern}
+			require.NoError(t, compilePatterns(rule))
+			assert.Equal(t, tt.expected, indexKeyFor(rule))
+		})
+	}
+}
+
+func TestRuleIndex_Candidates(t *testing.T) {
+	rules := []domain.Rule{
+		{Type: "exact", Pattern: "https://example.com/a"},
+		{Type: "wildcard", Pattern: "https://example.com/*"},
+		{Type: "wildcard", Pattern: "https://*.example.com/*"},
+		{Type: "wildcard", Pattern: "https://other.org/*"},
+		{Type: "regex", Pattern: `^https://docs\.example\.com/`},
+		{Type: "wildcard", Pattern: "*print*"},
+	}
+	idx := buildRuleIndex(rules, nil)
+
+	assert.Equal(t, []int{0, 1, 5}, idx.candidates("https://example.com/a"))
+	assert.Equal(t, []int{2, 4, 5}, idx.candidates("https://docs.example.com/guide"))
+	assert.Equal(t, []int{3, 5}, idx.candidates("https://other.org/"))
+	assert.Equal(t, []int{5}, idx.candidates("https://unknown.net/"))
+	assert.Equal(t, []int{5}, idx.candidates("not a url"))
+
+	// Host wildcards can swallow path segments, so later segments are checked too
+	assert.Equal(t, []int{2, 5}, idx.candidates("https://evil.org/x.example.com/y"))
+}
+
+func TestRuleIndex_HostnameCandidates(t *testing.T) {
+	rules := []domain.Rule{
+		{Type: "host", Pattern: "example.com"},
+		{Type: "host", Pattern: "docs.example.com"},
+		{Type: "urlpattern", Pattern: "https://docs.example.com/:page"},
+		{Type: "path_prefix", Pattern: "https://example.com/docs"},
+		{Type: "path_prefix", Pattern: "https://other.org"},
+	}
+	for i := range rules {
+		require.NoError(t, compilePatterns(&rules[i]))
+	}
+	idx := buildRuleIndex(rules, nil)
+
+	assert.Equal(t, []int{0, 3}, idx.candidates("https://example.com/docs"))
+	assert.Equal(t, []int{0, 1, 2}, idx.candidates("https://DOCS.example.com:8443/intro"))
+	assert.Equal(t, []int{0}, idx.candidates("https://a.b.example.com?q=1"))
+	assert.Equal(t, []int{4}, idx.candidates("https://other.org"))
+	assert.Empty(t, idx.candidates("https://notexample.com/"))
+	assert.Equal(t, map[string]int{"exact": 0, "host": 2, "host_suffix": 0, "domain": 2, "hostname": 1, "fallback": 0}, idx.size())
+}
+
+func TestURLHostname(t *testing.T) {
+	tests := map[string]string{
+		"https://Example.com/a":        "example.com",
+		"https://example.com:8443?q=1": "example.com",
+		"https://user:pw@example.com/": "example.com",
+		"http://[::1]:8080/":           "[::1]",
+		"https://example.com#top":      "example.com",
+		"not a url":                    "",
+		"https:///path":                "",
+	}
+	for url, expected := range tests {
+		assert.Equal(t, expected, urlHostname(url), url)
+	}
+}
+
+func TestMatcher_SlashInQueryWithoutPath(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "prefix", Type: "path_prefix", Pattern: "https://a.example.com/", CSS: "a"},
+		{ID: "suffix", Type: "wildcard", Pattern: "https://*.example.com/*", CSS: "b"},
+		{ID: "raw", Type: "wildcard", Pattern: "https://a.example.com*", CSS: "c", MatchRaw: true},
+	}}
+	matcher := NewMatcher(repo, newMockCache())
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	// The authority ends at "?" even though a "/" follows in the query
+	for _, url := range []string{"https://a.example.com?q=/a", "https://a.example.com#/top", "https://a.example.com?q=/a/b/"} {
+		result, err := matcher.Resolve(ctx, url)
+		require.NoError(t, err)
+		expectedID, expectedScore := linearResolve(matcher, matcher.snapshot.Load().rules, url)
+		require.NotEmpty(t, expectedID, url)
+		assert.Equal(t, expectedID, result.RuleID, url)
+		assert.Equal(t, expectedScore, result.Score, url)
+	}
+}
+
+func TestMatcher_IndexFollowsRuleUpdates(t *testing.T) {
+	ctx := context.Background()
+	matcher := NewMatcher(&mockRepository{}, newMockCache())
+
+	rule := domain.Rule{ID: uuid.New().String(), Type: "wildcard", Pattern: "https://example.com/*", CSS: "a"}
+	require.NoError(t, matcher.AddRule(ctx, &rule))
+
+	result, err := matcher.Resolve(ctx, "https://example.com/page")
+	require.NoError(t, err)
+	assert.Equal(t, rule.ID, result.RuleID)
+
+	rule.Pattern = "https://other.org/*"
+	require.NoError(t, matcher.UpdateRule(ctx, &rule))
+
+	result, err = matcher.Resolve(ctx, "https://example.com/page")
+	require.NoError(t, err)
+	assert.Empty(t, result.RuleID)
+
+	result, err = matcher.Resolve(ctx, "https://other.org/page")
+	require.NoError(t, err)
+	assert.Equal(t, rule.ID, result.RuleID)
+
+	require.NoError(t, matcher.RemoveRule(ctx, rule.ID))
+	result, err = matcher.Resolve(ctx, "https://other.org/page")
+	require.NoError(t, err)
+	assert.Empty(t, result.RuleID)
+}
+
+// Feature: github.com/freewebtopdf/asset-injector, Property 41: Indexed resolution equivalence
+func TestProperty_IndexedResolutionEquivalence(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	hosts := []string{"example.com", "www.example.com", "docs.example.com", "other.org", "a.b.other.org"}
+
+	genRule := gopter.CombineGens(
+		gen.IntRange(0, 10),
+		gen.IntRange(0, len(hosts)-1),
+		gen.IntRange(0, 3),
+	).Map(func(values []any) domain.Rule {
+		host := hosts[values[1].(int)]
+		path := fmt.Sprintf("/p%d", values[2].(int))
+		var ruleType, pattern string
+		switch values[0].(int) {
+		case 0:
+			ruleType, pattern = "exact", "https://"+host+path
+		case 1:
+			ruleType, pattern = "wildcard", "https://"+host+"/*"
+		case 2:
+			ruleType, pattern = "wildcard", "https://*."+host+"/*"
+		case 3:
+			ruleType, pattern = "wildcard", "*"+path+"*"
+		case 4:
+			ruleType, pattern = "regex", "^https://"+regexp.QuoteMeta(host)+path
+		case 5:
+			ruleType, pattern = "regex", regexp.QuoteMeta(host)
+		case 6:
+			ruleType, pattern = "wildcard", "https://*"+host+"/*"
+		case 7:
+			ruleType, pattern = "wildcard", "https://"+host+path+"?"
+		case 8:
+			ruleType, pattern = "host", host
+		case 9:
+			ruleType, pattern = "path_prefix", "https://"+host+path
+		default:
+			ruleType, pattern = "urlpattern", "https://"+host+path+"{/*}?"
+		}
+		return domain.Rule{ID: uuid.New().String(), Type: ruleType, Pattern: pattern, CSS: "css"}
+	})
+
+	genURL := gopter.CombineGens(
+		gen.IntRange(0, len(hosts)-1),
+		gen.IntRange(0, 3),
+		gen.IntRange(0, 2),
+	).Map(func(values []any) string {
+		host := hosts[values[0].(int)]
+		switch values[2].(int) {
+		case 1:
+			return fmt.Sprintf("https://%s/p%d/x.%s/", host, values[1].(int), hosts[(values[0].(int)+1)%len(hosts)])
+		case 2:
+			// No path, but a "/" in the query
+			return fmt.Sprintf("https://%s?next=/p%d", host, values[1].(int))
+		}
+		return fmt.Sprintf("https://%s/p%d", host, values[1].(int))
+	})
+
+	properties.Property("For any rule set and URL, indexed resolution should select the same rule and score as a linear scan", prop.ForAll(
+		func(rules []domain.Rule, url string) bool {
+			repo := &mockRepository{rules: rules}
+			matcher := NewMatcher(repo, newMockCache())
+			if err := matcher.LoadRules(context.Background()); err != nil {
+				return false
+			}
+
+			result, err := matcher.Resolve(context.Background(), url)
+			if err != nil {
+				return false
+			}
+
+			expectedID, expectedScore := linearResolve(matcher, matcher.snapshot.Load().rules, url)
+			return result.RuleID == expectedID && result.Score == expectedScore
+		},
+		gen.SliceOfN(20, genRule),
+		genURL,
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
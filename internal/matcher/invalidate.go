@@ -0,0 +1,143 @@
+package matcher
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// maxInvalidationMatches bounds the pattern matches a targeted invalidation runs while
+// it holds the cache lock: rules to match times cached entries. Larger changes, such as
+// bulk applies and pack syncs, clear the cache instead of blocking resolutions.
+const maxInvalidationMatches = 1 << 16
+
+// ruleChange describes which cached results a rule set change may have affected
+type ruleChange struct {
+	all        bool           // Every entry is affected, e.g. because rules were reordered
+	referenced []string       // Rules whose results are stale wherever they were served
+	matching   []*domain.Rule // Rules that may now be served for the URLs they match
+}
+
+// cacheKeyScheme captures everything that determines how cache keys are built. When it
+// changes, lookups stop finding the existing keys, which are dropped instead.
+type cacheKeyScheme struct {
+	fields         conditionField
+	viewportBounds []int
+}
+
+// keyScheme returns the cache key scheme of the snapshot
+func (s *ruleSnapshot) keyScheme() cacheKeyScheme {
+	return cacheKeyScheme{
+		fields:         s.conditions.fields,
+		viewportBounds: s.conditions.viewportBounds,
+	}
+}
+
+// equal reports whether two schemes build the same keys
+func (s cacheKeyScheme) equal(other cacheKeyScheme) bool {
+	return s.fields == other.fields && slices.Equal(s.viewportBounds, other.viewportBounds)
+}
+
+// invalidate drops the cached results a rule change may have affected: entries served
+// by a referenced rule, and entries for URLs a matching rule now matches, since it may
+// outrank the cached winner or join a stacked result. Patterns and excludes are checked
+// but conditions and validity windows are not, which only errs on the side of dropping.
+// Changes too large to match against every entry clear the cache.
+// Must be called with m.mu held, after the next snapshot has been published.
+func (m *Matcher) invalidate(change ruleChange, previous, next *ruleSnapshot) int {
+	if change.all || !previous.keyScheme().equal(next.keyScheme()) {
+		return m.invalidateAll()
+	}
+	if len(change.referenced) == 0 && len(change.matching) == 0 {
+		return 0
+	}
+	if len(change.matching) > 0 {
+		stats := m.cache.Stats()
+		if len(change.matching)*(stats.Size+stats.NegativeSize) > maxInvalidationMatches {
+			return m.invalidateAll()
+		}
+	}
+
+	referenced := make(map[string]bool, len(change.referenced))
+	for _, id := range change.referenced {
+		referenced[id] = true
+	}
+	return m.cache.InvalidateWhere(func(key string, result *domain.MatchResult) bool {
+		if referenced[result.RuleID] {
+			return true
+		}
+		for _, id := range result.RuleIDs {
+			if referenced[id] {
+				return true
+			}
+		}
+		if len(change.matching) == 0 {
+			return false
+		}
+
+		target := m.keyTarget(key)
+		for _, rule := range change.matching {
+			if matches, _ := m.matchRule(rule, target.urlFor(rule)); matches {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// invalidateAll drops every cached result, keeping the cache counters
+func (m *Matcher) invalidateAll() int {
+	return m.cache.InvalidateWhere(func(string, *domain.MatchResult) bool { return true })
+}
+
+// keyTarget recovers the forms of the URL a cache key was built from. A key without a
+// raw URL is shared by raw forms no raw rule matched, so its normalized URL stands in.
+func (m *Matcher) keyTarget(key string) resolveTarget {
+	normalized, raw, _, _ := parseCacheKey(key)
+	return resolveTarget{raw: raw, normalized: normalized}
+}
+
+// diffRules compares a reloaded rule set with the current one by ID. Both must be
+// prepared. A change in the relative order of kept rules affects tie-breaking anywhere
+// and invalidates everything.
+func diffRules(current, reloaded []domain.Rule) ruleChange {
+	positions := make(map[string]int, len(current))
+	for i := range current {
+		positions[current[i].ID] = i
+	}
+
+	var change ruleChange
+	kept := make(map[string]bool, len(reloaded))
+	last := -1
+	for i := range reloaded {
+		rule := &reloaded[i]
+		pos, exists := positions[rule.ID]
+		if !exists {
+			change.matching = append(change.matching, rule)
+			continue
+		}
+		kept[rule.ID] = true
+		if pos < last {
+			return ruleChange{all: true}
+		}
+		last = pos
+		if !sameRule(&current[pos], rule) {
+			change.referenced = append(change.referenced, rule.ID)
+			change.matching = append(change.matching, rule)
+		}
+	}
+	for i := range current {
+		if !kept[current[i].ID] {
+			change.referenced = append(change.referenced, current[i].ID)
+		}
+	}
+	return change
+}
+
+// sameRule reports whether two versions of a rule have the same exported fields
+func sameRule(a, b *domain.Rule) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(encodedA) == string(encodedB)
+}
@@ -0,0 +1,214 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/cache"
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// warmCache resolves every URL once so its result is cached
+func warmCache(t *testing.T, matcher *Matcher, urls ...string) {
+	t.Helper()
+	for _, url := range urls {
+		_, err := matcher.Resolve(context.Background(), url)
+		require.NoError(t, err)
+	}
+}
+
+// cachedURLs returns which of the URLs still have a cached single-rule result
+func cachedURLs(c domain.CacheManager, urls ...string) []string {
+	var cached []string
+	for _, url := range urls {
+		if _, found := c.Get(url); found {
+			cached = append(cached, url)
+		}
+	}
+	return cached
+}
+
+func TestMatcher_TargetedInvalidation(t *testing.T) {
+	ctx := context.Background()
+	const (
+		shop = "https://shop.example.com/cart"
+		blog = "https://blog.example.com/post"
+		docs = "https://docs.example.org/intro"
+	)
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "shop", Type: "host", Pattern: "shop.example.com", CSS: "a"},
+		{ID: "blog", Type: "host", Pattern: "blog.example.com", CSS: "b"},
+		{ID: "docs", Type: "host", Pattern: "docs.example.org", CSS: "c"},
+	}}
+	lru := cache.NewLRUCache(100)
+	matcher := NewMatcher(repo, lru)
+	require.NoError(t, matcher.LoadRules(ctx))
+	warmCache(t, matcher, shop, blog, docs)
+
+	// Adding a rule drops only the URLs it matches
+	checkout := domain.Rule{ID: "checkout", Type: "wildcard", Pattern: "https://shop.example.com/*", CSS: "d"}
+	require.NoError(t, matcher.AddRule(ctx, &checkout))
+	assert.Equal(t, []string{blog, docs}, cachedURLs(lru, blog, docs, shop))
+
+	// Updating a rule drops the results of its old version and the URLs it now matches
+	warmCache(t, matcher, shop)
+	checkout.Pattern = "https://blog.example.com/*"
+	require.NoError(t, matcher.UpdateRule(ctx, &checkout))
+	assert.Equal(t, []string{docs}, cachedURLs(lru, blog, docs, shop))
+
+	// Removing a rule drops only the results it contributed to
+	warmCache(t, matcher, shop, blog)
+	_, err := matcher.ResolveStacked(ctx, blog)
+	require.NoError(t, err)
+	require.NoError(t, matcher.RemoveRule(ctx, "checkout"))
+	assert.Equal(t, []string{shop, docs}, cachedURLs(lru, shop, blog, docs))
+	_, found := lru.Get(stackedCacheKey(blog))
+	assert.False(t, found)
+
+	// Counters are kept across invalidations
+	stats := lru.Stats()
+	assert.Positive(t, stats.Hits)
+	assert.Positive(t, stats.Invalidations)
+	assert.Equal(t, stats.Invalidations, matcher.GetStats(ctx)["cache_invalidations"])
+}
+
+func TestMatcher_ReloadInvalidatesChangedRules(t *testing.T) {
+	ctx := context.Background()
+	const (
+		shop = "https://shop.example.com/cart"
+		blog = "https://blog.example.com/post"
+	)
+	// The mock repository shares its slice, so every reload gets a fresh one
+	ruleSet := func(blogCSS string) []domain.Rule {
+		return []domain.Rule{
+			{ID: "shop", Type: "host", Pattern: "shop.example.com", CSS: "a"},
+			{ID: "blog", Type: "host", Pattern: "blog.example.com", CSS: blogCSS},
+		}
+	}
+	repo := &mockRepository{rules: ruleSet("b")}
+	c := newMockCache()
+	matcher := NewMatcher(repo, c)
+	require.NoError(t, matcher.LoadRules(ctx))
+	warmCache(t, matcher, shop, blog)
+
+	// An unchanged reload keeps everything
+	repo.rules = ruleSet("b")
+	require.NoError(t, matcher.LoadRules(ctx))
+	assert.Equal(t, []string{shop, blog}, cachedURLs(c, shop, blog))
+
+	// A changed rule only drops its own results
+	repo.rules = ruleSet("changed")
+	require.NoError(t, matcher.LoadRules(ctx))
+	assert.Equal(t, []string{shop}, cachedURLs(c, shop, blog))
+
+	// Reordering changes tie-breaking and drops everything
+	warmCache(t, matcher, blog)
+	reordered := ruleSet("changed")
+	repo.rules = []domain.Rule{reordered[1], reordered[0]}
+	require.NoError(t, matcher.LoadRules(ctx))
+	assert.Empty(t, cachedURLs(c, shop, blog))
+}
+
+func TestMatcher_KeySchemeChangeInvalidatesAll(t *testing.T) {
+	ctx := context.Background()
+	const shop = "https://shop.example.com/cart"
+	c := newMockCache()
+	matcher := NewMatcher(&mockRepository{}, c)
+	require.NoError(t, matcher.AddRule(ctx, &domain.Rule{ID: "shop", Type: "host", Pattern: "shop.example.com", CSS: "a"}))
+	warmCache(t, matcher, shop)
+
+	// A raw rule that matches nothing cached keeps the keys as they are
+	raw := domain.Rule{ID: "raw", Type: "exact", Pattern: "https://other.example.com/?utm_source=x", CSS: "b", MatchRaw: true}
+	require.NoError(t, matcher.AddRule(ctx, &raw))
+	assert.Equal(t, []string{shop}, cachedURLs(c, shop))
+
+	// A condition adds render context fields to every key, even though it matches nothing cached
+	conditional := domain.Rule{ID: "conditional", Type: "host", Pattern: "other.example.com", CSS: "c",
+		Conditions: &domain.RuleConditions{Orientation: domain.OrientationLandscape}}
+	require.NoError(t, matcher.AddRule(ctx, &conditional))
+	assert.Empty(t, cachedURLs(c, shop))
+}
+
+func TestMatcher_CacheResultSkipsStaleGeneration(t *testing.T) {
+	ctx := context.Background()
+	c := newMockCache()
+	matcher := NewMatcher(&mockRepository{}, c)
+
+	target := matcher.newResolveTarget(ctx, "https://example.com/")
+	require.NoError(t, matcher.AddRule(ctx, &domain.Rule{ID: "a", Type: "host", Pattern: "example.com", CSS: "a"}))
+
+	// The result was computed before the rule change and must not be cached
+	matcher.cacheResult(target, target.cacheKey, &domain.MatchResult{})
+	_, found := c.Get(target.cacheKey)
+	assert.False(t, found)
+}
+
+func TestMatcher_CachesNoMatchResults(t *testing.T) {
+	ctx := context.Background()
+	const shop = "https://shop.example.com/cart"
+	lru := cache.NewLRUCacheWithConfig(cache.LRUConfig{MaxSize: 100, NegativeMaxSize: 100, NegativeTTL: time.Minute})
+	matcher := NewMatcher(&mockRepository{}, lru)
+
+	result, err := matcher.Resolve(ctx, shop)
+	require.NoError(t, err)
+	assert.Empty(t, result.RuleID)
+	_, err = matcher.ResolveStacked(ctx, shop)
+	require.NoError(t, err)
+
+	result, err = matcher.Resolve(ctx, shop)
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Empty(t, result.RuleID)
+	result, err = matcher.ResolveStacked(ctx, shop)
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, 2, lru.Stats().NegativeSize)
+
+	// A rule matching the URL drops its negative entries
+	require.NoError(t, matcher.AddRule(ctx, &domain.Rule{ID: "shop", Type: "host", Pattern: "shop.example.com", CSS: "a"}))
+	result, err = matcher.Resolve(ctx, shop)
+	require.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.Equal(t, "shop", result.RuleID)
+
+	stats := matcher.GetStats(ctx)
+	assert.Equal(t, int64(2), stats["negative_cache_hits"])
+	assert.Equal(t, 0, stats["negative_cache_size"])
+}
+
+func TestMatcher_LargeChangeClearsCache(t *testing.T) {
+	ctx := context.Background()
+	// The mock repository shares its slice, so every reload gets a fresh one
+	ruleSet := func(added int) []domain.Rule {
+		rules := []domain.Rule{{ID: "shop", Type: "host", Pattern: "shop.example.com", CSS: "a"}}
+		for i := range added {
+			rules = append(rules, domain.Rule{ID: fmt.Sprintf("new-%d", i), Type: "host", Pattern: fmt.Sprintf("new%d.example.org", i), CSS: "b"})
+		}
+		return rules
+	}
+	urls := make([]string, 300)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://shop.example.com/item/%d", i)
+	}
+	repo := &mockRepository{rules: ruleSet(0)}
+	lru := cache.NewLRUCache(1000)
+	matcher := NewMatcher(repo, lru)
+	require.NoError(t, matcher.LoadRules(ctx))
+	warmCache(t, matcher, urls...)
+
+	// A few unrelated rules are matched against every entry and keep them
+	repo.rules = ruleSet(10)
+	require.NoError(t, matcher.LoadRules(ctx))
+	assert.Len(t, cachedURLs(lru, urls...), len(urls))
+
+	// Matching hundreds of rules against every entry would hold the cache lock too long
+	repo.rules = ruleSet(310)
+	require.NoError(t, matcher.LoadRules(ctx))
+	assert.Empty(t, cachedURLs(lru, urls...))
+	assert.Equal(t, int64(len(urls)), lru.Stats().Invalidations)
+}
@@ -1,31 +1,158 @@
 package matcher
 
 import (
+	"cmp"
 	"context"
 	"regexp"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"golang.org/x/net/idna"
 
 	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/urlpattern"
 )
 
+// MatcherConfig holds optional matcher behaviour
+type MatcherConfig struct {
+	Normalization NormalizeConfig
+}
+
 // Matcher implements the PatternMatcher interface with thread-safe operations
 type Matcher struct {
-	mu         sync.RWMutex
-	rules      []domain.Rule
+	mu         sync.Mutex // Serializes rule changes; resolutions only load the snapshot
+	snapshot   atomic.Pointer[ruleSnapshot]
+	variants   variantCounters
+	warmup     warmupProgress
+	flights    flightGroup
+	hints      patternHints // Analysis for the next snapshot, from UseIndexHints and UsePrefilterHints
 	repository domain.RuleRepository
 	cache      domain.CacheManager
+	normalizer *Normalizer
+	now        func() time.Time
 }
 
-// NewMatcher creates a new Matcher instance
+// NewMatcher creates a new Matcher instance without URL normalization
 func NewMatcher(repository domain.RuleRepository, cache domain.CacheManager) *Matcher {
-	return &Matcher{
+	return NewMatcherWithConfig(repository, cache, MatcherConfig{})
+}
+
+// NewMatcherWithConfig creates a new Matcher instance with full configuration
+func NewMatcherWithConfig(repository domain.RuleRepository, cache domain.CacheManager, config MatcherConfig) *Matcher {
+	m := &Matcher{
 		repository: repository,
 		cache:      cache,
-		rules:      make([]domain.Rule, 0),
+		normalizer: NewNormalizer(config.Normalization),
+		now:        time.Now,
+	}
+	m.snapshot.Store(newRuleSnapshot(make([]domain.Rule, 0), 0, nil, patternHints{}, m.now()))
+	return m
+}
+
+// resolveTarget holds the forms of a URL used during a single resolution
+type resolveTarget struct {
+	raw        string // Trimmed URL as requested, matched by rules with MatchRaw
+	normalized string // Canonical URL matched by all other rules
+	render     *domain.RenderContext
+	cacheKey   string
+	now        time.Time // Evaluation time for validity windows
+	expires    time.Time // Next schedule boundary; results are not cached once it passed
+	generation uint64    // Rule set generation the target was created in
+	assets     *assetSnapshot
+	snapshot   *ruleSnapshot
+}
+
+// newResolveTarget normalizes the URL and picks its cache key. The key is the normalized
+// URL, followed by the raw URL when a rule matching raw URLs matches it, so only the
+// raw forms such a rule tells apart get entries of their own. Render context fields
+// referenced by rule conditions are appended to the key.
+func (m *Matcher) newResolveTarget(ctx context.Context, url string) resolveTarget {
+	target := resolveTarget{
+		raw:        url,
+		normalized: m.normalizer.Normalize(url),
+		render:     domain.RenderContextFrom(ctx),
+		now:        m.now(),
+	}
+
+	snapshot := m.snapshot.Load()
+	if !snapshot.nextBoundary.IsZero() && !target.now.Before(snapshot.nextBoundary) {
+		snapshot = m.advanceSchedule(target.now)
+	}
+	target.snapshot = snapshot
+	target.expires = snapshot.nextBoundary
+	target.generation = snapshot.generation
+	target.assets = snapshot.assets
+
+	target.cacheKey = target.normalized
+	if m.matchesRawRule(snapshot, target.raw) {
+		target.cacheKey += rawKeyMarker + target.raw
+	}
+	target.cacheKey += snapshot.conditions.cacheKeySuffix(target.render)
+	return target
+}
+
+// matchesRawRule reports whether a pattern of a rule matching raw URLs matches the raw
+// URL. Conditions and validity windows are not checked, which only splits entries that
+// could have been shared.
+func (m *Matcher) matchesRawRule(snapshot *ruleSnapshot, raw string) bool {
+	if snapshot.rawRules == 0 {
+		return false
+	}
+	for _, pos := range snapshot.index.candidates(raw) {
+		rule := &snapshot.rules[pos]
+		if !rule.MatchRaw {
+			continue
+		}
+		if matches, _ := m.matchRule(rule, raw); matches {
+			return true
+		}
+	}
+	return false
+}
+
+// urlFor returns the form of the URL the rule is evaluated against
+func (t resolveTarget) urlFor(rule *domain.Rule) string {
+	if rule.MatchRaw {
+		return t.raw
+	}
+	return t.normalized
+}
+
+// cacheResult stores a result unless a schedule boundary passed or the rules changed
+// while it was computed. Writers publish a new snapshot before invalidating, so a
+// change missed by the first check but published before the write is seen by the
+// second, and the entry is dropped again.
+func (m *Matcher) cacheResult(target resolveTarget, key string, result *domain.MatchResult) {
+	if !target.expires.IsZero() && !m.now().Before(target.expires) {
+		return
+	}
+
+	if m.snapshot.Load().generation != target.generation {
+		return
+	}
+	m.cache.Set(key, result)
+	if m.snapshot.Load().generation != target.generation {
+		m.cache.Invalidate(key)
+	}
+}
+
+// matchTarget checks the rule's validity window, pattern and conditions against the target
+func (m *Matcher) matchTarget(rule *domain.Rule, target resolveTarget) (bool, int) {
+	if rule.ScheduleStatus(target.now) != domain.ScheduleActive {
+		return false, 0
+	}
+	matches, score := m.matchRule(rule, target.urlFor(rule))
+	if !matches {
+		return false, 0
+	}
+	if met, _ := evaluateConditions(rule, target.render); !met {
+		return false, 0
 	}
+	return true, score
 }
 
 // Resolve finds the best matching rule for the given URL
@@ -43,31 +170,47 @@ func (m *Matcher) Resolve(ctx context.Context, url string) (*domain.MatchResult,
 	default:
 	}
 
-	// Check cache first
-	if cachedResult, found := m.cache.Get(url); found {
-		// Create a new result to avoid race conditions on shared cached objects
-		result := &domain.MatchResult{
-			RuleID:    cachedResult.RuleID,
-			CSS:       cachedResult.CSS,
-			JS:        cachedResult.JS,
-			Score:     cachedResult.Score,
-			CacheHit:  true,
-			Timestamp: time.Now(),
+	target := m.newResolveTarget(ctx, url)
+
+	// Check cache first; a result referencing assets that changed since is resolved again
+	if cachedResult, found := m.cache.Get(target.cacheKey); found {
+		if css, js, fresh := target.assets.assets(cachedResult); fresh {
+			// Create a new result to avoid race conditions on shared cached objects
+			result := &domain.MatchResult{
+				RuleID:    cachedResult.RuleID,
+				CSS:       css,
+				JS:        js,
+				Score:     cachedResult.Score,
+				CacheHit:  true,
+				Timestamp: time.Now(),
+				VariantID: cachedResult.VariantID,
+			}
+			m.variants.record(result)
+			return result, nil
 		}
-		return result, nil
 	}
 
-	// Use read lock for concurrent access and release it as soon as possible
-	m.mu.RLock()
-	rulesCopy := make([]domain.Rule, len(m.rules))
-	copy(rulesCopy, m.rules)
-	m.mu.RUnlock()
+	// Concurrent misses for the same key wait for a single resolution
+	result, _, err := m.flights.do(ctx, flightKey{cacheKey: target.cacheKey, generation: target.generation},
+		func(ctx context.Context) (*domain.MatchResult, error) {
+			return m.resolveUncached(ctx, target)
+		})
+	if err != nil {
+		return nil, flightError(ctx, err, url, "resolve")
+	}
+	m.variants.record(result)
+	return result, nil
+}
+
+// resolveUncached scans the candidate rules for the best match and caches the result
+func (m *Matcher) resolveUncached(ctx context.Context, target resolveTarget) (*domain.MatchResult, error) {
+	candidates := m.candidateRules(target, true)
 
 	var bestMatch *domain.Rule
 	var bestScore int
 
-	// Iterate through all rules to find the best match
-	for i := range rulesCopy {
+	// Iterate through candidate rules to find the best match
+	for i, rule := range candidates {
 		// Check context periodically during long operations
 		select {
 		case <-ctx.Done():
@@ -76,13 +219,12 @@ func (m *Matcher) Resolve(ctx context.Context, url string) (*domain.MatchResult,
 				"Resolve operation cancelled during matching",
 				408,
 				ctx.Err(),
-				map[string]any{"url": url, "processed_rules": i},
+				map[string]any{"url": target.raw, "processed_rules": i},
 			).WithContext(ctx, "resolve")
 		default:
 		}
 
-		rule := &rulesCopy[i]
-		if matches, score := m.matchRule(rule, url); matches {
+		if matches, score := m.matchTarget(rule, target); matches {
 			// Higher score wins; on tie, prefer rule added earlier (stable)
 			if bestMatch == nil || score > bestScore {
 				bestMatch = rule
@@ -94,16 +236,17 @@ func (m *Matcher) Resolve(ctx context.Context, url string) (*domain.MatchResult,
 	// Create result
 	var result *domain.MatchResult
 	if bestMatch != nil {
+		variantID, css, js := ruleAssets(bestMatch, target)
 		result = &domain.MatchResult{
 			RuleID:    bestMatch.ID,
-			CSS:       bestMatch.CSS,
-			JS:        bestMatch.JS,
+			CSS:       css,
+			JS:        js,
 			Score:     bestScore,
 			CacheHit:  false,
 			Timestamp: time.Now(),
+			VariantID: variantID,
 		}
-		// Only cache positive matches to avoid cache pollution
-		m.cache.Set(url, result)
+		m.cacheResult(target, target.cacheKey, assetReference(result, target.generation, bestMatch))
 	} else {
 		result = &domain.MatchResult{
 			RuleID:    "",
@@ -113,16 +256,235 @@ func (m *Matcher) Resolve(ctx context.Context, url string) (*domain.MatchResult,
 			CacheHit:  false,
 			Timestamp: time.Now(),
 		}
-		// Don't cache empty results - they would pollute the cache
+		// No-match results go to the cache's bounded negative store, if it has one
+		m.cacheResult(target, target.cacheKey, result)
 	}
 
 	return result, nil
 }
 
-// matchRule checks if a rule matches the URL and returns the score
+// ResolveStacked finds every rule matching the URL and merges their assets.
+// Matches are ranked by score; when the top match is exclusive it is returned
+// alone, otherwise all non-exclusive matches are combined.
+func (m *Matcher) ResolveStacked(ctx context.Context, url string) (*domain.MatchResult, error) {
+	select {
+	case <-ctx.Done():
+		return nil, domain.NewAppErrorWithCause(
+			domain.ErrTimeout,
+			"Resolve operation cancelled",
+			408,
+			ctx.Err(),
+			map[string]any{"url": url},
+		).WithContext(ctx, "resolve_stacked")
+	default:
+	}
+
+	target := m.newResolveTarget(ctx, url)
+	cacheKey := stackedCacheKey(target.cacheKey)
+	if cachedResult, found := m.cache.Get(cacheKey); found {
+		if css, js, fresh := target.assets.stackedAssets(cachedResult); fresh {
+			result := &domain.MatchResult{
+				RuleID:     cachedResult.RuleID,
+				RuleIDs:    cachedResult.RuleIDs,
+				CSS:        css,
+				JS:         js,
+				Score:      cachedResult.Score,
+				CacheHit:   true,
+				Timestamp:  time.Now(),
+				VariantID:  cachedResult.VariantID,
+				VariantIDs: cachedResult.VariantIDs,
+			}
+			m.variants.record(result)
+			return result, nil
+		}
+	}
+
+	result, _, err := m.flights.do(ctx, flightKey{cacheKey: cacheKey, generation: target.generation},
+		func(ctx context.Context) (*domain.MatchResult, error) {
+			return m.resolveStackedUncached(ctx, target, cacheKey)
+		})
+	if err != nil {
+		return nil, flightError(ctx, err, url, "resolve_stacked")
+	}
+	m.variants.record(result)
+	return result, nil
+}
+
+// resolveStackedUncached collects and merges every matching rule and caches the result
+func (m *Matcher) resolveStackedUncached(ctx context.Context, target resolveTarget, cacheKey string) (*domain.MatchResult, error) {
+	candidates := m.candidateRules(target, true)
+
+	var matches []scoredRule
+	for i, rule := range candidates {
+		select {
+		case <-ctx.Done():
+			return nil, domain.NewAppErrorWithCause(
+				domain.ErrTimeout,
+				"Resolve operation cancelled during matching",
+				408,
+				ctx.Err(),
+				map[string]any{"url": target.raw, "processed_rules": i},
+			).WithContext(ctx, "resolve_stacked")
+		default:
+		}
+
+		if matched, score := m.matchTarget(rule, target); matched {
+			matches = append(matches, scoredRule{rule: rule, score: score})
+		}
+	}
+
+	if len(matches) == 0 {
+		result := &domain.MatchResult{Timestamp: time.Now()}
+		m.cacheResult(target, cacheKey, result)
+		return result, nil
+	}
+
+	// Stable sort keeps insertion order among equal scores, like Resolve's tie-breaking
+	slices.SortStableFunc(matches, func(a, b scoredRule) int {
+		return cmp.Compare(b.score, a.score)
+	})
+
+	if matches[0].rule.Exclusive {
+		matches = matches[:1]
+	} else {
+		matches = slices.DeleteFunc(matches, func(match scoredRule) bool {
+			return match.rule.Exclusive
+		})
+	}
+
+	result := stackMatches(matches, target)
+	rules := make([]*domain.Rule, len(matches))
+	for i, match := range matches {
+		rules[i] = match.rule
+	}
+	m.cacheResult(target, cacheKey, assetReference(result, target.generation, rules...))
+
+	return result, nil
+}
+
+// flightError returns the error of a coalesced resolution. A caller that stopped waiting
+// gets a timeout error of its own.
+func flightError(ctx context.Context, err error, url, operation string) error {
+	if _, ok := err.(*domain.AppError); ok {
+		return err
+	}
+	return domain.NewAppErrorWithCause(
+		domain.ErrTimeout,
+		"Resolve operation cancelled while waiting for a shared resolution",
+		408,
+		err,
+		map[string]any{"url": url},
+	).WithContext(ctx, operation)
+}
+
+// scoredRule pairs a matching rule with its computed score
+type scoredRule struct {
+	rule  *domain.Rule
+	score int
+}
+
+// stackMatches merges ranked matches into a single result. Assets are concatenated
+// from the lowest to the highest score so the most specific rule wins in the CSS
+// cascade and its script runs last. Each rule contributes the rendered assets of the
+// variant selected for the URL.
+func stackMatches(matches []scoredRule, target resolveTarget) *domain.MatchResult {
+	var stack assetStack
+	ruleIDs := make([]string, len(matches))
+	var variantIDs map[string]string
+
+	for i, match := range matches {
+		ruleIDs[i] = match.rule.ID
+	}
+
+	for i := len(matches) - 1; i >= 0; i-- {
+		rule := matches[i].rule
+		variantID, ruleCSS, ruleJS := ruleAssets(rule, target)
+		if variantID != "" {
+			if variantIDs == nil {
+				variantIDs = make(map[string]string)
+			}
+			variantIDs[rule.ID] = variantID
+		}
+		stack.add(rule.ID, ruleCSS, ruleJS)
+	}
+
+	return &domain.MatchResult{
+		RuleID:     matches[0].rule.ID,
+		RuleIDs:    ruleIDs,
+		CSS:        stack.css.String(),
+		JS:         stack.js.String(),
+		Score:      matches[0].score,
+		CacheHit:   false,
+		Timestamp:  time.Now(),
+		VariantID:  variantIDs[matches[0].rule.ID],
+		VariantIDs: variantIDs,
+	}
+}
+
+// stackedCachePrefix keeps stacked results apart from single-rule results for the same URL
+const stackedCachePrefix = "stacked:"
+
+// rawKeyMarker separates the normalized URL from the raw URL in a cache key
+const rawKeyMarker = "\x00raw"
+
+// stackedCacheKey returns the cache key of the stacked result for a URL
+func stackedCacheKey(url string) string {
+	return stackedCachePrefix + url
+}
+
+// parseCacheKey splits a cache key into the normalized and raw URL it was built from,
+// its render context suffix and whether it holds a stacked result. The raw URL equals
+// the normalized one when the key does not hold it.
+func parseCacheKey(key string) (normalized, raw, suffix string, stacked bool) {
+	key, stacked = strings.CutPrefix(key, stackedCachePrefix)
+	if i := strings.Index(key, contextKeyMarker); i >= 0 {
+		key, suffix = key[:i], key[i:]
+	}
+	normalized, raw, found := strings.Cut(key, rawKeyMarker)
+	if !found {
+		raw = normalized
+	}
+	return normalized, raw, suffix, stacked
+}
+
+// candidateRules returns the rules of the target's snapshot the index cannot rule out
+// for the URL, in insertion order, optionally narrowed further by the regex prefilter.
+// The rules belong to the immutable snapshot and must not be modified.
+func (m *Matcher) candidateRules(target resolveTarget, prefilter bool) []*domain.Rule {
+	snapshot := target.snapshot
+	positions := snapshot.index.candidates(target.normalized)
+	if snapshot.rawRules > 0 && target.raw != target.normalized {
+		// Rules matching the raw URL may be indexed under its non-canonical host
+		positions = append(positions, snapshot.index.candidates(target.raw)...)
+		slices.Sort(positions)
+		positions = slices.Compact(positions)
+	}
+	if prefilter {
+		positions = snapshot.prefilter.filter(positions, target)
+	}
+
+	rules := make([]*domain.Rule, len(positions))
+	for i, pos := range positions {
+		rules[i] = &snapshot.rules[pos]
+	}
+	return rules
+}
+
+// matchRule checks if a rule matches the URL and returns the score. A URL matching
+// any of the rule's exclude patterns is rejected.
 func (m *Matcher) matchRule(rule *domain.Rule, url string) (bool, int) {
+	matches, breakdown := m.scoreRule(rule, url)
+	if !matches || excludedBy(rule, url) != nil {
+		return false, 0
+	}
+	return true, breakdown.Total
+}
+
+// scoreRule checks if a rule matches the URL and returns how its score was computed
+func (m *Matcher) scoreRule(rule *domain.Rule, url string) (bool, domain.ScoreBreakdown) {
 	var matches bool
 	var baseScore int
+	maxLengthBonus := 499
 
 	switch rule.Type {
 	case "exact":
@@ -131,28 +493,56 @@ func (m *Matcher) matchRule(rule *domain.Rule, url string) (bool, int) {
 	case "regex":
 		if rule.GetCompiledRegex() == nil {
 			log.Warn().Str("rule_id", rule.ID).Str("pattern", rule.Pattern).Msg("Regex rule has nil compiled pattern")
-			return false, 0
+			return false, domain.ScoreBreakdown{}
 		}
 		matches = rule.GetCompiledRegex().MatchString(url)
 		baseScore = 500
+		maxLengthBonus = 249
 	case "wildcard":
 		matches = m.matchWildcard(rule.Pattern, url)
 		baseScore = 100
+		maxLengthBonus = 199
+	case "urlpattern":
+		if rule.GetCompiledURLPattern() == nil {
+			log.Warn().Str("rule_id", rule.ID).Str("pattern", rule.Pattern).Msg("URL pattern rule has nil compiled pattern")
+			return false, domain.ScoreBreakdown{}
+		}
+		matches = rule.GetCompiledURLPattern().Match(url)
+		baseScore = 750
+		maxLengthBonus = 249
+	case "path_prefix":
+		matches = matchPathPrefix(rule.Pattern, url)
+		baseScore = 300
+		maxLengthBonus = 199
+	case "host":
+		matches = matchHost(rule.Pattern, url)
+		baseScore = 50
+		maxLengthBonus = 49
 	default:
-		return false, 0
+		return false, domain.ScoreBreakdown{}
 	}
 
 	if !matches {
-		return false, 0
+		return false, domain.ScoreBreakdown{}
+	}
+
+	// Base scores ensure type hierarchy: exact (1000-1499) > urlpattern (750-999) > regex (500-749)
+	// > path_prefix (300-499) > wildcard (100-299) > host (50-99). Each length bonus is capped
+	// below the next higher base, so the bands never overlap.
+	breakdown := domain.ScoreBreakdown{
+		BaseScore:   baseScore,
+		LengthBonus: min(len(rule.Pattern), maxLengthBonus),
 	}
+	breakdown.Total = breakdown.BaseScore + breakdown.LengthBonus
 
 	// Priority override takes precedence
 	if rule.Priority != nil {
-		return true, *rule.Priority
+		priority := *rule.Priority
+		breakdown.PriorityOverride = &priority
+		breakdown.Total = priority
 	}
 
-	// Base scores ensure type hierarchy: exact (1000-1499) > regex (500-999) > wildcard (100-499)
-	return true, baseScore + min(len(rule.Pattern), 499)
+	return true, breakdown
 }
 
 // matchWildcard performs wildcard pattern matching for URLs
@@ -191,10 +581,55 @@ func wildcardMatch(pattern, str string) bool {
 	return pi == len(pattern)
 }
 
-// AddRule adds a new rule to the matcher
-func (m *Matcher) AddRule(ctx context.Context, rule *domain.Rule) error {
-	// Pre-compile regex if needed
-	if rule.Type == "regex" {
+// matchPathPrefix reports whether the URL has the pattern's scheme and authority and a
+// path at or below the pattern's path. "https://example.com/docs" matches "/docs" and
+// "/docs/intro" but not "/docsearch"; query and fragment are ignored.
+func matchPathPrefix(pattern, url string) bool {
+	schemeEnd := strings.Index(pattern, "://")
+	if schemeEnd < 0 {
+		return false
+	}
+	originEnd := len(pattern)
+	if slash := strings.IndexByte(pattern[schemeEnd+3:], '/'); slash >= 0 {
+		originEnd = schemeEnd + 3 + slash
+	}
+	origin, prefix := pattern[:originEnd], pattern[originEnd:]
+	if prefix == "" {
+		prefix = "/"
+	}
+
+	rest, found := strings.CutPrefix(url, origin)
+	if !found || (rest != "" && !strings.ContainsRune("/?#", rune(rest[0]))) {
+		return false
+	}
+	path := rest
+	if end := strings.IndexAny(path, "?#"); end >= 0 {
+		path = path[:end]
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// matchHost reports whether the URL's host is the pattern's host or one of its subdomains
+func matchHost(pattern, rawURL string) bool {
+	host := urlHostname(rawURL)
+	if host == "" {
+		return false
+	}
+	return host == pattern || (strings.HasSuffix(host, pattern) && host[len(host)-len(pattern)-1] == '.')
+}
+
+// compilePatterns pre-compiles the regex or URL pattern, the exclude patterns and the
+// user agent condition of a rule
+func compilePatterns(rule *domain.Rule) error {
+	switch rule.Type {
+	case "regex":
 		compiled, err := regexp.Compile(rule.Pattern)
 		if err != nil {
 			return domain.NewAppError(
@@ -209,16 +644,64 @@ func (m *Matcher) AddRule(ctx context.Context, rule *domain.Rule) error {
 			)
 		}
 		rule.SetCompiledRegex(compiled)
+	case "urlpattern":
+		compiled, err := urlpattern.Compile(rule.Pattern)
+		if err != nil {
+			return domain.NewAppError(
+				domain.ErrValidationFailed,
+				"Invalid URL pattern",
+				422,
+				map[string]any{
+					"field":  "pattern",
+					"value":  rule.Pattern,
+					"reason": err.Error(),
+				},
+			)
+		}
+		rule.SetCompiledURLPattern(compiled)
+	}
+
+	if err := compileExcludes(rule); err != nil {
+		return err
+	}
+
+	if rule.Conditions != nil && rule.Conditions.UserAgent != "" {
+		compiled, err := regexp.Compile(rule.Conditions.UserAgent)
+		if err != nil {
+			return domain.NewAppError(
+				domain.ErrValidationFailed,
+				"Invalid user agent regex",
+				422,
+				map[string]any{
+					"field":  "conditions.user_agent",
+					"value":  rule.Conditions.UserAgent,
+					"reason": err.Error(),
+				},
+			)
+		}
+		rule.SetCompiledUserAgent(compiled)
+	}
+	return nil
+}
+
+// AddRule adds a new rule to the matcher
+func (m *Matcher) AddRule(ctx context.Context, rule *domain.Rule) error {
+	// Pre-compile regex and URL patterns if needed
+	if err := compilePatterns(rule); err != nil {
+		return err
 	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Add rule to internal slice
-	m.rules = append(m.rules, *rule)
+	// Publish a copy of the rules with the new rule appended
+	current := m.snapshot.Load().rules
+	rules := make([]domain.Rule, len(current), len(current)+1)
+	copy(rules, current)
+	rules = append(rules, m.prepareRule(*rule))
 
-	// Invalidate cache since rules changed
-	m.cache.Clear()
+	// Drop the cached results for URLs the new rule matches
+	m.publish(rules, ruleChange{matching: []*domain.Rule{&rules[len(rules)-1]}})
 
 	return nil
 }
@@ -228,17 +711,15 @@ func (m *Matcher) RemoveRule(ctx context.Context, id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Find and remove rule
-	for i, rule := range m.rules {
-		if rule.ID == id {
-			// Remove rule from slice
-			m.rules = append(m.rules[:i], m.rules[i+1:]...)
+	snapshot := m.snapshot.Load()
+	if i := snapshot.position(id); i >= 0 {
+		// Publish a copy of the rules without the removed one
+		rules := slices.Delete(slices.Clone(snapshot.rules), i, i+1)
 
-			// Invalidate cache since rules changed
-			m.cache.Clear()
+		// Drop the cached results the rule contributed to
+		m.publish(rules, ruleChange{referenced: []string{id}})
 
-			return nil
-		}
+		return nil
 	}
 
 	return domain.NewAppError(
@@ -253,38 +734,24 @@ func (m *Matcher) RemoveRule(ctx context.Context, id string) error {
 
 // UpdateRule updates an existing rule in the matcher
 func (m *Matcher) UpdateRule(ctx context.Context, rule *domain.Rule) error {
-	// Pre-compile regex if needed
-	if rule.Type == "regex" {
-		compiled, err := regexp.Compile(rule.Pattern)
-		if err != nil {
-			return domain.NewAppError(
-				domain.ErrValidationFailed,
-				"Invalid regex pattern",
-				422,
-				map[string]any{
-					"field":  "pattern",
-					"value":  rule.Pattern,
-					"reason": err.Error(),
-				},
-			)
-		}
-		rule.SetCompiledRegex(compiled)
+	// Pre-compile regex and URL patterns if needed
+	if err := compilePatterns(rule); err != nil {
+		return err
 	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Find and update rule
-	for i, existingRule := range m.rules {
-		if existingRule.ID == rule.ID {
-			// Update rule in slice
-			m.rules[i] = *rule
+	snapshot := m.snapshot.Load()
+	if i := snapshot.position(rule.ID); i >= 0 {
+		// Publish a copy of the rules with the rule replaced in place
+		rules := slices.Clone(snapshot.rules)
+		rules[i] = m.prepareRule(*rule)
 
-			// Invalidate cache since rules changed
-			m.cache.Clear()
+		// Drop the results of the old version and those the new version may change
+		m.publish(rules, ruleChange{referenced: []string{rule.ID}, matching: []*domain.Rule{&rules[i]}})
 
-			return nil
-		}
+		return nil
 	}
 
 	return domain.NewAppError(
@@ -297,6 +764,39 @@ func (m *Matcher) UpdateRule(ctx context.Context, rule *domain.Rule) error {
 	)
 }
 
+// prepareRule returns the copy of a rule stored by the matcher. Exact and path prefix
+// patterns are normalized like request URLs so both compare equal after normalization,
+// and host patterns are lowercased (and converted to punycode when normalizing). Exclude
+// patterns are prepared the same way.
+func (m *Matcher) prepareRule(rule domain.Rule) domain.Rule {
+	rule.Pattern = m.preparePattern(rule.Type, rule.Pattern, rule.MatchRaw)
+	if len(rule.Exclude) > 0 {
+		rule.Exclude = slices.Clone(rule.Exclude)
+		for i := range rule.Exclude {
+			rule.Exclude[i].Pattern = m.preparePattern(rule.Exclude[i].Type, rule.Exclude[i].Pattern, rule.MatchRaw)
+		}
+	}
+	return rule
+}
+
+// preparePattern returns a pattern in the form it is compared against URLs
+func (m *Matcher) preparePattern(ruleType, pattern string, matchRaw bool) string {
+	switch ruleType {
+	case "exact", "path_prefix":
+		if !matchRaw {
+			pattern = m.normalizer.Normalize(pattern)
+		}
+	case "host":
+		pattern = strings.ToLower(pattern)
+		if m.normalizer != nil && !matchRaw {
+			if ascii, err := idna.Lookup.ToASCII(pattern); err == nil {
+				pattern = ascii
+			}
+		}
+	}
+	return pattern
+}
+
 // InvalidateCache clears the cache
 func (m *Matcher) InvalidateCache(ctx context.Context) error {
 	m.cache.Clear()
@@ -310,45 +810,41 @@ func (m *Matcher) LoadRules(ctx context.Context) error {
 		return err
 	}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// Pre-compile regex patterns
+	// Pre-compile regex and URL patterns
 	for i := range rules {
-		if rules[i].Type == "regex" {
-			compiled, err := regexp.Compile(rules[i].Pattern)
-			if err != nil {
-				// Log error but continue with other rules
-				continue
-			}
-			rules[i].SetCompiledRegex(compiled)
-		}
+		// On error continue with other rules
+		_ = compilePatterns(&rules[i])
+		rules[i] = m.prepareRule(rules[i])
 	}
 
-	m.rules = rules
-	m.cache.Clear()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Only the results of added, changed and removed rules are dropped
+	m.publish(rules, diffRules(m.snapshot.Load().rules, rules))
 
 	return nil
 }
 
 // HealthCheck performs a health check on the matcher
 func (m *Matcher) HealthCheck(ctx context.Context) domain.HealthStatus {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	snapshot := m.snapshot.Load()
 
 	now := time.Now()
 	status := "healthy"
 	message := "Matcher is operating normally"
 
-	ruleCount := len(m.rules)
+	ruleCount := len(snapshot.rules)
 	cacheStats := m.cache.Stats()
 
 	details := map[string]any{
 		"rule_count":      ruleCount,
+		"generation":      snapshot.generation,
 		"cache_size":      cacheStats.Size,
 		"cache_hits":      cacheStats.Hits,
 		"cache_misses":    cacheStats.Misses,
 		"cache_hit_ratio": cacheStats.HitRatio,
+		"cache_warmup":    m.warmup.details(),
 	}
 
 	// Check for potential issues
@@ -371,8 +867,12 @@ func (m *Matcher) HealthCheck(ctx context.Context) domain.HealthStatus {
 
 	// Validate rule integrity
 	invalidRules := 0
-	for _, rule := range m.rules {
-		if rule.Type == "regex" && rule.GetCompiledRegex() == nil {
+	for i := range snapshot.rules {
+		rule := &snapshot.rules[i]
+		if (rule.Type == "regex" && rule.GetCompiledRegex() == nil) ||
+			(rule.Type == "urlpattern" && rule.GetCompiledURLPattern() == nil) ||
+			(rule.Conditions != nil && rule.Conditions.UserAgent != "" && rule.GetCompiledUserAgent() == nil) ||
+			slices.ContainsFunc(rule.Exclude, invalidExclude) {
 			invalidRules++
 		}
 	}
@@ -393,25 +893,37 @@ func (m *Matcher) HealthCheck(ctx context.Context) domain.HealthStatus {
 
 // GetStats returns matcher statistics
 func (m *Matcher) GetStats(ctx context.Context) map[string]any {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
+	snapshot := m.snapshot.Load()
 	cacheStats := m.cache.Stats()
 
 	stats := map[string]any{
-		"rule_count":      len(m.rules),
+		"rule_count":      len(snapshot.rules),
+		"generation":      snapshot.generation,
 		"cache_hits":      cacheStats.Hits,
 		"cache_misses":    cacheStats.Misses,
 		"cache_size":      cacheStats.Size,
 		"cache_max_size":  cacheStats.MaxSize,
 		"cache_hit_ratio": cacheStats.HitRatio,
+
+		"cache_bytes":         cacheStats.Bytes,
+		"cache_max_bytes":     cacheStats.MaxBytes,
+		"cache_invalidations": cacheStats.Invalidations,
+		"cache_expirations":   cacheStats.Expirations,
+
+		"negative_cache_hits":     cacheStats.NegativeHits,
+		"negative_cache_misses":   cacheStats.NegativeMisses,
+		"negative_cache_size":     cacheStats.NegativeSize,
+		"negative_cache_max_size": cacheStats.NegativeMaxSize,
+
+		"resolve_coalescing": m.flights.stats(),
 	}
 
 	// Add rule type distribution
 	typeCount := make(map[string]int)
 	compiledRegexCount := 0
 
-	for _, rule := range m.rules {
+	for i := range snapshot.rules {
+		rule := &snapshot.rules[i]
 		typeCount[rule.Type]++
 		if rule.Type == "regex" && rule.GetCompiledRegex() != nil {
 			compiledRegexCount++
@@ -420,6 +932,25 @@ func (m *Matcher) GetStats(ctx context.Context) map[string]any {
 
 	stats["rule_types"] = typeCount
 	stats["compiled_regex_rules"] = compiledRegexCount
+	stats["index_buckets"] = snapshot.index.size()
+	stats["url_normalization"] = m.normalizer != nil
+	stats["match_raw_rules"] = snapshot.rawRules
+	stats["conditional_rules"] = snapshot.conditions.rules
+	stats["context_key_fields"] = snapshot.conditions.fieldNames()
+	stats["regex_prefilter"] = snapshot.prefilter.stats()
+	stats["variant_matches"] = m.variants.snapshot(snapshot.rules)
+
+	scheduleCount := map[string]int{domain.SchedulePending: 0, domain.ScheduleActive: 0, domain.ScheduleExpired: 0}
+	now := m.now()
+	for i := range snapshot.rules {
+		if snapshot.rules[i].ValidFrom != nil || snapshot.rules[i].ValidUntil != nil {
+			scheduleCount[snapshot.rules[i].ScheduleStatus(now)]++
+		}
+	}
+	stats["scheduled_rules"] = scheduleCount
+	if !snapshot.nextBoundary.IsZero() {
+		stats["next_schedule_boundary"] = snapshot.nextBoundary
+	}
 
 	return stats
 }
@@ -0,0 +1,235 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/cache"
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// noopCache never stores anything so every resolve exercises rule matching
+type noopCache struct{}
+
+func (noopCache) Get(key string) (*domain.MatchResult, bool)      { return nil, false }
+func (noopCache) Set(key string, result *domain.MatchResult)      {}
+func (noopCache) Invalidate(key string)                           {}
+func (noopCache) Clear()                                          {}
+func (noopCache) Stats() domain.CacheStats                        { return domain.CacheStats{} }
+func (noopCache) HealthCheck(context.Context) domain.HealthStatus { return domain.HealthStatus{} }
+
+func (noopCache) InvalidateWhere(func(string, *domain.MatchResult) bool) int { return 0 }
+
+// generateBenchmarkRules builds a community-sized rule set spread over many hosts
+func generateBenchmarkRules(count int) []domain.Rule {
+	rules := make([]domain.Rule, 0, count)
+	for i := 0; len(rules) < count; i++ {
+		host := fmt.Sprintf("site%d.example.com", i)
+		var rule domain.Rule
+		switch i % 10 {
+		case 0, 1, 2, 3:
+			rule = domain.Rule{Type: "exact", Pattern: fmt.Sprintf("https://%s/page/%d", host, i)}
+		case 4, 5, 6:
+			rule = domain.Rule{Type: "wildcard", Pattern: fmt.Sprintf("https://%s/*", host)}
+		case 7:
+			rule = domain.Rule{Type: "wildcard", Pattern: fmt.Sprintf("https://*.tenant%d.example.org/*", i)}
+		case 8:
+			rule = domain.Rule{Type: "regex", Pattern: fmt.Sprintf(`^https://%s/docs/\d+$`, regexp.QuoteMeta(host))}
+		default:
+			if i%100 == 9 {
+				// A small share of rules cannot be indexed by host
+				rule = domain.Rule{Type: "wildcard", Pattern: fmt.Sprintf("*/print/%d/*", i)}
+			} else {
+				rule = domain.Rule{Type: "wildcard", Pattern: fmt.Sprintf("https://%s/blog/*", host)}
+			}
+		}
+		rule.ID = fmt.Sprintf("rule-%d", i)
+		rule.CSS = "body { margin: 0; }"
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// benchmarkURLs returns a mix of matching and non-matching URLs
+func benchmarkURLs(count int) []string {
+	mid := count / 20 * 10
+	return []string{
+		fmt.Sprintf("https://site%d.example.com/page/%d", mid, mid),
+		fmt.Sprintf("https://site%d.example.com/docs/42", mid+8),
+		fmt.Sprintf("https://app.tenant%d.example.org/dashboard", mid+7),
+		"https://unknown.example.net/article/123",
+	}
+}
+
+func newBenchmarkMatcher(b *testing.B, count int) *Matcher {
+	b.Helper()
+	matcher := NewMatcher(&mockRepository{rules: generateBenchmarkRules(count)}, noopCache{})
+	if err := matcher.LoadRules(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	return matcher
+}
+
+func BenchmarkResolve(b *testing.B) {
+	for _, count := range []int{10_000, 100_000} {
+		matcher := newBenchmarkMatcher(b, count)
+		urls := benchmarkURLs(count)
+		ctx := context.Background()
+
+		b.Run(fmt.Sprintf("indexed/%d", count), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; b.Loop(); i++ {
+				if _, err := matcher.Resolve(ctx, urls[i%len(urls)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		// Baseline: copy the rule set and scan it linearly like the matcher did before indexing
+		b.Run(fmt.Sprintf("linear/%d", count), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; b.Loop(); i++ {
+				rules := matcher.snapshot.Load().rules
+				rulesCopy := make([]domain.Rule, len(rules))
+				copy(rulesCopy, rules)
+				linearResolve(matcher, rulesCopy, urls[i%len(urls)])
+			}
+		})
+	}
+}
+
+// generateRegexBenchmarkRules builds a community-sized set of unanchored regex rules,
+// which the index cannot narrow down. A few have no selective literal and are grouped.
+func generateRegexBenchmarkRules(count int) []domain.Rule {
+	rules := make([]domain.Rule, count)
+	for i := range rules {
+		host := regexp.QuoteMeta(fmt.Sprintf("site%d.example.com", i))
+		var pattern string
+		switch {
+		case i%50 == 0:
+			pattern = fmt.Sprintf(`/\d{4}/[a-z]{2}%d/`, i)
+		case i%2 == 0:
+			pattern = fmt.Sprintf(`^https?://(www\.)?%s/(article|news)/\d+`, host)
+		default:
+			pattern = fmt.Sprintf(`(?i)%s/.*[?&]print=1`, host)
+		}
+		rules[i] = domain.Rule{ID: fmt.Sprintf("regex-%d", i), Type: "regex", Pattern: pattern, CSS: "body { margin: 0; }"}
+	}
+	return rules
+}
+
+func BenchmarkResolveRegex(b *testing.B) {
+	for _, count := range []int{1_000, 10_000} {
+		matcher := NewMatcher(&mockRepository{rules: generateRegexBenchmarkRules(count)}, noopCache{})
+		if err := matcher.LoadRules(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+		mid := count/4*2 + 2
+		urls := []string{
+			fmt.Sprintf("https://www.site%d.example.com/news/42", mid),
+			fmt.Sprintf("https://site%d.example.com/a/b?print=1", mid+1),
+			"https://unknown.example.net/2024/05/article",
+		}
+		ctx := context.Background()
+		prefiltered := matcher.snapshot.Load()
+		unfiltered := *prefiltered
+		unfiltered.prefilter = nil
+
+		b.Run(fmt.Sprintf("prefiltered/%d", count), func(b *testing.B) {
+			matcher.snapshot.Store(prefiltered)
+			b.ReportAllocs()
+			for i := 0; b.Loop(); i++ {
+				if _, err := matcher.Resolve(ctx, urls[i%len(urls)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		// Baseline: run every regex like the matcher did before prefiltering
+		b.Run(fmt.Sprintf("unfiltered/%d", count), func(b *testing.B) {
+			matcher.snapshot.Store(&unfiltered)
+			b.ReportAllocs()
+			for i := 0; b.Loop(); i++ {
+				if _, err := matcher.Resolve(ctx, urls[i%len(urls)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		matcher.snapshot.Store(prefiltered)
+	}
+}
+
+func BenchmarkLoadRules(b *testing.B) {
+	const count = 10_000
+	rules := append(generateBenchmarkRules(count), generateRegexBenchmarkRules(count)...)
+	repo := &mockRepository{rules: rules}
+	ctx := context.Background()
+
+	source := NewMatcher(repo, noopCache{})
+	if err := source.LoadRules(ctx); err != nil {
+		b.Fatal(err)
+	}
+	indexHints, prefilterHints := source.IndexHints(), source.PrefilterHints()
+
+	// Every pattern is compiled and analysed, as on a full scan
+	b.Run("analysed", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			matcher := NewMatcher(repo, noopCache{})
+			if err := matcher.LoadRules(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// Patterns are compiled but their index buckets and prefilter literals come from a
+	// ruleset snapshot
+	b.Run("hinted", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			matcher := NewMatcher(repo, noopCache{})
+			matcher.UseIndexHints(indexHints)
+			matcher.UsePrefilterHints(prefilterHints)
+			if err := matcher.LoadRules(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkInvalidate(b *testing.B) {
+	const cached = 10_000
+	matcher := NewMatcher(&mockRepository{}, cache.NewLRUCache(cached))
+	if err := matcher.LoadRules(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	snapshot := matcher.snapshot.Load()
+	result := &domain.MatchResult{RuleID: "cached"}
+
+	// A bulk change adds rules for hosts nothing cached is on, so a targeted
+	// invalidation matches each of them against every entry and drops nothing
+	for _, changed := range []int{5, 100, 1_000} {
+		rules := make([]domain.Rule, changed)
+		change := ruleChange{matching: make([]*domain.Rule, changed)}
+		for i := range rules {
+			rules[i] = domain.Rule{ID: fmt.Sprintf("new-%d", i), Type: "host", Pattern: fmt.Sprintf("new%d.example.org", i), CSS: "a"}
+			change.matching[i] = &rules[i]
+		}
+
+		b.Run(fmt.Sprintf("changed/%d", changed), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				b.StopTimer()
+				for i := range cached {
+					matcher.cache.Set(fmt.Sprintf("https://site%d.example.com/page", i), result)
+				}
+				b.StartTimer()
+				matcher.mu.Lock()
+				matcher.invalidate(change, snapshot, snapshot)
+				matcher.mu.Unlock()
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package matcher
 import (
 	"context"
 	"regexp"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -98,6 +100,19 @@ func (m *mockCache) Invalidate(key string) {
 	delete(m.data, key)
 }
 
+func (m *mockCache) InvalidateWhere(match func(key string, result *domain.MatchResult) bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for key, result := range m.data {
+		if match(key, result) {
+			delete(m.data, key)
+			removed++
+		}
+	}
+	return removed
+}
+
 func (m *mockCache) Clear() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -712,3 +727,212 @@ func TestProperty_WriteOperationExclusivity(t *testing.T) {
 
 	properties.TestingRun(t, gopter.ConsoleReporter(false))
 }
+
+func TestMatcher_ResolveStacked(t *testing.T) {
+	ctx := context.Background()
+	priority := 50
+
+	siteRule := domain.Rule{ID: "site", Type: "wildcard", Pattern: "https://example.com/*", CSS: ".cookie { display: none; }"}
+	pageRule := domain.Rule{ID: "page", Type: "exact", Pattern: "https://example.com/table", CSS: "table { width: 100%; }", JS: "fixTable();"}
+	lowExclusive := domain.Rule{ID: "low-exclusive", Type: "wildcard", Pattern: "https://example.com/t*", CSS: ".x {}", Priority: &priority, Exclusive: true}
+
+	matcher := NewMatcher(&mockRepository{rules: []domain.Rule{siteRule, pageRule, lowExclusive}}, newMockCache())
+	if err := matcher.LoadRules(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := matcher.ResolveStacked(ctx, "https://example.com/table")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Exclusive rules that don't win are left out, the rest are ranked by score
+	if want := []string{"page", "site"}; !slices.Equal(result.RuleIDs, want) {
+		t.Fatalf("rule IDs = %v, want %v", result.RuleIDs, want)
+	}
+	if result.RuleID != "page" || result.Score != 1000+len(pageRule.Pattern) {
+		t.Fatalf("top match = %s (%d), want page", result.RuleID, result.Score)
+	}
+
+	// Most specific CSS comes last so it wins in the cascade
+	wantCSS := "/* rule: site */\n.cookie { display: none; }\n/* rule: page */\ntable { width: 100%; }\n"
+	if result.CSS != wantCSS {
+		t.Fatalf("css = %q, want %q", result.CSS, wantCSS)
+	}
+	if result.JS != "/* rule: page */\nfixTable();\n" {
+		t.Fatalf("js = %q", result.JS)
+	}
+
+	// Stacked results are cached separately from single-rule results
+	cached, err := matcher.ResolveStacked(ctx, "https://example.com/table")
+	if err != nil || !cached.CacheHit || !slices.Equal(cached.RuleIDs, result.RuleIDs) {
+		t.Fatalf("expected cached stacked result, got %+v (%v)", cached, err)
+	}
+	single, err := matcher.Resolve(ctx, "https://example.com/table")
+	if err != nil || single.CacheHit || single.CSS != pageRule.CSS {
+		t.Fatalf("expected uncached single result, got %+v (%v)", single, err)
+	}
+}
+
+func TestMatcher_ResolveStacked_ExclusiveWinner(t *testing.T) {
+	ctx := context.Background()
+
+	siteRule := domain.Rule{ID: "site", Type: "wildcard", Pattern: "https://example.com/*", CSS: ".cookie { display: none; }"}
+	pageRule := domain.Rule{ID: "page", Type: "exact", Pattern: "https://example.com/print", CSS: "body { margin: 0; }", Exclusive: true}
+
+	matcher := NewMatcher(&mockRepository{rules: []domain.Rule{siteRule, pageRule}}, newMockCache())
+	if err := matcher.LoadRules(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := matcher.ResolveStacked(ctx, "https://example.com/print")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.RuleIDs, []string{"page"}) {
+		t.Fatalf("rule IDs = %v, want only the exclusive winner", result.RuleIDs)
+	}
+
+	empty, err := matcher.ResolveStacked(ctx, "https://other.org/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.RuleID != "" || len(empty.RuleIDs) != 0 || empty.CSS != "" {
+		t.Fatalf("expected empty result, got %+v", empty)
+	}
+}
+
+func TestMatchPathPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		url     string
+		want    bool
+	}{
+		{"https://example.com/docs", "https://example.com/docs", true},
+		{"https://example.com/docs", "https://example.com/docs/intro", true},
+		{"https://example.com/docs", "https://example.com/docs?page=2", true},
+		{"https://example.com/docs", "https://example.com/docs#top", true},
+		{"https://example.com/docs", "https://example.com/docsearch", false},
+		{"https://example.com/docs", "https://example.com/", false},
+		{"https://example.com/docs/", "https://example.com/docs/intro", true},
+		{"https://example.com/docs/", "https://example.com/docs", false},
+		{"https://example.com", "https://example.com", true},
+		{"https://example.com", "https://example.com/anything", true},
+		{"https://example.com", "https://example.com?q=1", true},
+		{"https://example.com", "https://example.com.evil.org/", false},
+		{"https://example.com", "https://example.com:8443/", false},
+		{"https://example.com/docs", "http://example.com/docs", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPathPrefix(tt.pattern, tt.url); got != tt.want {
+			t.Errorf("matchPathPrefix(%q, %q) = %v, want %v", tt.pattern, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		pattern string
+		url     string
+		want    bool
+	}{
+		{"example.com", "https://example.com/", true},
+		{"example.com", "https://WWW.Example.com:8443/a", true},
+		{"example.com", "http://a.b.example.com", true},
+		{"example.com", "https://notexample.com/", false},
+		{"example.com", "https://example.com.evil.org/", false},
+		{"example.com", "https://evil.org/example.com", false},
+		{"docs.example.com", "https://example.com/", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchHost(tt.pattern, tt.url); got != tt.want {
+			t.Errorf("matchHost(%q, %q) = %v, want %v", tt.pattern, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher_NewRuleTypeScores(t *testing.T) {
+	ctx := context.Background()
+	longPath := "/" + strings.Repeat("a", 600)
+
+	rules := []domain.Rule{
+		{ID: "host", Type: "host", Pattern: "Example.com", CSS: "host"},
+		{ID: "prefix", Type: "path_prefix", Pattern: "https://example.com" + longPath, CSS: "prefix"},
+		{ID: "pattern", Type: "urlpattern", Pattern: "https://example.com" + longPath + "/:page", CSS: "pattern"},
+		{ID: "broken", Type: "urlpattern", Pattern: "https://example.com/(", CSS: "broken"},
+	}
+	matcher := NewMatcher(&mockRepository{rules: rules}, newMockCache())
+	if err := matcher.LoadRules(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Capped length bonuses keep each type below the next higher base score
+	tests := []struct {
+		url       string
+		wantRule  string
+		wantScore int
+	}{
+		{"https://www.example.com/", "host", 50 + len("example.com")},
+		{"https://example.com" + longPath, "prefix", 300 + 199},
+		{"https://example.com" + longPath + "/intro", "pattern", 750 + 249},
+	}
+	for _, tt := range tests {
+		result, err := matcher.Resolve(ctx, tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.RuleID != tt.wantRule || result.Score != tt.wantScore {
+			t.Errorf("Resolve(%q) = %s (%d), want %s (%d)", tt.url, result.RuleID, result.Score, tt.wantRule, tt.wantScore)
+		}
+	}
+
+	// Broken URL patterns are skipped and reported by the health check
+	health := matcher.HealthCheck(ctx)
+	if health.Details["invalid_rules"] != 1 {
+		t.Errorf("invalid_rules = %v, want 1", health.Details["invalid_rules"])
+	}
+
+	invalid := domain.Rule{ID: "invalid", Type: "urlpattern", Pattern: "https://example.com/:id/:id", CSS: "x"}
+	if err := matcher.AddRule(ctx, &invalid); err == nil {
+		t.Error("expected invalid URL pattern to be rejected")
+	}
+}
+
+func TestMatcher_ScoreBandsDoNotOverlap(t *testing.T) {
+	ctx := context.Background()
+	url := "https://example.com/" + strings.Repeat("a", 500)
+
+	// A 499 character wildcard stays below a short path prefix
+	matcher := NewMatcher(&mockRepository{rules: []domain.Rule{
+		{ID: "wildcard", Type: "wildcard", Pattern: url[:498] + "*", CSS: "wildcard"},
+		{ID: "prefix", Type: "path_prefix", Pattern: "https://example.com/", CSS: "prefix"},
+	}}, newMockCache())
+	if err := matcher.LoadRules(ctx); err != nil {
+		t.Fatal(err)
+	}
+	result, err := matcher.Resolve(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RuleID != "prefix" || result.Score != 300+len("https://example.com/") {
+		t.Errorf("Resolve = %s (%d), want prefix", result.RuleID, result.Score)
+	}
+
+	// A long regex stays below a short URL pattern
+	matcher = NewMatcher(&mockRepository{rules: []domain.Rule{
+		{ID: "regex", Type: "regex", Pattern: "^" + regexp.QuoteMeta(url), CSS: "regex"},
+		{ID: "pattern", Type: "urlpattern", Pattern: "https://example.com/:page", CSS: "pattern"},
+	}}, newMockCache())
+	if err := matcher.LoadRules(ctx); err != nil {
+		t.Fatal(err)
+	}
+	result, err = matcher.Resolve(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RuleID != "pattern" || result.Score != 750+len("https://example.com/:page") {
+		t.Errorf("Resolve = %s (%d), want pattern", result.RuleID, result.Score)
+	}
+}
@@ -0,0 +1,172 @@
+package matcher
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Trailing slash policies applied to URL paths during normalization
+const (
+	TrailingSlashKeep  = "keep"  // Leave paths untouched
+	TrailingSlashStrip = "strip" // Remove trailing slashes, except for the root path
+	TrailingSlashAdd   = "add"   // Append a slash unless the last segment looks like a file
+)
+
+// NormalizeConfig controls how URLs are canonicalized before matching and cache keying
+type NormalizeConfig struct {
+	Enabled bool
+	// TrackingParams are query parameters removed from URLs. Entries ending
+	// in "*" match by prefix, e.g. "utm_*". Names are compared case-insensitively.
+	TrackingParams []string
+	TrailingSlash  string
+}
+
+// Normalizer rewrites URLs into a canonical form so equivalent URLs share
+// cache entries and match the same rules. A nil Normalizer leaves URLs unchanged.
+type Normalizer struct {
+	trackingExact  map[string]struct{}
+	trackingPrefix []string
+	trailingSlash  string
+}
+
+// NewNormalizer creates a normalizer from the config, or returns nil when normalization is disabled
+func NewNormalizer(cfg NormalizeConfig) *Normalizer {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	n := &Normalizer{
+		trackingExact: make(map[string]struct{}),
+		trailingSlash: cfg.TrailingSlash,
+	}
+	for _, param := range cfg.TrackingParams {
+		param = strings.ToLower(strings.TrimSpace(param))
+		if param == "" {
+			continue
+		}
+		if prefix, found := strings.CutSuffix(param, "*"); found {
+			n.trackingPrefix = append(n.trackingPrefix, prefix)
+		} else {
+			n.trackingExact[param] = struct{}{}
+		}
+	}
+	return n
+}
+
+// Normalize returns the canonical form of the URL. Scheme and host are lowercased,
+// default ports dropped, IDN hosts converted to punycode, tracking parameters removed,
+// the remaining query parameters sorted and the trailing slash policy applied.
+// URLs that cannot be parsed as absolute URLs are returned unchanged.
+func (n *Normalizer) Normalize(rawURL string) string {
+	if n == nil {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || u.Opaque != "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = normalizeHost(u.Scheme, u.Hostname(), u.Port())
+	u.RawQuery = n.normalizeQuery(u.RawQuery)
+	if u.RawQuery == "" {
+		u.ForceQuery = false
+	}
+
+	escapedPath := n.normalizePath(u.EscapedPath())
+	if unescaped, err := url.PathUnescape(escapedPath); err == nil {
+		u.Path = unescaped
+		u.RawPath = escapedPath
+	}
+
+	return u.String()
+}
+
+// normalizeHost lowercases the host, converts it to punycode and drops default ports
+func normalizeHost(scheme, host, port string) string {
+	host = strings.ToLower(host)
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		host = ascii
+	}
+
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	if port != "" {
+		return net.JoinHostPort(host, port)
+	}
+	if strings.Contains(host, ":") {
+		// IPv6 literals keep their brackets
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// normalizeQuery removes tracking parameters and sorts the rest by name.
+// Parameters are kept in their original encoding and duplicates keep their order.
+func (n *Normalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	params := strings.Split(rawQuery, "&")
+	params = slices.DeleteFunc(params, func(param string) bool {
+		return param == "" || n.isTrackingParam(queryParamName(param))
+	})
+	slices.SortStableFunc(params, func(a, b string) int {
+		return strings.Compare(queryParamName(a), queryParamName(b))
+	})
+	return strings.Join(params, "&")
+}
+
+// isTrackingParam reports whether a query parameter should be removed
+func (n *Normalizer) isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	if _, found := n.trackingExact[name]; found {
+		return true
+	}
+	for _, prefix := range n.trackingPrefix {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryParamName returns the decoded name of a "name=value" query parameter
+func queryParamName(param string) string {
+	name, _, _ := strings.Cut(param, "=")
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+// normalizePath applies the trailing slash policy. An empty path becomes "/".
+func (n *Normalizer) normalizePath(escapedPath string) string {
+	if escapedPath == "" {
+		return "/"
+	}
+
+	switch n.trailingSlash {
+	case TrailingSlashStrip:
+		if trimmed := strings.TrimRight(escapedPath, "/"); trimmed != "" {
+			return trimmed
+		}
+		return "/"
+	case TrailingSlashAdd:
+		if strings.HasSuffix(escapedPath, "/") || strings.Contains(path.Base(escapedPath), ".") {
+			return escapedPath
+		}
+		return escapedPath + "/"
+	default:
+		return escapedPath
+	}
+}
@@ -0,0 +1,187 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNormalizeConfig(trailingSlash string) NormalizeConfig {
+	return NormalizeConfig{
+		Enabled:        true,
+		TrackingParams: []string{"utm_*", "gclid", "FBCLID"},
+		TrailingSlash:  trailingSlash,
+	}
+}
+
+func TestNormalizer_Normalize(t *testing.T) {
+	tests := []struct {
+		name          string
+		trailingSlash string
+		input         string
+		expected      string
+	}{
+		{"lowercase scheme and host", TrailingSlashKeep, "HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{"drop default https port", TrailingSlashKeep, "https://example.com:443/a", "https://example.com/a"},
+		{"drop default http port", TrailingSlashKeep, "http://example.com:80/a", "http://example.com/a"},
+		{"keep non-default port", TrailingSlashKeep, "https://example.com:8443/a", "https://example.com:8443/a"},
+		{"keep port of other scheme", TrailingSlashKeep, "http://example.com:443/a", "http://example.com:443/a"},
+		{"idn host to punycode", TrailingSlashKeep, "https://Bücher.example/katalog", "https://xn--bcher-kva.example/katalog"},
+		{"ipv6 host", TrailingSlashKeep, "http://[::1]:80/a", "http://[::1]/a"},
+		{"empty path becomes root", TrailingSlashKeep, "https://example.com", "https://example.com/"},
+		{"strip tracking params", TrailingSlashKeep, "https://example.com/a?utm_source=x&id=1&gclid=abc&fbclid=z", "https://example.com/a?id=1"},
+		{"drop query when only tracking params", TrailingSlashKeep, "https://example.com/a?utm_medium=email", "https://example.com/a"},
+		{"sort query params stably", TrailingSlashKeep, "https://example.com/a?b=2&a=1&b=1", "https://example.com/a?a=1&b=2&b=1"},
+		{"keep param encoding", TrailingSlashKeep, "https://example.com/a?q=a%20b&p=%2F", "https://example.com/a?p=%2F&q=a%20b"},
+		{"keep fragment", TrailingSlashKeep, "https://example.com/a#top", "https://example.com/a#top"},
+		{"keep escaped path", TrailingSlashKeep, "https://example.com/a%2Fb", "https://example.com/a%2Fb"},
+		{"keep trailing slash", TrailingSlashKeep, "https://example.com/a/", "https://example.com/a/"},
+		{"strip trailing slash", TrailingSlashStrip, "https://example.com/a//", "https://example.com/a"},
+		{"strip keeps root", TrailingSlashStrip, "https://example.com/", "https://example.com/"},
+		{"add trailing slash", TrailingSlashAdd, "https://example.com/a", "https://example.com/a/"},
+		{"add skips files", TrailingSlashAdd, "https://example.com/a/index.html", "https://example.com/a/index.html"},
+		{"full example", TrailingSlashStrip, "HTTPS://Example.com:443/a/?utm_source=x", "https://example.com/a"},
+		{"relative url unchanged", TrailingSlashStrip, "/a/b/", "/a/b/"},
+		{"opaque url unchanged", TrailingSlashStrip, "mailto:User@Example.com", "mailto:User@Example.com"},
+		{"unparsable url unchanged", TrailingSlashStrip, "https://exa mple.com/%zz", "https://exa mple.com/%zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := NewNormalizer(testNormalizeConfig(tt.trailingSlash))
+			assert.Equal(t, tt.expected, n.Normalize(tt.input))
+		})
+	}
+}
+
+func TestNormalizer_Disabled(t *testing.T) {
+	n := NewNormalizer(NormalizeConfig{Enabled: false, TrailingSlash: TrailingSlashStrip})
+	assert.Nil(t, n)
+	assert.Equal(t, "HTTPS://Example.com:443/a/", n.Normalize("HTTPS://Example.com:443/a/"))
+}
+
+func TestMatcher_NormalizedResolution(t *testing.T) {
+	ctx := context.Background()
+	cache := newMockCache()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "exact", Type: "exact", Pattern: "HTTPS://Example.com/a/", CSS: "exact"},
+		{ID: "site", Type: "wildcard", Pattern: "https://example.com/*", CSS: "site"},
+	}}
+	matcher := NewMatcherWithConfig(repo, cache, MatcherConfig{Normalization: testNormalizeConfig(TrailingSlashStrip)})
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	result, err := matcher.Resolve(ctx, "HTTPS://Example.com:443/a/?utm_source=x")
+	require.NoError(t, err)
+	assert.Equal(t, "exact", result.RuleID)
+	assert.False(t, result.CacheHit)
+
+	// Equivalent URLs share the cache entry of the normalized URL
+	result, err = matcher.Resolve(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	assert.Equal(t, "exact", result.RuleID)
+	assert.True(t, result.CacheHit)
+
+	_, found := cache.Get("https://example.com/a")
+	assert.True(t, found)
+}
+
+func TestMatcher_MatchRaw(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "raw", Type: "wildcard", Pattern: "https://Example.com/*utm_source=*", CSS: "raw", MatchRaw: true},
+		{ID: "site", Type: "wildcard", Pattern: "https://example.com/*", CSS: "site"},
+	}}
+	matcher := NewMatcherWithConfig(repo, newMockCache(), MatcherConfig{Normalization: testNormalizeConfig(TrailingSlashKeep)})
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	// The raw rule sees the tracking parameter and the original host casing
+	result, err := matcher.Resolve(ctx, "https://Example.com/a?utm_source=mail")
+	require.NoError(t, err)
+	assert.Equal(t, "raw", result.RuleID)
+
+	// A raw form the raw rule matches has a cache entry of its own
+	result, err = matcher.Resolve(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	assert.Equal(t, "site", result.RuleID)
+	assert.False(t, result.CacheHit)
+
+	require.NoError(t, matcher.RemoveRule(ctx, "raw"))
+	assert.Equal(t, 0, matcher.snapshot.Load().rawRules)
+
+	result, err = matcher.Resolve(ctx, "https://Example.com/a?utm_source=mail")
+	require.NoError(t, err)
+	assert.Equal(t, "site", result.RuleID)
+}
+
+func TestMatcher_MatchRawKeepsSharedCacheEntries(t *testing.T) {
+	ctx := context.Background()
+	cache := newMockCache()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "raw", Type: "exact", Pattern: "https://other.example.com/?utm_source=x", CSS: "raw", MatchRaw: true},
+		{ID: "site", Type: "wildcard", Pattern: "https://example.com/*", CSS: "site"},
+	}}
+	matcher := NewMatcherWithConfig(repo, cache, MatcherConfig{Normalization: testNormalizeConfig(TrailingSlashKeep)})
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	result, err := matcher.Resolve(ctx, "HTTPS://Example.com/a")
+	require.NoError(t, err)
+	assert.Equal(t, "site", result.RuleID)
+	assert.False(t, result.CacheHit)
+
+	// URLs the raw rule cannot match share the entry of their normalized URL
+	result, err = matcher.Resolve(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	assert.Equal(t, "site", result.RuleID)
+	assert.True(t, result.CacheHit)
+	assert.Len(t, cache.data, 1)
+	_, found := cache.Get("https://example.com/a")
+	assert.True(t, found)
+}
+
+// Feature: github.com/freewebtopdf/asset-injector, Property 43: URL normalization is idempotent
+func TestProperty_NormalizationIdempotent(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	genURL := gopter.CombineGens(
+		gen.OneConstOf("http", "HTTPS", "https"),
+		gen.OneConstOf("Example.com", "example.com", "Bücher.example", "[::1]", "a.B.c"),
+		gen.OneConstOf("", ":80", ":443", ":8080"),
+		gen.OneConstOf("", "/", "/a", "/a/", "/a/b.html", "/a%2Fb/", "//"),
+		gen.SliceOfN(3, gen.OneConstOf("utm_source=x", "b=2", "a=1", "gclid=z", "q=a%20b", "")),
+	).Map(func(values []any) string {
+		url := fmt.Sprintf("%s://%s%s%s", values[0], values[1], values[2], values[3])
+		params := values[4].([]string)
+		if len(params) > 0 {
+			query := ""
+			for i, param := range params {
+				if i > 0 {
+					query += "&"
+				}
+				query += param
+			}
+			url += "?" + query
+		}
+		return url
+	})
+
+	for _, policy := range []string{TrailingSlashKeep, TrailingSlashStrip, TrailingSlashAdd} {
+		n := NewNormalizer(testNormalizeConfig(policy))
+		properties.Property("For any URL, normalizing twice should equal normalizing once ("+policy+")", prop.ForAll(
+			func(url string) bool {
+				once := n.Normalize(url)
+				return n.Normalize(once) == once
+			},
+			genURL,
+		))
+	}
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
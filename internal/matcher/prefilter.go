@@ -0,0 +1,370 @@
+package matcher
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+const (
+	// minPrefilterLiteral is the shortest literal worth searching for; shorter ones
+	// occur in too many URLs to rule anything out
+	minPrefilterLiteral = 3
+	// maxPrefilterLiterals caps the alternatives a single regex may require
+	maxPrefilterLiterals = 16
+	// regexGroupSize is the number of literal-free regexes combined into one alternation
+	regexGroupSize = 16
+)
+
+// gateKind tells how the prefilter decides whether a rule's regex needs to run
+type gateKind uint8
+
+const (
+	gateNone     gateKind = iota // Not a regex rule, or nothing known: always evaluated
+	gateLiterals                 // Evaluated when one of its literals occurs in the URL
+	gateGroup                    // Evaluated when its combined alternation matches the URL
+)
+
+// regexGate is the prefilter entry for a single rule position
+type regexGate struct {
+	kind     gateKind
+	raw      bool    // Rule matches the raw URL instead of the normalized one
+	literals []int32 // Automaton pattern IDs, for gateLiterals
+	group    int     // Index into regexPrefilter.groups, for gateGroup
+}
+
+// regexPrefilter rules out regex rules that cannot match a URL before their regex runs.
+// Every regex is reduced to a set of literals one of which occurs in any match, and all
+// literals are found in a single Aho-Corasick pass over the lowercased URL. Regexes
+// without usable literals are combined into alternations of up to regexGroupSize
+// members; a member only runs when its group's alternation matches.
+type regexPrefilter struct {
+	gates     []regexGate // Indexed by rule position
+	automaton *ahoCorasick
+	literals  int
+	groups    []*regexp.Regexp
+	gated     int // Rules with a literal or group gate
+	// Regex pattern -> required literals, nil when it has no usable ones; reused by later
+	// snapshots so unchanged patterns are not analysed again
+	analysis map[string][]string
+}
+
+// buildRegexPrefilter analyzes the regex rules of a rule set. Patterns in known are not
+// analysed again. Rules whose regex failed to compile are left ungated; they never
+// match anyway.
+func buildRegexPrefilter(rules []domain.Rule, known map[string][]string) *regexPrefilter {
+	p := &regexPrefilter{gates: make([]regexGate, len(rules)), analysis: make(map[string][]string)}
+
+	literalIDs := make(map[string]int32)
+	var patterns []string
+	var ungrouped [2][]int // Literal-free regex positions, by MatchRaw
+
+	for pos := range rules {
+		rule := &rules[pos]
+		if rule.Type != "regex" || rule.GetCompiledRegex() == nil {
+			continue
+		}
+		literals, analysed := p.analysis[rule.Pattern]
+		if !analysed {
+			if literals, analysed = known[rule.Pattern]; !analysed {
+				if literals, analysed = analyseRegex(rule.Pattern); !analysed {
+					continue
+				}
+			}
+			p.analysis[rule.Pattern] = literals
+		}
+
+		gate := &p.gates[pos]
+		gate.raw = rule.MatchRaw
+		if literals == nil {
+			raw := 0
+			if rule.MatchRaw {
+				raw = 1
+			}
+			ungrouped[raw] = append(ungrouped[raw], pos)
+			continue
+		}
+
+		gate.kind = gateLiterals
+		for _, literal := range literals {
+			id, ok := literalIDs[literal]
+			if !ok {
+				id = int32(len(patterns))
+				literalIDs[literal] = id
+				patterns = append(patterns, literal)
+			}
+			if !slices.Contains(gate.literals, id) {
+				gate.literals = append(gate.literals, id)
+			}
+		}
+		p.gated++
+	}
+
+	if len(patterns) > 0 {
+		p.automaton = newAhoCorasick(patterns)
+		p.literals = len(patterns)
+	}
+
+	for _, positions := range ungrouped {
+		for chunk := range slices.Chunk(positions, regexGroupSize) {
+			p.addGroup(rules, chunk)
+		}
+	}
+	return p
+}
+
+// addGroup combines the regexes of the rules at positions into one alternation. Groups
+// of a single rule, or whose alternation fails to compile, leave their rules ungated.
+func (p *regexPrefilter) addGroup(rules []domain.Rule, positions []int) {
+	if len(positions) < 2 {
+		return
+	}
+
+	alternation := &syntax.Regexp{Op: syntax.OpAlternate}
+	for _, pos := range positions {
+		parsed, err := syntax.Parse(rules[pos].Pattern, syntax.Perl)
+		if err != nil {
+			return
+		}
+		alternation.Sub = append(alternation.Sub, stripCaptures(parsed))
+	}
+	combined, err := regexp.Compile(alternation.String())
+	if err != nil {
+		return
+	}
+
+	group := len(p.groups)
+	p.groups = append(p.groups, combined)
+	for _, pos := range positions {
+		p.gates[pos].kind = gateGroup
+		p.gates[pos].group = group
+	}
+	p.gated += len(positions)
+}
+
+// analyseRegex returns the literals gating a regex pattern, nil when it has no usable
+// ones, and false when the pattern does not parse
+func analyseRegex(pattern string) ([]string, bool) {
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+	literals := requiredLiterals(parsed)
+	if !usableLiterals(literals) {
+		return nil, true
+	}
+	return literals, true
+}
+
+// requiredLiterals returns a set of strings one of which occurs in every match of re,
+// lowercased for ASCII letters, or nil when no such set is known
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			// Some non-ASCII runes fold to ASCII letters (the Kelvin sign to k), which
+			// ASCII lowercasing cannot reproduce. ASCII runes are safe because URLs
+			// containing non-ASCII bytes bypass the prefilter.
+			for _, r := range re.Rune {
+				if r >= utf8.RuneSelf {
+					return nil
+				}
+			}
+		}
+		return []string{asciiLower(string(re.Rune))}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Adjacent literals are merged by the parser, so the most selective operand is used
+		var best []string
+		for _, sub := range re.Sub {
+			if literals := requiredLiterals(sub); literals != nil && moreSelective(literals, best) {
+				best = literals
+			}
+		}
+		return best
+	case syntax.OpAlternate:
+		var all []string
+		for _, sub := range re.Sub {
+			literals := requiredLiterals(sub)
+			if literals == nil {
+				return nil
+			}
+			all = append(all, literals...)
+		}
+		if len(all) > maxPrefilterLiterals {
+			return nil
+		}
+		return all
+	}
+	return nil
+}
+
+// moreSelective reports whether literal set a is expected to rule out more URLs than b:
+// a longer shortest literal first, then fewer alternatives
+func moreSelective(a, b []string) bool {
+	if b == nil {
+		return true
+	}
+	shortest := func(literals []string) int {
+		return len(slices.MinFunc(literals, func(x, y string) int { return len(x) - len(y) }))
+	}
+	if sa, sb := shortest(a), shortest(b); sa != sb {
+		return sa > sb
+	}
+	return len(a) < len(b)
+}
+
+// usableLiterals reports whether a literal set is selective enough to gate a regex
+func usableLiterals(literals []string) bool {
+	if len(literals) == 0 {
+		return false
+	}
+	for _, literal := range literals {
+		if len(literal) < minPrefilterLiteral {
+			return false
+		}
+	}
+	return true
+}
+
+// stripCaptures replaces capture groups by their contents so combined regexes do not
+// track submatches
+func stripCaptures(re *syntax.Regexp) *syntax.Regexp {
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+	for i, sub := range re.Sub {
+		re.Sub[i] = stripCaptures(sub)
+	}
+	return re
+}
+
+// asciiLower lowercases ASCII letters and leaves all other bytes unchanged
+func asciiLower(s string) string {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= 'A' && c <= 'Z' {
+			b := []byte(s)
+			for j := i; j < len(b); j++ {
+				if c := b[j]; c >= 'A' && c <= 'Z' {
+					b[j] = c + 'a' - 'A'
+				}
+			}
+			return string(b)
+		}
+	}
+	return s
+}
+
+// isASCII reports whether s contains only ASCII bytes
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// prefilterScan holds the prefilter results for one form of a URL
+type prefilterScan struct {
+	url     string
+	skip    bool     // URL is not ASCII; nothing is ruled out
+	scanned bool     // found has been computed
+	found   []uint64 // Bitset of literal IDs occurring in the URL
+	groups  []int8   // Per group: 0 unknown, 1 matches, -1 does not match
+}
+
+// filter removes the positions of regex rules that cannot match the target. The input
+// slice is reused for the result.
+func (p *regexPrefilter) filter(positions []int, target resolveTarget) []int {
+	if p == nil || p.gated == 0 {
+		return positions
+	}
+
+	scans := [2]prefilterScan{{url: target.normalized}, {url: target.raw}}
+	kept := positions[:0]
+	for _, pos := range positions {
+		gate := &p.gates[pos]
+		if gate.kind == gateNone {
+			kept = append(kept, pos)
+			continue
+		}
+		scan := &scans[0]
+		if gate.raw {
+			scan = &scans[1]
+		}
+		if p.mayMatch(gate, scan) {
+			kept = append(kept, pos)
+		}
+	}
+	return kept
+}
+
+// mayMatch evaluates a rule's gate against one form of the URL
+func (p *regexPrefilter) mayMatch(gate *regexGate, scan *prefilterScan) bool {
+	switch gate.kind {
+	case gateLiterals:
+		if !scan.scanned {
+			scan.scanned = true
+			scan.skip = !isASCII(scan.url)
+			if !scan.skip {
+				scan.found = make([]uint64, (p.literals+63)/64)
+				p.automaton.scan(asciiLower(scan.url), func(id int) {
+					scan.found[id/64] |= 1 << (id % 64)
+				})
+			}
+		}
+		if scan.skip {
+			return true
+		}
+		for _, id := range gate.literals {
+			if scan.found[id/64]&(1<<(id%64)) != 0 {
+				return true
+			}
+		}
+		return false
+	case gateGroup:
+		if scan.groups == nil {
+			scan.groups = make([]int8, len(p.groups))
+		}
+		if scan.groups[gate.group] == 0 {
+			scan.groups[gate.group] = -1
+			if p.groups[gate.group].MatchString(scan.url) {
+				scan.groups[gate.group] = 1
+			}
+		}
+		return scan.groups[gate.group] > 0
+	default:
+		return true
+	}
+}
+
+// stats reports the prefilter layout for diagnostics
+func (p *regexPrefilter) stats() map[string]int {
+	stats := map[string]int{"literal_gated": 0, "group_gated": 0, "groups": 0, "literals": 0, "automaton_states": 0}
+	if p == nil {
+		return stats
+	}
+	for _, gate := range p.gates {
+		switch gate.kind {
+		case gateLiterals:
+			stats["literal_gated"]++
+		case gateGroup:
+			stats["group_gated"]++
+		}
+	}
+	stats["groups"] = len(p.groups)
+	stats["literals"] = p.literals
+	if p.automaton != nil {
+		stats["automaton_states"] = p.automaton.states()
+	}
+	return stats
+}
@@ -0,0 +1,193 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAhoCorasick_Scan(t *testing.T) {
+	patterns := []string{"he", "she", "his", "hers", "usher"}
+	automaton := newAhoCorasick(patterns)
+
+	var found []string
+	automaton.scan("ushers and this", func(id int) {
+		found = append(found, patterns[id])
+	})
+	assert.Equal(t, []string{"she", "he", "usher", "hers", "his"}, found)
+
+	found = nil
+	automaton.scan("nothing to see", func(id int) {
+		found = append(found, patterns[id])
+	})
+	assert.Empty(t, found)
+}
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{`^https://example\.com/docs/\d+$`, []string{"https://example.com/docs/"}},
+		{`https?://(www\.)?shop\.example\.com/`, []string{"shop.example.com/"}},
+		{`/(article|news)/\d+`, []string{"article", "news"}},
+		{`(?i)/CHECKOUT`, []string{"/checkout"}},
+		{`/Docs/`, []string{"/docs/"}},
+		{`(?:/print)+`, []string{"/print"}},
+		{`(/print)?`, nil},
+		{`/(article|\d+)/`, []string{"/"}},
+		{`[a-z]+\.pdf`, []string{".pdf"}},
+		{`(?i)\x{212A}elvin`, []string{"kelvin"}}, // Folded to ASCII by the parser
+		{`.*`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			parsed, err := syntax.Parse(tt.pattern, syntax.Perl)
+			require.NoError(t, err)
+			literals := requiredLiterals(parsed)
+			slices.Sort(literals)
+			slices.Sort(tt.expected)
+			assert.Equal(t, tt.expected, literals)
+		})
+	}
+}
+
+func TestRegexPrefilter_Filter(t *testing.T) {
+	rules := []domain.Rule{
+		{Type: "wildcard", Pattern: "https://example.com/*"},
+		{Type: "regex", Pattern: `/(article|news)/\d+`},
+		{Type: "regex", Pattern: `(?i)/checkout`},
+		{Type: "regex", Pattern: `/\d{4}/\d{2}/`},
+		{Type: "regex", Pattern: `[?&]p=\d+`},
+		{Type: "regex", Pattern: `/print/`, MatchRaw: true},
+	}
+	for i := range rules {
+		require.NoError(t, compilePatterns(&rules[i]))
+	}
+	prefilter := buildRegexPrefilter(rules, nil)
+	assert.Equal(t, map[string]int{"literal_gated": 3, "group_gated": 2, "groups": 1, "literals": 4, "automaton_states": 27}, prefilter.stats())
+
+	filter := func(normalized, raw string) []int {
+		return prefilter.filter([]int{0, 1, 2, 3, 4, 5}, resolveTarget{normalized: normalized, raw: raw})
+	}
+
+	assert.Equal(t, []int{0}, filter("https://example.com/", "https://example.com/"))
+	assert.Equal(t, []int{0, 1, 2}, filter("https://example.com/news/1/Checkout", "https://example.com/news/1/Checkout"))
+	assert.Equal(t, []int{0, 3, 4}, filter("https://example.com/2024/01/?p=7", "https://example.com/2024/01/?p=7"))
+
+	// MatchRaw rules are gated on the raw URL
+	assert.Equal(t, []int{0, 5}, filter("https://example.com/", "https://example.com/print/"))
+
+	// Non-ASCII URLs bypass the literal gates
+	assert.Equal(t, []int{0, 1, 2, 5}, filter("https://example.com/ä", "https://example.com/ä"))
+}
+
+func TestMatcher_PrefilterFollowsRuleUpdates(t *testing.T) {
+	ctx := context.Background()
+	matcher := NewMatcher(&mockRepository{}, newMockCache())
+
+	rule := domain.Rule{ID: uuid.New().String(), Type: "regex", Pattern: `/news/\d+`, CSS: "a"}
+	require.NoError(t, matcher.AddRule(ctx, &rule))
+
+	result, err := matcher.Resolve(ctx, "https://example.com/news/1")
+	require.NoError(t, err)
+	assert.Equal(t, rule.ID, result.RuleID)
+
+	rule.Pattern = `/article/\d+`
+	require.NoError(t, matcher.UpdateRule(ctx, &rule))
+
+	result, err = matcher.Resolve(ctx, "https://example.com/news/1")
+	require.NoError(t, err)
+	assert.Empty(t, result.RuleID)
+
+	result, err = matcher.Resolve(ctx, "https://example.com/article/1")
+	require.NoError(t, err)
+	assert.Equal(t, rule.ID, result.RuleID)
+}
+
+// Feature: github.com/freewebtopdf/asset-injector, Property 45: Regex prefilter equivalence
+func TestProperty_RegexPrefilterEquivalence(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	words := []string{"news", "article", "Checkout", "print", "docs", "2024"}
+
+	genRule := gopter.CombineGens(
+		gen.IntRange(0, 7),
+		gen.IntRange(0, len(words)-1),
+		gen.IntRange(0, len(words)-1),
+	).Map(func(values []any) domain.Rule {
+		a, b := words[values[1].(int)], words[values[2].(int)]
+		var pattern string
+		switch values[0].(int) {
+		case 0:
+			pattern = "/" + regexp.QuoteMeta(a) + "/"
+		case 1:
+			pattern = "/(" + regexp.QuoteMeta(a) + "|" + regexp.QuoteMeta(b) + ")/"
+		case 2:
+			pattern = "(?i)/" + regexp.QuoteMeta(a)
+		case 3:
+			pattern = `/\d+/` + regexp.QuoteMeta(a) + "$"
+		case 4:
+			pattern = "(/" + regexp.QuoteMeta(a) + ")?/" + regexp.QuoteMeta(b) + "/"
+		case 5:
+			pattern = `/[a-z]{2}/\d+`
+		case 6:
+			pattern = `[?&]` + regexp.QuoteMeta(a[:2]) + `=`
+		default:
+			pattern = "^https://example\\.com/(" + regexp.QuoteMeta(a) + ")+"
+		}
+		return domain.Rule{ID: uuid.New().String(), Type: "regex", Pattern: pattern, CSS: "css"}
+	})
+
+	genURL := gopter.CombineGens(
+		gen.IntRange(0, len(words)-1),
+		gen.IntRange(0, len(words)-1),
+		gen.IntRange(0, 3),
+	).Map(func(values []any) string {
+		a, b := words[values[0].(int)], words[values[1].(int)]
+		switch values[2].(int) {
+		case 0:
+			return fmt.Sprintf("https://example.com/%s/%s/", a, b)
+		case 1:
+			return fmt.Sprintf("https://example.com/de/42/%s", a)
+		case 2:
+			return fmt.Sprintf("https://example.com/%s%s?%s=1", a, a, b[:2])
+		default:
+			return fmt.Sprintf("https://example.com/%s/%s", a, b) + "ä"
+		}
+	})
+
+	properties.Property("For any regex rule set and URL, prefiltered resolution should select the same rule and score as a linear scan", prop.ForAll(
+		func(rules []domain.Rule, url string) bool {
+			matcher := NewMatcher(&mockRepository{rules: rules}, newMockCache())
+			if err := matcher.LoadRules(context.Background()); err != nil {
+				return false
+			}
+
+			result, err := matcher.Resolve(context.Background(), url)
+			if err != nil {
+				return false
+			}
+
+			expectedID, expectedScore := linearResolve(matcher, matcher.snapshot.Load().rules, url)
+			return result.RuleID == expectedID && result.Score == expectedScore
+		},
+		gen.SliceOfN(40, genRule),
+		genURL,
+	))
+
+	properties.TestingRun(t, gopter.ConsoleReporter(false))
+}
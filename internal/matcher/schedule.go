@@ -0,0 +1,62 @@
+package matcher
+
+import (
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// nextScheduleBoundary returns the earliest valid_from or valid_until after now, or the
+// zero time when no rule changes its schedule status in the future
+func nextScheduleBoundary(rules []domain.Rule, now time.Time) time.Time {
+	var next time.Time
+	for i := range rules {
+		for _, boundary := range []*time.Time{rules[i].ValidFrom, rules[i].ValidUntil} {
+			if boundary != nil && boundary.After(now) && (next.IsZero() || boundary.Before(next)) {
+				next = *boundary
+			}
+		}
+	}
+	return next
+}
+
+// advanceSchedule invalidates the cached results of rules whose schedule status changed
+// once the next schedule boundary has passed, since they may include rules that just
+// expired or miss rules that just became active. It publishes the same rules with the
+// boundary after now and returns the snapshot to resolve against.
+func (m *Matcher) advanceSchedule(now time.Time) *ruleSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Another resolution may have advanced the schedule already
+	snapshot := m.snapshot.Load()
+	if snapshot.nextBoundary.IsZero() || now.Before(snapshot.nextBoundary) {
+		return snapshot
+	}
+
+	// No boundary lies between the previous computation and the passed one
+	var change ruleChange
+	for i := range snapshot.rules {
+		rule := &snapshot.rules[i]
+		for _, boundary := range []*time.Time{rule.ValidFrom, rule.ValidUntil} {
+			if boundary != nil && !boundary.Before(snapshot.nextBoundary) && !boundary.After(now) {
+				change.referenced = append(change.referenced, rule.ID)
+				change.matching = append(change.matching, rule)
+				break
+			}
+		}
+	}
+	return m.publish(snapshot.rules, change)
+}
+
+// scheduleReason explains why a rule outside its validity window was skipped
+func scheduleReason(rule *domain.Rule, now time.Time) string {
+	switch rule.ScheduleStatus(now) {
+	case domain.SchedulePending:
+		return "rule is pending until " + rule.ValidFrom.UTC().Format(time.RFC3339)
+	case domain.ScheduleExpired:
+		return "rule expired at " + rule.ValidUntil.UTC().Format(time.RFC3339)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,94 @@
+package matcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestNextScheduleBoundary(t *testing.T) {
+	now := time.Date(2026, 11, 20, 12, 0, 0, 0, time.UTC)
+	rules := []domain.Rule{
+		{ID: "unscheduled"},
+		{ID: "expired", ValidUntil: timePtr(now.Add(-time.Hour))},
+		{ID: "sale", ValidFrom: timePtr(now.Add(-time.Hour)), ValidUntil: timePtr(now.Add(48 * time.Hour))},
+		{ID: "launch", ValidFrom: timePtr(now.Add(24 * time.Hour))},
+	}
+
+	assert.Equal(t, now.Add(24*time.Hour), nextScheduleBoundary(rules, now))
+	assert.Equal(t, now.Add(48*time.Hour), nextScheduleBoundary(rules, now.Add(24*time.Hour)))
+	assert.True(t, nextScheduleBoundary(rules, now.Add(48*time.Hour)).IsZero())
+	assert.True(t, nextScheduleBoundary(nil, now).IsZero())
+}
+
+func TestMatcher_ResolveWithSchedule(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 11, 20, 12, 0, 0, 0, time.UTC)
+	saleEnd := start.Add(time.Hour)
+	launch := start.Add(2 * time.Hour)
+
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "sale", Type: "wildcard", Pattern: "https://example.com/*", CSS: ".sale { display: none; }", Priority: intPtr(2000), ValidUntil: &saleEnd},
+		{ID: "launch", Type: "wildcard", Pattern: "https://example.com/*", CSS: ".teaser { display: none; }", Priority: intPtr(1500), ValidFrom: &launch},
+		{ID: "site", Type: "wildcard", Pattern: "https://example.com/*", CSS: ".banner { display: none; }"},
+	}}
+	matcher := NewMatcher(repo, newMockCache())
+	clock := start
+	matcher.now = func() time.Time { return clock }
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	resolve := func() *domain.MatchResult {
+		result, err := matcher.Resolve(ctx, "https://example.com/page")
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.Equal(t, "sale", resolve().RuleID)
+	assert.True(t, resolve().CacheHit)
+
+	// Passing a boundary drops cached results computed before it
+	clock = saleEnd
+	result := resolve()
+	assert.Equal(t, "site", result.RuleID)
+	assert.False(t, result.CacheHit)
+
+	clock = launch.Add(time.Minute)
+	assert.Equal(t, "launch", resolve().RuleID)
+
+	stats := matcher.GetStats(ctx)
+	assert.Equal(t, map[string]int{domain.SchedulePending: 0, domain.ScheduleActive: 1, domain.ScheduleExpired: 1}, stats["scheduled_rules"])
+	assert.NotContains(t, stats, "next_schedule_boundary")
+
+	explanation, err := matcher.Explain(ctx, "https://example.com/page")
+	require.NoError(t, err)
+	require.Len(t, explanation.Evaluations, 3)
+	assert.Equal(t, "launch", explanation.Evaluations[0].RuleID)
+	expired := explanation.Evaluations[2]
+	assert.Equal(t, "sale", expired.RuleID)
+	assert.Equal(t, domain.EvaluationOutsideWindow, expired.Outcome)
+	assert.Equal(t, "rule expired at 2026-11-20T13:00:00Z", expired.Reason)
+}
+
+func TestMatcher_CacheResultSkipsPassedBoundary(t *testing.T) {
+	cache := newMockCache()
+	matcher := NewMatcher(&mockRepository{}, cache)
+	boundary := time.Date(2026, 11, 20, 12, 0, 0, 0, time.UTC)
+	matcher.now = func() time.Time { return boundary }
+
+	matcher.cacheResult(resolveTarget{expires: boundary}, "stale", &domain.MatchResult{RuleID: "a"})
+	_, found := cache.Get("stale")
+	assert.False(t, found)
+
+	matcher.cacheResult(resolveTarget{expires: boundary.Add(time.Second)}, "fresh", &domain.MatchResult{RuleID: "a"})
+	_, found = cache.Get("fresh")
+	assert.True(t, found)
+}
@@ -0,0 +1,166 @@
+package matcher
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/ruleset"
+)
+
+// ruleSnapshot is an immutable view of the rule set and everything derived from it.
+// Writers build a new snapshot and publish it; readers load the current one without
+// locking and never copy its rules.
+type ruleSnapshot struct {
+	// Incremented on every rule change; results computed before a change are not cached
+	generation uint64
+	rules      []domain.Rule
+	positions  map[string]int // Rule ID -> index in rules
+	index      *ruleIndex
+	prefilter  *regexPrefilter
+	rawRules   int // Rules with MatchRaw set
+	conditions conditionState
+	// Earliest future valid_from/valid_until of any rule; affected results are dropped once it passes
+	nextBoundary time.Time
+	// Assets of the rules, read by cached results that reference them
+	assets *assetSnapshot
+}
+
+// patternHints is pattern analysis from a ruleset snapshot, used for the next rule
+// change instead of analysing the patterns
+type patternHints struct {
+	index    map[patternKey]indexKey
+	literals map[string][]string // Regex pattern -> prefilter literals, nil for none
+}
+
+// newRuleSnapshot derives the lookup state for a set of prepared rules. Unchanged asset
+// entries, index buckets and regex analysis are shared with the previous snapshot;
+// hints add those of patterns it did not contain.
+func newRuleSnapshot(rules []domain.Rule, generation uint64, previous *ruleSnapshot, hints patternHints, now time.Time) *ruleSnapshot {
+	knownKeys, knownLiterals := hints.index, hints.literals
+	if previous != nil {
+		knownKeys = mergeKnown(previous.index.keys, hints.index)
+		knownLiterals = mergeKnown(previous.prefilter.analysis, hints.literals)
+	}
+
+	snapshot := &ruleSnapshot{
+		generation:   generation,
+		rules:        rules,
+		positions:    make(map[string]int, len(rules)),
+		index:        buildRuleIndex(rules, knownKeys),
+		prefilter:    buildRegexPrefilter(rules, knownLiterals),
+		conditions:   buildConditionState(rules),
+		nextBoundary: nextScheduleBoundary(rules, now),
+	}
+	for i := range rules {
+		if _, exists := snapshot.positions[rules[i].ID]; !exists {
+			snapshot.positions[rules[i].ID] = i
+		}
+		if rules[i].MatchRaw {
+			snapshot.rawRules++
+		}
+	}
+
+	var previousAssets *assetSnapshot
+	if previous != nil {
+		previousAssets = previous.assets
+	}
+	snapshot.assets = buildAssetSnapshot(rules, previousAssets, generation)
+	return snapshot
+}
+
+// mergeKnown adds hints to the analysis of the previous snapshot without modifying it
+func mergeKnown[K comparable, V any](previous, hints map[K]V) map[K]V {
+	if len(hints) == 0 {
+		return previous
+	}
+	known := maps.Clone(previous)
+	if known == nil {
+		known = make(map[K]V, len(hints))
+	}
+	maps.Copy(known, hints)
+	return known
+}
+
+// position returns the index of the rule with the ID, or -1
+func (s *ruleSnapshot) position(id string) int {
+	if i, exists := s.positions[id]; exists {
+		return i
+	}
+	return -1
+}
+
+// publish swaps in a snapshot of the rules and drops the cached results the change
+// affected. Must be called with m.mu held.
+func (m *Matcher) publish(rules []domain.Rule, change ruleChange) *ruleSnapshot {
+	current := m.snapshot.Load()
+	next := newRuleSnapshot(rules, current.generation+1, current, m.hints, m.now())
+	m.hints = patternHints{}
+	m.snapshot.Store(next)
+	m.invalidate(change, current, next)
+	return next
+}
+
+// Generation returns the generation of the current rule set, incremented on every change
+func (m *Matcher) Generation() uint64 {
+	return m.snapshot.Load().generation
+}
+
+// IndexHints returns the index bucket of every pattern in the current rule set, so a
+// later start can build the index without analysing the patterns
+func (m *Matcher) IndexHints() []ruleset.IndexHint {
+	keys := m.snapshot.Load().index.keys
+	hints := make([]ruleset.IndexHint, 0, len(keys))
+	for pk, key := range keys {
+		hints = append(hints, ruleset.IndexHint{Type: pk.ruleType, Pattern: pk.pattern, Kind: int(key.kind), Value: key.value})
+	}
+	slices.SortFunc(hints, func(a, b ruleset.IndexHint) int {
+		if c := strings.Compare(a.Type, b.Type); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Pattern, b.Pattern)
+	})
+	return hints
+}
+
+// UseIndexHints supplies index buckets from a ruleset snapshot for the next rule change.
+// Hints must come from a snapshot written by the same format version.
+func (m *Matcher) UseIndexHints(hints []ruleset.IndexHint) {
+	known := make(map[patternKey]indexKey, len(hints))
+	for _, hint := range hints {
+		known[patternKey{ruleType: hint.Type, pattern: hint.Pattern}] = indexKey{kind: indexKind(hint.Kind), value: hint.Value}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hints.index = known
+}
+
+// PrefilterHints returns the prefilter literals of every regex pattern in the current
+// rule set, so a later start can build the prefilter without parsing the patterns
+func (m *Matcher) PrefilterHints() []ruleset.PrefilterHint {
+	analysis := m.snapshot.Load().prefilter.analysis
+	hints := make([]ruleset.PrefilterHint, 0, len(analysis))
+	for pattern, literals := range analysis {
+		hints = append(hints, ruleset.PrefilterHint{Pattern: pattern, Literals: literals})
+	}
+	slices.SortFunc(hints, func(a, b ruleset.PrefilterHint) int {
+		return strings.Compare(a.Pattern, b.Pattern)
+	})
+	return hints
+}
+
+// UsePrefilterHints supplies regex prefilter literals from a ruleset snapshot for the
+// next rule change. Hints must come from a snapshot written by the same format version.
+func (m *Matcher) UsePrefilterHints(hints []ruleset.PrefilterHint) {
+	known := make(map[string][]string, len(hints))
+	for _, hint := range hints {
+		known[hint.Pattern] = hint.Literals
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hints.literals = known
+}
@@ -0,0 +1,153 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/ruleset"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcher_SnapshotGenerations(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "a", Type: "host", Pattern: "a.example.com", CSS: "a"},
+	}}
+	matcher := NewMatcher(repo, newMockCache())
+	assert.Equal(t, uint64(0), matcher.Generation())
+	require.NoError(t, matcher.LoadRules(ctx))
+	assert.Equal(t, uint64(1), matcher.Generation())
+
+	// A reader's snapshot is unaffected by later writes
+	before := matcher.snapshot.Load()
+	require.NoError(t, matcher.AddRule(ctx, &domain.Rule{ID: "b", Type: "host", Pattern: "b.example.com", CSS: "b"}))
+	require.NoError(t, matcher.UpdateRule(ctx, &domain.Rule{ID: "a", Type: "host", Pattern: "a.example.com", CSS: "changed"}))
+	require.NoError(t, matcher.RemoveRule(ctx, "b"))
+	assert.Equal(t, uint64(4), matcher.Generation())
+	require.Len(t, before.rules, 1)
+	assert.Equal(t, "a", before.rules[0].CSS)
+
+	after := matcher.snapshot.Load()
+	require.Len(t, after.rules, 1)
+	assert.Equal(t, "changed", after.rules[0].CSS)
+	assert.Equal(t, 0, after.position("a"))
+	assert.Equal(t, -1, after.position("b"))
+
+	// Failed changes publish nothing
+	assert.Error(t, matcher.RemoveRule(ctx, "b"))
+	assert.Equal(t, uint64(4), matcher.Generation())
+	assert.Equal(t, uint64(4), matcher.GetStats(ctx)["generation"])
+}
+
+func TestMatcher_ResolveDuringRuleChanges(t *testing.T) {
+	ctx := context.Background()
+	matcher := NewMatcher(&mockRepository{}, newMockCache())
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	var wg sync.WaitGroup
+	for worker := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				_, err := matcher.Resolve(ctx, fmt.Sprintf("https://site%d.example.com/", (worker+i)%20))
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	for i := range 20 {
+		rule := &domain.Rule{ID: fmt.Sprintf("rule%d", i), Type: "host", Pattern: fmt.Sprintf("site%d.example.com", i), CSS: "a"}
+		require.NoError(t, matcher.AddRule(ctx, rule))
+	}
+	wg.Wait()
+
+	// Once the writes are done every site resolves to its rule
+	for i := range 20 {
+		result, err := matcher.Resolve(ctx, fmt.Sprintf("https://site%d.example.com/", i))
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("rule%d", i), result.RuleID)
+	}
+}
+
+func TestMatcher_IndexHints(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "exact", Type: "exact", Pattern: "https://example.com/", CSS: "a"},
+		{ID: "wildcard", Type: "wildcard", Pattern: "https://*.example.org/*", CSS: "b"},
+		{ID: "regex", Type: "regex", Pattern: `^https://docs\.example\.net/`, CSS: "c"},
+		{ID: "regex-copy", Type: "regex", Pattern: `^https://docs\.example\.net/`, CSS: "d"},
+		{ID: "fallback", Type: "wildcard", Pattern: "*print*", CSS: "e"},
+	}}
+	original := NewMatcher(repo, newMockCache())
+	require.NoError(t, original.LoadRules(ctx))
+
+	hints := original.IndexHints()
+	require.Len(t, hints, 4)
+	assert.Equal(t, "exact", hints[0].Type)
+
+	// Hints rebuild the same index
+	restored := NewMatcher(repo, newMockCache())
+	restored.UseIndexHints(hints)
+	require.NoError(t, restored.LoadRules(ctx))
+	assert.Equal(t, original.snapshot.Load().index, restored.snapshot.Load().index)
+
+	// Hinted patterns are not analysed again, and later snapshots keep their buckets
+	for i := range hints {
+		if hints[i].Type == "regex" {
+			hints[i].Kind, hints[i].Value = int(indexFallback), ""
+		}
+	}
+	hinted := NewMatcher(repo, newMockCache())
+	hinted.UseIndexHints(hints)
+	require.NoError(t, hinted.LoadRules(ctx))
+	require.NoError(t, hinted.AddRule(ctx, &domain.Rule{ID: "new", Type: "host", Pattern: "example.com", CSS: "f"}))
+	assert.Equal(t, []int{2, 3, 4}, hinted.snapshot.Load().index.fallback)
+
+	result, err := hinted.Resolve(ctx, "https://docs.example.net/guide")
+	require.NoError(t, err)
+	assert.Equal(t, "regex", result.RuleID)
+}
+
+func TestMatcher_PrefilterHints(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "news", Type: "regex", Pattern: `/(article|news)/\d+`, CSS: "a"},
+		{ID: "dated", Type: "regex", Pattern: `/\d{4}/\d{2}/`, CSS: "b"},
+		{ID: "paged", Type: "regex", Pattern: `[?&]p=\d+`, CSS: "c"},
+		{ID: "exact", Type: "exact", Pattern: "https://example.com/", CSS: "d"},
+	}}
+	original := NewMatcher(repo, newMockCache())
+	require.NoError(t, original.LoadRules(ctx))
+
+	hints := original.PrefilterHints()
+	require.Len(t, hints, 3)
+	assert.Equal(t, ruleset.PrefilterHint{Pattern: `/(article|news)/\d+`, Literals: []string{"article", "news"}}, hints[0])
+	assert.Nil(t, hints[1].Literals)
+
+	// Hints rebuild the same prefilter
+	restored := NewMatcher(repo, newMockCache())
+	restored.UsePrefilterHints(hints)
+	require.NoError(t, restored.LoadRules(ctx))
+	assert.Equal(t, original.snapshot.Load().prefilter.gates, restored.snapshot.Load().prefilter.gates)
+	assert.Equal(t, original.snapshot.Load().prefilter.stats(), restored.snapshot.Load().prefilter.stats())
+
+	// Hinted patterns are not analysed again, and later snapshots keep their literals
+	hints[0].Literals = []string{"nowhere"}
+	hinted := NewMatcher(repo, newMockCache())
+	hinted.UsePrefilterHints(hints)
+	require.NoError(t, hinted.LoadRules(ctx))
+	require.NoError(t, hinted.AddRule(ctx, &domain.Rule{ID: "new", Type: "host", Pattern: "example.org", CSS: "e"}))
+
+	result, err := hinted.Resolve(ctx, "https://example.com/news/1")
+	require.NoError(t, err)
+	assert.Empty(t, result.RuleID)
+
+	result, err = hinted.Resolve(ctx, "https://example.com/nowhere/news/1")
+	require.NoError(t, err)
+	assert.Equal(t, "news", result.RuleID)
+}
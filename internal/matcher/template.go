@@ -0,0 +1,98 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// ruleAssets returns the variant ID and the rendered CSS/JS a rule serves for a URL
+func ruleAssets(rule *domain.Rule, target resolveTarget) (variantID, css, js string) {
+	variantID, css, js = selectVariant(rule, target.normalized)
+	css, js = renderAssets(rule, target.urlFor(rule), css, js)
+	return variantID, css, js
+}
+
+// renderAssets expands {{.name}} references in CSS and JS with the named groups the
+// rule's regex or URL pattern captured from url. Values are escaped so they can only
+// form literal text in either language; groups that did not participate render empty.
+func renderAssets(rule *domain.Rule, url, css, js string) (string, string) {
+	if !strings.Contains(css, "{{") && !strings.Contains(js, "{{") {
+		return css, js
+	}
+
+	groups := captureGroups(rule, url)
+	css = domain.ExpandTemplate(css, func(name string) string { return escapeCSS(groups[name]) })
+	js = domain.ExpandTemplate(js, func(name string) string { return escapeJS(groups[name]) })
+	return css, js
+}
+
+// captureGroups returns the named groups of the rule's pattern matched against url
+func captureGroups(rule *domain.Rule, url string) map[string]string {
+	switch rule.Type {
+	case "regex":
+		re := rule.GetCompiledRegex()
+		if re == nil {
+			return nil
+		}
+		match := re.FindStringSubmatch(url)
+		if match == nil {
+			return nil
+		}
+		groups := make(map[string]string)
+		for i, name := range re.SubexpNames() {
+			if name != "" {
+				groups[name] = match[i]
+			}
+		}
+		return groups
+	case "urlpattern":
+		if pattern := rule.GetCompiledURLPattern(); pattern != nil {
+			groups, _ := pattern.Exec(url)
+			return groups
+		}
+	}
+	return nil
+}
+
+// escapeCSS escapes everything but ASCII letters, digits, '-' and '_' as CSS hex
+// escapes, which are valid in identifiers, strings and url() alike
+func escapeCSS(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case isTemplateSafe(r):
+			b.WriteRune(r)
+		case r == 0:
+			b.WriteString(`\fffd `)
+		default:
+			fmt.Fprintf(&b, `\%x `, r)
+		}
+	}
+	return b.String()
+}
+
+// escapeJS escapes everything but ASCII letters, digits, '-', '_' and '.' as \u
+// escapes, so a value cannot end a string literal, a template literal or the script
+func escapeJS(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case isTemplateSafe(r) || r == '.':
+			b.WriteRune(r)
+		case r > 0xFFFF:
+			high, low := utf16.EncodeRune(r)
+			fmt.Fprintf(&b, `\u%04x\u%04x`, high, low)
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+	}
+	return b.String()
+}
+
+// isTemplateSafe reports whether a rune is inserted into rendered assets unescaped
+func isTemplateSafe(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_'
+}
@@ -0,0 +1,66 @@
+package matcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeCSS(t *testing.T) {
+	assert.Equal(t, "acme-corp_1", escapeCSS("acme-corp_1"))
+	assert.Equal(t, `a\7d \20 body\7b \7d `, escapeCSS("a} body{}"))
+	assert.Equal(t, `\22 \29 \3b \e4 \fffd `, escapeCSS("\");ä\x00"))
+}
+
+func TestEscapeJS(t *testing.T) {
+	assert.Equal(t, "acme-corp_1.2", escapeJS("acme-corp_1.2"))
+	assert.Equal(t, `\u0027\u0029\u003balert`, escapeJS("');alert"))
+	assert.Equal(t, `\u003c\u002fscript\u003e\u0060\u0024`, escapeJS("</script>`$"))
+	assert.Equal(t, `\u00e4\ud83d\ude00`, escapeJS("ä😀"))
+}
+
+func TestMatcher_ResolveRendersCaptureGroups(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "tenant", Type: "regex", Pattern: `^https://(?P<tenant>[^./]+)\.example\.com/`, CSS: ".tenant-{{.tenant}} .banner { display: none; }"},
+		{ID: "book", Type: "urlpattern", Pattern: `https://books.example.org/books/:id/:title?`, JS: "highlight('{{.id}}', '{{ .title }}');"},
+	}}
+	matcher := NewMatcher(repo, newMockCache())
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	result, err := matcher.Resolve(ctx, "https://acme.example.com/page")
+	require.NoError(t, err)
+	assert.Equal(t, ".tenant-acme .banner { display: none; }", result.CSS)
+
+	// Cached results hold the output rendered for their own URL
+	result, err = matcher.Resolve(ctx, "https://globex.example.com/page")
+	require.NoError(t, err)
+	assert.False(t, result.CacheHit)
+	assert.Equal(t, ".tenant-globex .banner { display: none; }", result.CSS)
+
+	result, err = matcher.Resolve(ctx, "https://acme.example.com/page")
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, ".tenant-acme .banner { display: none; }", result.CSS)
+
+	// Values are escaped for the JS string context; missing optional groups render empty
+	result, err = matcher.Resolve(ctx, "https://books.example.org/books/42/it's")
+	require.NoError(t, err)
+	assert.Equal(t, `highlight('42', 'it\u0027s');`, result.JS)
+
+	result, err = matcher.Resolve(ctx, "https://books.example.org/books/42")
+	require.NoError(t, err)
+	assert.Equal(t, "highlight('42', '');", result.JS)
+
+	result, err = matcher.ResolveStacked(ctx, "https://acme.example.com/page")
+	require.NoError(t, err)
+	assert.Equal(t, "/* rule: tenant */\n.tenant-acme .banner { display: none; }\n", result.CSS)
+
+	explanation, err := matcher.Explain(ctx, "https://acme.example.com/page")
+	require.NoError(t, err)
+	assert.Equal(t, ".tenant-acme .banner { display: none; }", explanation.Result.CSS)
+}
@@ -0,0 +1,94 @@
+package matcher
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// variantBucket maps a URL to one of 100 buckets, stable for a given salt
+func variantBucket(salt, url string) int {
+	h := fnv.New64a()
+	h.Write([]byte(salt))
+	h.Write([]byte{0})
+	h.Write([]byte(url))
+	return int(h.Sum64() % 100)
+}
+
+// selectVariant returns the variant ID and assets a rule serves for a normalized URL.
+// Buckets are assigned to variants in order by weight; the remaining buckets get the
+// rule's own assets as the control variant. Rules without variants report no ID.
+func selectVariant(rule *domain.Rule, url string) (id, css, js string) {
+	if len(rule.Variants) == 0 {
+		return "", rule.CSS, rule.JS
+	}
+
+	salt := rule.VariantSalt
+	if salt == "" {
+		salt = rule.ID
+	}
+	bucket := variantBucket(salt, url)
+	for i := range rule.Variants {
+		variant := &rule.Variants[i]
+		if bucket < variant.Weight {
+			return variant.ID, variant.CSS, variant.JS
+		}
+		bucket -= variant.Weight
+	}
+	return domain.ControlVariantID, rule.CSS, rule.JS
+}
+
+// variantCounters counts resolutions served by each variant of each rule
+type variantCounters struct {
+	mu     sync.Mutex
+	counts map[string]map[string]int64 // Rule ID -> variant ID -> count
+}
+
+// record counts one resolution; results without a variant are ignored
+func (c *variantCounters) record(result *domain.MatchResult) {
+	if result.VariantID == "" && len(result.VariantIDs) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]map[string]int64)
+	}
+	add := func(ruleID, variantID string) {
+		if c.counts[ruleID] == nil {
+			c.counts[ruleID] = make(map[string]int64)
+		}
+		c.counts[ruleID][variantID]++
+	}
+
+	if len(result.VariantIDs) > 0 {
+		for ruleID, variantID := range result.VariantIDs {
+			add(ruleID, variantID)
+		}
+		return
+	}
+	add(result.RuleID, result.VariantID)
+}
+
+// snapshot returns the counters of the rules that currently have variants, with
+// zero counts for variants that have not been served yet
+func (c *variantCounters) snapshot(rules []domain.Rule) map[string]map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := make(map[string]map[string]int64)
+	for i := range rules {
+		rule := &rules[i]
+		if len(rule.Variants) == 0 {
+			continue
+		}
+		counts := map[string]int64{domain.ControlVariantID: c.counts[rule.ID][domain.ControlVariantID]}
+		for _, variant := range rule.Variants {
+			counts[variant.ID] = c.counts[rule.ID][variant.ID]
+		}
+		snapshot[rule.ID] = counts
+	}
+	return snapshot
+}
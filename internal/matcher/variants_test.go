@@ -0,0 +1,109 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectVariant(t *testing.T) {
+	rule := &domain.Rule{ID: "rollout", CSS: "control", Variants: []domain.RuleVariant{
+		{ID: "compact", Weight: 10, CSS: "compact"},
+		{ID: "wide", Weight: 30, CSS: "wide"},
+	}}
+
+	counts := make(map[string]int)
+	for i := range 10_000 {
+		url := fmt.Sprintf("https://example.com/page/%d", i)
+		id, css, _ := selectVariant(rule, url)
+		counts[id]++
+		if id == domain.ControlVariantID {
+			assert.Equal(t, "control", css)
+		} else {
+			assert.Equal(t, id, css)
+		}
+
+		// The assignment is stable for a URL
+		again, _, _ := selectVariant(rule, url)
+		assert.Equal(t, id, again)
+	}
+	assert.InDelta(t, 1_000, counts["compact"], 200)
+	assert.InDelta(t, 3_000, counts["wide"], 300)
+	assert.InDelta(t, 6_000, counts[domain.ControlVariantID], 300)
+
+	// Changing the salt reshuffles URLs between variants
+	salted := *rule
+	salted.VariantSalt = "second-run"
+	moved := 0
+	for i := range 1_000 {
+		url := fmt.Sprintf("https://example.com/page/%d", i)
+		before, _, _ := selectVariant(rule, url)
+		after, _, _ := selectVariant(&salted, url)
+		if before != after {
+			moved++
+		}
+	}
+	assert.Greater(t, moved, 100)
+
+	id, css, js := selectVariant(&domain.Rule{ID: "plain", CSS: "a", JS: "b"}, "https://example.com/")
+	assert.Empty(t, id)
+	assert.Equal(t, "a", css)
+	assert.Equal(t, "b", js)
+}
+
+func TestMatcher_ResolveWithVariants(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "rollout", Type: "wildcard", Pattern: "https://example.com/*", CSS: ".old {}", Priority: intPtr(2000), Variants: []domain.RuleVariant{
+			{ID: "new", Weight: 100, CSS: ".new {}", JS: "run()"},
+		}},
+		{ID: "paused", Type: "wildcard", Pattern: "https://example.com/docs/*", CSS: ".docs {}", Variants: []domain.RuleVariant{
+			{ID: "dark", Weight: 0, CSS: ".dark {}"},
+		}},
+		{ID: "plain", Type: "host", Pattern: "example.com", CSS: ".plain {}"},
+	}}
+	matcher := NewMatcher(repo, newMockCache())
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	result, err := matcher.Resolve(ctx, "https://example.com/page")
+	require.NoError(t, err)
+	assert.Equal(t, "rollout", result.RuleID)
+	assert.Equal(t, "new", result.VariantID)
+	assert.Equal(t, ".new {}", result.CSS)
+	assert.Equal(t, "run()", result.JS)
+
+	// Cached results keep the variant
+	result, err = matcher.Resolve(ctx, "https://example.com/page")
+	require.NoError(t, err)
+	assert.True(t, result.CacheHit)
+	assert.Equal(t, "new", result.VariantID)
+	assert.Equal(t, ".new {}", result.CSS)
+
+	result, err = matcher.ResolveStacked(ctx, "https://example.com/docs/intro")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rollout", "paused", "plain"}, result.RuleIDs)
+	assert.Equal(t, map[string]string{"rollout": "new", "paused": domain.ControlVariantID}, result.VariantIDs)
+	assert.Equal(t, "/* rule: plain */\n.plain {}\n/* rule: paused */\n.docs {}\n/* rule: rollout */\n.new {}\n", result.CSS)
+
+	explanation, err := matcher.Explain(ctx, "https://example.com/page")
+	require.NoError(t, err)
+	assert.Equal(t, "new", explanation.Result.VariantID)
+	assert.Equal(t, ".new {}", explanation.Result.CSS)
+
+	// Explain does not count as served traffic
+	stats := matcher.GetStats(ctx)
+	assert.Equal(t, map[string]map[string]int64{
+		"rollout": {"new": 3, domain.ControlVariantID: 0},
+		"paused":  {"dark": 0, domain.ControlVariantID: 1},
+	}, stats["variant_matches"])
+
+	// Removed rules drop out of the counters
+	require.NoError(t, matcher.RemoveRule(ctx, "paused"))
+	stats = matcher.GetStats(ctx)
+	assert.NotContains(t, stats["variant_matches"], "paused")
+}
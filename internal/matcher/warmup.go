@@ -0,0 +1,126 @@
+package matcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// Cache warm-up states reported in health details
+const (
+	warmupIdle      = "idle"
+	warmupRunning   = "running"
+	warmupCompleted = "completed"
+	warmupCancelled = "cancelled"
+)
+
+// warmupProgress tracks the background re-resolution of saved cache keys
+type warmupProgress struct {
+	mu         sync.Mutex
+	state      string
+	total      int
+	warmed     int
+	skipped    int
+	failed     int
+	startedAt  time.Time
+	finishedAt time.Time
+}
+
+// details returns the progress for health details
+func (p *warmupProgress) details() map[string]any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state := p.state
+	if state == "" {
+		state = warmupIdle
+	}
+	details := map[string]any{
+		"state":   state,
+		"total":   p.total,
+		"warmed":  p.warmed,
+		"skipped": p.skipped,
+		"failed":  p.failed,
+	}
+	if !p.startedAt.IsZero() {
+		details["started_at"] = p.startedAt
+	}
+	if !p.finishedAt.IsZero() {
+		details["finished_at"] = p.finishedAt
+	}
+	return details
+}
+
+// update applies a change to the progress under its lock
+func (p *warmupProgress) update(change func(p *warmupProgress)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	change(p)
+}
+
+// WarmCache re-resolves the URLs behind saved cache keys, hottest first, so a restarted
+// service starts with a warm cache. Keys are resolved one at a time; keys that cannot be
+// turned back into a request are skipped. Progress is reported by HealthCheck.
+func (m *Matcher) WarmCache(ctx context.Context, keys []string) {
+	m.warmup.update(func(p *warmupProgress) {
+		p.state, p.total, p.startedAt = warmupRunning, len(keys), time.Now()
+		p.warmed, p.skipped, p.failed = 0, 0, 0
+		p.finishedAt = time.Time{}
+	})
+
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			m.warmup.update(func(p *warmupProgress) {
+				p.state = warmupCancelled
+				p.finishedAt = time.Now()
+			})
+			return
+		}
+
+		url, stacked, render, ok := m.warmupRequest(key)
+		if !ok {
+			m.warmup.update(func(p *warmupProgress) { p.skipped++ })
+			continue
+		}
+
+		requestCtx := ctx
+		if render != nil {
+			requestCtx = domain.WithRenderContext(ctx, render)
+		}
+		var err error
+		if stacked {
+			_, err = m.ResolveStacked(requestCtx, url)
+		} else {
+			_, err = m.Resolve(requestCtx, url)
+		}
+		m.warmup.update(func(p *warmupProgress) {
+			if err != nil {
+				p.failed++
+			} else {
+				p.warmed++
+			}
+		})
+	}
+
+	m.warmup.update(func(p *warmupProgress) {
+		p.state = warmupCompleted
+		p.finishedAt = time.Now()
+	})
+}
+
+// warmupRequest turns a cache key back into the URL, resolution mode and render
+// context it was built from
+func (m *Matcher) warmupRequest(key string) (url string, stacked bool, render *domain.RenderContext, ok bool) {
+	_, url, suffix, stacked := parseCacheKey(key)
+	if url == "" {
+		return "", false, nil, false
+	}
+	if suffix == "" {
+		return url, stacked, nil, true
+	}
+
+	render, ok = m.snapshot.Load().conditions.renderContextFromSuffix(suffix)
+	return url, stacked, render, ok
+}
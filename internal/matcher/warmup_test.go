@@ -0,0 +1,65 @@
+package matcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcher_WarmCache(t *testing.T) {
+	ctx := context.Background()
+	minWidth := 768
+	repo := &mockRepository{rules: []domain.Rule{
+		{ID: "shop", Type: "host", Pattern: "shop.example.com", CSS: "a"},
+		{ID: "wide", Type: "host", Pattern: "wide.example.com", CSS: "b",
+			Conditions: &domain.RuleConditions{MinViewportWidth: &minWidth, Languages: []string{"de"}}},
+	}}
+	c := newMockCache()
+	matcher := NewMatcher(repo, c)
+	require.NoError(t, matcher.LoadRules(ctx))
+
+	// Keys as the previous process cached them, including stacked and render context keys
+	render := &domain.RenderContext{ViewportWidth: 1024, AcceptLanguage: "de-DE,de;q=0.9"}
+	warmedTarget := matcher.newResolveTarget(domain.WithRenderContext(ctx, render), "https://wide.example.com/")
+	plainTarget := matcher.newResolveTarget(ctx, "https://shop.example.com/cart")
+	keys := []string{
+		plainTarget.cacheKey,
+		stackedCacheKey(plainTarget.cacheKey),
+		warmedTarget.cacheKey,
+		contextKeyMarker + "|vw=9|lang=de",
+	}
+
+	assert.Equal(t, "idle", matcher.HealthCheck(ctx).Details["cache_warmup"].(map[string]any)["state"])
+	matcher.WarmCache(ctx, keys)
+
+	result, found := c.Get(plainTarget.cacheKey)
+	require.True(t, found)
+	assert.Equal(t, "shop", result.RuleID)
+	_, found = c.Get(stackedCacheKey(plainTarget.cacheKey))
+	assert.True(t, found)
+	result, found = c.Get(warmedTarget.cacheKey)
+	require.True(t, found)
+	assert.Equal(t, "wide", result.RuleID)
+
+	progress := matcher.HealthCheck(ctx).Details["cache_warmup"].(map[string]any)
+	assert.Equal(t, "completed", progress["state"])
+	assert.Equal(t, 4, progress["total"])
+	assert.Equal(t, 3, progress["warmed"])
+	assert.Equal(t, 1, progress["skipped"])
+	assert.Contains(t, progress, "finished_at")
+}
+
+func TestMatcher_WarmCacheCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	matcher := NewMatcher(&mockRepository{}, newMockCache())
+
+	matcher.WarmCache(ctx, []string{"https://example.com/"})
+	progress := matcher.HealthCheck(context.Background()).Details["cache_warmup"].(map[string]any)
+	assert.Equal(t, "cancelled", progress["state"])
+	assert.Equal(t, 0, progress["warmed"])
+}
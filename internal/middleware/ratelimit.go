@@ -81,6 +81,9 @@ func NewRateLimiter(rps, burst int) *RateLimiter {
 	rl.endpointLimits["/health"] = struct{ capacity, refillRate int }{20, 2}                  // Very low for health
 	rl.endpointLimits["/metrics"] = struct{ capacity, refillRate int }{20, 2}                 // Very low for metrics
 
+	// Batch requests resolve many URLs each, so they get a smaller bucket of their own
+	rl.endpointLimits["/v1/resolve/batch"] = struct{ capacity, refillRate int }{max(burst/4, 1), max(rps/4, 1)}
+
 	return rl
 }
 
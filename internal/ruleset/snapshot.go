@@ -0,0 +1,191 @@
+// Package ruleset persists a snapshot of the loaded rule set and the matcher's pattern
+// analysis, so a restart can skip scanning, parsing and resolving rule files and
+// analysing their patterns while none of them changed. Patterns are still compiled.
+//
+// The file is a small binary header (magic, format version, payload length and CRC-32)
+// followed by a JSON payload. JSON is used rather than gob because gob drops pointers to
+// zero values, which would turn "priority: 0" into no priority at all.
+package ruleset
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/loader"
+)
+
+// FileName is the file in DATA_DIR the snapshot is written to
+const FileName = "ruleset.snapshot"
+
+// FormatVersion is incremented whenever the payload changes incompatibly
+const FormatVersion uint16 = 2
+
+var magic = [8]byte{'A', 'I', 'R', 'U', 'L', 'E', 'S', 0}
+
+// headerSize is the length of magic, version, payload length and checksum
+const headerSize = len(magic) + 2 + 4 + 4
+
+// ErrIncompatible is returned for files written by another format version
+var ErrIncompatible = errors.New("ruleset snapshot has an incompatible format version")
+
+// Fingerprint identifies the state of a source file when the snapshot was taken. A
+// file that did not exist is recorded with a size of -1.
+type Fingerprint struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"` // Unix nanoseconds
+}
+
+// IndexHint is the precomputed rule index bucket for rules of a type and prepared
+// pattern, so the index can be built without analysing the pattern again
+type IndexHint struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"`
+	Kind    int    `json:"kind"`
+	Value   string `json:"value,omitempty"`
+}
+
+// PrefilterHint is the precomputed regex prefilter analysis of a regex pattern: the
+// literals one of which occurs in every match, or none when it has no usable ones
+type PrefilterHint struct {
+	Pattern  string   `json:"pattern"`
+	Literals []string `json:"literals,omitempty"`
+}
+
+// Snapshot is the state of a full rule load
+type Snapshot struct {
+	CreatedAt    time.Time     `json:"created_at"`
+	ScanDuration time.Duration `json:"scan_duration"` // Duration of the full load the snapshot was taken from
+	Fingerprints []Fingerprint `json:"fingerprints"`
+
+	// Rules parsed from the files, before conflict resolution
+	Rules      []domain.Rule      `json:"rules"`
+	LoadErrors []loader.LoadError `json:"load_errors,omitempty"`
+	// Rules left after conflict resolution and disabling, in the order they were served
+	Active []domain.Rule `json:"active"`
+	// Index buckets of the matcher's prepared patterns
+	IndexHints []IndexHint `json:"index_hints,omitempty"`
+	// Regex prefilter literals of the matcher's regex patterns
+	PrefilterHints []PrefilterHint `json:"prefilter_hints,omitempty"`
+}
+
+// Write encodes the snapshot to path atomically
+func Write(path string, snapshot *Snapshot) error {
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode ruleset snapshot: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(headerSize + len(payload))
+	buf.Write(magic[:])
+	buf.Write(binary.BigEndian.AppendUint16(nil, FormatVersion))
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(payload))))
+	buf.Write(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(payload)))
+	buf.Write(payload)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create ruleset snapshot directory: %w", err)
+	}
+
+	// Write atomically using temp file
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write ruleset snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename ruleset snapshot: %w", err)
+	}
+	return nil
+}
+
+// Read decodes the snapshot at path. A missing file is reported as os.ErrNotExist.
+func Read(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < headerSize || !bytes.Equal(data[:len(magic)], magic[:]) {
+		return nil, errors.New("not a ruleset snapshot")
+	}
+
+	header := data[len(magic):headerSize]
+	if version := binary.BigEndian.Uint16(header); version != FormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrIncompatible, version)
+	}
+	payload := data[headerSize:]
+	if int(binary.BigEndian.Uint32(header[2:])) != len(payload) {
+		return nil, errors.New("ruleset snapshot is truncated")
+	}
+	if binary.BigEndian.Uint32(header[6:]) != crc32.ChecksumIEEE(payload) {
+		return nil, errors.New("ruleset snapshot checksum mismatch")
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(payload, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode ruleset snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// Fingerprints records the size and modification time of the files, sorted by path
+func Fingerprints(ctx context.Context, paths []string) ([]Fingerprint, error) {
+	fingerprints := make([]Fingerprint, 0, len(paths))
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fingerprint := Fingerprint{Path: path, Size: -1}
+		info, err := os.Stat(path)
+		switch {
+		case err == nil:
+			fingerprint.Size = info.Size()
+			fingerprint.ModTime = info.ModTime().UnixNano()
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	slices.SortFunc(fingerprints, func(a, b Fingerprint) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return fingerprints, nil
+}
+
+// Stale compares the snapshot's fingerprints with the current ones and describes the
+// first difference, or returns "" when every source file is unchanged
+func (s *Snapshot) Stale(current []Fingerprint) string {
+	recorded := make(map[string]Fingerprint, len(s.Fingerprints))
+	for _, fingerprint := range s.Fingerprints {
+		recorded[fingerprint.Path] = fingerprint
+	}
+
+	for _, fingerprint := range current {
+		previous, exists := recorded[fingerprint.Path]
+		switch {
+		case !exists:
+			return "file added: " + fingerprint.Path
+		case previous != fingerprint:
+			return "file changed: " + fingerprint.Path
+		}
+		delete(recorded, fingerprint.Path)
+	}
+	for _, fingerprint := range s.Fingerprints {
+		if _, removed := recorded[fingerprint.Path]; removed {
+			return "file removed: " + fingerprint.Path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package ruleset
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/loader"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", FileName)
+	priority := 0
+	snapshot := &Snapshot{
+		CreatedAt:    time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		ScanDuration: 1500 * time.Millisecond,
+		Fingerprints: []Fingerprint{{Path: "/rules/a.rule.yaml", Size: 42, ModTime: 7}},
+		Rules: []domain.Rule{
+			{ID: "a", Type: "exact", Pattern: "https://example.com", Priority: &priority, Source: domain.RuleSource{Type: domain.SourceLocal}},
+		},
+		LoadErrors: []loader.LoadError{{FilePath: "/rules/broken.rule.yaml", Error: "invalid YAML", Line: 3}},
+		Active: []domain.Rule{
+			{ID: "a", Type: "exact", Pattern: "https://example.com", Priority: &priority, Source: domain.RuleSource{Type: domain.SourceLocal}},
+		},
+		IndexHints: []IndexHint{{Type: "exact", Pattern: "https://example.com", Kind: 1, Value: "https://example.com"}},
+		PrefilterHints: []PrefilterHint{
+			{Pattern: `/(article|news)/\d+`, Literals: []string{"article", "news"}},
+			{Pattern: `[?&]p=\d+`},
+		},
+	}
+	require.NoError(t, Write(path, snapshot))
+
+	read, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, snapshot, read)
+	require.NotNil(t, read.Active[0].Priority)
+	assert.Equal(t, 0, *read.Active[0].Priority)
+
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSnapshot_ReadRejectsDamagedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+
+	_, err := Read(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, Write(path, &Snapshot{Active: []domain.Rule{{ID: "a"}}}))
+	valid, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		damage func(data []byte) []byte
+		err    string
+	}{
+		{"not a snapshot", func([]byte) []byte { return []byte("rules: []\n") }, "not a ruleset snapshot"},
+		{"truncated", func(data []byte) []byte { return data[:len(data)-3] }, "truncated"},
+		{"corrupted", func(data []byte) []byte { data[len(data)-2] ^= 0xff; return data }, "checksum mismatch"},
+		{"other version", func(data []byte) []byte {
+			binary.BigEndian.PutUint16(data[len(magic):], FormatVersion+1)
+			return data
+		}, ErrIncompatible.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			damaged := filepath.Join(dir, tt.name)
+			require.NoError(t, os.WriteFile(damaged, tt.damage(append([]byte(nil), valid...)), 0644))
+			_, err := Read(damaged)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestSnapshot_Stale(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.rule.yaml")
+	b := filepath.Join(dir, "b.rule.yaml")
+	missing := filepath.Join(dir, ".disabled.json")
+	require.NoError(t, os.WriteFile(a, []byte("rules: []\n"), 0644))
+	require.NoError(t, os.WriteFile(b, []byte("rules: []\n"), 0644))
+
+	ctx := context.Background()
+	fingerprints, err := Fingerprints(ctx, []string{b, missing, a})
+	require.NoError(t, err)
+	require.Len(t, fingerprints, 3)
+	assert.Equal(t, missing, fingerprints[0].Path)
+	assert.Equal(t, int64(-1), fingerprints[0].Size)
+	assert.Equal(t, a, fingerprints[1].Path)
+	snapshot := &Snapshot{Fingerprints: fingerprints}
+
+	current, err := Fingerprints(ctx, []string{a, b, missing})
+	require.NoError(t, err)
+	assert.Empty(t, snapshot.Stale(current))
+
+	require.NoError(t, os.WriteFile(a, []byte("rules:\n  - id: a\n"), 0644))
+	current, err = Fingerprints(ctx, []string{a, b, missing})
+	require.NoError(t, err)
+	assert.Equal(t, "file changed: "+a, snapshot.Stale(current))
+
+	current, err = Fingerprints(ctx, []string{b, missing})
+	require.NoError(t, err)
+	assert.Equal(t, "file removed: "+a, snapshot.Stale(current))
+
+	snapshot.Fingerprints, err = Fingerprints(ctx, []string{a, b, missing})
+	require.NoError(t, err)
+	c := filepath.Join(dir, "c.rule.yaml")
+	require.NoError(t, os.WriteFile(c, []byte("rules: []\n"), 0644))
+	current, err = Fingerprints(ctx, []string{a, b, c, missing})
+	require.NoError(t, err)
+	assert.Equal(t, "file added: "+c, snapshot.Stale(current))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = Fingerprints(cancelled, []string{a})
+	assert.ErrorIs(t, err, context.Canceled)
+}
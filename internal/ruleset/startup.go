@@ -0,0 +1,50 @@
+package ruleset
+
+import (
+	"math"
+	"time"
+)
+
+// Sources the rules can be loaded from at startup
+const (
+	SourceSnapshot = "snapshot"
+	SourceFullScan = "full_scan"
+)
+
+// StartupReport describes how the rules were loaded at startup
+type StartupReport struct {
+	Source      string
+	StaleReason string // Why no snapshot was used
+	Rules       int
+	// Time to load the rules into the store and the matcher
+	Duration time.Duration
+	// Duration of the last full scan, taken at this start or when the snapshot was written
+	ScanDuration      time.Duration
+	SnapshotCreatedAt time.Time
+}
+
+// Details returns the report for health details. A start from a snapshot is compared
+// with the full scan the snapshot was taken from.
+func (r StartupReport) Details() map[string]any {
+	details := map[string]any{
+		"source":       r.Source,
+		"rules":        r.Rules,
+		"load_ms":      milliseconds(r.Duration),
+		"full_scan_ms": milliseconds(r.ScanDuration),
+	}
+	if r.StaleReason != "" {
+		details["stale_reason"] = r.StaleReason
+	}
+	if !r.SnapshotCreatedAt.IsZero() {
+		details["snapshot_created_at"] = r.SnapshotCreatedAt
+	}
+	if r.Source == SourceSnapshot && r.Duration > 0 && r.ScanDuration > 0 {
+		details["speedup"] = math.Round(float64(r.ScanDuration)/float64(r.Duration)*10) / 10
+	}
+	return details
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
@@ -0,0 +1,39 @@
+package ruleset
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartupReport_Details(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	details := StartupReport{
+		Source:            SourceSnapshot,
+		Rules:             120,
+		Duration:          40 * time.Millisecond,
+		ScanDuration:      850 * time.Millisecond,
+		SnapshotCreatedAt: created,
+	}.Details()
+	assert.Equal(t, map[string]any{
+		"source":              SourceSnapshot,
+		"rules":               120,
+		"load_ms":             40.0,
+		"full_scan_ms":        850.0,
+		"snapshot_created_at": created,
+		"speedup":             21.3,
+	}, details)
+
+	details = StartupReport{
+		Source:       SourceFullScan,
+		StaleReason:  "file changed: rules/local/site.rule.yaml",
+		Rules:        120,
+		Duration:     900 * time.Microsecond,
+		ScanDuration: 900 * time.Microsecond,
+	}.Details()
+	assert.Equal(t, "file changed: rules/local/site.rule.yaml", details["stale_reason"])
+	assert.Equal(t, 0.9, details["load_ms"])
+	assert.NotContains(t, details, "speedup")
+	assert.NotContains(t, details, "snapshot_created_at")
+}
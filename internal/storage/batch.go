@@ -0,0 +1,345 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// batchChange is a checked operation of a batch
+type batchChange struct {
+	action domain.RevisionAction
+	rule   *domain.Rule // The rule after the change, or the deleted rule
+	stored *domain.Rule // The rule an update or delete replaces
+}
+
+// planBatch checks the operations against the current rules and prepares the rules they
+// write the way the single-rule methods do, including setting the timestamps and source
+// of the operations' rules. Nothing is written.
+func planBatch(current *ruleSet, operations []domain.RuleOperation, now time.Time) ([]batchChange, *domain.AppError) {
+	changes := make([]batchChange, 0, len(operations))
+	seen := make(map[string]int, len(operations))
+	for i, op := range operations {
+		id := op.RuleID()
+		if id == "" {
+			return nil, batchError(domain.ErrValidationFailed, "Rule ID is required", 422, i, id)
+		}
+		if first, duplicate := seen[id]; duplicate {
+			appErr := batchError(domain.ErrValidationFailed, "Rule appears more than once in the batch", 422, i, id)
+			appErr.Details.(map[string]any)["first_index"] = first
+			return nil, appErr
+		}
+		seen[id] = i
+
+		if op.Op != domain.RuleOpDelete {
+			if op.Rule == nil {
+				return nil, batchError(domain.ErrValidationFailed, "Operation has no rule", 422, i, id)
+			}
+			if op.Rule.ID == "" {
+				op.Rule.ID = id
+			} else if op.Rule.ID != id {
+				return nil, batchError(domain.ErrValidationFailed, "Operation ID does not match the rule ID", 422, i, id)
+			}
+		}
+
+		existing, exists := current.get(id)
+		switch op.Op {
+		case domain.RuleOpCreate:
+			if exists {
+				return nil, batchError(domain.ErrConflict, "Rule already exists", 409, i, id)
+			}
+			rule := op.Rule
+			if rule.CreatedAt.IsZero() {
+				rule.CreatedAt = now
+			}
+			if rule.UpdatedAt.IsZero() {
+				rule.UpdatedAt = now
+			}
+			if rule.Source.Type == "" {
+				rule.Source.Type = domain.SourceLocal
+			}
+			ruleCopy := *rule
+			ruleCopy.FilePath = "" // New rules are always local
+			changes = append(changes, batchChange{action: domain.RevisionCreated, rule: &ruleCopy})
+
+		case domain.RuleOpUpdate:
+			if !exists {
+				return nil, batchError(domain.ErrNotFound, "Rule not found", 404, i, id)
+			}
+			rule := op.Rule
+			rule.CreatedAt = existing.CreatedAt
+			rule.UpdatedAt = now
+			if rule.Source.Type == "" {
+				rule.Source = existing.Source
+			}
+			if rule.FilePath == "" {
+				rule.FilePath = existing.FilePath
+			}
+			ruleCopy := *rule
+			changes = append(changes, batchChange{action: domain.RevisionUpdated, rule: &ruleCopy, stored: existing})
+
+		case domain.RuleOpDelete:
+			if !exists {
+				return nil, batchError(domain.ErrNotFound, "Rule not found", 404, i, id)
+			}
+			changes = append(changes, batchChange{action: domain.RevisionDeleted, rule: existing, stored: existing})
+
+		default:
+			return nil, batchError(domain.ErrValidationFailed, "Unknown operation", 422, i, id)
+		}
+	}
+	return changes, nil
+}
+
+// batchError reports the operation of a batch that cannot be applied
+func batchError(code, message string, status, index int, id string) *domain.AppError {
+	return domain.NewAppError(code, message, status, map[string]any{"index": index, "id": id})
+}
+
+// batchList returns the rule list after the changes; created rules are appended
+func batchList(current *ruleSet, changes []batchChange) []*domain.Rule {
+	list := slices.Clone(current.list)
+	deleted := make(map[string]bool)
+	for _, change := range changes {
+		switch change.action {
+		case domain.RevisionCreated:
+			list = append(list, change.rule)
+		case domain.RevisionUpdated:
+			list[current.positions[change.rule.ID]] = change.rule
+		case domain.RevisionDeleted:
+			deleted[change.rule.ID] = true
+		}
+	}
+	if len(deleted) > 0 {
+		list = slices.DeleteFunc(list, func(rule *domain.Rule) bool { return deleted[rule.ID] })
+	}
+	return list
+}
+
+// operationsFor returns an operation of the type for each rule
+func operationsFor(op domain.RuleOperationType, rules []*domain.Rule) []domain.RuleOperation {
+	operations := make([]domain.RuleOperation, len(rules))
+	for i, rule := range rules {
+		operations[i] = domain.RuleOperation{Op: op, Rule: rule}
+	}
+	return operations
+}
+
+// deleteOperations returns a delete operation for each ID
+func deleteOperations(ids []string) []domain.RuleOperation {
+	operations := make([]domain.RuleOperation, len(ids))
+	for i, id := range ids {
+		operations[i] = domain.RuleOperation{Op: domain.RuleOpDelete, ID: id}
+	}
+	return operations
+}
+
+// stagedFile is the content a file had before a batch touched it
+type stagedFile struct {
+	path    string
+	data    []byte
+	existed bool
+}
+
+// fileStage records the original content of every file a batch writes or deletes, so
+// the files can be restored when a later write fails
+type fileStage struct {
+	files []stagedFile
+	saved map[string]bool
+}
+
+// run backs up the file the first time it is touched and then changes it
+func (st *fileStage) run(path string, change func() error) error {
+	if !st.saved[path] {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+		if st.saved == nil {
+			st.saved = make(map[string]bool)
+		}
+		st.saved[path] = true
+		st.files = append(st.files, stagedFile{path: path, data: data, existed: err == nil})
+	}
+	return change()
+}
+
+// rollback restores the files in reverse order
+func (st *fileStage) rollback() error {
+	var errs []error
+	for i := len(st.files) - 1; i >= 0; i-- {
+		file := st.files[i]
+		if !file.existed {
+			if err := os.Remove(file.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		tmpPath := file.path + ".tmp"
+		if err := os.WriteFile(tmpPath, file.data, 0644); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Rename(tmpPath, file.path); err != nil {
+			_ = os.Remove(tmpPath)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rulePath returns the file a rule is written to
+func (s *Store) rulePath(rule *domain.Rule) string {
+	if rule.FilePath != "" {
+		return rule.FilePath
+	}
+	return filepath.Join(s.config.LocalDir, rule.ID+".rule.yaml")
+}
+
+// ApplyRules checks every operation first, then writes the rule files. If a file
+// cannot be written the files already written are restored and nothing changes;
+// otherwise the new rule set is published once.
+func (s *Store) ApplyRules(ctx context.Context, operations []domain.RuleOperation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.snapshot.Load()
+	changes, appErr := planBatch(current, operations, time.Now())
+	if appErr != nil {
+		return appErr.WithContext(ctx, "apply_rules")
+	}
+
+	var stage fileStage
+	for i, change := range changes {
+		var err error
+		switch change.action {
+		case domain.RevisionCreated:
+			change.rule.FilePath = s.rulePath(change.rule)
+			err = stage.run(change.rule.FilePath, func() error {
+				return s.ruleWriter.WriteRuleToPath(change.rule, change.rule.FilePath)
+			})
+		case domain.RevisionUpdated:
+			err = stage.run(s.rulePath(change.rule), func() error {
+				return s.ruleWriter.UpdateRule(change.rule)
+			})
+		case domain.RevisionDeleted:
+			path := s.rulePath(change.rule)
+			err = stage.run(path, func() error {
+				return s.ruleWriter.DeleteRuleFile(path)
+			})
+		}
+		if err != nil {
+			if rollbackErr := stage.rollback(); rollbackErr != nil {
+				log.Error().Err(rollbackErr).Str("rule_id", change.rule.ID).
+					Msg("Failed to restore rule files after batch failure - state may be inconsistent")
+			}
+			return domain.NewAppErrorWithCause(
+				domain.ErrInternal,
+				"Failed to write rule files",
+				500,
+				err,
+				map[string]any{"index": i, "id": change.rule.ID},
+			).WithContext(ctx, "apply_rules")
+		}
+	}
+
+	s.publish(batchList(current, changes))
+	for _, change := range changes {
+		s.recordRevision(ctx, change.action, change.rule)
+	}
+	return nil
+}
+
+// CreateRules creates the rules as one batch
+func (s *Store) CreateRules(ctx context.Context, rules []*domain.Rule) error {
+	return s.ApplyRules(ctx, operationsFor(domain.RuleOpCreate, rules))
+}
+
+// UpdateRules updates the rules as one batch
+func (s *Store) UpdateRules(ctx context.Context, rules []*domain.Rule) error {
+	return s.ApplyRules(ctx, operationsFor(domain.RuleOpUpdate, rules))
+}
+
+// DeleteRules deletes the rules as one batch
+func (s *Store) DeleteRules(ctx context.Context, ids []string) error {
+	return s.ApplyRules(ctx, deleteOperations(ids))
+}
+
+// ApplyRules checks every operation first and writes them in one transaction, then
+// publishes the new rule set once
+func (s *BoltStore) ApplyRules(ctx context.Context, operations []domain.RuleOperation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.snapshot.Load()
+	changes, appErr := planBatch(current, operations, time.Now())
+	if appErr != nil {
+		return appErr.WithContext(ctx, "apply_rules")
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, change := range changes {
+			var err error
+			if change.action == domain.RevisionDeleted {
+				err = deleteRule(tx, change.rule)
+			} else {
+				change.rule.FilePath = ""
+				err = putRule(tx, change.stored, change.rule)
+			}
+			if errors.Is(err, errDisabledRuleExists) {
+				return domain.NewAppError(
+					domain.ErrConflict,
+					"Rule already exists",
+					409,
+					map[string]any{"id": change.rule.ID, "disabled": true},
+				)
+			}
+			if err != nil {
+				return fmt.Errorf("rule %s: %w", change.rule.ID, err)
+			}
+		}
+		return nil
+	})
+	if errors.As(err, &appErr) {
+		return appErr.WithContext(ctx, "apply_rules")
+	}
+	if err != nil {
+		return domain.NewAppErrorWithCause(
+			domain.ErrInternal,
+			"Failed to write rules to database",
+			500,
+			err,
+			nil,
+		).WithContext(ctx, "apply_rules")
+	}
+
+	s.publish(batchList(current, changes))
+	for _, change := range changes {
+		s.history.record(ctx, change.action, change.rule)
+	}
+	return nil
+}
+
+// CreateRules creates the rules as one batch
+func (s *BoltStore) CreateRules(ctx context.Context, rules []*domain.Rule) error {
+	return s.ApplyRules(ctx, operationsFor(domain.RuleOpCreate, rules))
+}
+
+// UpdateRules updates the rules as one batch
+func (s *BoltStore) UpdateRules(ctx context.Context, rules []*domain.Rule) error {
+	return s.ApplyRules(ctx, operationsFor(domain.RuleOpUpdate, rules))
+}
+
+// DeleteRules deletes the rules as one batch
+func (s *BoltStore) DeleteRules(ctx context.Context, ids []string) error {
+	return s.ApplyRules(ctx, deleteOperations(ids))
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ApplyRules(t *testing.T) {
+	tempDir := t.TempDir()
+	store := NewStore(tempDir)
+	ctx := context.Background()
+	require.NoError(t, store.Load(ctx))
+
+	require.NoError(t, store.CreateRules(ctx, []*domain.Rule{
+		{ID: "a", Type: "exact", Pattern: "https://a.example.com", CSS: "a"},
+		{ID: "b", Type: "exact", Pattern: "https://b.example.com"},
+	}))
+	generation := store.Generation()
+
+	var appErr *domain.AppError
+	for name, tc := range map[string]struct {
+		operations []domain.RuleOperation
+		status     int
+	}{
+		"duplicate": {[]domain.RuleOperation{
+			{Op: domain.RuleOpDelete, ID: "a"},
+			{Op: domain.RuleOpUpdate, Rule: &domain.Rule{ID: "a", Type: "exact", Pattern: "x"}},
+		}, 422},
+		"existing":       {[]domain.RuleOperation{{Op: domain.RuleOpCreate, Rule: &domain.Rule{ID: "b", Type: "exact", Pattern: "x"}}}, 409},
+		"missing":        {[]domain.RuleOperation{{Op: domain.RuleOpCreate, Rule: &domain.Rule{ID: "c", Type: "exact", Pattern: "x"}}, {Op: domain.RuleOpDelete, ID: "z"}}, 404},
+		"unknown":        {[]domain.RuleOperation{{Op: "upsert", Rule: &domain.Rule{ID: "c"}}}, 422},
+		"mismatched ids": {[]domain.RuleOperation{{Op: domain.RuleOpUpdate, ID: "a", Rule: &domain.Rule{ID: "b"}}}, 422},
+	} {
+		require.ErrorAs(t, store.ApplyRules(ctx, tc.operations), &appErr, name)
+		assert.Equal(t, tc.status, appErr.StatusCode, name)
+	}
+	assert.Equal(t, generation, store.Generation(), "a rejected batch changes nothing")
+	assert.NoFileExists(t, filepath.Join(store.config.LocalDir, "c.rule.yaml"))
+
+	ctx = domain.WithChangeInfo(ctx, domain.ChangeInfo{Actor: "importer"})
+	require.NoError(t, store.ApplyRules(ctx, []domain.RuleOperation{
+		{Op: domain.RuleOpCreate, Rule: &domain.Rule{ID: "c", Type: "exact", Pattern: "https://c.example.com"}},
+		{Op: domain.RuleOpUpdate, ID: "a", Rule: &domain.Rule{Type: "exact", Pattern: "https://a.example.com", CSS: "b"}},
+		{Op: domain.RuleOpDelete, ID: "b"},
+	}))
+	assert.Equal(t, generation+1, store.Generation(), "a batch is published once")
+
+	restarted := NewStore(tempDir)
+	require.NoError(t, restarted.Load(ctx))
+	rules, err := restarted.GetAllRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	a, err := restarted.GetRuleByID(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "b", a.CSS)
+	_, err = restarted.GetRuleByID(ctx, "b")
+	assert.Error(t, err)
+
+	revisions, err := restarted.GetRuleHistory(ctx, "b")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, domain.RevisionDeleted, revisions[1].Action)
+	assert.Equal(t, "importer", revisions[1].Actor)
+}
+
+func TestStore_ApplyRulesRollback(t *testing.T) {
+	tempDir := t.TempDir()
+	store := NewStore(tempDir)
+	ctx := context.Background()
+	require.NoError(t, store.Load(ctx))
+	require.NoError(t, store.CreateRules(ctx, []*domain.Rule{
+		{ID: "a", Type: "exact", Pattern: "https://a.example.com", CSS: "a"},
+		{ID: "b", Type: "exact", Pattern: "https://b.example.com"},
+	}))
+
+	pathA := filepath.Join(store.config.LocalDir, "a.rule.yaml")
+	pathB := filepath.Join(store.config.LocalDir, "b.rule.yaml")
+	original, err := os.ReadFile(pathA)
+	require.NoError(t, err)
+	generation := store.Generation()
+
+	// A directory in place of the new rule's file makes the last write fail
+	blocked := filepath.Join(store.config.LocalDir, "c.rule.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Join(blocked, "keep"), 0755))
+
+	err = store.ApplyRules(ctx, []domain.RuleOperation{
+		{Op: domain.RuleOpUpdate, Rule: &domain.Rule{ID: "a", Type: "exact", Pattern: "https://a.example.com", CSS: "changed"}},
+		{Op: domain.RuleOpDelete, ID: "b"},
+		{Op: domain.RuleOpCreate, Rule: &domain.Rule{ID: "c", Type: "exact", Pattern: "https://c.example.com"}},
+	})
+	var appErr *domain.AppError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 500, appErr.StatusCode)
+
+	// The written files are restored and nothing was published or recorded
+	restored, err := os.ReadFile(pathA)
+	require.NoError(t, err)
+	assert.Equal(t, original, restored)
+	assert.FileExists(t, pathB)
+	assert.Equal(t, generation, store.Generation())
+	revisions, err := store.GetRuleHistory(ctx, "a")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1)
+
+	restarted := NewStore(tempDir)
+	require.NoError(t, restarted.Load(ctx))
+	rules, err := restarted.GetAllRules(ctx)
+	require.NoError(t, err)
+	assert.Len(t, rules, 2)
+}
+
+func TestBoltStore_ApplyRules(t *testing.T) {
+	tempDir := t.TempDir()
+	store := openTestBoltStore(t, tempDir)
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateRules(ctx, []*domain.Rule{
+		{ID: "b", Type: "exact", Pattern: "https://b.example.com", Tags: []string{"ads"}},
+		{ID: "a", Type: "exact", Pattern: "https://a.example.com", Tags: []string{"ads"}},
+	}))
+	generation := store.Generation()
+
+	var appErr *domain.AppError
+	require.ErrorAs(t, store.ApplyRules(ctx, []domain.RuleOperation{
+		{Op: domain.RuleOpCreate, Rule: &domain.Rule{ID: "c", Type: "exact", Pattern: "x"}},
+		{Op: domain.RuleOpUpdate, Rule: &domain.Rule{ID: "z", Type: "exact", Pattern: "x"}},
+	}), &appErr)
+	assert.Equal(t, 404, appErr.StatusCode)
+	assert.Equal(t, generation, store.Generation())
+
+	require.NoError(t, store.ApplyRules(ctx, []domain.RuleOperation{
+		{Op: domain.RuleOpCreate, Rule: &domain.Rule{ID: "c", Type: "wildcard", Pattern: "*c.example.com*", Tags: []string{"ads"}}},
+		{Op: domain.RuleOpUpdate, Rule: &domain.Rule{ID: "a", Type: "exact", Pattern: "https://a.example.com"}},
+	}))
+	require.NoError(t, store.DeleteRules(ctx, []string{"b"}))
+	assert.Equal(t, generation+2, store.Generation())
+
+	require.NoError(t, store.Close())
+	reopened := openTestBoltStore(t, tempDir)
+	rules, err := reopened.GetAllRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "a", rules[0].ID)
+	assert.Equal(t, "c", rules[1].ID)
+
+	tagged, err := reopened.FindRules(ctx, domain.RuleFilter{Tag: "ads"})
+	require.NoError(t, err)
+	require.Len(t, tagged, 1)
+	assert.Equal(t, "c", tagged[0].ID)
+}
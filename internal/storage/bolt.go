@@ -0,0 +1,763 @@
+package storage
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/freewebtopdf/asset-injector/internal/conflict"
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// DatabaseFileName is the file in DATA_DIR the database backend keeps its rules in
+const DatabaseFileName = "rules.db"
+
+// databaseSchemaVersion is incremented whenever the bucket layout changes incompatibly
+const databaseSchemaVersion = 1
+
+var (
+	bucketRules   = []byte("rules")   // Rule ID -> insertion sequence and rule JSON
+	bucketOrder   = []byte("order")   // Insertion sequence -> rule ID
+	bucketIndexes = []byte("indexes") // One bucket per index of "value\x00id" keys
+	bucketMeta    = []byte("meta")
+	keySchema     = []byte("schema_version")
+)
+
+// Secondary indexes kept next to the rules
+const (
+	indexSource = "source"
+	indexPack   = "pack"
+	indexTag    = "tag"
+	indexType   = "type"
+)
+
+var indexNames = []string{indexSource, indexPack, indexTag, indexType}
+
+// errDisabledRuleExists is returned when a new rule has the ID of a stored, disabled rule
+var errDisabledRuleExists = errors.New("a disabled rule with this ID exists")
+
+// BoltStore implements the RuleRepository interface on an embedded bbolt database.
+// Every change is a single transaction that updates the rule and its secondary
+// indexes; the in-memory rule set is only published once it committed, so a crash
+// never leaves memory ahead of disk. Rules keep the order they were created in, like
+// in the file store, since it breaks ties between equally specific rules. Rules listed
+// in DATA_DIR/.disabled.json stay in the database but are not served.
+type BoltStore struct {
+	mu       sync.Mutex // Serializes writers
+	snapshot atomic.Pointer[ruleSet]
+	db       *bolt.DB
+	path     string
+	history  *ruleHistory
+	disabled *conflict.DisabledRulesManager
+	hidden   atomic.Int64 // Stored rules that are not served because they are disabled
+}
+
+// OpenBoltStore opens or creates the rule database in dataDir. The file is locked
+// while it is open, so a second process fails instead of sharing it.
+func OpenBoltStore(dataDir string) (*BoltStore, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	path := filepath.Join(dataDir, DatabaseFileName)
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rule database %s: %w", path, err)
+	}
+
+	if err := db.Update(initBuckets); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	s := &BoltStore{
+		db:       db,
+		path:     path,
+		history:  newRuleHistory(filepath.Join(dataDir, HistoryDirName)),
+		disabled: conflict.NewDisabledRulesManager(dataDir),
+	}
+	s.snapshot.Store(newRuleSet(nil, 0))
+	return s, nil
+}
+
+// initBuckets creates the buckets of a new database and checks the schema version of
+// an existing one
+func initBuckets(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+	if err != nil {
+		return err
+	}
+	if version := meta.Get(keySchema); version != nil {
+		if string(version) != strconv.Itoa(databaseSchemaVersion) {
+			return fmt.Errorf("rule database has an incompatible schema version: %s", version)
+		}
+	} else if err := meta.Put(keySchema, []byte(strconv.Itoa(databaseSchemaVersion))); err != nil {
+		return err
+	}
+
+	if _, err := tx.CreateBucketIfNotExists(bucketRules); err != nil {
+		return err
+	}
+	if _, err := tx.CreateBucketIfNotExists(bucketOrder); err != nil {
+		return err
+	}
+	indexes, err := tx.CreateBucketIfNotExists(bucketIndexes)
+	if err != nil {
+		return err
+	}
+	for _, name := range indexNames {
+		if _, err := indexes.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// Path returns the database file
+func (s *BoltStore) Path() string {
+	return s.path
+}
+
+// Generation returns the generation of the current rule set, incremented on every change
+func (s *BoltStore) Generation() uint64 {
+	return s.snapshot.Load().generation
+}
+
+// publish swaps in a rule set built from the current one. Must be called with s.mu held.
+func (s *BoltStore) publish(list []*domain.Rule) {
+	s.snapshot.Store(newRuleSet(list, s.snapshot.Load().generation+1))
+}
+
+// publishStored publishes the stored rules that are not disabled. Must be called with
+// s.mu held.
+func (s *BoltStore) publishStored(stored []*domain.Rule) {
+	list := slices.DeleteFunc(slices.Clone(stored), func(rule *domain.Rule) bool {
+		return s.disabled.IsDisabled(rule.ID)
+	})
+	s.hidden.Store(int64(len(stored) - len(list)))
+	s.publish(list)
+}
+
+// Load reads every rule from the database in insertion order and serves those that
+// are not disabled
+func (s *BoltStore) Load(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return domain.NewAppErrorWithCause(
+			domain.ErrTimeout,
+			"Load cancelled",
+			408,
+			err,
+			map[string]any{"operation": "load"},
+		)
+	}
+
+	if err := s.disabled.Load(); err != nil {
+		return domain.NewAppErrorWithCause(
+			domain.ErrInternal,
+			"Failed to load disabled rules",
+			500,
+			err,
+			map[string]any{"path": s.disabled.FilePath()},
+		).WithContext(ctx, "load")
+	}
+
+	stored, err := s.storedRules()
+	if err != nil {
+		return domain.NewAppErrorWithCause(
+			domain.ErrInternal,
+			"Failed to load rules from database",
+			500,
+			err,
+			map[string]any{"database": s.path},
+		).WithContext(ctx, "load")
+	}
+
+	s.publishStored(stored)
+	return nil
+}
+
+// storedRules reads every rule from the database in insertion order, including
+// disabled ones
+func (s *BoltStore) storedRules() ([]*domain.Rule, error) {
+	var list []*domain.Rule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		rules := tx.Bucket(bucketRules)
+		return tx.Bucket(bucketOrder).ForEach(func(_, id []byte) error {
+			data := rules.Get(id)
+			if data == nil {
+				return fmt.Errorf("order refers to missing rule %s", id)
+			}
+			_, rule, err := decodeRule(id, data)
+			if err != nil {
+				return err
+			}
+			list = append(list, rule)
+			return nil
+		})
+	})
+	return list, err
+}
+
+// GetAllRules returns all rules in the repository
+func (s *BoltStore) GetAllRules(ctx context.Context) ([]domain.Rule, error) {
+	list := s.snapshot.Load().list
+
+	result := make([]domain.Rule, len(list))
+	for i, rule := range list {
+		result[i] = *rule
+	}
+
+	return result, nil
+}
+
+// GetRuleByID retrieves a rule by its ID
+func (s *BoltStore) GetRuleByID(ctx context.Context, id string) (*domain.Rule, error) {
+	rule, exists := s.snapshot.Load().get(id)
+	if !exists {
+		return nil, domain.NewAppError(
+			domain.ErrNotFound,
+			"Rule not found",
+			404,
+			map[string]any{"id": id},
+		)
+	}
+
+	ruleCopy := *rule
+	return &ruleCopy, nil
+}
+
+// FindRules returns the rules selected by the filter in insertion order, using the
+// secondary indexes
+func (s *BoltStore) FindRules(ctx context.Context, filter domain.RuleFilter) ([]domain.Rule, error) {
+	if filter.IsEmpty() {
+		return s.GetAllRules(ctx)
+	}
+
+	var result []domain.Rule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		indexes := tx.Bucket(bucketIndexes)
+		var ids []string
+		first := true
+		for _, lookup := range []struct{ index, value string }{
+			{indexSource, string(filter.Source)},
+			{indexPack, filter.Pack},
+			{indexTag, filter.Tag},
+			{indexType, filter.Type},
+		} {
+			if lookup.value == "" {
+				continue
+			}
+			matched := indexLookup(indexes.Bucket([]byte(lookup.index)), lookup.value)
+			if first {
+				ids, first = matched, false
+				continue
+			}
+			ids = slices.DeleteFunc(ids, func(id string) bool {
+				_, found := slices.BinarySearch(matched, id)
+				return !found
+			})
+		}
+
+		rules := tx.Bucket(bucketRules)
+		sequences := make(map[string]uint64, len(ids))
+		result = make([]domain.Rule, 0, len(ids))
+		for _, id := range ids {
+			if s.disabled.IsDisabled(id) {
+				continue
+			}
+			data := rules.Get([]byte(id))
+			if data == nil {
+				return fmt.Errorf("index refers to missing rule %s", id)
+			}
+			sequence, rule, err := decodeRule([]byte(id), data)
+			if err != nil {
+				return err
+			}
+			sequences[id] = sequence
+			result = append(result, *rule)
+		}
+		slices.SortFunc(result, func(a, b domain.Rule) int {
+			return cmp.Compare(sequences[a.ID], sequences[b.ID])
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, domain.NewAppErrorWithCause(
+			domain.ErrInternal,
+			"Failed to query rule database",
+			500,
+			err,
+			nil,
+		).WithContext(ctx, "find_rules")
+	}
+	return result, nil
+}
+
+// CreateRule creates a new rule in the repository
+func (s *BoltStore) CreateRule(ctx context.Context, rule *domain.Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.snapshot.Load()
+	if _, exists := current.get(rule.ID); exists {
+		return domain.NewAppError(
+			domain.ErrConflict,
+			"Rule already exists",
+			409,
+			map[string]any{"id": rule.ID},
+		)
+	}
+
+	now := time.Now()
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = now
+	}
+	if rule.UpdatedAt.IsZero() {
+		rule.UpdatedAt = now
+	}
+
+	if rule.Source.Type == "" {
+		rule.Source.Type = domain.SourceLocal
+	}
+
+	ruleCopy := *rule
+	ruleCopy.FilePath = ""
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return putRule(tx, nil, &ruleCopy)
+	})
+	if errors.Is(err, errDisabledRuleExists) {
+		return domain.NewAppError(
+			domain.ErrConflict,
+			"Rule already exists",
+			409,
+			map[string]any{"id": rule.ID, "disabled": true},
+		)
+	}
+	if err != nil {
+		return domain.NewAppError(
+			domain.ErrInternal,
+			"Failed to write rule to database",
+			500,
+			map[string]any{"error": err.Error(), "rule_id": rule.ID},
+		)
+	}
+
+	s.publish(append(slices.Clone(current.list), &ruleCopy))
+	s.history.record(ctx, domain.RevisionCreated, &ruleCopy)
+	return nil
+}
+
+// UpdateRule updates an existing rule in the repository
+func (s *BoltStore) UpdateRule(ctx context.Context, rule *domain.Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.snapshot.Load()
+	existingRule, exists := current.get(rule.ID)
+	if !exists {
+		return domain.NewAppError(
+			domain.ErrNotFound,
+			"Rule not found",
+			404,
+			map[string]any{"id": rule.ID},
+		)
+	}
+
+	rule.CreatedAt = existingRule.CreatedAt
+	rule.UpdatedAt = time.Now()
+
+	if rule.Source.Type == "" {
+		rule.Source = existingRule.Source
+	}
+
+	ruleCopy := *rule
+	ruleCopy.FilePath = ""
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return putRule(tx, existingRule, &ruleCopy)
+	})
+	if err != nil {
+		return domain.NewAppError(
+			domain.ErrInternal,
+			"Failed to update rule in database",
+			500,
+			map[string]any{"error": err.Error(), "rule_id": rule.ID},
+		)
+	}
+
+	list := slices.Clone(current.list)
+	list[current.positions[rule.ID]] = &ruleCopy
+	s.publish(list)
+	s.history.record(ctx, domain.RevisionUpdated, &ruleCopy)
+
+	return nil
+}
+
+// DeleteRule removes a rule from the repository
+func (s *BoltStore) DeleteRule(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.snapshot.Load()
+	rule, exists := current.get(id)
+	if !exists {
+		return domain.NewAppError(
+			domain.ErrNotFound,
+			"Rule not found",
+			404,
+			map[string]any{"id": id},
+		)
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return deleteRule(tx, rule)
+	})
+	if err != nil {
+		return domain.NewAppError(
+			domain.ErrInternal,
+			"Failed to delete rule from database",
+			500,
+			map[string]any{"error": err.Error(), "rule_id": rule.ID},
+		)
+	}
+
+	i := current.positions[id]
+	s.publish(slices.Delete(slices.Clone(current.list), i, i+1))
+	s.history.record(ctx, domain.RevisionDeleted, rule)
+
+	return nil
+}
+
+// importRules writes rules into an empty database in one transaction, keeping their
+// order, and adds the disabled entries to DATA_DIR/.disabled.json. No revisions are
+// recorded; the rules keep the history they have.
+func (s *BoltStore) importRules(ctx context.Context, rules []domain.Rule, disabled []conflict.DisabledRuleEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mergeDisabled(disabled); err != nil {
+		return domain.NewAppErrorWithCause(
+			domain.ErrInternal,
+			"Failed to import disabled rules",
+			500,
+			err,
+			map[string]any{"path": s.disabled.FilePath()},
+		).WithContext(ctx, "import_rules")
+	}
+
+	list := make([]*domain.Rule, len(rules))
+	for i := range rules {
+		ruleCopy := rules[i]
+		ruleCopy.FilePath = ""
+		list[i] = &ruleCopy
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketRules).Stats().KeyN > 0 {
+			return domain.NewAppError(
+				domain.ErrConflict,
+				"Rule database is not empty",
+				409,
+				map[string]any{"database": s.path},
+			)
+		}
+		seen := make(map[string]bool, len(list))
+		for _, rule := range list {
+			if seen[rule.ID] {
+				return domain.NewAppError(
+					domain.ErrConflict,
+					"Duplicate rule ID",
+					409,
+					map[string]any{"id": rule.ID},
+				)
+			}
+			seen[rule.ID] = true
+			if err := putRule(tx, nil, rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		var appErr *domain.AppError
+		if errors.As(err, &appErr) {
+			return appErr.WithContext(ctx, "import_rules")
+		}
+		return domain.NewAppErrorWithCause(
+			domain.ErrInternal,
+			"Failed to import rules into database",
+			500,
+			err,
+			map[string]any{"database": s.path},
+		).WithContext(ctx, "import_rules")
+	}
+
+	s.publishStored(list)
+	return nil
+}
+
+// mergeDisabled adds entries to the disabled rules, keeping those already listed
+func (s *BoltStore) mergeDisabled(entries []conflict.DisabledRuleEntry) error {
+	if err := s.disabled.Load(); err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return s.disabled.MergeEntries(entries)
+}
+
+// GetRuleHistory returns the revisions of a rule, oldest first. Deleted rules keep
+// their history.
+func (s *BoltStore) GetRuleHistory(ctx context.Context, id string) ([]domain.RuleRevision, error) {
+	return s.history.revisions(ctx, id)
+}
+
+// GetRuleRevision returns a single revision of a rule
+func (s *BoltStore) GetRuleRevision(ctx context.Context, id string, revision int) (*domain.RuleRevision, error) {
+	return s.history.revision(ctx, id, revision)
+}
+
+// HealthCheck performs a health check on the database
+func (s *BoltStore) HealthCheck(ctx context.Context) domain.HealthStatus {
+	snapshot := s.snapshot.Load()
+
+	status := "healthy"
+	message := "Storage is operating normally"
+	details := map[string]any{
+		"backend":    "bolt",
+		"rule_count": len(snapshot.list),
+		"generation": snapshot.generation,
+		"database":   s.path,
+	}
+
+	hidden := int(s.hidden.Load())
+	if hidden > 0 {
+		details["disabled_rules"] = hidden
+	}
+
+	var stored int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stored = tx.Bucket(bucketRules).Stats().KeyN
+		details["size_bytes"] = tx.Size()
+		return nil
+	})
+	switch {
+	case err != nil:
+		status = "unhealthy"
+		message = "Rule database is not accessible"
+		details["error"] = err.Error()
+	case stored != len(snapshot.list)+hidden:
+		status = "unhealthy"
+		message = "Database and loaded rules are out of sync"
+		details["stored_rules"] = stored
+	}
+
+	if failures := s.history.failures(); failures > 0 && status == "healthy" {
+		status = "degraded"
+		message = "Rule revisions could not be recorded"
+		details["history_write_errors"] = failures
+	}
+
+	return domain.HealthStatus{
+		Status:    status,
+		Message:   message,
+		Details:   details,
+		Timestamp: time.Now(),
+	}
+}
+
+// GetStats returns storage statistics
+func (s *BoltStore) GetStats(ctx context.Context) map[string]any {
+	snapshot := s.snapshot.Load()
+
+	stats := map[string]any{
+		"backend":    "bolt",
+		"rule_count": len(snapshot.list),
+		"generation": snapshot.generation,
+		"database":   s.path,
+	}
+
+	typeCount := make(map[string]int)
+	sourceCount := make(map[string]int)
+	for _, rule := range snapshot.list {
+		typeCount[rule.Type]++
+		sourceCount[string(rule.Source.Type)]++
+	}
+	stats["rule_types"] = typeCount
+	stats["rule_sources"] = sourceCount
+
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		stats["size_bytes"] = tx.Size()
+		indexEntries := make(map[string]int, len(indexNames))
+		indexes := tx.Bucket(bucketIndexes)
+		for _, name := range indexNames {
+			indexEntries[name] = indexes.Bucket([]byte(name)).Stats().KeyN
+		}
+		stats["index_entries"] = indexEntries
+		return nil
+	})
+
+	return stats
+}
+
+// putRule writes a rule and its index entries, replacing those of the previous version.
+// A new rule is appended to the insertion order; an updated one keeps its place.
+func putRule(tx *bolt.Tx, previous, rule *domain.Rule) error {
+	rules := tx.Bucket(bucketRules)
+	var sequence uint64
+	if previous != nil {
+		if err := updateIndexes(tx, previous, false); err != nil {
+			return err
+		}
+		var err error
+		if sequence, err = storedSequence(rules, previous.ID); err != nil {
+			return err
+		}
+	} else {
+		if rules.Get([]byte(rule.ID)) != nil {
+			return errDisabledRuleExists
+		}
+		order := tx.Bucket(bucketOrder)
+		var err error
+		if sequence, err = order.NextSequence(); err != nil {
+			return err
+		}
+		if err := order.Put(sequenceKey(sequence), []byte(rule.ID)); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("failed to encode rule: %w", err)
+	}
+	if err := rules.Put([]byte(rule.ID), append(sequenceKey(sequence), data...)); err != nil {
+		return err
+	}
+	return updateIndexes(tx, rule, true)
+}
+
+// deleteRule removes a rule, its place in the insertion order and its index entries
+func deleteRule(tx *bolt.Tx, rule *domain.Rule) error {
+	if err := updateIndexes(tx, rule, false); err != nil {
+		return err
+	}
+	rules := tx.Bucket(bucketRules)
+	sequence, err := storedSequence(rules, rule.ID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketOrder).Delete(sequenceKey(sequence)); err != nil {
+		return err
+	}
+	return rules.Delete([]byte(rule.ID))
+}
+
+// storedSequence returns the insertion sequence of a stored rule
+func storedSequence(rules *bolt.Bucket, id string) (uint64, error) {
+	data := rules.Get([]byte(id))
+	if len(data) < sequenceSize {
+		return 0, fmt.Errorf("rule %s is missing from the database", id)
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// sequenceSize is the length of an encoded insertion sequence
+const sequenceSize = 8
+
+// sequenceKey encodes an insertion sequence so keys sort in insertion order
+func sequenceKey(sequence uint64) []byte {
+	return binary.BigEndian.AppendUint64(make([]byte, 0, sequenceSize), sequence)
+}
+
+// updateIndexes adds or removes the index entries of a rule
+func updateIndexes(tx *bolt.Tx, rule *domain.Rule, add bool) error {
+	indexes := tx.Bucket(bucketIndexes)
+	for name, values := range indexValues(rule) {
+		bucket := indexes.Bucket([]byte(name))
+		for _, value := range values {
+			key := indexEntry(value, rule.ID)
+			var err error
+			if add {
+				err = bucket.Put(key, nil)
+			} else {
+				err = bucket.Delete(key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexValues returns the values a rule is indexed under, by index name
+func indexValues(rule *domain.Rule) map[string][]string {
+	values := map[string][]string{
+		indexSource: {string(rule.Source.Type)},
+		indexType:   {rule.Type},
+	}
+	if rule.Source.PackName != "" {
+		values[indexPack] = []string{rule.Source.PackName}
+	}
+	var tags []string
+	for _, tag := range rule.Tags {
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	values[indexTag] = tags
+	return values
+}
+
+// indexEntry returns the key of a rule in an index; the separator keeps a value from
+// matching the prefix of a longer one
+func indexEntry(value, id string) []byte {
+	return []byte(value + "\x00" + id)
+}
+
+// indexLookup returns the IDs of the rules indexed under the value, in ID order
+func indexLookup(bucket *bolt.Bucket, value string) []string {
+	prefix := []byte(value + "\x00")
+	var ids []string
+	cursor := bucket.Cursor()
+	for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+		ids = append(ids, string(key[len(prefix):]))
+	}
+	return ids
+}
+
+// decodeRule decodes a stored rule and its insertion sequence
+func decodeRule(id, data []byte) (uint64, *domain.Rule, error) {
+	if len(data) < sequenceSize {
+		return 0, nil, fmt.Errorf("failed to decode rule %s: record too short", id)
+	}
+	var rule domain.Rule
+	if err := json.Unmarshal(data[sequenceSize:], &rule); err != nil {
+		return 0, nil, fmt.Errorf("failed to decode rule %s: %w", id, err)
+	}
+	return binary.BigEndian.Uint64(data), &rule, nil
+}
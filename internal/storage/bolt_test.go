@@ -0,0 +1,343 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/freewebtopdf/asset-injector/internal/cache"
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/matcher"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestBoltStore(t *testing.T, dataDir string) *BoltStore {
+	t.Helper()
+	store, err := OpenBoltStore(dataDir)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	require.NoError(t, store.Load(context.Background()))
+	return store
+}
+
+func TestBoltStore_BasicOperations(t *testing.T) {
+	tempDir := t.TempDir()
+	store := openTestBoltStore(t, tempDir)
+	ctx := context.Background()
+
+	rule := &domain.Rule{ID: "b", Type: "exact", Pattern: "https://example.com", CSS: "a", Tags: []string{"ads"}}
+	require.NoError(t, store.CreateRule(ctx, rule))
+	require.NoError(t, store.CreateRule(ctx, &domain.Rule{ID: "a", Type: "wildcard", Pattern: "*example.org*"}))
+	assert.Equal(t, domain.SourceLocal, rule.Source.Type)
+
+	var appErr *domain.AppError
+	require.ErrorAs(t, store.CreateRule(ctx, &domain.Rule{ID: "a", Type: "exact", Pattern: "x"}), &appErr)
+	assert.Equal(t, 409, appErr.StatusCode)
+
+	rule.CSS = "b"
+	require.NoError(t, store.UpdateRule(ctx, rule))
+	require.ErrorAs(t, store.UpdateRule(ctx, &domain.Rule{ID: "c"}), &appErr)
+	assert.Equal(t, 404, appErr.StatusCode)
+	assert.Equal(t, uint64(4), store.Generation())
+
+	// Rules keep their insertion order and survive reopening the database
+	require.NoError(t, store.Close())
+	reopened := openTestBoltStore(t, tempDir)
+	rules, err := reopened.GetAllRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "b", rules[0].ID)
+	assert.Equal(t, "a", rules[1].ID)
+	assert.Equal(t, "b", rules[0].CSS)
+	assert.Equal(t, domain.SourceLocal, rules[0].Source.Type)
+	assert.Empty(t, rules[0].FilePath)
+
+	require.NoError(t, reopened.DeleteRule(ctx, "b"))
+	_, err = reopened.GetRuleByID(ctx, "b")
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 404, appErr.StatusCode)
+
+	revisions, err := reopened.GetRuleHistory(ctx, "b")
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, domain.RevisionDeleted, revisions[2].Action)
+
+	health := reopened.HealthCheck(ctx)
+	assert.Equal(t, "healthy", health.Status)
+	assert.Equal(t, 1, health.Details["rule_count"])
+	stats := reopened.GetStats(ctx)
+	assert.Equal(t, map[string]int{indexSource: 1, indexPack: 0, indexTag: 0, indexType: 1}, stats["index_entries"])
+
+	// The database is locked while it is open
+	_, err = OpenBoltStore(tempDir)
+	assert.Error(t, err)
+}
+
+func TestBoltStore_KeepsInsertionOrder(t *testing.T) {
+	tempDir := t.TempDir()
+	store := openTestBoltStore(t, tempDir)
+	ctx := context.Background()
+
+	// Equally specific rules: the one created first wins the tie
+	for _, id := range []string{"b", "a"} {
+		require.NoError(t, store.CreateRule(ctx, &domain.Rule{ID: id, Type: "wildcard", Pattern: "*example.com*", CSS: id, Tags: []string{"ads"}}))
+	}
+	require.NoError(t, store.UpdateRule(ctx, &domain.Rule{ID: "b", Type: "wildcard", Pattern: "*example.com*", CSS: "b2", Tags: []string{"ads"}}))
+
+	winner := func(repository domain.RuleRepository) string {
+		patternMatcher := matcher.NewMatcher(repository, cache.NewLRUCache(10))
+		require.NoError(t, patternMatcher.LoadRules(ctx))
+		result, err := patternMatcher.Resolve(ctx, "https://example.com/")
+		require.NoError(t, err)
+		return result.RuleID
+	}
+	before := winner(store)
+
+	require.NoError(t, store.Close())
+	reopened := openTestBoltStore(t, tempDir)
+	rules, err := reopened.GetAllRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "b", rules[0].ID)
+	assert.Equal(t, "a", rules[1].ID)
+	assert.Equal(t, "b", before)
+	assert.Equal(t, before, winner(reopened))
+
+	found, err := reopened.FindRules(ctx, domain.RuleFilter{Tag: "ads"})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, "b", found[0].ID)
+	assert.Equal(t, "a", found[1].ID)
+
+	// A deleted and recreated rule moves to the end
+	require.NoError(t, reopened.DeleteRule(ctx, "b"))
+	require.NoError(t, reopened.CreateRule(ctx, &domain.Rule{ID: "b", Type: "wildcard", Pattern: "*example.com*", CSS: "b"}))
+	assert.Equal(t, "a", winner(reopened))
+}
+
+func TestBoltStore_FindRules(t *testing.T) {
+	store := openTestBoltStore(t, t.TempDir())
+	ctx := context.Background()
+
+	community := domain.RuleSource{Type: domain.SourceCommunity, PackName: "privacy"}
+	for _, rule := range []*domain.Rule{
+		{ID: "a", Type: "exact", Pattern: "https://a.example.com", Tags: []string{"cookies", "cookies"}, Source: community},
+		{ID: "b", Type: "wildcard", Pattern: "*b.example.com*", Tags: []string{"cookies", "ads"}, Source: community},
+		{ID: "c", Type: "wildcard", Pattern: "*c.example.com*", Tags: []string{"ads"}},
+	} {
+		require.NoError(t, store.CreateRule(ctx, rule))
+	}
+
+	ids := func(filter domain.RuleFilter) []string {
+		rules, err := store.FindRules(ctx, filter)
+		require.NoError(t, err)
+		ids := make([]string, len(rules))
+		for i := range rules {
+			ids[i] = rules[i].ID
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, ids(domain.RuleFilter{}))
+	assert.Equal(t, []string{"a", "b"}, ids(domain.RuleFilter{Source: domain.SourceCommunity}))
+	assert.Equal(t, []string{"a", "b"}, ids(domain.RuleFilter{Pack: "privacy"}))
+	assert.Equal(t, []string{"a", "b"}, ids(domain.RuleFilter{Tag: "cookies"}))
+	assert.Equal(t, []string{"b", "c"}, ids(domain.RuleFilter{Type: "wildcard"}))
+	assert.Equal(t, []string{"b"}, ids(domain.RuleFilter{Tag: "ads", Pack: "privacy"}))
+	assert.Empty(t, ids(domain.RuleFilter{Tag: "cookie"}))
+
+	// Updates move the index entries and deletions remove them
+	require.NoError(t, store.UpdateRule(ctx, &domain.Rule{ID: "a", Type: "wildcard", Pattern: "*a.example.com*"}))
+	require.NoError(t, store.DeleteRule(ctx, "c"))
+	assert.Equal(t, []string{"b"}, ids(domain.RuleFilter{Tag: "cookies"}))
+	assert.Equal(t, []string{"a", "b"}, ids(domain.RuleFilter{Type: "wildcard"}))
+	assert.Empty(t, ids(domain.RuleFilter{Tag: "ads", Source: domain.SourceLocal}))
+	assert.Equal(t, []string{"a", "b"}, ids(domain.RuleFilter{Pack: "privacy"}), "an update without a source keeps it")
+}
+
+func TestMigrate_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	filesDir := t.TempDir()
+	config := DefaultStoreConfig(filesDir)
+
+	writeRuleFile(t, filepath.Join(config.LocalDir, "local.rule.yaml"), "local-rule", "*local.example.com*")
+	writeRuleFile(t, filepath.Join(config.OverrideDir, "override.rule.yaml"), "override-rule", "*override.example.com*")
+	writeRuleFile(t, filepath.Join(config.CommunityDir, "privacy", "banner.rule.yaml"), "pack-rule", "*pack.example.com*")
+
+	files := NewStoreWithConfig(config)
+	require.NoError(t, files.GetConflictManager().DisableRule("override-rule", "breaks print layout"))
+
+	db := openTestBoltStore(t, t.TempDir())
+	migrated, err := MigrateToDatabase(ctx, files, db)
+	require.NoError(t, err)
+	assert.Equal(t, 3, migrated)
+
+	// The disabled rule is stored but not served
+	served, err := db.GetAllRules(ctx)
+	require.NoError(t, err)
+	assert.Len(t, served, 2)
+	_, err = db.GetRuleByID(ctx, "override-rule")
+	var appErr *domain.AppError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 404, appErr.StatusCode)
+	require.ErrorAs(t, db.CreateRule(ctx, &domain.Rule{ID: "override-rule", Type: "exact", Pattern: "x"}), &appErr)
+	assert.Equal(t, 409, appErr.StatusCode)
+	health := db.HealthCheck(ctx)
+	assert.Equal(t, "healthy", health.Status)
+	assert.Equal(t, 1, health.Details["disabled_rules"])
+
+	// It stays disabled when the database is opened again
+	require.NoError(t, db.Load(ctx))
+	served, err = db.GetAllRules(ctx)
+	require.NoError(t, err)
+	assert.Len(t, served, 2)
+
+	rules, err := db.FindRules(ctx, domain.RuleFilter{Pack: "privacy"})
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "pack-rule", rules[0].ID)
+
+	// The migration is one-shot
+	_, err = MigrateToDatabase(ctx, NewStoreWithConfig(config), db)
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 409, appErr.StatusCode)
+
+	// Exporting refuses to mix with existing rule files
+	_, err = MigrateToFiles(ctx, db, config)
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 409, appErr.StatusCode)
+
+	exportConfig := DefaultStoreConfig(t.TempDir())
+	exported, err := MigrateToFiles(ctx, db, exportConfig)
+	require.NoError(t, err)
+	assert.Equal(t, 3, exported)
+	assert.FileExists(t, filepath.Join(exportConfig.CommunityDir, "privacy", "pack-rule.rule.yaml"))
+
+	reloaded := NewStoreWithConfig(exportConfig)
+	require.NoError(t, reloaded.Load(ctx))
+	for _, want := range []struct {
+		id     string
+		source domain.SourceType
+	}{
+		{"local-rule", domain.SourceLocal},
+		{"pack-rule", domain.SourceCommunity},
+	} {
+		rule, err := reloaded.GetRuleByID(ctx, want.id)
+		require.NoError(t, err)
+		assert.Equal(t, want.source, rule.Source.Type, want.id)
+	}
+
+	// The disabled rule is exported and still disabled
+	excluded := reloaded.GetExcludedRules(ctx)
+	require.Len(t, excluded, 1)
+	assert.Equal(t, "override-rule", excluded[0].Rule.ID)
+	assert.Equal(t, domain.SourceOverride, excluded[0].Rule.Source.Type)
+	assert.Equal(t, "disabled: breaks print layout", excluded[0].Reason)
+}
+
+func writeRuleFile(t *testing.T, path, id, pattern string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	content := "id: " + id + "\ntype: wildcard\npattern: \"" + pattern + "\"\ncss: \"body { color: red; }\"\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+// Feature: github.com/freewebtopdf/asset-injector, Property 46: Database index lookups equal filtering every rule
+func TestProperty_BoltIndexEquivalence(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	tags := []string{"ads", "cookies", "print"}
+	packs := []string{"", "privacy", "news"}
+
+	genRule := gopter.CombineGens(
+		gen.OneConstOf("exact", "wildcard", "regex"),
+		gen.IntRange(0, len(packs)-1),
+		gen.SliceOfN(2, gen.IntRange(0, len(tags))),
+	).Map(func(values []any) domain.Rule {
+		rule := domain.Rule{Type: values[0].(string), Pattern: "*example.com*"}
+		if pack := packs[values[1].(int)]; pack != "" {
+			rule.Source = domain.RuleSource{Type: domain.SourceCommunity, PackName: pack}
+		}
+		for _, i := range values[2].([]int) {
+			if i < len(tags) {
+				rule.Tags = append(rule.Tags, tags[i])
+			}
+		}
+		return rule
+	})
+
+	genFilter := gopter.CombineGens(
+		gen.OneConstOf(domain.SourceType(""), domain.SourceLocal, domain.SourceCommunity),
+		gen.OneConstOf("", "privacy", "news"),
+		gen.OneConstOf("", "ads", "cookies", "print"),
+		gen.OneConstOf("", "exact", "wildcard", "regex"),
+	).Map(func(values []any) domain.RuleFilter {
+		return domain.RuleFilter{
+			Source: values[0].(domain.SourceType),
+			Pack:   values[1].(string),
+			Tag:    values[2].(string),
+			Type:   values[3].(string),
+		}
+	})
+
+	properties.Property("FindRules returns exactly the rules the filter matches, in insertion order", prop.ForAll(
+		func(rules []domain.Rule, deleted int, filter domain.RuleFilter) bool {
+			store, err := OpenBoltStore(t.TempDir())
+			if err != nil {
+				return false
+			}
+			defer store.Close()
+			ctx := context.Background()
+
+			for i := range rules {
+				// IDs descend so insertion order differs from ID order
+				rules[i].ID = string(rune('z' - i))
+				if err := store.CreateRule(ctx, &rules[i]); err != nil {
+					return false
+				}
+			}
+			// Deleting and rewriting a rule must leave no stale index entries
+			if deleted < len(rules) {
+				if err := store.DeleteRule(ctx, rules[deleted].ID); err != nil {
+					return false
+				}
+				rules = append(rules[:deleted:deleted], rules[deleted+1:]...)
+			}
+			if len(rules) > 0 {
+				rules[0].Tags = nil
+				rules[0].Type = "exact"
+				if err := store.UpdateRule(ctx, &rules[0]); err != nil {
+					return false
+				}
+			}
+
+			var want []string
+			for i := range rules {
+				if filter.Matches(&rules[i]) {
+					want = append(want, rules[i].ID)
+				}
+			}
+			found, err := store.FindRules(ctx, filter)
+			if err != nil || len(found) != len(want) {
+				return false
+			}
+			for i := range found {
+				if found[i].ID != want[i] {
+					return false
+				}
+			}
+			return true
+		},
+		gen.SliceOfN(8, genRule),
+		gen.IntRange(0, 9),
+		genFilter,
+	))
+
+	properties.TestingRun(t)
+}
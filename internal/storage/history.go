@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+)
+
+// HistoryDirName is the directory in DATA_DIR that holds the rule revisions
+const HistoryDirName = "history"
+
+// ruleHistory appends rule revisions to one JSON Lines file per rule
+type ruleHistory struct {
+	mu          sync.Mutex
+	dir         string
+	latest      map[string]int // Rule ID -> latest revision number, filled on first use
+	writeErrors int64          // Revisions that could not be written
+}
+
+// newRuleHistory creates a history stored in dir
+func newRuleHistory(dir string) *ruleHistory {
+	return &ruleHistory{dir: dir, latest: make(map[string]int)}
+}
+
+// path returns the history file of a rule
+func (h *ruleHistory) path(id string) string {
+	return filepath.Join(h.dir, url.PathEscape(id)+".jsonl")
+}
+
+// append records a revision of the rule and returns its number
+func (h *ruleHistory) append(action domain.RevisionAction, rule *domain.Rule, info domain.ChangeInfo, now time.Time) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	latest, known := h.latest[rule.ID]
+	if !known {
+		revisions, err := h.read(rule.ID)
+		if err != nil {
+			return 0, err
+		}
+		if len(revisions) > 0 {
+			latest = revisions[len(revisions)-1].Revision
+		}
+	}
+
+	revision := domain.RuleRevision{
+		Revision:     latest + 1,
+		RuleID:       rule.ID,
+		Action:       action,
+		Actor:        info.Actor,
+		Reason:       info.Reason,
+		Timestamp:    now,
+		RevertedFrom: info.RevertedFrom,
+		Rule:         *rule,
+	}
+	line, err := json.Marshal(revision)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode revision: %w", err)
+	}
+
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	file, err := os.OpenFile(h.path(rule.ID), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	// Terminate a line left incomplete by a crash so it does not swallow this one
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return 0, fmt.Errorf("failed to write revision: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to sync history file: %w", err)
+	}
+
+	h.latest[rule.ID] = revision.Revision
+	return revision.Revision, nil
+}
+
+// failures returns the number of revisions that could not be written
+func (h *ruleHistory) failures() int64 {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.writeErrors
+}
+
+// read returns the revisions of a rule, oldest first. Lines that cannot be decoded,
+// such as one cut off by a crash, are skipped.
+func (h *ruleHistory) read(id string) ([]domain.RuleRevision, error) {
+	file, err := os.Open(h.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	var revisions []domain.RuleRevision
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var revision domain.RuleRevision
+			if json.Unmarshal(line, &revision) == nil {
+				revisions = append(revisions, revision)
+			}
+		}
+		if err == io.EOF {
+			return revisions, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history file: %w", err)
+		}
+	}
+}
+
+// record appends a revision for a change that was already written. A failure does not
+// undo the change; it is logged and counted in the health details.
+func (h *ruleHistory) record(ctx context.Context, action domain.RevisionAction, rule *domain.Rule) {
+	if h == nil {
+		return
+	}
+	info := domain.ChangeInfoFromContext(ctx)
+	if action != domain.RevisionDeleted && info.RevertedFrom > 0 {
+		action = domain.RevisionReverted
+	}
+	// The file path is where the rule lives now, not part of the revision
+	recorded := *rule
+	recorded.FilePath = ""
+	if _, err := h.append(action, &recorded, info, time.Now()); err != nil {
+		h.mu.Lock()
+		h.writeErrors++
+		h.mu.Unlock()
+		log.Error().Err(err).Str("rule_id", rule.ID).Str("action", string(action)).Msg("Failed to record rule revision")
+	}
+}
+
+// revisions returns the revisions of a rule, oldest first, or a 404 AppError if none
+// were recorded
+func (h *ruleHistory) revisions(ctx context.Context, id string) ([]domain.RuleRevision, error) {
+	if h == nil {
+		return nil, historyNotFound(ctx, id)
+	}
+	h.mu.Lock()
+	revisions, err := h.read(id)
+	h.mu.Unlock()
+	if err != nil {
+		return nil, domain.NewAppErrorWithCause(
+			domain.ErrInternal,
+			"Failed to read rule history",
+			500,
+			err,
+			map[string]any{"rule_id": id},
+		).WithContext(ctx, "get_rule_history")
+	}
+	if len(revisions) == 0 {
+		return nil, historyNotFound(ctx, id)
+	}
+	return revisions, nil
+}
+
+// revision returns a single revision of a rule
+func (h *ruleHistory) revision(ctx context.Context, id string, revision int) (*domain.RuleRevision, error) {
+	revisions, err := h.revisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range revisions {
+		if revisions[i].Revision == revision {
+			return &revisions[i], nil
+		}
+	}
+	return nil, domain.NewAppError(
+		domain.ErrNotFound,
+		"Revision not found",
+		404,
+		map[string]any{"rule_id": id, "revision": revision},
+	).WithContext(ctx, "get_rule_revision")
+}
+
+// recordRevision records a revision of a change that was already written
+func (s *Store) recordRevision(ctx context.Context, action domain.RevisionAction, rule *domain.Rule) {
+	s.history.record(ctx, action, rule)
+}
+
+// GetRuleHistory returns the revisions of a rule, oldest first. Deleted rules keep
+// their history.
+func (s *Store) GetRuleHistory(ctx context.Context, id string) ([]domain.RuleRevision, error) {
+	return s.history.revisions(ctx, id)
+}
+
+// GetRuleRevision returns a single revision of a rule
+func (s *Store) GetRuleRevision(ctx context.Context, id string, revision int) (*domain.RuleRevision, error) {
+	return s.history.revision(ctx, id, revision)
+}
+
+// historyNotFound reports a rule without recorded revisions
+func historyNotFound(ctx context.Context, id string) *domain.AppError {
+	return domain.NewAppError(
+		domain.ErrNotFound,
+		"No history recorded for rule",
+		404,
+		map[string]any{"rule_id": id},
+	).WithContext(ctx, "get_rule_history")
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/freewebtopdf/asset-injector/internal/conflict"
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/loader"
+)
+
+// exportPackName is the pack directory of community rules that have no pack name
+const exportPackName = "imported"
+
+// MigrateToDatabase copies the rules of the YAML tree into an empty rule database in
+// one transaction and returns their number. Shadowed rules are not copied; disabled
+// rules are, and stay disabled in the database's DATA_DIR.
+func MigrateToDatabase(ctx context.Context, files *Store, db *BoltStore) (int, error) {
+	if err := files.Load(ctx); err != nil {
+		return 0, err
+	}
+	rules := files.resolvedRules()
+	disabled := files.conflictManager.GetDisabledManager().GetDisabledRuleEntries()
+	if err := db.importRules(ctx, rules, disabled); err != nil {
+		return 0, err
+	}
+	return len(rules), nil
+}
+
+// MigrateToFiles writes the rules of the database to one YAML file each and returns
+// their number. The rule directories must not contain rule files yet. Local and
+// override rules go to their directory and community rules to a directory named
+// after their pack; if a file cannot be written the ones already written are removed.
+// Disabled rules are written too and added to the disabled rules of config.DataDir.
+func MigrateToFiles(ctx context.Context, db *BoltStore, config StoreConfig) (int, error) {
+	if err := db.Load(ctx); err != nil {
+		return 0, err
+	}
+
+	existing, err := loader.NewScanner(loader.ScanConfig{
+		LocalDir:     config.LocalDir,
+		CommunityDir: config.CommunityDir,
+		OverrideDir:  config.OverrideDir,
+	}).Scan(ctx)
+	if err != nil {
+		return 0, domain.NewAppErrorWithCause(
+			domain.ErrInternal,
+			"Failed to scan rule directories",
+			500,
+			err,
+			nil,
+		).WithContext(ctx, "migrate_to_files")
+	}
+	if len(existing) > 0 {
+		return 0, domain.NewAppError(
+			domain.ErrConflict,
+			"Rule directories already contain rule files",
+			409,
+			map[string]any{"files": len(existing), "first": existing[0].Path},
+		).WithContext(ctx, "migrate_to_files")
+	}
+
+	rules, err := db.storedRules()
+	if err != nil {
+		return 0, domain.NewAppErrorWithCause(
+			domain.ErrInternal,
+			"Failed to read rules from database",
+			500,
+			err,
+			map[string]any{"database": db.Path()},
+		).WithContext(ctx, "migrate_to_files")
+	}
+
+	writer := loader.NewWriter(config.LocalDir)
+	written := make([]string, 0, len(rules))
+	removeWritten := func() {
+		for _, path := range written {
+			_ = os.Remove(path)
+		}
+	}
+	for _, rule := range rules {
+		path := exportPath(config, rule)
+		if err := writer.WriteRuleToPath(rule, path); err != nil {
+			removeWritten()
+			return 0, domain.NewAppErrorWithCause(
+				domain.ErrInternal,
+				"Failed to write rule file",
+				500,
+				err,
+				map[string]any{"rule_id": rule.ID, "path": path},
+			).WithContext(ctx, "migrate_to_files")
+		}
+		written = append(written, path)
+	}
+
+	if entries := db.disabled.GetDisabledRuleEntries(); len(entries) > 0 {
+		target := conflict.NewDisabledRulesManager(config.DataDir)
+		err := target.Load()
+		if err == nil {
+			err = target.MergeEntries(entries)
+		}
+		if err != nil {
+			removeWritten()
+			return 0, domain.NewAppErrorWithCause(
+				domain.ErrInternal,
+				"Failed to write disabled rules",
+				500,
+				err,
+				map[string]any{"path": target.FilePath()},
+			).WithContext(ctx, "migrate_to_files")
+		}
+	}
+	return len(written), nil
+}
+
+// exportPath returns the file a rule is written to by MigrateToFiles. The directory
+// determines the source the rule has when the files are loaded again.
+func exportPath(config StoreConfig, rule *domain.Rule) string {
+	name := rule.ID + ".rule.yaml"
+	switch rule.Source.Type {
+	case domain.SourceOverride:
+		return filepath.Join(config.OverrideDir, name)
+	case domain.SourceCommunity:
+		pack := filepath.Base(rule.Source.PackName)
+		if pack == "." || pack == ".." || pack == string(filepath.Separator) {
+			pack = exportPackName
+		}
+		return filepath.Join(config.CommunityDir, pack, name)
+	default:
+		return filepath.Join(config.LocalDir, name)
+	}
+}
@@ -0,0 +1,37 @@
+package storage
+
+import "github.com/freewebtopdf/asset-injector/internal/domain"
+
+// ruleSet is an immutable snapshot of the store's rules. Writers build a new set and
+// publish it; readers load the current one without locking. Rules in a set are never
+// modified, so readers hand out copies.
+type ruleSet struct {
+	generation uint64
+	rules      map[string]*domain.Rule
+	list       []*domain.Rule
+	positions  map[string]int // Rule ID -> index in list
+}
+
+// newRuleSet indexes a list of rules with unique IDs
+func newRuleSet(list []*domain.Rule, generation uint64) *ruleSet {
+	set := &ruleSet{
+		generation: generation,
+		rules:      make(map[string]*domain.Rule, len(list)),
+		list:       list,
+		positions:  make(map[string]int, len(list)),
+	}
+	if set.list == nil {
+		set.list = make([]*domain.Rule, 0)
+	}
+	for i, rule := range list {
+		set.rules[rule.ID] = rule
+		set.positions[rule.ID] = i
+	}
+	return set
+}
+
+// get returns the rule with the ID
+func (s *ruleSet) get(id string) (*domain.Rule, bool) {
+	rule, exists := s.rules[id]
+	return rule, exists
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/loader"
+	"github.com/freewebtopdf/asset-injector/internal/ruleset"
+)
+
+// sourceFingerprints fingerprints every file the loaded rules depend on: the rule files
+// found by the scanner and the disabled rules list
+func (s *Store) sourceFingerprints(ctx context.Context) ([]ruleset.Fingerprint, error) {
+	scanned, err := loader.NewScanner(loader.ScanConfig{
+		LocalDir:     s.config.LocalDir,
+		CommunityDir: s.config.CommunityDir,
+		OverrideDir:  s.config.OverrideDir,
+	}).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(scanned)+1)
+	for _, file := range scanned {
+		paths = append(paths, file.Path)
+	}
+	paths = append(paths, s.conflictManager.GetDisabledManager().FilePath())
+	return ruleset.Fingerprints(ctx, paths)
+}
+
+// LoadSnapshot restores the rules of a ruleset snapshot instead of scanning and parsing
+// the rule files. When a source file changed since the snapshot was taken nothing is
+// loaded and the difference is returned, so the caller can fall back to Load.
+func (s *Store) LoadSnapshot(ctx context.Context, snapshot *ruleset.Snapshot) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.conflictManager.Load(); err != nil {
+		// Log warning but continue - disabled rules file might not exist yet
+	}
+
+	if err := s.ensureDirectories(ctx); err != nil {
+		return "", err
+	}
+
+	current, err := s.sourceFingerprints(ctx)
+	if err != nil {
+		return "", domain.NewAppErrorWithCause(
+			domain.ErrInternal,
+			"Failed to fingerprint rule files",
+			500,
+			err,
+			nil,
+		).WithContext(ctx, "load_snapshot")
+	}
+	if reason := snapshot.Stale(current); reason != "" {
+		return reason, nil
+	}
+
+	s.ruleLoader.Restore(snapshot.Rules, snapshot.LoadErrors)
+	list := make([]*domain.Rule, len(snapshot.Active))
+	for i := range snapshot.Active {
+		ruleCopy := snapshot.Active[i]
+		list[i] = &ruleCopy
+	}
+	s.publish(list)
+	s.fingerprints = current
+
+	return "", nil
+}
+
+// Snapshot returns the compiled state of the last load for ruleset.Write, or nil when
+// the rule files could not be fingerprinted
+func (s *Store) Snapshot() *ruleset.Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fingerprints == nil {
+		return nil
+	}
+	list := s.snapshot.Load().list
+	active := make([]domain.Rule, len(list))
+	for i, rule := range list {
+		active[i] = *rule
+	}
+	return &ruleset.Snapshot{
+		CreatedAt:    time.Now(),
+		Fingerprints: s.fingerprints,
+		Rules:        s.ruleLoader.GetRules(),
+		LoadErrors:   s.ruleLoader.GetLoadErrors(),
+		Active:       active,
+	}
+}
+
+// SetStartupReport records how the rules were loaded at startup for health details
+func (s *Store) SetStartupReport(report ruleset.StartupReport) {
+	s.startup.Store(&report)
+}
@@ -2,14 +2,18 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/freewebtopdf/asset-injector/internal/conflict"
 	"github.com/freewebtopdf/asset-injector/internal/domain"
 	"github.com/freewebtopdf/asset-injector/internal/loader"
+	"github.com/freewebtopdf/asset-injector/internal/ruleset"
 )
 
 // StoreConfig holds configuration for the Store
@@ -30,16 +34,21 @@ func DefaultStoreConfig(dataDir string) StoreConfig {
 	}
 }
 
-// Store implements the RuleRepository interface with dual indexing
+// Store implements the RuleRepository interface with dual indexing. The rules are
+// published as immutable snapshots, so reads never lock.
 type Store struct {
-	mu       sync.RWMutex
-	rules    map[string]*domain.Rule
-	ruleList []*domain.Rule
+	mu       sync.Mutex // Serializes writers
+	snapshot atomic.Pointer[ruleSet]
 	config   StoreConfig
 
 	ruleLoader      *loader.FileRuleLoader
 	ruleWriter      *loader.Writer
 	conflictManager *conflict.ConflictManager
+	history         *ruleHistory // Nil without a data directory
+
+	// Source files of the last load, taken before they were read
+	fingerprints []ruleset.Fingerprint
+	startup      atomic.Pointer[ruleset.StartupReport]
 }
 
 // NewStore creates a new Store instance
@@ -56,14 +65,27 @@ func NewStoreWithConfig(config StoreConfig) *Store {
 		OverrideDir:  config.OverrideDir,
 	}
 
-	return &Store{
-		rules:           make(map[string]*domain.Rule),
-		ruleList:        make([]*domain.Rule, 0),
+	s := &Store{
 		config:          config,
 		ruleLoader:      loader.NewFileRuleLoader(scanConfig),
 		ruleWriter:      loader.NewWriter(config.LocalDir),
 		conflictManager: conflict.NewConflictManager(config.DataDir),
 	}
+	if config.DataDir != "" {
+		s.history = newRuleHistory(filepath.Join(config.DataDir, HistoryDirName))
+	}
+	s.snapshot.Store(newRuleSet(nil, 0))
+	return s
+}
+
+// Generation returns the generation of the current rule set, incremented on every change
+func (s *Store) Generation() uint64 {
+	return s.snapshot.Load().generation
+}
+
+// publish swaps in a rule set built from the current one. Must be called with s.mu held.
+func (s *Store) publish(list []*domain.Rule) {
+	s.snapshot.Store(newRuleSet(list, s.snapshot.Load().generation+1))
 }
 
 // Load loads rules from file-based storage
@@ -87,20 +109,13 @@ func (s *Store) Load(ctx context.Context) error {
 		// Log warning but continue - disabled rules file might not exist yet
 	}
 
-	for _, dir := range []string{s.config.LocalDir, s.config.CommunityDir, s.config.OverrideDir} {
-		if dir != "" {
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				return domain.NewAppErrorWithCause(
-					domain.ErrInternal,
-					"Failed to create rules directory",
-					500,
-					err,
-					map[string]any{"dir": dir},
-				).WithContext(ctx, "load")
-			}
-		}
+	if err := s.ensureDirectories(ctx); err != nil {
+		return err
 	}
 
+	// Files changed while they are read leave the snapshot stale, never wrong
+	s.fingerprints, _ = s.sourceFingerprints(ctx)
+
 	rules, loadErrors, err := s.ruleLoader.LoadAll(ctx)
 	if err != nil {
 		return domain.NewAppErrorWithCause(
@@ -114,26 +129,22 @@ func (s *Store) Load(ctx context.Context) error {
 
 	resolvedRules := s.conflictManager.GetActiveRules(rules)
 
-	s.rules = make(map[string]*domain.Rule, len(resolvedRules))
-	s.ruleList = make([]*domain.Rule, 0, len(resolvedRules))
-
+	list := make([]*domain.Rule, len(resolvedRules))
 	for i := range resolvedRules {
-		rule := resolvedRules[i]
-		ruleCopy := rule
-		s.rules[rule.ID] = &ruleCopy
-		s.ruleList = append(s.ruleList, &ruleCopy)
+		ruleCopy := resolvedRules[i]
+		list[i] = &ruleCopy
 	}
+	s.publish(list)
 
 	return nil
 }
 
 // GetAllRules returns all rules in the repository
 func (s *Store) GetAllRules(ctx context.Context) ([]domain.Rule, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	list := s.snapshot.Load().list
 
-	result := make([]domain.Rule, len(s.ruleList))
-	for i, rule := range s.ruleList {
+	result := make([]domain.Rule, len(list))
+	for i, rule := range list {
 		result[i] = *rule
 	}
 
@@ -142,10 +153,7 @@ func (s *Store) GetAllRules(ctx context.Context) ([]domain.Rule, error) {
 
 // GetRuleByID retrieves a rule by its ID
 func (s *Store) GetRuleByID(ctx context.Context, id string) (*domain.Rule, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	rule, exists := s.rules[id]
+	rule, exists := s.snapshot.Load().get(id)
 	if !exists {
 		return nil, domain.NewAppError(
 			domain.ErrNotFound,
@@ -164,7 +172,8 @@ func (s *Store) CreateRule(ctx context.Context, rule *domain.Rule) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.rules[rule.ID]; exists {
+	current := s.snapshot.Load()
+	if _, exists := current.get(rule.ID); exists {
 		return domain.NewAppError(
 			domain.ErrConflict,
 			"Rule already exists",
@@ -187,12 +196,8 @@ func (s *Store) CreateRule(ctx context.Context, rule *domain.Rule) error {
 
 	ruleCopy := *rule
 
-	s.rules[rule.ID] = &ruleCopy
-	s.ruleList = append(s.ruleList, &ruleCopy)
-
+	// The new set is only published once the rule file was written
 	if err := s.ruleWriter.WriteRule(&ruleCopy); err != nil {
-		delete(s.rules, rule.ID)
-		s.ruleList = s.ruleList[:len(s.ruleList)-1]
 		return domain.NewAppError(
 			domain.ErrInternal,
 			"Failed to write rule file",
@@ -201,7 +206,9 @@ func (s *Store) CreateRule(ctx context.Context, rule *domain.Rule) error {
 		)
 	}
 
+	s.publish(append(slices.Clone(current.list), &ruleCopy))
 	rule.FilePath = filepath.Join(s.config.LocalDir, rule.ID+".rule.yaml")
+	s.recordRevision(ctx, domain.RevisionCreated, rule)
 	return nil
 }
 
@@ -210,7 +217,8 @@ func (s *Store) UpdateRule(ctx context.Context, rule *domain.Rule) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	existingRule, exists := s.rules[rule.ID]
+	current := s.snapshot.Load()
+	existingRule, exists := current.get(rule.ID)
 	if !exists {
 		return domain.NewAppError(
 			domain.ErrNotFound,
@@ -233,21 +241,7 @@ func (s *Store) UpdateRule(ctx context.Context, rule *domain.Rule) error {
 
 	ruleCopy := *rule
 
-	oldRule := *existingRule
-	var oldIndex int
-	for i, r := range s.ruleList {
-		if r.ID == rule.ID {
-			oldIndex = i
-			break
-		}
-	}
-
-	s.rules[rule.ID] = &ruleCopy
-	s.ruleList[oldIndex] = &ruleCopy
-
 	if err := s.ruleWriter.UpdateRule(&ruleCopy); err != nil {
-		s.rules[rule.ID] = &oldRule
-		s.ruleList[oldIndex] = &oldRule
 		return domain.NewAppError(
 			domain.ErrInternal,
 			"Failed to update rule file",
@@ -256,6 +250,11 @@ func (s *Store) UpdateRule(ctx context.Context, rule *domain.Rule) error {
 		)
 	}
 
+	list := slices.Clone(current.list)
+	list[current.positions[rule.ID]] = &ruleCopy
+	s.publish(list)
+	s.recordRevision(ctx, domain.RevisionUpdated, &ruleCopy)
+
 	return nil
 }
 
@@ -264,7 +263,8 @@ func (s *Store) DeleteRule(ctx context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	rule, exists := s.rules[id]
+	current := s.snapshot.Load()
+	rule, exists := current.get(id)
 	if !exists {
 		return domain.NewAppError(
 			domain.ErrNotFound,
@@ -287,15 +287,28 @@ func (s *Store) DeleteRule(ctx context.Context, id string) error {
 		_ = s.ruleWriter.DeleteRule(rule.ID)
 	}
 
-	delete(s.rules, id)
+	i := current.positions[id]
+	s.publish(slices.Delete(slices.Clone(current.list), i, i+1))
+	s.recordRevision(ctx, domain.RevisionDeleted, rule)
+
+	return nil
+}
 
-	for i, r := range s.ruleList {
-		if r.ID == id {
-			s.ruleList = append(s.ruleList[:i], s.ruleList[i+1:]...)
-			break
+// ensureDirectories creates the rule directories
+func (s *Store) ensureDirectories(ctx context.Context) error {
+	for _, dir := range []string{s.config.LocalDir, s.config.CommunityDir, s.config.OverrideDir} {
+		if dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return domain.NewAppErrorWithCause(
+					domain.ErrInternal,
+					"Failed to create rules directory",
+					500,
+					err,
+					map[string]any{"dir": dir},
+				).WithContext(ctx, "load")
+			}
 		}
 	}
-
 	return nil
 }
 
@@ -314,6 +327,50 @@ func (s *Store) GetConflictManager() *conflict.ConflictManager {
 	return s.conflictManager
 }
 
+// GetExcludedRules returns loaded rules that are not active because they are
+// shadowed by a higher-priority rule with the same ID or have been disabled
+func (s *Store) GetExcludedRules(ctx context.Context) []domain.ExcludedRule {
+	// The lock keeps a concurrent Load from replacing the loader's rules
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loaded := s.ruleLoader.GetRules()
+	resolver := s.conflictManager.GetResolver()
+	disabledManager := s.conflictManager.GetDisabledManager()
+
+	var excluded []domain.ExcludedRule
+	for _, rule := range resolver.GetOverriddenRules(loaded) {
+		reason := "shadowed by a higher-priority rule with the same ID"
+		if winner := resolver.GetActiveRule(rule.ID, loaded); winner != nil {
+			reason = fmt.Sprintf("shadowed by %s rule with the same ID", winner.Source.Type)
+		}
+		excluded = append(excluded, domain.ExcludedRule{Rule: rule, Reason: reason})
+	}
+
+	for _, rule := range resolver.ResolveConflicts(loaded) {
+		entry := disabledManager.GetDisabledEntry(rule.ID)
+		if entry == nil {
+			continue
+		}
+		reason := "disabled"
+		if entry.Reason != "" {
+			reason += ": " + entry.Reason
+		}
+		excluded = append(excluded, domain.ExcludedRule{Rule: rule, Reason: reason})
+	}
+
+	return excluded
+}
+
+// resolvedRules returns the loaded rules that are not shadowed, in the order they are
+// served, including disabled ones
+func (s *Store) resolvedRules() []domain.Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.conflictManager.GetResolver().ResolveConflicts(s.ruleLoader.GetRules())
+}
+
 // GetLoadErrors returns any errors from the last load operation
 func (s *Store) GetLoadErrors() []loader.LoadError {
 	return s.ruleLoader.GetLoadErrors()
@@ -321,17 +378,20 @@ func (s *Store) GetLoadErrors() []loader.LoadError {
 
 // HealthCheck performs a health check on the storage system
 func (s *Store) HealthCheck(ctx context.Context) domain.HealthStatus {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	snapshot := s.snapshot.Load()
 
 	now := time.Now()
 	status := "healthy"
 	message := "Storage is operating normally"
 	details := map[string]any{
-		"rule_count": len(s.ruleList),
+		"rule_count": len(snapshot.list),
+		"generation": snapshot.generation,
 		"data_dir":   s.config.DataDir,
 		"local_dir":  s.config.LocalDir,
 	}
+	if startup := s.startup.Load(); startup != nil {
+		details["startup"] = startup.Details()
+	}
 
 	if _, err := os.Stat(s.config.DataDir); err != nil {
 		status = "unhealthy"
@@ -345,11 +405,17 @@ func (s *Store) HealthCheck(ctx context.Context) domain.HealthStatus {
 		}
 	}
 
-	if len(s.rules) != len(s.ruleList) {
+	if len(snapshot.rules) != len(snapshot.list) {
 		status = "unhealthy"
 		message = "Data structure inconsistency detected"
-		details["map_size"] = len(s.rules)
-		details["list_size"] = len(s.ruleList)
+		details["map_size"] = len(snapshot.rules)
+		details["list_size"] = len(snapshot.list)
+	}
+
+	if failures := s.history.failures(); failures > 0 && status == "healthy" {
+		status = "degraded"
+		message = "Rule revisions could not be recorded"
+		details["history_write_errors"] = failures
 	}
 
 	return domain.HealthStatus{
@@ -362,11 +428,12 @@ func (s *Store) HealthCheck(ctx context.Context) domain.HealthStatus {
 
 // GetStats returns storage statistics
 func (s *Store) GetStats(ctx context.Context) map[string]any {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	snapshot := s.snapshot.Load()
 
 	stats := map[string]any{
-		"rule_count":     len(s.ruleList),
+		"backend":        "files",
+		"rule_count":     len(snapshot.list),
+		"generation":     snapshot.generation,
 		"data_directory": s.config.DataDir,
 		"local_dir":      s.config.LocalDir,
 		"community_dir":  s.config.CommunityDir,
@@ -376,7 +443,7 @@ func (s *Store) GetStats(ctx context.Context) map[string]any {
 
 	typeCount := make(map[string]int)
 	sourceCount := make(map[string]int)
-	for _, rule := range s.ruleList {
+	for _, rule := range snapshot.list {
 		typeCount[rule.Type]++
 		sourceCount[string(rule.Source.Type)]++
 	}
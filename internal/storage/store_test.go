@@ -3,11 +3,13 @@ package storage
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/freewebtopdf/asset-injector/internal/domain"
+	"github.com/freewebtopdf/asset-injector/internal/ruleset"
 
 	"github.com/google/uuid"
 	"github.com/leanovate/gopter"
@@ -437,3 +439,209 @@ func TestProperty_ThreadSafeConcurrentAccess(t *testing.T) {
 
 	properties.TestingRun(t)
 }
+
+func TestStore_GetExcludedRules(t *testing.T) {
+	tempDir := t.TempDir()
+	store := NewStore(tempDir)
+	ctx := context.Background()
+
+	localRules := `rules:
+  - id: "shared"
+    type: "wildcard"
+    pattern: "https://example.com/*"
+    css: ".local {}"
+`
+	communityRules := `rules:
+  - id: "shared"
+    type: "wildcard"
+    pattern: "https://example.com/*"
+    css: ".community {}"
+  - id: "muted"
+    type: "exact"
+    pattern: "https://example.com/page"
+    css: ".muted {}"
+`
+	packDir := filepath.Join(tempDir, "rules", "community", "test-pack")
+	require.NoError(t, os.MkdirAll(packDir, 0755))
+	require.NoError(t, os.MkdirAll(store.config.LocalDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(store.config.LocalDir, "shared.rule.yaml"), []byte(localRules), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(packDir, "rules.rule.yaml"), []byte(communityRules), 0644))
+	require.NoError(t, store.GetConflictManager().DisableRule("muted", "breaks print layout"))
+
+	require.NoError(t, store.Load(ctx))
+
+	active, err := store.GetAllRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	assert.Equal(t, domain.SourceLocal, active[0].Source.Type)
+
+	excluded := store.GetExcludedRules(ctx)
+	require.Len(t, excluded, 2)
+
+	reasons := make(map[string]string)
+	for _, rule := range excluded {
+		reasons[rule.Rule.ID] = rule.Reason
+	}
+	assert.Equal(t, "shadowed by local rule with the same ID", reasons["shared"])
+	assert.Equal(t, "disabled: breaks print layout", reasons["muted"])
+}
+
+func TestStore_SnapshotGenerations(t *testing.T) {
+	store := NewStore(t.TempDir())
+	ctx := context.Background()
+	require.NoError(t, store.Load(ctx))
+	assert.Equal(t, uint64(1), store.Generation())
+
+	rule := &domain.Rule{ID: "a", Type: "exact", Pattern: "https://example.com", CSS: "a"}
+	require.NoError(t, store.CreateRule(ctx, rule))
+	require.NoError(t, store.CreateRule(ctx, &domain.Rule{ID: "b", Type: "exact", Pattern: "https://example.org"}))
+	assert.Equal(t, uint64(3), store.Generation())
+
+	// A reader's rule set is unaffected by later writes
+	before := store.snapshot.Load()
+	rule.CSS = "changed"
+	require.NoError(t, store.UpdateRule(ctx, rule))
+	require.NoError(t, store.DeleteRule(ctx, "b"))
+	assert.Equal(t, uint64(5), store.Generation())
+	assert.Len(t, before.list, 2)
+	assert.Equal(t, "a", before.rules["a"].CSS)
+
+	rules, err := store.GetAllRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "changed", rules[0].CSS)
+	assert.Equal(t, map[string]int{"a": 0}, store.snapshot.Load().positions)
+
+	// Failed changes publish nothing
+	assert.Error(t, store.CreateRule(ctx, &domain.Rule{ID: "a"}))
+	assert.Error(t, store.DeleteRule(ctx, "b"))
+	assert.Equal(t, uint64(5), store.Generation())
+	assert.Equal(t, uint64(5), store.GetStats(ctx)["generation"])
+}
+
+func TestStore_LoadSnapshot(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+
+	store := NewStore(tempDir)
+	rulesPath := filepath.Join(store.config.LocalDir, "site.rule.yaml")
+	require.NoError(t, os.MkdirAll(store.config.LocalDir, 0755))
+	require.NoError(t, os.WriteFile(rulesPath, []byte(`rules:
+  - id: "site"
+    type: "wildcard"
+    pattern: "https://example.com/*"
+    priority: 0
+    css: ".site {}"
+  - id: "muted"
+    type: "exact"
+    pattern: "https://example.com/page"
+    css: ".muted {}"
+`), 0644))
+	require.NoError(t, store.GetConflictManager().DisableRule("muted", "breaks print layout"))
+	require.NoError(t, store.Load(ctx))
+
+	snapshotPath := filepath.Join(tempDir, ruleset.FileName)
+	snapshot := store.Snapshot()
+	require.NotNil(t, snapshot)
+	require.NoError(t, ruleset.Write(snapshotPath, snapshot))
+
+	// Unchanged files restore the same state without parsing them
+	restored := NewStore(tempDir)
+	snapshot, err := ruleset.Read(snapshotPath)
+	require.NoError(t, err)
+	stale, err := restored.LoadSnapshot(ctx, snapshot)
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+
+	expected, err := store.GetAllRules(ctx)
+	require.NoError(t, err)
+	rules, err := restored.GetAllRules(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, expected, rules)
+	require.NotNil(t, rules[0].Priority)
+	assert.Equal(t, 0, *rules[0].Priority)
+	assert.Equal(t, store.GetExcludedRules(ctx), restored.GetExcludedRules(ctx))
+
+	restored.SetStartupReport(ruleset.StartupReport{Source: ruleset.SourceSnapshot, Rules: len(rules)})
+	startup := restored.HealthCheck(ctx).Details["startup"].(map[string]any)
+	assert.Equal(t, ruleset.SourceSnapshot, startup["source"])
+
+	// A changed rule file or disabled list leaves the store empty for a full load
+	require.NoError(t, os.WriteFile(rulesPath, []byte("rules: []\n"), 0644))
+	fresh := NewStore(tempDir)
+	stale, err = fresh.LoadSnapshot(ctx, snapshot)
+	require.NoError(t, err)
+	assert.Equal(t, "file changed: "+rulesPath, stale)
+	assert.Equal(t, uint64(0), fresh.Generation())
+
+	require.NoError(t, store.GetConflictManager().EnableRule("muted"))
+	stale, err = NewStore(tempDir).LoadSnapshot(ctx, snapshot)
+	require.NoError(t, err)
+	assert.Contains(t, stale, ".disabled.json")
+}
+
+func TestStore_RuleHistory(t *testing.T) {
+	tempDir := t.TempDir()
+	store := NewStore(tempDir)
+	ctx := context.Background()
+	require.NoError(t, store.Load(ctx))
+
+	_, err := store.GetRuleHistory(ctx, "a")
+	var appErr *domain.AppError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 404, appErr.StatusCode)
+
+	rule := &domain.Rule{ID: "a", Type: "exact", Pattern: "https://example.com", CSS: "a"}
+	require.NoError(t, store.CreateRule(domain.WithChangeInfo(ctx, domain.ChangeInfo{Actor: "jane", Reason: "hide banner"}), rule))
+	rule.CSS = "b"
+	require.NoError(t, store.UpdateRule(ctx, rule))
+	rule.CSS = "a"
+	require.NoError(t, store.UpdateRule(domain.WithChangeInfo(ctx, domain.ChangeInfo{Actor: "joe", RevertedFrom: 1}), rule))
+	require.NoError(t, store.DeleteRule(ctx, "a"))
+
+	// Failed changes record nothing
+	assert.Error(t, store.DeleteRule(ctx, "a"))
+
+	// The history survives a restart and the deletion of the rule
+	restarted := NewStore(tempDir)
+	require.NoError(t, restarted.Load(ctx))
+	revisions, err := restarted.GetRuleHistory(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, revisions, 4)
+
+	actions := make([]domain.RevisionAction, len(revisions))
+	for i, revision := range revisions {
+		assert.Equal(t, i+1, revision.Revision)
+		assert.Equal(t, "a", revision.RuleID)
+		actions[i] = revision.Action
+	}
+	assert.Equal(t, []domain.RevisionAction{domain.RevisionCreated, domain.RevisionUpdated, domain.RevisionReverted, domain.RevisionDeleted}, actions)
+	assert.Equal(t, "jane", revisions[0].Actor)
+	assert.Equal(t, "hide banner", revisions[0].Reason)
+	assert.Equal(t, "b", revisions[1].Rule.CSS)
+	assert.Equal(t, 1, revisions[2].RevertedFrom)
+	assert.Equal(t, "a", revisions[3].Rule.CSS)
+
+	revision, err := restarted.GetRuleRevision(ctx, "a", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "b", revision.Rule.CSS)
+	_, err = restarted.GetRuleRevision(ctx, "a", 5)
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, 404, appErr.StatusCode)
+
+	// A line cut off by a crash is skipped and does not swallow the next revision
+	path := restarted.history.path("a")
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"revision":5,"rule_id":"a","act`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	require.NoError(t, restarted.CreateRule(ctx, &domain.Rule{ID: "a", Type: "exact", Pattern: "https://example.com"}))
+	revisions, err = restarted.GetRuleHistory(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, revisions, 5)
+	assert.Equal(t, domain.RevisionCreated, revisions[4].Action)
+	assert.Equal(t, 5, revisions[4].Revision)
+	assert.Equal(t, "healthy", restarted.HealthCheck(ctx).Status)
+}
@@ -0,0 +1,474 @@
+package urlpattern
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tokenKind classifies the tokens of a pattern string
+type tokenKind int
+
+const (
+	tokenChar     tokenKind = iota // Literal character
+	tokenEscaped                   // Character escaped with a backslash
+	tokenName                      // :name
+	tokenRegexp                    // (regexp)
+	tokenAsterisk                  // *
+	tokenOpen                      // {
+	tokenClose                     // }
+	tokenModifier                  // ? or +
+)
+
+// token is a lexical element of a pattern string
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// isChar reports whether the token is the unescaped literal character c
+func (t token) isChar(c string) bool {
+	return t.kind == tokenChar && t.value == c
+}
+
+// tokenize splits a pattern string into tokens
+func tokenize(pattern string) ([]token, error) {
+	var tokens []token
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch r {
+		case '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("urlpattern: trailing backslash at position %d", i)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenEscaped, value: string(runes[i]), pos: i - 1})
+		case ':':
+			end := i + 1
+			for end < len(runes) && isNameRune(runes[end], end == i+1) {
+				end++
+			}
+			if end == i+1 {
+				tokens = append(tokens, token{kind: tokenChar, value: ":", pos: i})
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenName, value: string(runes[i+1 : end]), pos: i})
+			i = end - 1
+		case '(':
+			end, err := regexpGroupEnd(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenRegexp, value: string(runes[i+1 : end]), pos: i})
+			i = end
+		case '*':
+			tokens = append(tokens, token{kind: tokenAsterisk, value: "*", pos: i})
+		case '{':
+			tokens = append(tokens, token{kind: tokenOpen, value: "{", pos: i})
+		case '}':
+			tokens = append(tokens, token{kind: tokenClose, value: "}", pos: i})
+		case '?', '+':
+			tokens = append(tokens, token{kind: tokenModifier, value: string(r), pos: i})
+		default:
+			tokens = append(tokens, token{kind: tokenChar, value: string(r), pos: i})
+		}
+	}
+
+	return tokens, nil
+}
+
+// isNameRune reports whether r can appear in a group name
+func isNameRune(r rune, first bool) bool {
+	if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+		return true
+	}
+	return !first && r >= '0' && r <= '9'
+}
+
+// regexpGroupEnd returns the index of the parenthesis closing the regexp group opened at start
+func regexpGroupEnd(runes []rune, start int) (int, error) {
+	if start+1 < len(runes) && runes[start+1] == '?' {
+		return 0, fmt.Errorf("urlpattern: regexp group at position %d must not start with '?'", start)
+	}
+
+	depth := 0
+	inClass := false
+	for i := start; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '(':
+			if !inClass {
+				depth++
+			}
+		case ')':
+			if inClass {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				if i == start+1 {
+					return 0, fmt.Errorf("urlpattern: empty regexp group at position %d", start)
+				}
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("urlpattern: unterminated regexp group at position %d", start)
+}
+
+// componentTokens holds the tokens of each component and whether it was present
+type componentTokens struct {
+	tokens  [numComponents][]token
+	present [numComponents]bool
+}
+
+// isSearchPrefix reports whether the token at i starts the search component
+// rather than acting as a modifier of the preceding group
+func isSearchPrefix(tokens []token, i int) bool {
+	if tokens[i].kind != tokenModifier || tokens[i].value != "?" {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch tokens[i-1].kind {
+	case tokenName, tokenRegexp, tokenAsterisk, tokenClose:
+		return false
+	}
+	return true
+}
+
+// splitComponents divides the tokens of a constructor string into URL components
+func splitComponents(tokens []token) ([numComponents][]token, error) {
+	var parts componentTokens
+
+	// protocol "://"
+	depth := 0
+	protocolEnd := -1
+	for i := 0; i+2 < len(tokens); i++ {
+		depth += braceDelta(tokens[i])
+		if depth == 0 && tokens[i].isChar(":") && tokens[i+1].isChar("/") && tokens[i+2].isChar("/") {
+			protocolEnd = i
+			break
+		}
+	}
+	if protocolEnd <= 0 {
+		return parts.tokens, errors.New("urlpattern: pattern must start with a protocol followed by \"://\"")
+	}
+	parts.set(componentProtocol, tokens[:protocolEnd])
+	rest := tokens[protocolEnd+3:]
+
+	// authority, up to "/", "?" or "#"
+	authorityEnd := len(rest)
+	depth = 0
+	for i, t := range rest {
+		depth += braceDelta(t)
+		if depth != 0 {
+			continue
+		}
+		if t.isChar("@") {
+			return parts.tokens, errors.New("urlpattern: username and password are not supported")
+		}
+		if t.isChar("/") || t.isChar("#") || isSearchPrefix(rest, i) {
+			authorityEnd = i
+			break
+		}
+	}
+	authority := rest[:authorityEnd]
+	rest = rest[authorityEnd:]
+
+	portStart := -1
+	depth, brackets := 0, 0
+	for i, t := range authority {
+		depth += braceDelta(t)
+		switch {
+		case t.isChar("["):
+			brackets++
+		case t.isChar("]"):
+			brackets--
+		case depth == 0 && brackets == 0 && t.isChar(":"):
+			portStart = i
+		}
+	}
+	if portStart >= 0 {
+		parts.set(componentHostname, authority[:portStart])
+		parts.set(componentPort, authority[portStart+1:])
+	} else {
+		parts.set(componentHostname, authority)
+	}
+	if len(parts.tokens[componentHostname]) == 0 {
+		return parts.tokens, errors.New("urlpattern: pattern must include a hostname")
+	}
+
+	// pathname, search and hash
+	current := componentPathname
+	start := 0
+	depth = 0
+	for i := 0; i <= len(rest); i++ {
+		if i < len(rest) {
+			depth += braceDelta(rest[i])
+		}
+		next := current
+		switch {
+		case i == len(rest):
+		case depth != 0:
+			continue
+		case current == componentPathname && isSearchPrefix(rest, i):
+			next = componentSearch
+		case current != componentHash && rest[i].isChar("#"):
+			next = componentHash
+		default:
+			continue
+		}
+
+		if i > start || (current == componentPathname && i > 0) {
+			parts.set(current, rest[start:i])
+		}
+		if i == len(rest) {
+			break
+		}
+		current, start = next, i+1
+		parts.present[current] = true
+	}
+
+	return parts.fill(), nil
+}
+
+// set stores the tokens of a component and marks it as present
+func (p *componentTokens) set(comp component, tokens []token) {
+	p.tokens[comp] = tokens
+	p.present[comp] = true
+}
+
+// fill applies the defaults for absent components. Components after the last
+// present one match anything, skipped ones match only the empty value, except
+// for the pathname which defaults to "/".
+func (p *componentTokens) fill() [numComponents][]token {
+	last := componentPort
+	for comp := componentPathname; comp < numComponents; comp++ {
+		if p.present[comp] {
+			last = comp
+		}
+	}
+
+	wildcard := []token{{kind: tokenAsterisk, value: "*"}}
+	for comp := componentPathname; comp < numComponents; comp++ {
+		switch {
+		case p.present[comp]:
+		case comp > last:
+			p.tokens[comp] = wildcard
+		case comp == componentPathname:
+			p.tokens[comp] = []token{{kind: tokenChar, value: "/"}}
+		}
+	}
+	return p.tokens
+}
+
+// braceDelta returns how the token changes the brace nesting depth
+func braceDelta(t token) int {
+	switch t.kind {
+	case tokenOpen:
+		return 1
+	case tokenClose:
+		return -1
+	}
+	return 0
+}
+
+// literal returns the text of a component that consists only of literal characters
+func literal(tokens []token) (string, bool) {
+	var b strings.Builder
+	for _, t := range tokens {
+		if t.kind != tokenChar && t.kind != tokenEscaped {
+			return "", false
+		}
+		b.WriteString(t.value)
+	}
+	return b.String(), b.Len() > 0
+}
+
+// compiler turns component tokens into regular expressions
+type compiler struct {
+	names []string
+	seen  map[string]bool
+	comp  component
+}
+
+// compile returns the regular expression body for a component
+func (c *compiler) compile(tokens []token, comp component) (string, error) {
+	c.comp = comp
+	return c.sequence(tokens)
+}
+
+// segmentWildcard is the expression matched by a named group without a regexp
+func (c *compiler) segmentWildcard() string {
+	switch c.comp {
+	case componentPathname:
+		return "[^/]+?"
+	case componentHostname:
+		return `[^.]+?`
+	default:
+		return ".+?"
+	}
+}
+
+// prefixChar is the separator that becomes part of a following group with a modifier
+func (c *compiler) prefixChar() string {
+	if c.comp == componentPathname {
+		return "/"
+	}
+	return ""
+}
+
+// literalText returns the text of a literal token, lowercased where the URL value is
+func (c *compiler) literalText(t token) string {
+	if c.comp == componentProtocol || c.comp == componentHostname {
+		return strings.ToLower(t.value)
+	}
+	return t.value
+}
+
+// sequence compiles a run of tokens
+func (c *compiler) sequence(tokens []token) (string, error) {
+	var out, pending strings.Builder
+
+	flush := func() {
+		out.WriteString(regexp.QuoteMeta(pending.String()))
+		pending.Reset()
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.kind {
+		case tokenChar, tokenEscaped:
+			pending.WriteString(c.literalText(t))
+
+		case tokenName, tokenRegexp, tokenAsterisk:
+			name, expr := "", ""
+			switch t.kind {
+			case tokenName:
+				name = t.value
+				expr = c.segmentWildcard()
+				if i+1 < len(tokens) && tokens[i+1].kind == tokenRegexp {
+					i++
+					expr = tokens[i].value
+				}
+			case tokenRegexp:
+				expr = t.value
+			default:
+				expr = ".*"
+			}
+			if name != "" {
+				if c.seen[name] {
+					return "", fmt.Errorf("duplicate group name %q", name)
+				}
+				c.seen[name] = true
+				c.names = append(c.names, name)
+			}
+			if _, err := regexp.Compile(expr); err != nil {
+				return "", fmt.Errorf("invalid regexp %q: %w", expr, err)
+			}
+
+			modifier := ""
+			if i+1 < len(tokens) && isModifier(tokens[i+1]) {
+				i++
+				modifier = tokens[i].value
+			}
+
+			prefix := ""
+			if sep := c.prefixChar(); modifier != "" && sep != "" && strings.HasSuffix(pending.String(), sep) {
+				text := pending.String()
+				pending.Reset()
+				pending.WriteString(strings.TrimSuffix(text, sep))
+				prefix = sep
+			}
+			flush()
+			out.WriteString(groupExpr(name, expr, prefix, modifier))
+
+		case tokenOpen:
+			end := matchingClose(tokens, i)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated '{' at position %d", t.pos)
+			}
+			inner, err := c.sequence(tokens[i+1 : end])
+			if err != nil {
+				return "", err
+			}
+			i = end
+
+			modifier := ""
+			if i+1 < len(tokens) && isModifier(tokens[i+1]) {
+				i++
+				modifier = tokens[i].value
+			}
+			flush()
+			out.WriteString("(?:" + inner + ")" + modifier)
+
+		case tokenClose:
+			return "", fmt.Errorf("unexpected '}' at position %d", t.pos)
+
+		case tokenModifier:
+			return "", fmt.Errorf("modifier %q at position %d must follow a group", t.value, t.pos)
+		}
+	}
+
+	flush()
+	return out.String(), nil
+}
+
+// isModifier reports whether the token can modify a preceding group
+func isModifier(t token) bool {
+	return t.kind == tokenModifier || t.kind == tokenAsterisk
+}
+
+// matchingClose returns the index of the '}' closing the '{' at start, or -1
+func matchingClose(tokens []token, start int) int {
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		depth += braceDelta(tokens[i])
+		if depth == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// groupExpr builds the expression for a group, folding the prefix into optional
+// and repeated groups so "/books/:id?" also matches "/books"
+func groupExpr(name, expr, prefix, modifier string) string {
+	open := "("
+	if name != "" {
+		open = "(?P<" + name + ">"
+	}
+	quoted := regexp.QuoteMeta(prefix)
+
+	switch modifier {
+	case "":
+		return quoted + open + expr + ")"
+	case "?":
+		if prefix == "" {
+			return open + expr + ")?"
+		}
+		return "(?:" + quoted + open + expr + "))?"
+	default: // "+" or "*"
+		var repeated string
+		if prefix == "" {
+			repeated = open + "(?:" + expr + ")+)"
+		} else {
+			repeated = "(?:" + quoted + open + "(?:" + expr + ")(?:" + quoted + "(?:" + expr + "))*))"
+		}
+		if modifier == "*" {
+			return repeated + "?"
+		}
+		return repeated
+	}
+}
@@ -0,0 +1,186 @@
+// Package urlpattern implements matching of URLs against WHATWG URLPattern
+// constructor strings such as "https://*.example.com/books/:id".
+//
+// The pattern is split into protocol, hostname, port, pathname, search and hash
+// components, and each component is matched separately. Supported syntax:
+//
+//   - :name          named group matching one segment ("[^/]+?" in the pathname, "[^.]+?" in the hostname)
+//   - :name(regexp)  named group with a custom regular expression
+//   - (regexp)       unnamed group with a custom regular expression
+//   - *              unnamed group matching anything, including nothing
+//   - ? + *          modifiers after a group; in the pathname a preceding "/" becomes part of the group
+//   - {...}          non-capturing group, usually followed by a modifier
+//   - \x             escaped literal character
+//
+// Components missing at the end of the pattern match anything, components skipped
+// before a later one match only the empty value, and a missing port matches only the
+// default port of the scheme. Username and password are not supported.
+package urlpattern
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// component identifies a part of a URL matched by its own expression
+type component int
+
+const (
+	componentProtocol component = iota
+	componentHostname
+	componentPort
+	componentPathname
+	componentSearch
+	componentHash
+	numComponents
+)
+
+// componentNames are used in error messages
+var componentNames = [numComponents]string{"protocol", "hostname", "port", "pathname", "search", "hash"}
+
+// defaultPorts are omitted from URLs before the port component is matched
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// Pattern is a compiled URL pattern. It is safe for concurrent use.
+type Pattern struct {
+	source     string
+	components [numComponents]*regexp.Regexp
+	names      []string
+	hostname   string // Literal hostname, empty if the hostname contains groups
+}
+
+// Compile parses a URLPattern constructor string
+func Compile(pattern string) (*Pattern, error) {
+	tokens, err := tokenize(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := splitComponents(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pattern{source: pattern}
+	c := &compiler{seen: make(map[string]bool)}
+	for comp := range numComponents {
+		expr, err := c.compile(parts[comp], comp)
+		if err != nil {
+			return nil, fmt.Errorf("urlpattern: %s: %w", componentNames[comp], err)
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("urlpattern: %s: %w", componentNames[comp], err)
+		}
+		p.components[comp] = re
+	}
+	p.names = c.names
+
+	if hostname, ok := literal(parts[componentHostname]); ok {
+		p.hostname = strings.ToLower(hostname)
+	}
+
+	return p, nil
+}
+
+// MustCompile is like Compile but panics if the pattern cannot be parsed
+func MustCompile(pattern string) *Pattern {
+	p, err := Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source pattern
+func (p *Pattern) String() string {
+	return p.source
+}
+
+// GroupNames returns the names of all named groups in pattern order
+func (p *Pattern) GroupNames() []string {
+	return append([]string(nil), p.names...)
+}
+
+// Hostname returns the hostname when the hostname component is a literal
+func (p *Pattern) Hostname() (string, bool) {
+	return p.hostname, p.hostname != ""
+}
+
+// Match reports whether the URL matches every component of the pattern
+func (p *Pattern) Match(rawURL string) bool {
+	values, ok := splitURL(rawURL)
+	if !ok {
+		return false
+	}
+	for comp := range numComponents {
+		if !p.components[comp].MatchString(values[comp]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Exec matches the URL and returns the values of the named groups
+func (p *Pattern) Exec(rawURL string) (map[string]string, bool) {
+	values, ok := splitURL(rawURL)
+	if !ok {
+		return nil, false
+	}
+
+	groups := make(map[string]string, len(p.names))
+	for comp := range numComponents {
+		re := p.components[comp]
+		match := re.FindStringSubmatch(values[comp])
+		if match == nil {
+			return nil, false
+		}
+		for i, name := range re.SubexpNames() {
+			if name != "" {
+				groups[name] = match[i]
+			}
+		}
+	}
+	return groups, true
+}
+
+// splitURL extracts the component values of an absolute URL in the form the
+// components are matched against
+func splitURL(rawURL string) ([numComponents]string, bool) {
+	var values [numComponents]string
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Opaque != "" {
+		return values, false
+	}
+
+	protocol := strings.ToLower(u.Scheme)
+	hostname := strings.ToLower(u.Hostname())
+	if strings.Contains(hostname, ":") {
+		hostname = "[" + hostname + "]"
+	}
+	port := u.Port()
+	if defaultPorts[protocol] == port {
+		port = ""
+	}
+	pathname := u.EscapedPath()
+	if pathname == "" && defaultPorts[protocol] != "" {
+		pathname = "/"
+	}
+
+	values[componentProtocol] = protocol
+	values[componentHostname] = hostname
+	values[componentPort] = port
+	values[componentPathname] = pathname
+	values[componentSearch] = u.RawQuery
+	values[componentHash] = u.EscapedFragment()
+	return values, true
+}
@@ -0,0 +1,113 @@
+package urlpattern
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPattern_Match(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		url     string
+		matches bool
+	}{
+		{"literal", "https://example.com/about", "https://example.com/about", true},
+		{"literal other path", "https://example.com/about", "https://example.com/contact", false},
+		{"case-insensitive host", "https://Example.COM/a", "HTTPS://example.com/a", true},
+		{"case-sensitive path", "https://example.com/a", "https://example.com/A", false},
+		{"named segment", "https://example.com/books/:id", "https://example.com/books/42", true},
+		{"named segment stops at slash", "https://example.com/books/:id", "https://example.com/books/42/reviews", false},
+		{"named segment requires value", "https://example.com/books/:id", "https://example.com/books/", false},
+		{"custom regexp", `https://example.com/books/:id(\d+)`, "https://example.com/books/abc", false},
+		{"optional group present", "https://example.com/books/:id?", "https://example.com/books/1", true},
+		{"optional group absent", "https://example.com/books/:id?", "https://example.com/books", true},
+		{"one or more", "https://example.com/docs/:path+", "https://example.com/docs/a/b/c", true},
+		{"one or more requires one", "https://example.com/docs/:path+", "https://example.com/docs", false},
+		{"zero or more", "https://example.com/docs/:path*", "https://example.com/docs", true},
+		{"trailing wildcard", "https://example.com/blog/*", "https://example.com/blog/2024/post", true},
+		{"subdomain wildcard", "https://*.example.com/*", "https://shop.example.com/cart", true},
+		{"subdomain wildcard requires dot", "https://*.example.com/*", "https://example.com/cart", false},
+		{"named hostname label", "https://:tenant.example.com/", "https://acme.example.com/", true},
+		{"named hostname label single", "https://:tenant.example.com/", "https://a.b.example.com/", false},
+		{"protocol group", "http{s}?://example.com/", "http://example.com/", true},
+		{"missing search matches any", "https://example.com/a", "https://example.com/a?x=1#top", true},
+		{"explicit search", "https://example.com/a?page=:n", "https://example.com/a?page=2", true},
+		{"explicit search mismatch", "https://example.com/a?page=:n", "https://example.com/a?size=2", false},
+		{"skipped search must be empty", "https://example.com/a#top", "https://example.com/a?x=1#top", false},
+		{"hash", "https://example.com/a#:section", "https://example.com/a#intro", true},
+		{"missing path matches root", "https://example.com", "https://example.com/", true},
+		{"missing path matches any path", "https://example.com", "https://example.com/a", true},
+		{"skipped path is root", "https://example.com?x=1", "https://example.com/?x=1", true},
+		{"skipped path is root mismatch", "https://example.com?x=1", "https://example.com/a?x=1", false},
+		{"default port", "https://example.com/", "https://example.com:443/", true},
+		{"missing port rejects other ports", "https://example.com/", "https://example.com:8443/", false},
+		{"explicit port", "https://example.com:8443/", "https://example.com:8443/", true},
+		{"port wildcard", "https://example.com:*/", "https://example.com:8443/", true},
+		{"escaped literal", `https://example.com/a\+b`, "https://example.com/a+b", true},
+		{"non-capturing group", "https://example.com/{blog/}?:slug", "https://example.com/hello", true},
+		{"non-capturing group present", "https://example.com/{blog/}?:slug", "https://example.com/blog/hello", true},
+		{"ipv6", "http://[::1]:8080/*", "http://[::1]:8080/x", true},
+		{"relative url", "https://example.com/*", "/a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Compile(tt.pattern)
+			require.NoError(t, err)
+			assert.Equal(t, tt.matches, p.Match(tt.url))
+		})
+	}
+}
+
+func TestPattern_Exec(t *testing.T) {
+	p := MustCompile(`https://:tenant.example.com/books/:id(\d+)/:rest*`)
+	assert.Equal(t, []string{"tenant", "id", "rest"}, p.GroupNames())
+
+	groups, ok := p.Exec("https://acme.example.com/books/42/reviews/latest")
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"tenant": "acme", "id": "42", "rest": "reviews/latest"}, groups)
+
+	_, ok = p.Exec("https://acme.example.com/books/abc")
+	assert.False(t, ok)
+}
+
+func TestPattern_Hostname(t *testing.T) {
+	host, ok := MustCompile("https://Docs.Example.com/*").Hostname()
+	assert.True(t, ok)
+	assert.Equal(t, "docs.example.com", host)
+
+	_, ok = MustCompile("https://*.example.com/*").Hostname()
+	assert.False(t, ok)
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+	}{
+		{"no protocol", "example.com/a"},
+		{"relative", "/books/:id"},
+		{"no hostname", "https:///a"},
+		{"credentials", "https://user@example.com/"},
+		{"duplicate group", "https://example.com/:id/:id"},
+		{"duplicate across components", "https://:id.example.com/:id"},
+		{"unterminated regexp", "https://example.com/(a"},
+		{"empty regexp", "https://example.com/()"},
+		{"lookahead regexp", "https://example.com/(?=a)"},
+		{"invalid regexp", "https://example.com/([a)"},
+		{"unterminated brace", "https://example.com/{a"},
+		{"stray brace", "https://example.com/a}"},
+		{"dangling modifier", "https://example.com/a/+"},
+		{"trailing backslash", `https://example.com/a\`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.pattern)
+			assert.Error(t, err)
+		})
+	}
+}